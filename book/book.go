@@ -0,0 +1,153 @@
+// Package book defines a format-neutral document model: metadata, a chapter
+// tree of blocks with styled inline spans, and binary resources.
+//
+// Input formats map their documents into a *Book and output formats render a
+// *Book, so a new input or output format only has to deal with this model and
+// never with another format's structures.
+package book
+
+import "strings"
+
+// Book is a complete document
+type Book struct {
+	Metadata  Metadata
+	Cover     string      // ID of the cover image resource; empty if the book has none
+	Front     []Block     // Images and epigraphs of the whole book, shown before the first chapter
+	Chapters  []*Chapter  // Main text in reading order
+	Notes     []*Chapter  // Notes, comments and other back matter linked from the text
+	Resources []*Resource // Images referenced by ID from blocks and spans
+}
+
+// Metadata describes a book
+type Metadata struct {
+	Title       string
+	Authors     []string // Display names, e.g. "Leo Tolstoy"
+	Translators []string // Display names of the translators
+	Language    string   // Language code such as "en" or "ru"
+	Genres      []string // FB2 genre codes such as sf_fantasy
+	Date        string
+	Identifier  string // Unique ID of the source document, if it has one
+	Publisher   string
+	ISBN        string
+	Series      []Series
+	Annotation  []Block // Description shown before the text
+
+	// The original of a translated book, if the source describes it
+	OriginalTitle    string
+	OriginalAuthors  []string
+	OriginalLanguage string
+}
+
+// Series is a series the book belongs to and its position in it
+type Series struct {
+	Name   string
+	Number string
+}
+
+// Chapter is a titled part of the text; chapters nest to form the table of contents
+type Chapter struct {
+	ID       string // Anchor that links point to; empty if nothing links here
+	Title    string // Plain text title; empty for untitled sections
+	Blocks   []Block
+	Children []*Chapter
+}
+
+// BlockKind identifies what a block holds
+type BlockKind int
+
+// Block kinds. Quote, Poem, Stanza and Epigraph blocks hold Children; the
+// others hold Spans, except EmptyLine (nothing) and Image (ImageID).
+const (
+	Paragraph BlockKind = iota
+	Subtitle
+	EmptyLine
+	Image
+	Quote
+	Poem
+	Stanza
+	Verse
+	Epigraph
+	TextAuthor
+)
+
+// Block is a paragraph-level element
+type Block struct {
+	Kind     BlockKind
+	ID       string // Anchor that links point to, if any
+	Spans    []Span
+	Children []Block
+	ImageID  string
+}
+
+// Text returns the plain text of the block and its children
+func (b *Block) Text() string {
+	if len(b.Children) == 0 {
+		return SpansText(b.Spans)
+	}
+	parts := make([]string, 0, len(b.Children))
+	for i := range b.Children {
+		if text := b.Children[i].Text(); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Style is a set of inline formatting flags
+type Style uint8
+
+// Inline styles; a span may combine several
+const (
+	Strong Style = 1 << iota
+	Emphasis
+	Strikethrough
+	Code
+	Superscript
+	Subscript
+)
+
+// Span is a run of text sharing one style, an inline link or an inline image
+type Span struct {
+	Text    string
+	Style   Style
+	Href    string // Link target: "#id" inside the book or an external URL
+	ImageID string // Inline image resource; Text is then its alternative text
+}
+
+// SpansText returns the plain text of a run of spans
+func SpansText(spans []Span) string {
+	var text strings.Builder
+	for _, span := range spans {
+		text.WriteString(span.Text)
+	}
+	return text.String()
+}
+
+// Resource is binary content of the book, such as an image
+type Resource struct {
+	ID          string
+	ContentType string
+	Data        []byte
+}
+
+// Resource returns the resource with the given ID, or nil
+func (b *Book) Resource(id string) *Resource {
+	for _, resource := range b.Resources {
+		if resource.ID == id {
+			return resource
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for every chapter of the main text depth-first in reading order
+func (b *Book) Walk(fn func(chapter *Chapter, depth int)) {
+	var walk func(chapters []*Chapter, depth int)
+	walk = func(chapters []*Chapter, depth int) {
+		for _, chapter := range chapters {
+			fn(chapter, depth)
+			walk(chapter.Children, depth+1)
+		}
+	}
+	walk(b.Chapters, 0)
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	fileMutex sync.Mutex
+	fileInUse string                               // Path set by UseFile; CONFIG_FILE applies otherwise
+	fileCache = make(map[string]map[string]string) // Parsed files by path
+)
+
+// UseFile reads the YAML or TOML configuration file at path and makes Load
+// apply it, taking precedence over CONFIG_FILE. It fails when the file cannot
+// be read or parsed.
+func UseFile(path string) error {
+	values, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+	fileInUse = path
+	fileCache[path] = values
+	return nil
+}
+
+// ReadFile parses a configuration file into environment variable values. The
+// format follows the extension: .yaml, .yml or .toml. Each key is the name of
+// an environment variable in lower case, and nested tables join their keys
+// with underscores, so the table smtp with the key host sets SMTP_HOST. Lists
+// become comma-separated values.
+func ReadFile(path string) (map[string]string, error) {
+	//nolint:gosec // Path is chosen by the operator
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var document map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flattenConfig("", document, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flattenConfig stores the scalar values of a parsed document under their
+// environment variable names
+func flattenConfig(prefix string, value interface{}, values map[string]string) error {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+			if prefix != "" {
+				name = prefix + "_" + name
+			}
+			if err := flattenConfig(name, value[key], values); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return fmt.Errorf("%s: lists may only hold plain values", strings.ToLower(prefix))
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(value)
+	}
+	return nil
+}
+
+// startupSettings are read once when the server starts, or name resources
+// that existing jobs and connections depend on, so Reload does not change them
+var startupSettings = []string{
+	"PORT", "GRPC_PORT", "ENVIRONMENT", "TEMP_DIR", "HISTORY_DB", "DEBUG_ENDPOINTS",
+	"AMQP_URL", "AMQP_QUEUE", "AMQP_EVENTS_QUEUE", "AMQP_PREFETCH", "AMQP_INPUT_DIR",
+	"TELEGRAM_BOT_TOKEN", "TELEGRAM_API_URL", "OTEL_EXPORTER_OTLP_ENDPOINT", "SENTRY_DSN",
+}
+
+// Reload reads the configuration file in use again, so that later calls to
+// Load see its changes. Changes to startupSettings are not applied; their
+// names are returned as ignored. A file that cannot be read or fails Validate
+// leaves the previous values in effect. It returns the path of the file, or
+// an empty path when no file is in use.
+func Reload() (path string, ignored []string, err error) {
+	fileMutex.Lock()
+	path = fileInUse
+	fileMutex.Unlock()
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		return "", nil, nil
+	}
+
+	values, err := ReadFile(path)
+	if err != nil {
+		return path, nil, err
+	}
+	_, previous := fileValues()
+	for _, name := range startupSettings {
+		if values[name] == previous[name] {
+			continue
+		}
+		if os.Getenv(name) == "" {
+			ignored = append(ignored, name)
+		}
+		if value, ok := previous[name]; ok {
+			values[name] = value
+		} else {
+			delete(values, name)
+		}
+	}
+	if err := load(path, values).validate(values); err != nil {
+		return path, nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+	fileCache[path] = values
+	return path, ignored, nil
+}
+
+// fileValues returns the path and values of the configuration file in use, if
+// any. A file named by CONFIG_FILE but not passed to UseFile is read on first
+// use; an unreadable one is treated as empty.
+func fileValues() (string, map[string]string) {
+	fileMutex.Lock()
+	defer fileMutex.Unlock()
+
+	path := fileInUse
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		return "", nil
+	}
+	values, ok := fileCache[path]
+	if !ok {
+		values, _ = ReadFile(path)
+		fileCache[path] = values
+	}
+	return path, values
+}
+
+// newLookup returns a function reading a setting from the environment, falling
+// back to the configuration file
+func newLookup(values map[string]string) func(name string) string {
+	return func(name string) string {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+		return values[name]
+	}
+}
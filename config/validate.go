@@ -0,0 +1,199 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings parsed as numbers, booleans or durations. Load silently falls back to the
+// default for invalid values; Validate reports them.
+var (
+	positiveIntSettings = []string{
+		"MAX_FILE_SIZE", "CLEANUP_TRIGGER_COUNT", "SMTP_PORT", "MAX_XML_DEPTH",
+		"MAX_BINARY_SIZE", "MAX_DECOMPRESSED_SIZE", "MAX_COMPRESSION_RATIO",
+		"MAX_SECTION_DEPTH", "MAX_SECTIONS", "MAX_PARAGRAPHS", "AMQP_PREFETCH",
+	}
+	nonNegativeIntSettings = []string{
+		"MIN_FREE_DISK_SPACE", "QUOTA_CONVERSIONS_PER_DAY", "QUOTA_BYTES_PER_DAY", "QUOTA_CONCURRENT_JOBS",
+		"CONVERSION_WORKERS", "MAX_CONVERSION_MEMORY",
+	}
+	boolSettings     = []string{"DEDUPLICATE_UPLOADS", "DEBUG_ENDPOINTS"}
+	durationSettings = []string{
+		"CONVERSION_TIMEOUT", "HISTORY_RETENTION", "JOB_RETENTION", "MIN_JOB_RETENTION", "MAX_JOB_RETENTION",
+		"HOOK_TIMEOUT",
+	}
+)
+
+// telegramTokenPattern matches the bot tokens issued by @BotFather
+var telegramTokenPattern = regexp.MustCompile(`^\d+:[\w-]+$`)
+
+// Validate checks the configuration for mistakes that would otherwise only
+// show up on the first request: unparsable values, an unusable temp directory
+// or history database location, inconsistent limits and incomplete delivery
+// or bot settings. It returns every problem found, each naming the setting to fix.
+func (c *Config) Validate() error {
+	var problems []error
+	if c.ConfigFile != "" {
+		if _, err := ReadFile(c.ConfigFile); err != nil {
+			problems = append(problems, fmt.Errorf("CONFIG_FILE: %v", err))
+		}
+	}
+	_, values := fileValues()
+	if err := c.validate(values); err != nil {
+		problems = append(problems, err)
+	}
+	return errors.Join(problems...)
+}
+
+// validate checks the settings of c, reading the raw values of the
+// configuration file from values
+func (c *Config) validate(values map[string]string) error {
+	var problems []error
+	report := func(setting, format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf("%s: %s", setting, fmt.Sprintf(format, args...)))
+	}
+
+	getenv := newLookup(values)
+	for _, name := range positiveIntSettings {
+		if value := getenv(name); value != "" {
+			if parsed, err := strconv.ParseInt(value, 10, 64); err != nil || parsed <= 0 {
+				report(name, "%q is not a positive whole number", value)
+			}
+		}
+	}
+	for _, name := range nonNegativeIntSettings {
+		if value := getenv(name); value != "" {
+			if parsed, err := strconv.ParseInt(value, 10, 64); err != nil || parsed < 0 {
+				report(name, "%q is not a whole number of zero or more", value)
+			}
+		}
+	}
+	for _, name := range boolSettings {
+		if value := getenv(name); value != "" {
+			if _, err := strconv.ParseBool(value); err != nil {
+				report(name, "%q is not true or false", value)
+			}
+		}
+	}
+	for _, name := range durationSettings {
+		if value := getenv(name); value != "" {
+			if parsed, err := time.ParseDuration(value); err != nil || parsed <= 0 {
+				report(name, "%q is not a positive duration such as 90s, 30m or 2h", value)
+			}
+		}
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		report("PORT", "%q is not a port number between 1 and 65535", c.Port)
+	}
+	if c.GRPCPort != "" {
+		if port, err := strconv.Atoi(c.GRPCPort); err != nil || port < 1 || port > 65535 {
+			report("GRPC_PORT", "%q is not a port number between 1 and 65535", c.GRPCPort)
+		} else if c.GRPCPort == c.Port {
+			report("GRPC_PORT", "%s is already used by the HTTP server (PORT)", c.GRPCPort)
+		}
+	}
+
+	if err := checkWritableDir(c.TempDir); err != nil {
+		report("TEMP_DIR", "%v", err)
+	}
+	if c.LibraryDir != "" {
+		if err := checkWritableDir(c.LibraryDir); err != nil {
+			report("LIBRARY_DIR", "%v", err)
+		}
+	}
+	if c.HistoryDB != "" {
+		if err := checkWritableDir(filepath.Dir(c.HistoryDB)); err != nil {
+			report("HISTORY_DB", "cannot create the database: %v", err)
+		}
+	}
+	if c.FontsDir != "" {
+		if info, err := os.Stat(c.FontsDir); err != nil || !info.IsDir() {
+			report("FONTS_DIR", "%s is not a readable directory", c.FontsDir)
+		}
+	}
+	if c.AMQPInputDir != "" {
+		if info, err := os.Stat(c.AMQPInputDir); err != nil || !info.IsDir() {
+			report("AMQP_INPUT_DIR", "%s is not a readable directory", c.AMQPInputDir)
+		}
+	}
+	if c.TemplatesDir != "" {
+		if info, err := os.Stat(c.TemplatesDir); err != nil || !info.IsDir() {
+			report("TEMPLATES_DIR", "%s is not a readable directory", c.TemplatesDir)
+		}
+	}
+
+	if c.MinJobRetention > c.MaxJobRetention {
+		report("MIN_JOB_RETENTION", "%s exceeds MAX_JOB_RETENTION of %s", c.MinJobRetention, c.MaxJobRetention)
+	} else if c.JobRetention < c.MinJobRetention || c.JobRetention > c.MaxJobRetention {
+		report("JOB_RETENTION", "%s is outside MIN_JOB_RETENTION and MAX_JOB_RETENTION (%s to %s)",
+			c.JobRetention, c.MinJobRetention, c.MaxJobRetention)
+	}
+	if c.MaxDecompressedSize < c.MaxFileSize {
+		report("MAX_DECOMPRESSED_SIZE", "%d is smaller than MAX_FILE_SIZE of %d, so uploaded archives could not be extracted",
+			c.MaxDecompressedSize, c.MaxFileSize)
+	}
+
+	if c.SMTPHost != "" && c.SMTPFrom == "" && c.SMTPUsername == "" {
+		report("SMTP_FROM", "a sender address is required when SMTP_HOST is set")
+	}
+	for _, hook := range c.PostConversionHooks {
+		if _, err := exec.LookPath(strings.Fields(hook)[0]); err != nil {
+			report("POST_CONVERSION_HOOKS", "%v", err)
+		}
+	}
+	if c.AMQPURL == "" && c.AMQPInputDir != "" {
+		report("AMQP_INPUT_DIR", "has no effect without AMQP_URL")
+	}
+	if c.SMTPHost == "" && len(c.DeliveryDomains) > 0 {
+		report("DELIVERY_ALLOWED_DOMAINS", "has no effect without SMTP_HOST")
+	}
+	if c.DebugEndpoints && c.AdminAPIKey == "" {
+		report("DEBUG_ENDPOINTS", "requires ADMIN_API_KEY, which protects the debug endpoints")
+	}
+	if len(c.APIKeys) == 0 && (c.QuotaConversionsPerDay > 0 || c.QuotaBytesPerDay > 0 || c.QuotaConcurrentJobs > 0) {
+		report("API_KEYS", "quotas are set but have no effect without API keys to count them against")
+	}
+	if c.TelegramBotToken != "" && !telegramTokenPattern.MatchString(c.TelegramBotToken) {
+		report("TELEGRAM_BOT_TOKEN", "does not look like a token from @BotFather (123456:ABC-DEF...)")
+	}
+	if c.OTLPEndpoint != "" {
+		if endpoint, err := url.Parse(c.OTLPEndpoint); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") ||
+			endpoint.Host == "" {
+			report("OTEL_EXPORTER_OTLP_ENDPOINT", "%q is not an http or https URL such as http://collector:4318", c.OTLPEndpoint)
+		}
+	}
+	if c.SentryDSN != "" {
+		if dsn, err := url.Parse(c.SentryDSN); err != nil || dsn.User == nil || dsn.Host == "" {
+			report("SENTRY_DSN", "does not look like a DSN from the Sentry project settings (https://key@host/project)")
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// checkWritableDir creates dir if needed and checks that files can be written to it
+func checkWritableDir(dir string) error {
+	//nolint:gosec // 0755 needed for Docker volume mounts
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", dir, err)
+	}
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	name := file.Name()
+	_ = file.Close()
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("cannot remove files from %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: conversion.proto
+
+package conversionpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ConversionService_Convert_FullMethodName   = "/fb2epub.v1.ConversionService/Convert"
+	ConversionService_GetStatus_FullMethodName = "/fb2epub.v1.ConversionService/GetStatus"
+	ConversionService_Download_FullMethodName  = "/fb2epub.v1.ConversionService/Download"
+)
+
+// ConversionServiceClient is the client API for ConversionService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ConversionServiceClient interface {
+	// Convert starts the conversion of a book and returns its job
+	Convert(ctx context.Context, in *ConvertRequest, opts ...grpc.CallOption) (*ConvertResponse, error)
+	// GetStatus returns the status of a job
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*JobStatus, error)
+	// Download streams the result of a completed job in chunks
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (ConversionService_DownloadClient, error)
+}
+
+type conversionServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewConversionServiceClient(cc grpc.ClientConnInterface) ConversionServiceClient {
+	return &conversionServiceClient{cc}
+}
+
+func (c *conversionServiceClient) Convert(ctx context.Context, in *ConvertRequest, opts ...grpc.CallOption) (*ConvertResponse, error) {
+	out := new(ConvertResponse)
+	err := c.cc.Invoke(ctx, ConversionService_Convert_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *conversionServiceClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*JobStatus, error) {
+	out := new(JobStatus)
+	err := c.cc.Invoke(ctx, ConversionService_GetStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *conversionServiceClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (ConversionService_DownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ConversionService_ServiceDesc.Streams[0], ConversionService_Download_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &conversionServiceDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ConversionService_DownloadClient interface {
+	Recv() (*DownloadChunk, error)
+	grpc.ClientStream
+}
+
+type conversionServiceDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *conversionServiceDownloadClient) Recv() (*DownloadChunk, error) {
+	m := new(DownloadChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ConversionServiceServer is the server API for ConversionService service.
+// All implementations must embed UnimplementedConversionServiceServer
+// for forward compatibility
+type ConversionServiceServer interface {
+	// Convert starts the conversion of a book and returns its job
+	Convert(context.Context, *ConvertRequest) (*ConvertResponse, error)
+	// GetStatus returns the status of a job
+	GetStatus(context.Context, *GetStatusRequest) (*JobStatus, error)
+	// Download streams the result of a completed job in chunks
+	Download(*DownloadRequest, ConversionService_DownloadServer) error
+	mustEmbedUnimplementedConversionServiceServer()
+}
+
+// UnimplementedConversionServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedConversionServiceServer struct {
+}
+
+func (UnimplementedConversionServiceServer) Convert(context.Context, *ConvertRequest) (*ConvertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Convert not implemented")
+}
+func (UnimplementedConversionServiceServer) GetStatus(context.Context, *GetStatusRequest) (*JobStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedConversionServiceServer) Download(*DownloadRequest, ConversionService_DownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
+func (UnimplementedConversionServiceServer) mustEmbedUnimplementedConversionServiceServer() {}
+
+// UnsafeConversionServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ConversionServiceServer will
+// result in compilation errors.
+type UnsafeConversionServiceServer interface {
+	mustEmbedUnimplementedConversionServiceServer()
+}
+
+func RegisterConversionServiceServer(s grpc.ServiceRegistrar, srv ConversionServiceServer) {
+	s.RegisterService(&ConversionService_ServiceDesc, srv)
+}
+
+func _ConversionService_Convert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConvertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConversionServiceServer).Convert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConversionService_Convert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConversionServiceServer).Convert(ctx, req.(*ConvertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConversionService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConversionServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ConversionService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConversionServiceServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ConversionService_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConversionServiceServer).Download(m, &conversionServiceDownloadServer{stream})
+}
+
+type ConversionService_DownloadServer interface {
+	Send(*DownloadChunk) error
+	grpc.ServerStream
+}
+
+type conversionServiceDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *conversionServiceDownloadServer) Send(m *DownloadChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ConversionService_ServiceDesc is the grpc.ServiceDesc for ConversionService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ConversionService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fb2epub.v1.ConversionService",
+	HandlerType: (*ConversionServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Convert",
+			Handler:    _ConversionService_Convert_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _ConversionService_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Download",
+			Handler:       _ConversionService_Download_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "conversion.proto",
+}
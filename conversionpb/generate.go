@@ -0,0 +1,5 @@
+// Package conversionpb holds the gRPC definitions of the conversion service,
+// generated from conversion.proto.
+package conversionpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative conversion.proto
@@ -0,0 +1,120 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// xhtmlRoot is the opening of the html element of every generated XHTML document
+const xhtmlRoot = `<html xmlns="http://www.w3.org/1999/xhtml"`
+
+// bookLanguage returns the language of the book, English when the FB2 names none
+func bookLanguage(fb2 *models.FictionBook) string {
+	if lang := strings.TrimSpace(fb2.Description.TitleInfo.Lang); lang != "" {
+		return lang
+	}
+	return "en"
+}
+
+// setDocumentLanguage declares the language of an XHTML document on its html
+// element, so reading systems and screen readers pick the right voice,
+// dictionary and hyphenation
+func setDocumentLanguage(document, lang string) string {
+	escaped := html.EscapeString(lang)
+	return strings.Replace(document, xhtmlRoot,
+		fmt.Sprintf(`%s lang="%s" xml:lang="%s"`, xhtmlRoot, escaped, escaped), 1)
+}
+
+// langAttrs returns the lang and xml:lang attributes of an element whose text
+// is in lang, such as a quotation in a foreign language, or an empty string
+// when the FB2 gives it no language of its own
+func langAttrs(lang string) string {
+	lang = strings.TrimSpace(lang)
+	if lang == "" {
+		return ""
+	}
+	escaped := html.EscapeString(lang)
+	return fmt.Sprintf(` lang="%s" xml:lang="%s"`, escaped, escaped)
+}
+
+// buildAccessibilityMeta returns the schema.org accessibility metadata of the
+// package document, describing how the book can be read and navigated
+func buildAccessibilityMeta(fb2 *models.FictionBook, imageMap map[string]*ImageInfo, opts *Options) string {
+	hasImages := len(imageMap) > 0
+
+	accessModes := []string{"textual"}
+	if hasImages {
+		accessModes = append(accessModes, "visual")
+	}
+	// Illustrations rarely have text alternatives, so text alone only suffices without them
+	sufficient := "textual"
+	if hasImages {
+		sufficient = "textual,visual"
+	}
+
+	features := []string{"structuralNavigation", "tableOfContents", "readingOrder"}
+	if opts.PageLength > 0 {
+		features = append(features, "pageBreakMarkers", "pageNavigation")
+	}
+	if hasImages && imagesHaveAlt(fb2) {
+		features = append(features, "alternativeText")
+	}
+
+	summary := "Headings, a table of contents and the reading order follow the structure of the book."
+	if hasImages {
+		summary += " Illustrations are described only where the source provides a text alternative."
+	}
+
+	var meta strings.Builder
+	for _, mode := range accessModes {
+		fmt.Fprintf(&meta, "\n    <meta property=\"schema:accessMode\">%s</meta>", mode)
+	}
+	fmt.Fprintf(&meta, "\n    <meta property=\"schema:accessModeSufficient\">%s</meta>", sufficient)
+	for _, feature := range features {
+		fmt.Fprintf(&meta, "\n    <meta property=\"schema:accessibilityFeature\">%s</meta>", feature)
+	}
+	meta.WriteString("\n    <meta property=\"schema:accessibilityHazard\">none</meta>")
+	fmt.Fprintf(&meta, "\n    <meta property=\"schema:accessibilitySummary\">%s</meta>", html.EscapeString(summary))
+	return meta.String()
+}
+
+// imagesHaveAlt reports whether every inline image of the book has a text alternative
+func imagesHaveAlt(fb2 *models.FictionBook) bool {
+	found := false
+	for i := range fb2.Body {
+		for _, image := range fb2.Body[i].Image {
+			if strings.TrimSpace(image.Alt) == "" {
+				return false
+			}
+			found = true
+		}
+		for j := range fb2.Body[i].Section {
+			if !sectionImagesHaveAlt(&fb2.Body[i].Section[j], &found) {
+				return false
+			}
+		}
+	}
+	return found
+}
+
+func sectionImagesHaveAlt(section *models.Section, found *bool) bool {
+	images := append([]models.Image(nil), section.Image...)
+	for i := range section.Paragraph {
+		images = append(images, section.Paragraph[i].Image...)
+	}
+	for _, image := range images {
+		if strings.TrimSpace(image.Alt) == "" {
+			return false
+		}
+		*found = true
+	}
+	for i := range section.Section {
+		if !sectionImagesHaveAlt(&section.Section[i], found) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package converter
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// annotationFile is the front-matter page showing the book annotation
+const annotationFile = "annotation.xhtml"
+
+// hasAnnotation reports whether the book gets an annotation page
+func hasAnnotation(fb2 *models.FictionBook) bool {
+	return fb2.Description.TitleInfo.Annotation.HasContent()
+}
+
+// annotationText returns the plain text of the annotation paragraphs, used as the OPF description
+func annotationText(annotation *models.Annotation) string {
+	if annotation == nil {
+		return ""
+	}
+	var parts []string
+	for i := range annotation.Paragraph {
+		if text := strings.Join(strings.Fields(extractParagraphText(&annotation.Paragraph[i])), " "); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// addAnnotationPage writes the "About This Book" page when the book has an annotation
+func addAnnotationPage(
+	writer *zip.Writer,
+	fb2 *models.FictionBook,
+	imageMap map[string]*ImageInfo,
+	targets map[string]string,
+	processor *documentProcessor,
+) error {
+	if !hasAnnotation(fb2) {
+		return nil
+	}
+
+	w, err := writer.Create("OEBPS/" + annotationFile)
+	if err != nil {
+		return err
+	}
+
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
+	annotation := fb2.Description.TitleInfo.Annotation
+
+	var bodyContent strings.Builder
+
+	if annotation.ID != "" {
+		fmt.Fprintf(&bodyContent, "<a id=\"%s\"></a>\n", html.EscapeString(annotation.ID))
+	}
+	for i := range annotation.Subtitle {
+		if text := processParagraph(&annotation.Subtitle[i], imageMap); text != "" {
+			fmt.Fprintf(&bodyContent, "<p class=\"subtitle\">%s</p>\n", text)
+		}
+	}
+	for i := range annotation.Paragraph {
+		p := &annotation.Paragraph[i]
+		if text := processParagraph(p, imageMap); text != "" {
+			fmt.Fprintf(&bodyContent, "<p%s>%s</p>\n", langAttrs(p.Lang), text)
+		}
+	}
+	for range annotation.EmptyLine {
+		bodyContent.WriteString(`<div class="empty-line"></div>` + "\n")
+	}
+	for i := range annotation.Poem {
+		processPoem(&bodyContent, &annotation.Poem[i], 2, imageMap)
+	}
+	for i := range annotation.Cite {
+		processCite(&bodyContent, &annotation.Cite[i], 2, imageMap)
+	}
+
+	content, err := renderTemplate(processor.templates, annotationTemplate, pageData{
+		Title: l.AboutBook,
+		Body:  markup(bodyContent.String()),
+	})
+	if err != nil {
+		return err
+	}
+	content = rewriteInternalLinks(content, targets, annotationFile)
+	content = processor.process(content, annotationFile)
+	_, err = w.Write([]byte(content))
+	return err
+}
@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lex/fb2epub/models"
+)
+
+const defaultNotesTitle = "Notes"
+
+// backMatterBody describes an extra FB2 body (notes, comments) rendered as a separate XHTML file
+type backMatterBody struct {
+	ID     string
+	File   string
+	Title  string
+	Body   *models.Body
+	Notes  bool // The body holds notes or comments
+	Linear bool // The body is read in sequence after the main text
+}
+
+// EpubType returns the structural semantics of the body: endnotes for notes
+// bodies, backmatter for anything else read after the main text
+func (bm *backMatterBody) EpubType() string {
+	if bm.Notes {
+		return "endnotes"
+	}
+	return "backmatter"
+}
+
+// collectBackMatter returns the extra bodies of the book in document order.
+// As endnotes, notes bodies are read in sequence and come after the others.
+func collectBackMatter(fb2 *models.FictionBook, notes NotesMode) []*backMatterBody {
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
+	extra := fb2.ExtraBodies()
+	result := make([]*backMatterBody, 0, len(extra))
+	for i := range extra {
+		body := &extra[i]
+		id := fmt.Sprintf("backmatter-%d", i+1)
+		isNotes := isNotesBody(body)
+		result = append(result, &backMatterBody{
+			ID:     id,
+			File:   id + ".xhtml",
+			Title:  backMatterTitle(body, l),
+			Body:   body,
+			Notes:  isNotes,
+			Linear: !isNotes || notes == NotesEndnotes,
+		})
+	}
+	if notes == NotesEndnotes {
+		sort.SliceStable(result, func(i, j int) bool { return !result[i].Notes && result[j].Notes })
+	}
+	return result
+}
+
+// isNotesBody reports whether a body holds footnotes or comments, which are
+// reached through links rather than read in sequence
+func isNotesBody(body *models.Body) bool {
+	switch strings.ToLower(body.Name) {
+	case "notes", "comments", "footnotes":
+		return true
+	default:
+		return false
+	}
+}
+
+// backMatterTitle derives a display title for an extra body
+func backMatterTitle(body *models.Body, l *labels) string {
+	var titleParts []string
+	for i := range body.Title.Paragraph {
+		p := body.Title.Paragraph[i]
+		if text := strings.TrimSpace(extractParagraphText(&p)); text != "" {
+			titleParts = append(titleParts, text)
+		}
+	}
+	if len(titleParts) > 0 {
+		return strings.Join(titleParts, " ")
+	}
+	if body.Name != "" && !strings.EqualFold(body.Name, "notes") {
+		first, size := utf8.DecodeRuneInString(body.Name)
+		return string(unicode.ToUpper(first)) + body.Name[size:]
+	}
+	return l.Notes
+}
+
+// extractParagraphText returns the plain text of a paragraph without markup
+func extractParagraphText(p *models.Paragraph) string {
+	return inlineText(p.OrderedContent(), p.Strong, p.Emphasis, p.Link)
+}
+
+// collectLinkTargets maps section IDs of every back-matter body to the file containing them,
+// so that note references in the main content can point across files
+func collectLinkTargets(backMatter []*backMatterBody) map[string]string {
+	targets := make(map[string]string)
+	for _, bm := range backMatter {
+		for i := range bm.Body.Section {
+			collectSectionIDs(&bm.Body.Section[i], bm.File, targets)
+		}
+	}
+	return targets
+}
+
+func collectSectionIDs(section *models.Section, file string, targets map[string]string) {
+	if section.ID != "" {
+		targets[section.ID] = file
+	}
+	for i := range section.Section {
+		collectSectionIDs(&section.Section[i], file, targets)
+	}
+}
+
+// rewriteInternalLinks points fragment links at the file that contains their target.
+// Links to targets in currentFile are left untouched.
+func rewriteInternalLinks(content string, targets map[string]string, currentFile string) string {
+	for id, file := range targets {
+		if file == currentFile {
+			continue
+		}
+		escapedID := html.EscapeString(id)
+		content = strings.ReplaceAll(content,
+			fmt.Sprintf(`href="#%s"`, escapedID),
+			fmt.Sprintf(`href="%s#%s"`, file, escapedID))
+	}
+	return content
+}
+
+// addBackMatter writes one XHTML file per extra body
+func addBackMatter(
+	writer *zip.Writer,
+	backMatter []*backMatterBody,
+	targets map[string]string,
+	imageMap map[string]*ImageInfo,
+	processor *documentProcessor,
+) error {
+	for _, bm := range backMatter {
+		w, err := writer.Create("OEBPS/" + bm.File)
+		if err != nil {
+			return err
+		}
+
+		var bodyContent strings.Builder
+
+		for i := range bm.Body.Section {
+			section := &bm.Body.Section[i]
+			processSectionWithID(&bodyContent, section, 1, i, bm.ID, imageMap)
+			if section.ID != "" {
+				bodyContent.WriteString(processor.notes.backlink(section.ID))
+			}
+		}
+
+		content, err := renderTemplate(processor.templates, backMatterTemplate, backMatterData{
+			Title: bm.Title,
+			ID:    bm.ID,
+			Type:  bm.EpubType(),
+			Body:  markup(bodyContent.String()),
+		})
+		if err != nil {
+			return err
+		}
+		content = rewriteInternalLinks(content, targets, bm.File)
+		content = processor.process(content, bm.File)
+		if _, err := w.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
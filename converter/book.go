@@ -0,0 +1,542 @@
+package converter
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/lex/fb2epub/book"
+	"github.com/lex/fb2epub/models"
+)
+
+// BookFromFB2 maps a parsed FB2 document into the format-neutral book model.
+// Extra bodies become notes chapters, binaries that are not valid base64 are
+// skipped and, like the EPUB renderer, paragraphs keep their plain text before
+// their links, strong, emphasized and image runs.
+func BookFromFB2(fb2 *models.FictionBook) *book.Book {
+	titleInfo := &fb2.Description.TitleInfo
+	b := &book.Book{
+		Metadata: book.Metadata{
+			Title:      strings.TrimSpace(titleInfo.BookTitle),
+			Language:   strings.TrimSpace(titleInfo.Lang),
+			Genres:     append([]string(nil), titleInfo.Genre...),
+			Date:       strings.TrimSpace(titleInfo.Date),
+			Identifier: strings.TrimSpace(fb2.Description.DocumentInfo.ID),
+			Publisher:  strings.TrimSpace(fb2.Description.PublishInfo.Publisher),
+			ISBN:       strings.TrimSpace(fb2.Description.PublishInfo.ISBN),
+			Series:     bookSeries(titleInfo.Sequence, nil),
+		},
+	}
+	for _, author := range titleInfo.Author {
+		if name := buildAuthorName(author); name != "" {
+			b.Metadata.Authors = append(b.Metadata.Authors, name)
+		}
+	}
+	for _, translator := range titleInfo.Translator {
+		if name := buildAuthorName(translator); name != "" {
+			b.Metadata.Translators = append(b.Metadata.Translators, name)
+		}
+	}
+	original := originalOf(fb2)
+	b.Metadata.OriginalTitle = original.Title
+	b.Metadata.OriginalAuthors = original.Authors
+	b.Metadata.OriginalLanguage = original.Language
+	if annotation := titleInfo.Annotation; annotation != nil {
+		b.Metadata.Annotation = containerBlocks(annotation.Subtitle, annotation.Paragraph,
+			annotation.Poem, annotation.Cite, annotation.EmptyLine, nil)
+	}
+	if titleInfo.Coverpage != nil && len(titleInfo.Coverpage.Image) > 0 {
+		b.Cover = strings.TrimPrefix(titleInfo.Coverpage.Image[0].Href, "#")
+	}
+
+	mainBody := fb2.MainBody()
+	for _, image := range mainBody.Image {
+		if id := strings.TrimPrefix(image.Href, "#"); id != "" {
+			b.Front = append(b.Front, book.Block{Kind: book.Image, ImageID: id})
+		}
+	}
+	for i := range mainBody.Epigraph {
+		b.Front = append(b.Front, epigraphBlock(&mainBody.Epigraph[i]))
+	}
+	for i := range mainBody.Section {
+		b.Chapters = append(b.Chapters, chapterFromSection(&mainBody.Section[i]))
+	}
+
+	l := labelsFor(titleInfo.Lang)
+	extra := fb2.ExtraBodies()
+	for i := range extra {
+		notes := &book.Chapter{Title: backMatterTitle(&extra[i], l)}
+		for j := range extra[i].Section {
+			notes.Children = append(notes.Children, chapterFromSection(&extra[i].Section[j]))
+		}
+		b.Notes = append(b.Notes, notes)
+	}
+
+	for _, binary := range fb2.Binary {
+		data, err := base64.StdEncoding.DecodeString(binary.Data)
+		if err != nil || b.Resource(binary.ID) != nil {
+			continue
+		}
+		contentType := binary.ContentType
+		if sniffed := sniffImageType(data); sniffed != "" {
+			contentType = sniffed
+		}
+		b.Resources = append(b.Resources, &book.Resource{
+			ID:          binary.ID,
+			ContentType: contentType,
+			Data:        data,
+		})
+	}
+	return b
+}
+
+// bookSeries lists nested FB2 sequences depth-first
+func bookSeries(sequences []models.Sequence, series []book.Series) []book.Series {
+	for _, sequence := range sequences {
+		if name := strings.TrimSpace(sequence.Name); name != "" {
+			series = append(series, book.Series{Name: name, Number: strings.TrimSpace(sequence.Number)})
+		}
+		series = bookSeries(sequence.Sequence, series)
+	}
+	return series
+}
+
+func chapterFromSection(section *models.Section) *book.Chapter {
+	chapter := &book.Chapter{
+		ID: section.ID,
+	}
+	for _, block := range section.OrderedBlocks() {
+		switch block.Kind {
+		case models.ParagraphBlock:
+			chapter.Blocks = append(chapter.Blocks, paragraphBlocks(&section.Paragraph[block.Index])...)
+		case models.EmptyLineBlock:
+			chapter.Blocks = append(chapter.Blocks, book.Block{Kind: book.EmptyLine})
+		case models.PoemBlock:
+			chapter.Blocks = append(chapter.Blocks, poemBlock(&section.Poem[block.Index]))
+		case models.CiteBlock:
+			chapter.Blocks = append(chapter.Blocks, citeBlock(&section.Cite[block.Index]))
+		case models.ImageBlock:
+			chapter.Blocks = append(chapter.Blocks, book.Block{
+				Kind:    book.Image,
+				ImageID: strings.TrimPrefix(section.Image[block.Index].Href, "#"),
+			})
+		}
+	}
+	if section.Title != nil {
+		var titleParts []string
+		for i := range section.Title.Paragraph {
+			if text := strings.TrimSpace(extractParagraphText(&section.Title.Paragraph[i])); text != "" {
+				titleParts = append(titleParts, text)
+			}
+		}
+		chapter.Title = strings.Join(titleParts, " ")
+	}
+	for i := range section.Section {
+		chapter.Children = append(chapter.Children, chapterFromSection(&section.Section[i]))
+	}
+	return chapter
+}
+
+// containerBlocks maps the content of a section-like FB2 element in the order
+// the EPUB renderer emits it
+func containerBlocks(
+	subtitles, paragraphs []models.Paragraph,
+	poems []models.Poem,
+	cites []models.Cite,
+	emptyLines []models.EmptyLine,
+	textAuthors []models.Paragraph,
+) []book.Block {
+	var blocks []book.Block
+	for i := range subtitles {
+		blocks = append(blocks, book.Block{Kind: book.Subtitle, Spans: paragraphSpans(&subtitles[i])})
+	}
+	for i := range paragraphs {
+		blocks = append(blocks, paragraphBlocks(&paragraphs[i])...)
+	}
+	for range emptyLines {
+		blocks = append(blocks, book.Block{Kind: book.EmptyLine})
+	}
+	for i := range poems {
+		blocks = append(blocks, poemBlock(&poems[i]))
+	}
+	for i := range cites {
+		blocks = append(blocks, citeBlock(&cites[i]))
+	}
+	for i := range textAuthors {
+		blocks = append(blocks, book.Block{Kind: book.TextAuthor, Spans: paragraphSpans(&textAuthors[i])})
+	}
+	return blocks
+}
+
+// citeBlock maps a citation to a quote, keeping the order of its children
+func citeBlock(cite *models.Cite) book.Block {
+	block := book.Block{Kind: book.Quote, ID: cite.ID}
+	for _, child := range cite.OrderedBlocks() {
+		switch child.Kind {
+		case models.SubtitleBlock:
+			block.Children = append(block.Children,
+				book.Block{Kind: book.Subtitle, Spans: paragraphSpans(&cite.Subtitle[child.Index])})
+		case models.ParagraphBlock:
+			block.Children = append(block.Children, paragraphBlocks(&cite.Paragraph[child.Index])...)
+		case models.PoemBlock:
+			block.Children = append(block.Children, poemBlock(&cite.Poem[child.Index]))
+		case models.EmptyLineBlock:
+			block.Children = append(block.Children, book.Block{Kind: book.EmptyLine})
+		case models.TextAuthorBlock:
+			block.Children = append(block.Children,
+				book.Block{Kind: book.TextAuthor, Spans: paragraphSpans(&cite.TextAuthor[child.Index])})
+		}
+	}
+	return block
+}
+
+// paragraphBlocks maps a paragraph; a paragraph holding only an image becomes an image block
+func paragraphBlocks(p *models.Paragraph) []book.Block {
+	spans := paragraphSpans(p)
+	if len(spans) == 1 && spans[0].ImageID != "" {
+		return []book.Block{{Kind: book.Image, ImageID: spans[0].ImageID}}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return []book.Block{{Kind: book.Paragraph, Spans: spans}}
+}
+
+func poemBlock(poem *models.Poem) book.Block {
+	block := book.Block{Kind: book.Poem}
+	if poem.Title != nil {
+		for i := range poem.Title.Paragraph {
+			block.Children = append(block.Children, book.Block{
+				Kind:  book.Subtitle,
+				Spans: paragraphSpans(&poem.Title.Paragraph[i]),
+			})
+		}
+	}
+	for i := range poem.Epigraph {
+		block.Children = append(block.Children, epigraphBlock(&poem.Epigraph[i]))
+	}
+	for i := range poem.Stanza {
+		stanza := book.Block{Kind: book.Stanza}
+		if poem.Stanza[i].Subtitle != nil {
+			stanza.Children = append(stanza.Children, book.Block{
+				Kind:  book.Subtitle,
+				Spans: paragraphSpans(poem.Stanza[i].Subtitle),
+			})
+		}
+		for _, verse := range poem.Stanza[i].Verse {
+			stanza.Children = append(stanza.Children, book.Block{
+				Kind:  book.Verse,
+				Spans: []book.Span{{Text: verse.Text}},
+			})
+		}
+		block.Children = append(block.Children, stanza)
+	}
+	for i := range poem.TextAuthor {
+		block.Children = append(block.Children, book.Block{
+			Kind:  book.TextAuthor,
+			Spans: paragraphSpans(&poem.TextAuthor[i]),
+		})
+	}
+	return block
+}
+
+func epigraphBlock(epigraph *models.Epigraph) book.Block {
+	return book.Block{
+		Kind: book.Epigraph,
+		Children: containerBlocks(nil, epigraph.Paragraph, epigraph.Poem, epigraph.Cite,
+			epigraph.EmptyLine, epigraph.TextAuthor),
+	}
+}
+
+func paragraphSpans(p *models.Paragraph) []book.Span {
+	return inlineSpans(p.OrderedContent(), p.Strong, p.Emphasis, p.Link, p.Image, 0)
+}
+
+// inlineSpans flattens FB2 inline markup into spans in document order,
+// combining the styles of nested elements
+func inlineSpans(
+	content []models.Inline,
+	strong []models.Strong,
+	emphasis []models.Emphasis,
+	links []models.Link,
+	images []models.Image,
+	style book.Style,
+) []book.Span {
+	var spans []book.Span
+	for _, piece := range content {
+		switch piece.Kind {
+		case models.TextInline:
+			if piece.Text != "" {
+				spans = append(spans, book.Span{Text: piece.Text, Style: style})
+			}
+		case models.LinkInline:
+			link := &links[piece.Index]
+			spans = append(spans, book.Span{Text: link.Text, Style: style, Href: link.Href})
+		case models.StrongInline:
+			s := &strong[piece.Index]
+			spans = append(spans, inlineSpans(s.OrderedContent(), s.Strong, s.Emphasis, s.Link, nil, style|book.Strong)...)
+		case models.EmphasisInline:
+			e := &emphasis[piece.Index]
+			spans = append(spans, inlineSpans(e.OrderedContent(), e.Strong, e.Emphasis, e.Link, nil, style|book.Emphasis)...)
+		case models.ImageInline:
+			if id := strings.TrimPrefix(images[piece.Index].Href, "#"); id != "" {
+				spans = append(spans, book.Span{ImageID: id})
+			}
+		}
+	}
+	return spans
+}
+
+// FB2FromBook maps a book back into FB2 structures. Notes chapters become
+// bodies named "notes", the front matter becomes the images and epigraphs of
+// the main body, quotes and epigraphs outside poems become cites, images on
+// their own become section images, and styles FB2 cannot express
+// (strikethrough, code, super- and subscript) are dropped while their text is kept.
+func FB2FromBook(b *book.Book) *models.FictionBook {
+	fb2 := &models.FictionBook{}
+	titleInfo := &fb2.Description.TitleInfo
+	titleInfo.BookTitle = b.Metadata.Title
+	titleInfo.Lang = b.Metadata.Language
+	titleInfo.Genre = append([]string(nil), b.Metadata.Genres...)
+	titleInfo.Date = b.Metadata.Date
+	titleInfo.Author = parseAuthorOverride(strings.Join(b.Metadata.Authors, ","))
+	titleInfo.Translator = parseAuthorOverride(strings.Join(b.Metadata.Translators, ","))
+	titleInfo.SrcLang = b.Metadata.OriginalLanguage
+	if b.Metadata.OriginalTitle != "" || len(b.Metadata.OriginalAuthors) > 0 {
+		fb2.Description.SrcTitleInfo = &models.TitleInfo{
+			BookTitle: b.Metadata.OriginalTitle,
+			Author:    parseAuthorOverride(strings.Join(b.Metadata.OriginalAuthors, ",")),
+			Lang:      b.Metadata.OriginalLanguage,
+		}
+	}
+	for _, series := range b.Metadata.Series {
+		titleInfo.Sequence = append(titleInfo.Sequence, models.Sequence{Name: series.Name, Number: series.Number})
+	}
+	if len(b.Metadata.Annotation) > 0 {
+		var cite models.Cite
+		fillCite(&cite, b.Metadata.Annotation)
+		titleInfo.Annotation = &models.Annotation{
+			Subtitle:  cite.Subtitle,
+			Paragraph: cite.Paragraph,
+			Poem:      cite.Poem,
+			EmptyLine: cite.EmptyLine,
+		}
+	}
+	if b.Cover != "" {
+		titleInfo.Coverpage = &models.Coverpage{Image: []models.Image{{Href: "#" + b.Cover}}}
+	}
+	fb2.Description.DocumentInfo.ID = b.Metadata.Identifier
+	fb2.Description.PublishInfo.Publisher = b.Metadata.Publisher
+	fb2.Description.PublishInfo.ISBN = b.Metadata.ISBN
+
+	mainBody := models.Body{}
+	for i := range b.Front {
+		block := &b.Front[i]
+		switch block.Kind {
+		case book.Image:
+			mainBody.Image = append(mainBody.Image, models.Image{Href: "#" + block.ImageID})
+		default:
+			mainBody.Epigraph = append(mainBody.Epigraph, epigraphFromBlock(block))
+		}
+	}
+	for _, chapter := range b.Chapters {
+		mainBody.Section = append(mainBody.Section, sectionFromChapter(chapter))
+	}
+	fb2.Body = append(fb2.Body, mainBody)
+	for _, notes := range b.Notes {
+		body := models.Body{Name: "notes"}
+		if notes.Title != "" {
+			body.Title.Paragraph = []models.Paragraph{{Text: notes.Title}}
+		}
+		for _, chapter := range notes.Children {
+			body.Section = append(body.Section, sectionFromChapter(chapter))
+		}
+		fb2.Body = append(fb2.Body, body)
+	}
+
+	for _, resource := range b.Resources {
+		fb2.Binary = append(fb2.Binary, models.Binary{
+			ID:          resource.ID,
+			ContentType: resource.ContentType,
+			Data:        base64.StdEncoding.EncodeToString(resource.Data),
+		})
+	}
+	return fb2
+}
+
+func sectionFromChapter(chapter *book.Chapter) models.Section {
+	section := models.Section{ID: chapter.ID}
+	if chapter.Title != "" {
+		section.Title = &models.Title{Paragraph: []models.Paragraph{{Text: chapter.Title}}}
+	}
+	for i := range chapter.Blocks {
+		block := &chapter.Blocks[i]
+		switch block.Kind {
+		case book.EmptyLine:
+			section.Blocks = append(section.Blocks,
+				models.SectionBlock{Kind: models.EmptyLineBlock, Index: len(section.EmptyLine)})
+			section.EmptyLine = append(section.EmptyLine, models.EmptyLine{})
+		case book.Quote, book.Epigraph:
+			cite := models.Cite{ID: block.ID}
+			fillCite(&cite, block.Children)
+			section.Blocks = append(section.Blocks,
+				models.SectionBlock{Kind: models.CiteBlock, Index: len(section.Cite)})
+			section.Cite = append(section.Cite, cite)
+		case book.Poem, book.Stanza:
+			section.Blocks = append(section.Blocks,
+				models.SectionBlock{Kind: models.PoemBlock, Index: len(section.Poem)})
+			section.Poem = append(section.Poem, poemFromBlock(block))
+		case book.Image:
+			section.Blocks = append(section.Blocks,
+				models.SectionBlock{Kind: models.ImageBlock, Index: len(section.Image)})
+			section.Image = append(section.Image, models.Image{Href: "#" + block.ImageID})
+		default:
+			section.Blocks = append(section.Blocks,
+				models.SectionBlock{Kind: models.ParagraphBlock, Index: len(section.Paragraph)})
+			section.Paragraph = append(section.Paragraph, paragraphFromBlock(block))
+		}
+	}
+	for _, child := range chapter.Children {
+		section.Blocks = append(section.Blocks,
+			models.SectionBlock{Kind: models.SubsectionBlock, Index: len(section.Section)})
+		section.Section = append(section.Section, sectionFromChapter(child))
+	}
+	return section
+}
+
+// fillCite adds blocks to a cite; nested quotes are flattened since FB2 cites do not nest
+func fillCite(cite *models.Cite, blocks []book.Block) {
+	for i := range blocks {
+		block := &blocks[i]
+		switch block.Kind {
+		case book.Subtitle:
+			cite.Blocks = append(cite.Blocks, models.SectionBlock{Kind: models.SubtitleBlock, Index: len(cite.Subtitle)})
+			cite.Subtitle = append(cite.Subtitle, paragraphFromBlock(block))
+		case book.TextAuthor:
+			cite.Blocks = append(cite.Blocks, models.SectionBlock{Kind: models.TextAuthorBlock, Index: len(cite.TextAuthor)})
+			cite.TextAuthor = append(cite.TextAuthor, paragraphFromBlock(block))
+		case book.EmptyLine:
+			cite.Blocks = append(cite.Blocks, models.SectionBlock{Kind: models.EmptyLineBlock, Index: len(cite.EmptyLine)})
+			cite.EmptyLine = append(cite.EmptyLine, models.EmptyLine{})
+		case book.Quote, book.Epigraph:
+			fillCite(cite, block.Children)
+		case book.Poem, book.Stanza:
+			cite.Blocks = append(cite.Blocks, models.SectionBlock{Kind: models.PoemBlock, Index: len(cite.Poem)})
+			cite.Poem = append(cite.Poem, poemFromBlock(block))
+		default:
+			cite.Blocks = append(cite.Blocks, models.SectionBlock{Kind: models.ParagraphBlock, Index: len(cite.Paragraph)})
+			cite.Paragraph = append(cite.Paragraph, paragraphFromBlock(block))
+		}
+	}
+}
+
+// poemFromBlock maps a poem block; a lone stanza becomes a one-stanza poem
+func poemFromBlock(block *book.Block) models.Poem {
+	if block.Kind == book.Stanza {
+		return models.Poem{Stanza: []models.Stanza{stanzaFromBlock(block)}}
+	}
+	var poem models.Poem
+	var loose []book.Block
+	for i := range block.Children {
+		child := &block.Children[i]
+		switch child.Kind {
+		case book.Subtitle:
+			if poem.Title == nil {
+				poem.Title = &models.Title{}
+			}
+			poem.Title.Paragraph = append(poem.Title.Paragraph, paragraphFromBlock(child))
+		case book.Epigraph:
+			poem.Epigraph = append(poem.Epigraph, epigraphFromBlock(child))
+		case book.Stanza:
+			poem.Stanza = append(poem.Stanza, stanzaFromBlock(child))
+		case book.TextAuthor:
+			poem.TextAuthor = append(poem.TextAuthor, paragraphFromBlock(child))
+		default:
+			loose = append(loose, *child)
+		}
+	}
+	if len(loose) > 0 {
+		poem.Stanza = append(poem.Stanza, stanzaFromBlock(&book.Block{Kind: book.Stanza, Children: loose}))
+	}
+	return poem
+}
+
+// epigraphFromBlock maps an epigraph, or any other block, to an FB2 epigraph
+func epigraphFromBlock(block *book.Block) models.Epigraph {
+	var cite models.Cite
+	if block.Kind == book.Epigraph || block.Kind == book.Quote {
+		fillCite(&cite, block.Children)
+	} else {
+		fillCite(&cite, []book.Block{*block})
+	}
+	return models.Epigraph{
+		Paragraph:  cite.Paragraph,
+		Poem:       cite.Poem,
+		EmptyLine:  cite.EmptyLine,
+		TextAuthor: cite.TextAuthor,
+	}
+}
+
+func stanzaFromBlock(block *book.Block) models.Stanza {
+	var stanza models.Stanza
+	for i := range block.Children {
+		child := &block.Children[i]
+		if child.Kind == book.Subtitle && stanza.Subtitle == nil && len(stanza.Verse) == 0 {
+			subtitle := paragraphFromBlock(child)
+			stanza.Subtitle = &subtitle
+			continue
+		}
+		stanza.Verse = append(stanza.Verse, models.Verse{Text: child.Text()})
+	}
+	return stanza
+}
+
+// paragraphFromBlock maps a text or image block to a paragraph. Unstyled text
+// runs are joined into the paragraph text, styled runs become strong or
+// emphasis elements and links keep their text.
+func paragraphFromBlock(block *book.Block) models.Paragraph {
+	var p models.Paragraph
+	if block.Kind == book.Image {
+		p.Image = append(p.Image, models.Image{Href: "#" + block.ImageID})
+		return p
+	}
+	if len(block.Children) > 0 {
+		p.Text = block.Text()
+		return p
+	}
+
+	var text strings.Builder
+	for _, span := range block.Spans {
+		switch {
+		case span.ImageID != "":
+			p.Content = append(p.Content, models.Inline{Kind: models.ImageInline, Index: len(p.Image)})
+			p.Image = append(p.Image, models.Image{Href: "#" + span.ImageID})
+		case span.Href != "":
+			p.Content = append(p.Content, models.Inline{Kind: models.LinkInline, Index: len(p.Link)})
+			p.Link = append(p.Link, models.Link{Href: span.Href, Text: span.Text})
+		case span.Style&book.Strong != 0 && span.Style&book.Emphasis != 0:
+			p.Content = append(p.Content, models.Inline{Kind: models.StrongInline, Index: len(p.Strong)})
+			p.Strong = append(p.Strong, models.Strong{Emphasis: []models.Emphasis{{Text: span.Text}}})
+		case span.Style&book.Strong != 0:
+			p.Content = append(p.Content, models.Inline{Kind: models.StrongInline, Index: len(p.Strong)})
+			p.Strong = append(p.Strong, models.Strong{Text: span.Text})
+		case span.Style&book.Emphasis != 0:
+			p.Content = append(p.Content, models.Inline{Kind: models.EmphasisInline, Index: len(p.Emphasis)})
+			p.Emphasis = append(p.Emphasis, models.Emphasis{Text: span.Text})
+		default:
+			text.WriteString(span.Text)
+			p.Content = append(p.Content, models.Inline{Kind: models.TextInline, Text: span.Text})
+		}
+	}
+	p.Text = text.String()
+	return p
+}
+
+// WriteEPUBFromBook renders a book as an EPUB. It is the entry point for input
+// formats other than FB2: they build a *book.Book and never touch FB2
+// structures. The renderer itself still works on FB2 elements, so the book is
+// mapped with FB2FromBook first.
+func WriteEPUBFromBook(ctx context.Context, b *book.Book, w io.Writer, opts *Options) error {
+	return WriteEPUBContext(ctx, FB2FromBook(b), w, opts)
+}
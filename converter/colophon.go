@@ -0,0 +1,116 @@
+package converter
+
+import (
+	"archive/zip"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// colophonFile is the back-matter page recording where the book came from
+const colophonFile = "colophon.xhtml"
+
+// Version identifies the converter on the colophon page. Release builds set it with
+// -ldflags "-X github.com/lex/fb2epub/converter.Version=v1.2.3"; otherwise the
+// VCS revision the binary was built from is used.
+var Version = ""
+
+// converterVersion returns Version, the VCS revision or "dev"
+func converterVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+				return setting.Value[:12]
+			}
+		}
+	}
+	return "dev"
+}
+
+// colophonEntry is one labelled line of the colophon
+type colophonEntry struct {
+	Label string
+	Value string
+}
+
+// colophonEntries returns the label and value of each line of the colophon,
+// skipping document details the FB2 does not give. Custom information follows
+// the document details, labelled with its type.
+func colophonEntries(description *models.Description, l *labels, converted time.Time) []colophonEntry {
+	documentInfo := &description.DocumentInfo
+	var entries []colophonEntry
+	add := func(label, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			entries = append(entries, colophonEntry{Label: label, Value: value})
+		}
+	}
+
+	source := strings.TrimSpace(documentInfo.ID)
+	if version := strings.TrimSpace(documentInfo.Version); source != "" && version != "" {
+		source += " (" + version + ")"
+	}
+	add(l.SourceDocument, source)
+
+	var authors []string
+	for _, author := range documentInfo.Author {
+		if name := buildAuthorName(author); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	add(l.DocumentAuthors, strings.Join(authors, ", "))
+	add(l.DocumentDate, documentInfo.Date)
+	add(l.ProgramUsed, documentInfo.ProgramUsed)
+	for _, info := range description.CustomInfo {
+		label := strings.TrimSpace(info.InfoType)
+		if label == "" {
+			label = l.CustomInfo
+		}
+		add(label, strings.Join(strings.Fields(info.Text), " "))
+	}
+	add(l.ConvertedOn, converted.UTC().Format("2006-01-02"))
+	add(l.ConvertedWith, "fb2epub "+converterVersion())
+	return entries
+}
+
+// historyParagraphs returns the plain text of the paragraphs of a document
+// history, usually one per version of the document
+func historyParagraphs(history *models.Annotation) []string {
+	if history == nil {
+		return nil
+	}
+	var paragraphs []string
+	for i := range history.Paragraph {
+		if text := strings.Join(strings.Fields(extractParagraphText(&history.Paragraph[i])), " "); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return paragraphs
+}
+
+// addColophonPage writes the colophon, the last page of the book, when enabled
+func addColophonPage(writer *zip.Writer, fb2 *models.FictionBook, processor *documentProcessor) error {
+	w, err := writer.Create("OEBPS/" + colophonFile)
+	if err != nil {
+		return err
+	}
+
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
+
+	content, err := renderTemplate(processor.templates, colophonTemplate, colophonData{
+		Title:        l.Colophon,
+		Entries:      colophonEntries(&fb2.Description, l, time.Now()),
+		HistoryTitle: l.DocumentHistory,
+		History:      historyParagraphs(fb2.Description.DocumentInfo.History),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write([]byte(processor.process(content, colophonFile)))
+	return err
+}
@@ -0,0 +1,42 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// contextReader fails reads once its context is done, aborting parsing mid-document
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// contextWriter fails writes once its context is done, aborting EPUB generation
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
+
+// contextError replaces err with the context's error when the context ended,
+// so callers can tell a timeout or cancellation from a conversion failure
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("conversion aborted: %w", ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// Converter converts FB2 documents to EPUB using a fixed set of options.
+// A Converter is safe for concurrent use as long as its callbacks are.
+type Converter struct {
+	opts Options
+}
+
+// ParseError reports that the input could not be read as an FB2 document
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying parse error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// New creates a Converter. A nil opts uses DefaultOptions.
+func New(opts *Options) *Converter {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	return &Converter{opts: *opts}
+}
+
+// Convert reads an FB2 document from r and writes the resulting EPUB to w.
+// Errors caused by invalid input are returned as *ParseError.
+func (c *Converter) Convert(r io.Reader, w io.Writer) error {
+	return c.ConvertContext(context.Background(), r, w)
+}
+
+// ConvertContext converts like Convert, giving up once ctx is done.
+// An aborted conversion returns an error wrapping ctx.Err().
+func (c *Converter) ConvertContext(ctx context.Context, r io.Reader, w io.Writer) error {
+	opts := c.opts
+
+	opts.reportProgress(StageParsing, 0)
+	fb2, err := parseInputContext(ctx, r, &opts)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	opts.reportPreview(fb2)
+
+	return WriteEPUBContext(ctx, fb2, w, &opts)
+}
+
+// Stats describes a finished (or failed) file conversion
+type Stats struct {
+	Title            string   // Title of the book, after metadata overrides
+	Authors          []string // Names of the authors, after metadata overrides
+	Series           string   // Name of the series the book belongs to, if any
+	InputSize        int64    // Bytes read from the FB2 file
+	OutputSize       int64    // Bytes of the written EPUB
+	ImageCount       int      // Binary objects in the FB2 document
+	PrunedImageBytes int64    // Decoded bytes of the unreferenced binaries left out with PruneImages
+	ChapterCount     int      // Titled sections of the main body, at any depth
+	ParseDuration    time.Duration
+	GenerateDuration time.Duration
+}
+
+// ConvertFile converts the FB2 file at inputPath into an EPUB file at outputPath
+func (c *Converter) ConvertFile(inputPath, outputPath string) error {
+	_, err := c.ConvertFileWithStats(inputPath, outputPath)
+	return err
+}
+
+// ConvertFileWithStats converts like ConvertFile and reports sizes, counts and
+// stage durations. On failure the stats of the completed stages are returned.
+func (c *Converter) ConvertFileWithStats(inputPath, outputPath string) (*Stats, error) {
+	return c.ConvertFileWithStatsContext(context.Background(), inputPath, outputPath)
+}
+
+// ConvertFileWithStatsContext converts like ConvertFileWithStats, giving up once ctx is done.
+// An aborted conversion returns an error wrapping ctx.Err().
+func (c *Converter) ConvertFileWithStatsContext(ctx context.Context, inputPath, outputPath string) (*Stats, error) {
+	stats := &Stats{}
+
+	//nolint:gosec // Path is controlled by the caller
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return stats, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		if closeErr := input.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+	if info, err := input.Stat(); err == nil {
+		stats.InputSize = info.Size()
+	}
+
+	opts := c.opts
+
+	opts.reportProgress(StageParsing, 0)
+	start := time.Now()
+	fb2, err := parseInputContext(ctx, input, &opts)
+	stats.ParseDuration = time.Since(start)
+	if err != nil {
+		return stats, contextError(ctx, err)
+	}
+	opts.reportPreview(fb2)
+	// The cover override is left out, as only the title info is needed
+	overrides := opts.Metadata
+	overrides.CoverImage = nil
+	stats.describe(&applyMetadataOverrides(fb2, &overrides).Description.TitleInfo)
+	stats.ImageCount = len(fb2.Binary)
+	stats.ChapterCount = countChapters(fb2.MainBody().Section)
+	if opts.PruneImages {
+		fb2, stats.PrunedImageBytes = pruneUnreferencedBinaries(fb2, !opts.DisableCoverDetection)
+	}
+
+	start = time.Now()
+	err = GenerateEPUBContext(ctx, fb2, outputPath, &opts)
+	stats.GenerateDuration = time.Since(start)
+	if err != nil {
+		return stats, err
+	}
+	if info, err := os.Stat(outputPath); err == nil {
+		stats.OutputSize = info.Size()
+	}
+
+	return stats, nil
+}
+
+// describe records the title, authors and series of a book
+func (s *Stats) describe(titleInfo *models.TitleInfo) {
+	s.Title = titleInfo.BookTitle
+	s.Authors = nil
+	for _, author := range titleInfo.Author {
+		if name := buildAuthorName(author); name != "" {
+			s.Authors = append(s.Authors, name)
+		}
+	}
+	s.Series = ""
+	if len(titleInfo.Sequence) > 0 {
+		s.Series = strings.TrimSpace(titleInfo.Sequence[0].Name)
+	}
+}
+
+// countChapters counts titled sections, including nested ones
+func countChapters(sections []models.Section) int {
+	count := 0
+	for i := range sections {
+		if title := sections[i].Title; title != nil && len(title.Paragraph) > 0 {
+			count++
+		}
+		count += countChapters(sections[i].Section)
+	}
+	return count
+}
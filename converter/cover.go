@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// detectCoverImage guesses a cover image for books without a coverpage element.
+// It prefers a binary whose ID mentions "cover", then the first image referenced
+// in the opening section of the book, then the largest image.
+func detectCoverImage(fb2 *models.FictionBook, imageMap map[string]*ImageInfo) string {
+	if len(imageMap) == 0 {
+		return ""
+	}
+
+	// Sort IDs so the result doesn't depend on map iteration order
+	ids := make([]string, 0, len(imageMap))
+	for id := range imageMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if strings.Contains(strings.ToLower(id), "cover") {
+			return id
+		}
+	}
+
+	mainBody := fb2.MainBody()
+	if len(mainBody.Section) > 0 {
+		for _, ref := range sectionImageRefs(&mainBody.Section[0]) {
+			if _, exists := imageMap[ref]; exists {
+				return ref
+			}
+		}
+	}
+
+	largest := ""
+	for _, id := range ids {
+		if largest == "" || imageMap[id].Size > imageMap[largest].Size {
+			largest = id
+		}
+	}
+	return largest
+}
+
+// sectionImageRefs returns the binary IDs referenced by images in a section, in document order
+func sectionImageRefs(section *models.Section) []string {
+	var refs []string
+	for _, block := range section.OrderedBlocks() {
+		switch block.Kind {
+		case models.ParagraphBlock:
+			for _, image := range section.Paragraph[block.Index].Image {
+				refs = append(refs, strings.TrimPrefix(image.Href, "#"))
+			}
+		case models.ImageBlock:
+			refs = append(refs, strings.TrimPrefix(section.Image[block.Index].Href, "#"))
+		case models.SubsectionBlock:
+			refs = append(refs, sectionImageRefs(&section.Section[block.Index])...)
+		}
+	}
+	return refs
+}
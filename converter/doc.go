@@ -0,0 +1,20 @@
+// Package converter provides FB2 to EPUB conversion functionality.
+//
+// The package has no dependency on the HTTP service and can be used as a
+// library by other Go programs:
+//
+//	opts := converter.DefaultOptions()
+//	opts.OnProgress = func(stage converter.Stage, percent int) {
+//		log.Printf("%s: %d%%", stage, percent)
+//	}
+//	if err := converter.New(opts).Convert(fb2Reader, epubWriter); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// Lower-level functions (ParseFB2, GenerateEPUB, WriteEPUB) are available
+// for callers that want to inspect or modify the parsed book before
+// generating the EPUB.
+//
+// ParseEPUB and WriteFB2 run the conversion in reverse, through the
+// format-neutral model of the book package.
+package converter
@@ -0,0 +1,18 @@
+package converter
+
+import "regexp"
+
+// openingParagraphPattern matches the start of a paragraph right after a
+// section heading up to its first letter or digit, with the inline elements and
+// opening punctuation before it
+var openingParagraphPattern = regexp.MustCompile(
+	`(</h[1-6]>\n)<p((?: lang="[^"]*" xml:lang="[^"]*")?)>((?:\s|<(?:strong|em|span)\b[^>]*>)*)` +
+		`((?:[«„“‘(\[]|&#34;|&#39;)*[\p{L}\p{N}])`)
+
+// addDropCaps styles the opening paragraph of every section like printed
+// books do: it is set without indentation and its first letter, with any
+// quotation mark before it, becomes a drop cap
+func addDropCaps(document string) string {
+	return openingParagraphPattern.ReplaceAllString(document,
+		`$1<p class="opening"$2>$3<span class="dropcap">$4</span>`)
+}
@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Byte order marks documents may start with
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// newUTF8Reader returns a reader of the document in r as UTF-8 without a byte
+// order mark or whitespace before its XML declaration, which XML does not
+// allow. UTF-16 documents are recognized by their byte order mark or, lacking
+// one, by how their first character "<" is encoded, and transcoded.
+func newUTF8Reader(r io.Reader) io.Reader {
+	input := bufio.NewReader(r)
+	head, _ := input.Peek(4)
+
+	var decoded io.Reader = input
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		_, _ = input.Discard(len(utf8BOM))
+	case bytes.HasPrefix(head, utf16LEBOM):
+		_, _ = input.Discard(len(utf16LEBOM))
+		decoded = &utf16Reader{r: input}
+	case bytes.HasPrefix(head, utf16BEBOM):
+		_, _ = input.Discard(len(utf16BEBOM))
+		decoded = &utf16Reader{r: input, bigEndian: true}
+	case len(head) == 4 && head[1] == 0 && head[3] == 0 && head[0] != 0:
+		decoded = &utf16Reader{r: input}
+	case len(head) == 4 && head[0] == 0 && head[2] == 0 && head[1] != 0:
+		decoded = &utf16Reader{r: input, bigEndian: true}
+	}
+
+	return skipLeadingSpace(decoded)
+}
+
+// skipLeadingSpace returns a reader of r without the whitespace it starts with
+func skipLeadingSpace(r io.Reader) io.Reader {
+	input, ok := r.(*bufio.Reader)
+	if !ok {
+		input = bufio.NewReader(r)
+	}
+	for {
+		b, err := input.ReadByte()
+		if err != nil {
+			return input
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			_ = input.UnreadByte()
+			return input
+		}
+	}
+}
+
+// utf16Reader transcodes a UTF-16 document to UTF-8 as it is read. Unpaired
+// surrogates are replaced with U+FFFD.
+type utf16Reader struct {
+	r         io.Reader
+	bigEndian bool
+
+	buf     [32 * 1024]byte
+	pending []byte // Code unit or surrogate pair cut off at the end of the last read
+	out     []byte
+	err     error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		n, err := u.r.Read(u.buf[:])
+		data := append(u.pending, u.buf[:n]...)
+		u.pending = nil
+		u.out, u.pending = u.decode(data, err != nil)
+		u.err = err
+	}
+
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// decode transcodes data, returning the code units that are cut off unless
+// the input ends with it
+func (u *utf16Reader) decode(data []byte, last bool) (out, rest []byte) {
+	out = make([]byte, 0, len(data)*3/2)
+	for len(data) >= 2 {
+		r := rune(u.unit(data))
+		size := 2
+		if utf16.IsSurrogate(r) {
+			if len(data) < 4 && !last {
+				break
+			}
+			r = utf8.RuneError
+			if len(data) >= 4 {
+				if pair := utf16.DecodeRune(rune(u.unit(data)), rune(u.unit(data[2:]))); pair != utf8.RuneError {
+					r, size = pair, 4
+				}
+			}
+		}
+		out = utf8.AppendRune(out, r)
+		data = data[size:]
+	}
+	if len(data) > 0 && last {
+		// An odd trailing byte is not a character
+		out = utf8.AppendRune(out, utf8.RuneError)
+		data = nil
+	}
+	return out, append([]byte(nil), data...)
+}
+
+// unit returns the code unit data starts with
+func (u *utf16Reader) unit(data []byte) uint16 {
+	if u.bigEndian {
+		return uint16(data[0])<<8 | uint16(data[1])
+	}
+	return uint16(data[1])<<8 | uint16(data[0])
+}
@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// NotesMode selects how the notes and comments bodies of a book are rendered
+type NotesMode string
+
+// Supported notes modes; the empty mode is NotesPopup
+const (
+	// NotesPopup keeps notes in documents outside the reading order, reached
+	// through their references, which readers with popup footnotes show in place
+	NotesPopup NotesMode = "popup"
+
+	// NotesEndnotes reads notes as final chapters after the text, each note
+	// linking back to its first reference, for readers without popup footnotes
+	NotesEndnotes NotesMode = "endnotes"
+)
+
+// Validate reports an unsupported notes mode
+func (m NotesMode) Validate() error {
+	switch m {
+	case "", NotesPopup, NotesEndnotes:
+		return nil
+	default:
+		return fmt.Errorf("unsupported notes mode %q, expected popup or endnotes", string(m))
+	}
+}
+
+// noteReferences links notes rendered as endnotes with the first reference to
+// each of them. A nil *noteReferences links nothing.
+type noteReferences struct {
+	notes map[string]string // Note section ID to the file holding the note
+	files map[string]bool   // Files holding notes, whose links are not references
+	refs  map[string]string // Note section ID to the file of its first reference
+}
+
+// newNoteReferences collects the notes of the notes bodies among backMatter
+func newNoteReferences(backMatter []*backMatterBody) *noteReferences {
+	nr := &noteReferences{
+		notes: make(map[string]string),
+		files: make(map[string]bool),
+		refs:  make(map[string]string),
+	}
+	for _, bm := range backMatter {
+		if !bm.Notes {
+			continue
+		}
+		nr.files[bm.File] = true
+		for i := range bm.Body.Section {
+			if id := bm.Body.Section[i].ID; id != "" {
+				nr.notes[id] = bm.File
+			}
+		}
+	}
+	return nr
+}
+
+// noteRefID is the anchor ID of the first reference to a note
+func noteRefID(noteID string) string {
+	return "noteref-" + noteID
+}
+
+// mark gives the first link in document to each note not referenced before
+// an anchor ID, so the note can link back to it. Documents holding notes are
+// left alone; their links point from one note to another.
+func (nr *noteReferences) mark(document, file string) string {
+	if nr == nil || nr.files[file] {
+		return document
+	}
+	for id, noteFile := range nr.notes {
+		if _, seen := nr.refs[id]; seen {
+			continue
+		}
+		link := fmt.Sprintf(`<a href="%s#%s"`, html.EscapeString(noteFile), html.EscapeString(id))
+		if !strings.Contains(document, link) {
+			continue
+		}
+		anchored := fmt.Sprintf(`<a id="%s" href="%s#%s"`,
+			html.EscapeString(noteRefID(id)), html.EscapeString(noteFile), html.EscapeString(id))
+		document = strings.Replace(document, link, anchored, 1)
+		nr.refs[id] = file
+	}
+	return document
+}
+
+// backlink returns the link from a note back to its first reference, or an
+// empty string when nothing refers to the note
+func (nr *noteReferences) backlink(noteID string) string {
+	if nr == nil {
+		return ""
+	}
+	file, ok := nr.refs[noteID]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(`<p class="note-backlink"><a href="%s#%s" role="doc-backlink">↩</a></p>`+"\n",
+		html.EscapeString(file), html.EscapeString(noteRefID(noteID)))
+}
@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// maxEntityLength is the length of the longest HTML entity reference, &thetasym;,
+// which is longer than the start of a CDATA section as well
+const maxEntityLength = 10
+
+// cdataStart starts a CDATA section, whose text is taken literally up to ]]>
+var cdataStart = []byte("<![CDATA[")
+
+// htmlEntityFilter replaces the HTML entities XML does not define, such as
+// &nbsp; and &mdash;, with character references to what they stand for as a
+// document is read, so it can be parsed as XML. Character references keep the
+// document valid in any encoding. CDATA sections are left as they are.
+type htmlEntityFilter struct {
+	*streamFilter
+	cdata    bool // Inside a CDATA section
+	brackets int  // Closing brackets just read inside a CDATA section
+	replaced int  // Entities replaced so far
+}
+
+// newHTMLEntityFilter returns a filter reading the document from r
+func newHTMLEntityFilter(r io.Reader) *htmlEntityFilter {
+	f := &htmlEntityFilter{}
+	f.streamFilter = newStreamFilter(r, "&<", maxEntityLength, f.filter)
+	return f
+}
+
+// filter returns data with the HTML entities outside CDATA sections replaced
+func (f *htmlEntityFilter) filter(data []byte) []byte {
+	var result []byte
+	for {
+		// The end of a CDATA section is matched byte by byte, as reads may cut it
+		for f.cdata && len(data) > 0 {
+			switch b := data[0]; {
+			case b == ']':
+				f.brackets++
+			case b == '>' && f.brackets >= 2:
+				f.cdata = false
+				fallthrough
+			default:
+				f.brackets = 0
+			}
+			result = append(result, data[0])
+			data = data[1:]
+		}
+		if len(data) == 0 {
+			return result
+		}
+
+		next := bytes.IndexAny(data, "&<")
+		if next < 0 {
+			return append(result, data...)
+		}
+		result = append(result, data[:next]...)
+		data = data[next:]
+
+		if bytes.HasPrefix(data, cdataStart) {
+			f.cdata = true
+			continue
+		}
+		if data[0] == '&' {
+			if end := bytes.IndexByte(data, ';'); end > 1 && end <= maxEntityLength {
+				if replacement, ok := htmlEntity(string(data[1:end])); ok {
+					result = append(result, replacement...)
+					data = data[end+1:]
+					f.replaced++
+					continue
+				}
+			}
+		}
+		result = append(result, data[0])
+		data = data[1:]
+	}
+}
+
+// htmlEntity returns the character references of an HTML entity XML does not define
+func htmlEntity(name string) (string, bool) {
+	switch name {
+	case "amp", "lt", "gt", "quot", "apos":
+		return "", false
+	}
+	text, ok := xml.HTMLEntity[name]
+	if !ok {
+		return "", false
+	}
+	var refs []byte
+	for _, r := range text {
+		refs = append(refs, "&#"...)
+		refs = strconv.AppendInt(refs, int64(r), 10)
+		refs = append(refs, ';')
+	}
+	return string(refs), true
+}
@@ -1,11 +1,13 @@
-// Package converter provides FB2 to EPUB conversion functionality.
 package converter
 
 import (
 	"archive/zip"
-	"encoding/base64"
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"html"
+	"html/template"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/lex/fb2epub/models"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -22,6 +25,16 @@ const (
 
 // GenerateEPUB creates an EPUB file from an FB2 book
 func GenerateEPUB(fb2 *models.FictionBook, outputPath string) error {
+	return GenerateEPUBWithOptions(fb2, outputPath, DefaultOptions())
+}
+
+// GenerateEPUBWithOptions creates an EPUB file from an FB2 book using the given options
+func GenerateEPUBWithOptions(fb2 *models.FictionBook, outputPath string, opts *Options) error {
+	return GenerateEPUBContext(context.Background(), fb2, outputPath, opts)
+}
+
+// GenerateEPUBContext creates an EPUB file like GenerateEPUBWithOptions, giving up once ctx is done
+func GenerateEPUBContext(ctx context.Context, fb2 *models.FictionBook, outputPath string, opts *Options) error {
 	// Create output directory if it doesn't exist
 	dir := filepath.Dir(outputPath)
 	//nolint:gosec // 0755 needed for proper file access
@@ -42,13 +55,49 @@ func GenerateEPUB(fb2 *models.FictionBook, outputPath string) error {
 		}
 	}()
 
-	zipWriter := zip.NewWriter(file)
-	defer func() {
-		if closeErr := zipWriter.Close(); closeErr != nil {
-			_ = closeErr
-		}
-	}()
+	return WriteEPUBContext(ctx, fb2, file, opts)
+}
+
+// WriteEPUB writes an EPUB built from an FB2 book to w
+func WriteEPUB(fb2 *models.FictionBook, w io.Writer, opts *Options) error {
+	return WriteEPUBContext(context.Background(), fb2, w, opts)
+}
+
+// WriteEPUBContext writes an EPUB like WriteEPUB, giving up once ctx is done
+func WriteEPUBContext(ctx context.Context, fb2 *models.FictionBook, w io.Writer, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	fb2 = applyMetadataOverrides(fb2, &opts.Metadata)
+	if opts.PruneImages {
+		fb2, _ = pruneUnreferencedBinaries(fb2, !opts.DisableCoverDetection)
+	}
+
+	ctx, span := startSpan(ctx, "generate", attribute.String("output.format", "epub"))
+	err := writeEPUBArchive(ctx, fb2, w, opts)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	opts.reportProgress(StageDone, 100)
+	return nil
+}
+
+// writeEPUBArchive writes the entries of an EPUB to a ZIP archive on w
+func writeEPUBArchive(ctx context.Context, fb2 *models.FictionBook, w io.Writer, opts *Options) error {
+	zipWriter := zip.NewWriter(&contextWriter{ctx: ctx, w: w})
+	if err := writeEPUBEntries(ctx, zipWriter, fb2, opts); err != nil {
+		_ = zipWriter.Close()
+		return contextError(ctx, err)
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return contextError(ctx, fmt.Errorf("failed to finalize EPUB archive: %w", err))
+	}
+	return nil
+}
 
+func writeEPUBEntries(ctx context.Context, zipWriter *zip.Writer, fb2 *models.FictionBook, opts *Options) (err error) {
 	// Add mimetype file (must be first, uncompressed)
 	if err := addMimetype(zipWriter); err != nil {
 		return err
@@ -59,35 +108,74 @@ func GenerateEPUB(fb2 *models.FictionBook, outputPath string) error {
 		return err
 	}
 
+	// Each stage is traced as a span, ended when the next one starts
+	_, span := startSpan(ctx, "images")
+	defer func() { endSpan(span, err) }()
+
 	// Collect images first (needed for manifest)
-	imageMap := collectImages(fb2)
+	opts.reportProgress(StageImages, 20)
+	imageMap := collectImages(fb2, opts)
+	span.SetAttributes(attribute.Int("epub.images", len(imageMap)))
+
+	reportMissingImages(fb2, imageMap, opts)
+
+	// Guess a cover when the book doesn't declare one
+	if coverImageID(fb2, imageMap) == "" && !opts.DisableCoverDetection {
+		if coverID := detectCoverImage(fb2, imageMap); coverID != "" {
+			fb2.Description.TitleInfo.Coverpage = &models.Coverpage{
+				Image: []models.Image{{Href: "#" + coverID}},
+			}
+		}
+	}
+	if coverImageID(fb2, imageMap) == "" && opts.GenerateCover {
+		addGeneratedCover(fb2, imageMap, opts)
+	}
+
+	// The unique identifier is shared by the OPF, the NCX and font obfuscation
+	bookID := "urn:uuid:" + generateUUID()
+	fonts := prepareFonts(opts.Fonts)
+
+	templates, err := loadTemplates(opts.TemplateDir)
+	if err != nil {
+		return err
+	}
 
 	// Add OEBPS/content.opf (package document)
-	if err := addContentOPF(zipWriter, fb2, imageMap); err != nil {
+	span.End()
+	_, span = startSpan(ctx, "packaging")
+	opts.reportProgress(StagePackaging, 40)
+	if err := addContentOPF(zipWriter, templates, fb2, imageMap, fonts, bookID, opts); err != nil {
 		return err
 	}
 
-	// Add OEBPS/toc.ncx (navigation)
-	if err := addTOCNCX(zipWriter, fb2); err != nil {
+	// Add HTML content files (need imageMap for image references).
+	// Content goes first because navigation lists the page breaks placed in it.
+	opts.reportProgress(StageContent, 60)
+	pages, err := addHTMLContent(zipWriter, templates, fb2, imageMap, fonts, opts)
+	if err != nil {
 		return err
 	}
 
-	// Add EPUB 3.0 nav document
-	if err := addNavXHTML(zipWriter, fb2); err != nil {
+	// Add OEBPS/toc.ncx (navigation)
+	if err := addTOCNCX(zipWriter, templates, fb2, bookID, opts, pages); err != nil {
 		return err
 	}
 
-	// Add HTML content files (need imageMap for image references)
-	if err := addHTMLContent(zipWriter, fb2, imageMap); err != nil {
+	// Add EPUB 3.0 nav document
+	if err := addNavXHTML(zipWriter, templates, fb2, opts, pages); err != nil {
 		return err
 	}
 
 	// Add binary resources (images)
+	span.End()
+	_, span = startSpan(ctx, "resources")
+	opts.reportProgress(StageResources, 80)
 	if err := addBinaryResources(zipWriter, fb2, imageMap); err != nil {
 		return err
 	}
 
-	return nil
+	// Add embedded fonts
+	return addFontResources(zipWriter, fonts, bookID, opts.ObfuscateFonts)
 }
 
 func addMimetype(writer *zip.Writer) error {
@@ -122,16 +210,25 @@ func addContainer(writer *zip.Writer) error {
 	return err
 }
 
-func addContentOPF(writer *zip.Writer, fb2 *models.FictionBook, imageMap map[string]*ImageInfo) error {
+func addContentOPF(
+	writer *zip.Writer,
+	templates *template.Template,
+	fb2 *models.FictionBook,
+	imageMap map[string]*ImageInfo,
+	fonts []embeddedFont,
+	bookID string,
+	opts *Options,
+) error {
 	w, err := writer.Create("OEBPS/content.opf")
 	if err != nil {
 		return err
 	}
 
 	// Extract metadata
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
 	title := fb2.Description.TitleInfo.BookTitle
 	if title == "" {
-		title = defaultTitle
+		title = l.Untitled
 	}
 
 	authors := make([]string, 0)
@@ -143,78 +240,176 @@ func addContentOPF(writer *zip.Writer, fb2 *models.FictionBook, imageMap map[str
 	}
 	authorStr := strings.Join(authors, ", ")
 	if authorStr == "" {
-		authorStr = defaultAuthor
+		authorStr = l.UnknownAuthor
 	}
 
-	lang := fb2.Description.TitleInfo.Lang
-	if lang == "" {
-		lang = "en"
-	}
+	lang := bookLanguage(fb2)
 
-	uuid := "urn:uuid:" + generateUUID()
 	date := time.Now().Format("2006-01-02")
 
-	// Build manifest items
-	manifestItems := `<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml" properties="nav"/>
-    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
-    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>
-    <item id="content" href="content.xhtml" media-type="application/xhtml+xml"/>`
+	backMatter := collectBackMatter(fb2, opts.Notes)
+	manifestItems := writeManifestItems(buildManifest(fb2, imageMap, backMatter, fonts, opts.Colophon))
+	guide := writeGuide(buildLandmarks(backMatter, l))
 
-	// Add image items to manifest
-	for imgID, imgInfo := range imageMap {
-		ext := getImageExtension(imgInfo.ContentType)
-		manifestItems += fmt.Sprintf("\n    <item id=\"%s\" href=\"images/%s%s\" "+
-			"media-type=\"%s\"/>", imgID, imgID, ext, imgInfo.ContentType)
+	// Build spine
+	spine := `<itemref idref="cover"/>`
+	if hasAnnotation(fb2) {
+		spine += "\n    <itemref idref=\"annotation\"/>"
+	}
+	spine += "\n    <itemref idref=\"content\"/>"
+	for _, bm := range backMatter {
+		if bm.Linear {
+			spine += fmt.Sprintf("\n    <itemref idref=\"%s\"/>", bm.ID)
+		} else {
+			spine += fmt.Sprintf("\n    <itemref idref=\"%s\" linear=\"no\"/>", bm.ID)
+		}
+	}
+	if opts.Colophon {
+		spine += "\n    <itemref idref=\"colophon\"/>"
 	}
 
-	// Build spine
-	spine := `<itemref idref="cover"/>
-    <itemref idref="content"/>`
-
-	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
-  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
-    <dc:title>%s</dc:title>
-    <dc:creator>%s</dc:creator>
-    <dc:language>%s</dc:language>
-    <dc:identifier id="bookid">%s</dc:identifier>
-    <meta property="dcterms:modified">%s</meta>
-  </metadata>
-  <manifest>
-    %s
-  </manifest>
-  <spine toc="ncx">
-    %s
-  </spine>
-</package>`, html.EscapeString(title), html.EscapeString(authorStr), lang, uuid, date, manifestItems, spine)
+	// Build optional metadata
+	var extraMeta strings.Builder
+	if description := annotationText(fb2.Description.TitleInfo.Annotation); description != "" {
+		fmt.Fprintf(&extraMeta, "\n    <dc:description>%s</dc:description>", html.EscapeString(description))
+	}
+	for _, genre := range genreNamesFor(fb2.Description.TitleInfo.Genre, lang) {
+		fmt.Fprintf(&extraMeta, "\n    <dc:subject>%s</dc:subject>", html.EscapeString(genre))
+	}
+	if coverID := coverImageID(fb2, imageMap); coverID != "" {
+		fmt.Fprintf(&extraMeta, "\n    <meta name=\"cover\" content=\"%s\"/>", html.EscapeString(coverID))
+	}
+	extraMeta.WriteString(buildContributorMeta(fb2))
+	extraMeta.WriteString(buildOriginalMeta(fb2, title))
+	if sequence := fb2.Description.TitleInfo.Sequence; len(sequence) > 0 {
+		extraMeta.WriteString(buildSeriesMeta(sequence[0].Name, sequence[0].Number))
+	}
+	extraMeta.WriteString(buildAccessibilityMeta(fb2, imageMap, opts))
+
+	content, err := renderTemplate(templates, opfTemplate, opfData{
+		Title:    title,
+		Author:   authorStr,
+		Language: lang,
+		BookID:   bookID,
+		Modified: date,
+		Metadata: markup(extraMeta.String()),
+		Manifest: markup(manifestItems),
+		Spine:    markup(spine),
+		Guide:    markup(guide),
+	})
+	if err != nil {
+		return err
+	}
 
 	_, err = w.Write([]byte(content))
 	return err
 }
 
+// Roles of contributors, as MARC relator codes
+const (
+	roleTranslator   = "trl"
+	roleBookProducer = "bkp" // Whoever produced the FB2 document, e.g. by scanning or typesetting
+)
+
+// buildContributorMeta lists the translators of the book and the authors of the
+// FB2 document as contributors, each refined with its MARC relator role
+func buildContributorMeta(fb2 *models.FictionBook) string {
+	var meta strings.Builder
+	count := 0
+	add := func(authors []models.Author, role string) {
+		for _, author := range authors {
+			name := buildAuthorName(author)
+			if name == "" {
+				continue
+			}
+			count++
+			fmt.Fprintf(&meta, "\n    <dc:contributor id=\"contributor-%d\">%s</dc:contributor>", count, html.EscapeString(name))
+			fmt.Fprintf(&meta, "\n    <meta refines=\"#contributor-%d\" property=\"role\" scheme=\"marc:relators\">%s</meta>", count, role)
+		}
+	}
+	add(fb2.Description.TitleInfo.Translator, roleTranslator)
+	add(fb2.Description.DocumentInfo.Author, roleBookProducer)
+	return meta.String()
+}
+
+// buildSeriesMeta returns EPUB 3 collection metadata plus the calibre series
+// extension understood by most reading apps
+func buildSeriesMeta(series, index string) string {
+	series = strings.TrimSpace(series)
+	if series == "" {
+		return ""
+	}
+
+	var meta strings.Builder
+	escapedSeries := html.EscapeString(series)
+	fmt.Fprintf(&meta, "\n    <meta property=\"belongs-to-collection\" id=\"series\">%s</meta>", escapedSeries)
+	meta.WriteString("\n    <meta refines=\"#series\" property=\"collection-type\">series</meta>")
+	fmt.Fprintf(&meta, "\n    <meta name=\"calibre:series\" content=\"%s\"/>", escapedSeries)
+
+	if index = strings.TrimSpace(index); index != "" {
+		escapedIndex := html.EscapeString(index)
+		fmt.Fprintf(&meta, "\n    <meta refines=\"#series\" property=\"group-position\">%s</meta>", escapedIndex)
+		fmt.Fprintf(&meta, "\n    <meta name=\"calibre:series_index\" content=\"%s\"/>", escapedIndex)
+	}
+
+	return meta.String()
+}
+
+// coverImageID returns the ID of the stored image referenced by the coverpage element,
+// or an empty string if the book has no usable cover image
+func coverImageID(fb2 *models.FictionBook, imageMap map[string]*ImageInfo) string {
+	coverpage := fb2.Description.TitleInfo.Coverpage
+	if coverpage == nil {
+		return ""
+	}
+	for _, image := range coverpage.Image {
+		imgID := strings.TrimPrefix(image.Href, "#")
+		if info, exists := imageMap[imgID]; exists {
+			return info.ID
+		}
+	}
+	return ""
+}
+
 // TOCEntry represents a table of contents entry
 type TOCEntry struct {
 	ID        string
+	File      string // XHTML file containing the entry; content.xhtml if empty
 	Title     string
 	PlayOrder int
 	Children  []*TOCEntry
 }
 
-func addTOCNCX(writer *zip.Writer, fb2 *models.FictionBook) error {
+// Href returns the link target of the entry relative to the OEBPS directory
+func (e *TOCEntry) Href() string {
+	file := e.File
+	if file == "" {
+		file = "content.xhtml"
+	}
+	return file + "#" + e.ID
+}
+
+func addTOCNCX(
+	writer *zip.Writer,
+	templates *template.Template,
+	fb2 *models.FictionBook,
+	bookID string,
+	opts *Options,
+	pages []pageMarker,
+) error {
 	w, err := writer.Create("OEBPS/toc.ncx")
 	if err != nil {
 		return err
 	}
 
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
 	title := fb2.Description.TitleInfo.BookTitle
 	if title == "" {
-		title = "Untitled"
+		title = l.Untitled
 	}
 
-	uuid := "urn:uuid:" + generateUUID()
-
 	// Build TOC from sections
-	tocEntries := buildTOC(fb2)
+	tocEntries := buildShapedTOC(fb2, opts)
 
 	// Calculate depth
 	maxDepth := calculateTOCDepth(tocEntries, 0)
@@ -229,21 +424,33 @@ func addTOCNCX(writer *zip.Writer, fb2 *models.FictionBook) error {
 	// Add cover
 	navMap.WriteString(fmt.Sprintf(`    <navPoint id="navpoint-%d" playOrder="%d">
       <navLabel>
-        <text>Cover</text>
+        <text>%s</text>
       </navLabel>
       <content src="cover.xhtml"/>
     </navPoint>
-`, playOrder, playOrder))
+`, playOrder, playOrder, html.EscapeString(l.Cover)))
 	playOrder++
 
+	// Add annotation page
+	if hasAnnotation(fb2) {
+		navMap.WriteString(fmt.Sprintf(`    <navPoint id="navpoint-%d" playOrder="%d">
+      <navLabel>
+        <text>%s</text>
+      </navLabel>
+      <content src="%s"/>
+    </navPoint>
+`, playOrder, playOrder, html.EscapeString(l.AboutBook), annotationFile))
+		playOrder++
+	}
+
 	// Add content entry
 	navMap.WriteString(fmt.Sprintf(`    <navPoint id="navpoint-%d" playOrder="%d">
       <navLabel>
-        <text>Content</text>
+        <text>%s</text>
       </navLabel>
       <content src="content.xhtml"/>
     </navPoint>
-`, playOrder, playOrder))
+`, playOrder, playOrder, html.EscapeString(l.Content)))
 	playOrder++
 
 	// Add all section entries
@@ -251,54 +458,71 @@ func addTOCNCX(writer *zip.Writer, fb2 *models.FictionBook) error {
 		playOrder = writeTOCEntry(&navMap, entry, playOrder, 0)
 	}
 
-	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
-  <head>
-    <meta name="dtb:uid" content="%s"/>
-    <meta name="dtb:depth" content="%d"/>
-    <meta name="dtb:totalPageCount" content="0"/>
-    <meta name="dtb:maxPageNumber" content="0"/>
-  </head>
-  <docTitle>
-    <text>%s</text>
-  </docTitle>
-  <navMap>
-%s  </navMap>
-</ncx>`, uuid, maxDepth+1, html.EscapeString(title), navMap.String())
+	content, err := renderTemplate(templates, ncxTemplate, ncxData{
+		BookID:    bookID,
+		Title:     title,
+		Depth:     maxDepth + 1,
+		PageCount: len(pages),
+		NavMap:    markup(navMap.String()),
+		PageList:  markup(writeNCXPageList(pages, playOrder, l)),
+	})
+	if err != nil {
+		return err
+	}
 
 	_, err = w.Write([]byte(content))
 	return err
 }
 
-func buildTOC(fb2 *models.FictionBook) []*TOCEntry {
+func buildTOC(fb2 *models.FictionBook, opts *Options) []*TOCEntry {
 	var entries []*TOCEntry
 
-	// Process body sections
-	for i := range fb2.Body.Section {
-		section := fb2.Body.Section[i]
-		if entry := buildTOCFromSection(&section, fmt.Sprintf("section-%d", i)); entry != nil {
+	// Process main body sections
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
+	mainBody := fb2.MainBody()
+	for i := range mainBody.Section {
+		section := mainBody.Section[i]
+		if entry := buildTOCFromSection(&section, fmt.Sprintf("section-%d", i), i, opts.UntitledSections, l); entry != nil {
 			entries = append(entries, entry)
 		}
 	}
 
+	// Back-matter bodies get a single entry each
+	for _, bm := range collectBackMatter(fb2, opts.Notes) {
+		entries = append(entries, &TOCEntry{
+			ID:    bm.ID,
+			File:  bm.File,
+			Title: bm.Title,
+		})
+	}
+
 	return entries
 }
 
-func buildTOCFromSection(section *models.Section, baseID string) *TOCEntry {
+// buildTOCFromSection builds the entry of a section, the index-th of its parent.
+// Untitled sections are titled as the mode says, or only list their children.
+func buildTOCFromSection(
+	section *models.Section,
+	baseID string,
+	index int,
+	untitled UntitledSections,
+	l *labels,
+) *TOCEntry {
 	// Only create TOC entry if section has a title
 	if section.Title == nil || len(section.Title.Paragraph) == 0 {
 		// If no title but has subsections, still process children
 		var children []*TOCEntry
 		for i := range section.Section {
 			subSection := section.Section[i]
-			if child := buildTOCFromSection(&subSection, fmt.Sprintf("%s-sub-%d", baseID, i)); child != nil {
+			if child := buildTOCFromSection(&subSection, fmt.Sprintf("%s-sub-%d", baseID, i), i, untitled, l); child != nil {
 				children = append(children, child)
 			}
 		}
-		if len(children) > 0 {
+		title := untitledSectionTitle(section, index, untitled, l)
+		if title != "" || len(children) > 0 {
 			return &TOCEntry{
 				ID:       baseID,
-				Title:    "", // No title
+				Title:    title,
 				Children: children,
 			}
 		}
@@ -316,14 +540,14 @@ func buildTOCFromSection(section *models.Section, baseID string) *TOCEntry {
 	}
 	title := strings.Join(titleParts, " ")
 	if title == "" {
-		title = "Untitled Section"
+		title = l.UntitledSection
 	}
 
 	// Process children
 	var children []*TOCEntry
 	for i := range section.Section {
 		subSection := section.Section[i]
-		if child := buildTOCFromSection(&subSection, fmt.Sprintf("%s-sub-%d", baseID, i)); child != nil {
+		if child := buildTOCFromSection(&subSection, fmt.Sprintf("%s-sub-%d", baseID, i), i, untitled, l); child != nil {
 			children = append(children, child)
 		}
 	}
@@ -349,8 +573,9 @@ func writeTOCEntry(builder *strings.Builder, entry *TOCEntry, playOrder int, ind
 %s  <navLabel>
 %s    <text>%s</text>
 %s  </navLabel>
-%s  <content src="content.xhtml#%s"/>
-`, indentStr, entry.ID, currentOrder, indentStr, indentStr, escapedTitle, indentStr, indentStr, entry.ID)
+%s  <content src="%s"/>
+`, indentStr, entry.ID, currentOrder, indentStr, indentStr, escapedTitle, indentStr, indentStr,
+			html.EscapeString(entry.Href()))
 
 		currentOrder++
 
@@ -382,29 +607,122 @@ func calculateTOCDepth(entries []*TOCEntry, currentDepth int) int {
 	return maxDepth
 }
 
-func addHTMLContent(writer *zip.Writer, fb2 *models.FictionBook, imageMap map[string]*ImageInfo) error {
+// documentProcessor applies the post-processing shared by the content documents:
+// text passes, page breaks, Kobo spans and the embedded font stylesheet
+type documentProcessor struct {
+	passes []textPass
+	pages  *paginator
+	kobo   bool
+	fonts  []embeddedFont
+	lang   string
+
+	// dropCaps styles the opening paragraphs of the sections of the main text
+	dropCaps bool
+
+	// theme adds the styles of a color scheme to every document
+	theme Theme
+
+	templates *template.Template
+
+	// notes links references and notes rendered as endnotes; nil otherwise
+	notes *noteReferences
+}
+
+// process finishes a content document written to file
+func (dp *documentProcessor) process(document, file string) string {
+	document = setDocumentLanguage(document, dp.lang)
+	document = dp.notes.mark(document, file)
+	document = normalizeHeadings(document)
+	document = applyTextPasses(document, dp.passes)
+	if dp.dropCaps && file == "content.xhtml" {
+		document = addDropCaps(document)
+	}
+	document = dp.pages.paginate(document, file)
+	if dp.kobo {
+		document = addKoboSpans(document)
+	}
+	document = applyTheme(document, dp.theme)
+	return linkStylesheet(document, dp.fonts)
+}
+
+// addHTMLContent writes the cover, main content and back-matter documents and
+// returns the page breaks placed in them, if pagination is enabled
+func addHTMLContent(
+	writer *zip.Writer,
+	templates *template.Template,
+	fb2 *models.FictionBook,
+	imageMap map[string]*ImageInfo,
+	fonts []embeddedFont,
+	opts *Options,
+) ([]pageMarker, error) {
 	// Add cover page
-	if err := addCoverPage(writer, fb2, imageMap); err != nil {
-		return err
+	if err := addCoverPage(writer, templates, fb2, imageMap, fonts, opts.Theme); err != nil {
+		return nil, err
+	}
+
+	// Note references may point into back-matter files
+	backMatter := collectBackMatter(fb2, opts.Notes)
+	targets := collectLinkTargets(backMatter)
+	var notes *noteReferences
+	if opts.Notes == NotesEndnotes {
+		notes = newNoteReferences(backMatter)
+	}
+
+	// Optional text post-processing (typography, hyphenation), page breaks, Kobo spans and fonts
+	processor := &documentProcessor{
+		passes:    textPassesFor(fb2.Description.TitleInfo.Lang, opts),
+		pages:     newPaginator(opts.PageLength),
+		kobo:      opts.Kepub,
+		dropCaps:  opts.DropCaps,
+		theme:     opts.Theme,
+		fonts:     fonts,
+		lang:      bookLanguage(fb2),
+		templates: templates,
+		notes:     notes,
+	}
+
+	// Add the annotation page between the cover and the text
+	if err := addAnnotationPage(writer, fb2, imageMap, targets, processor); err != nil {
+		return nil, err
 	}
 
 	// Add main content
-	if err := addMainContent(writer, fb2, imageMap); err != nil {
-		return err
+	if err := addMainContent(writer, fb2, imageMap, targets, processor); err != nil {
+		return nil, err
 	}
 
-	return nil
+	// Add back-matter bodies (notes, comments)
+	if err := addBackMatter(writer, backMatter, targets, imageMap, processor); err != nil {
+		return nil, err
+	}
+
+	// Add the colophon after everything else
+	if opts.Colophon {
+		if err := addColophonPage(writer, fb2, processor); err != nil {
+			return nil, err
+		}
+	}
+
+	return processor.pages.Pages(), nil
 }
 
-func addCoverPage(writer *zip.Writer, fb2 *models.FictionBook, _ map[string]*ImageInfo) error {
+func addCoverPage(
+	writer *zip.Writer,
+	templates *template.Template,
+	fb2 *models.FictionBook,
+	imageMap map[string]*ImageInfo,
+	fonts []embeddedFont,
+	theme Theme,
+) error {
 	w, err := writer.Create("OEBPS/cover.xhtml")
 	if err != nil {
 		return err
 	}
 
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
 	title := fb2.Description.TitleInfo.BookTitle
 	if title == "" {
-		title = "Untitled"
+		title = l.Untitled
 	}
 
 	authors := make([]string, 0)
@@ -416,75 +734,91 @@ func addCoverPage(writer *zip.Writer, fb2 *models.FictionBook, _ map[string]*Ima
 	}
 	authorStr := strings.Join(authors, ", ")
 	if authorStr == "" {
-		authorStr = defaultAuthor
-	}
-
-	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE html>
-<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
-<head>
-  <title>%s</title>
-  <style type="text/css">
-    body { text-align: center; padding: 2em; font-family: serif; }
-    h1 { margin-top: 3em; }
-    h2 { margin-top: 2em; color: #666; }
-  </style>
-</head>
-<body>
-  <h1>%s</h1>
-  <h2>%s</h2>
-</body>
-</html>`, html.EscapeString(title), html.EscapeString(title), html.EscapeString(authorStr))
+		authorStr = l.UnknownAuthor
+	}
 
-	_, err = w.Write([]byte(content))
+	// Use the cover image when the book has one, otherwise fall back to a text cover
+	original := originalOf(fb2)
+	cover := coverData{
+		Title:         title,
+		Author:        authorStr,
+		Genres:        genreNamesFor(fb2.Description.TitleInfo.Genre, fb2.Description.TitleInfo.Lang),
+		OriginalLabel: l.Original,
+		Original:      original.attribution(),
+		OriginalLang:  original.Language,
+	}
+	if coverID := coverImageID(fb2, imageMap); coverID != "" {
+		cover.Image = imageMap[coverID].Path()
+	}
+
+	content, err := renderTemplate(templates, coverTemplate, cover)
+	if err != nil {
+		return err
+	}
+	content = setDocumentLanguage(content, bookLanguage(fb2))
+	content = applyTheme(content, theme)
+
+	_, err = w.Write([]byte(linkStylesheet(content, fonts)))
 	return err
 }
 
-func addMainContent(writer *zip.Writer, fb2 *models.FictionBook, imageMap map[string]*ImageInfo) error {
+func addMainContent(
+	writer *zip.Writer,
+	fb2 *models.FictionBook,
+	imageMap map[string]*ImageInfo,
+	targets map[string]string,
+	processor *documentProcessor,
+) error {
 	w, err := writer.Create("OEBPS/content.xhtml")
 	if err != nil {
 		return err
 	}
 
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
+
 	var bodyContent strings.Builder
-	bodyContent.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE html>
-<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
-<head>
-  <title>Content</title>
-  <style type="text/css">
-    body { font-family: serif; padding: 1em; line-height: 1.6; }
-    h1, h2, h3 { margin-top: 1.5em; }
-    p { margin: 1em 0; text-align: justify; }
-    .empty-line { height: 1em; }
-    strong { font-weight: bold; }
-    em { font-style: italic; }
-  </style>
-</head>
-<body>
-`)
-
-	// Process body title if present
-	if len(fb2.Body.Title.Paragraph) > 0 {
-		for i := range fb2.Body.Title.Paragraph {
-			p := fb2.Body.Title.Paragraph[i]
-			text := processParagraph(&p, imageMap)
-			bodyContent.WriteString(fmt.Sprintf("<h1>%s</h1>\n", text))
+
+	// The body may open with an image, such as a frontispiece, before its title
+	mainBody := fb2.MainBody()
+	for _, image := range mainBody.Image {
+		if img := imageHTML(image, imageMap); img != "" {
+			fmt.Fprintf(&bodyContent, "<div class=\"illustration\">%s</div>\n", img)
 		}
 	}
 
-	// Process body sections
-	for i := range fb2.Body.Section {
-		processSectionWithID(&bodyContent, &fb2.Body.Section[i], 0, i, "", imageMap)
+	// Process body title if present; section titles then start one level below it
+	depth := 0
+	if text := joinTitleParagraphs(&mainBody.Title, imageMap); text != "" {
+		fmt.Fprintf(&bodyContent, "<h1>%s</h1>\n", text)
+		depth = 1
 	}
 
-	bodyContent.WriteString(`</body>
-</html>`)
+	// Epigraphs of the whole book come before the first chapter
+	for i := range mainBody.Epigraph {
+		processEpigraph(&bodyContent, &mainBody.Epigraph[i], depth+1, imageMap)
+	}
+
+	// Process body sections
+	for i := range mainBody.Section {
+		processSectionWithID(&bodyContent, &mainBody.Section[i], depth, i, "", imageMap)
+	}
 
-	_, err = w.Write([]byte(bodyContent.String()))
+	content, err := renderTemplate(processor.templates, contentTemplate, pageData{
+		Title: l.Content,
+		Body:  markup(bodyContent.String()),
+	})
+	if err != nil {
+		return err
+	}
+	content = rewriteInternalLinks(content, targets, "content.xhtml")
+	content = processor.process(content, "content.xhtml")
+	_, err = w.Write([]byte(content))
 	return err
 }
 
+// processSectionWithID writes a section and its subsections. Depth counts the
+// titled sections above it, so like in the table of contents untitled sections
+// add no heading level; the title of the section becomes an h(depth+1).
 func processSectionWithID(
 	builder *strings.Builder,
 	section *models.Section,
@@ -500,242 +834,128 @@ func processSectionWithID(
 		sectionID = fmt.Sprintf("section-%d", sectionIndex)
 	}
 
-	// Add title if present
-	if section.Title != nil && len(section.Title.Paragraph) > 0 {
-		level := depth + 1
-		if level > 6 {
-			level = 6
-		}
-		tag := fmt.Sprintf("h%d", level)
-		for i := range section.Title.Paragraph {
-			p := section.Title.Paragraph[i]
-			text := processParagraph(&p, nil) // Titles don't need images
-			// Ensure sectionID is safe for XML (no special characters)
-			safeID := html.EscapeString(sectionID)
-			fmt.Fprintf(builder, "<%s id=\"%s\">%s</%s>\n", tag, safeID, text, tag)
-		}
-	}
-
-	// Add paragraphs
-	for i := range section.Paragraph {
-		p := section.Paragraph[i]
-		text := processParagraph(&p, imageMap)
-		if text != "" {
-			fmt.Fprintf(builder, "<p>%s</p>\n", text)
-		}
+	// Chapters, the sections at the top of the body, are wrapped so they start
+	// on a new page; so is a section in another language than the book, to declare it
+	lang := langAttrs(section.Lang)
+	switch {
+	case parentID == "":
+		fmt.Fprintf(builder, "<div class=\"chapter\"%s>\n", lang)
+		defer builder.WriteString("</div>\n")
+	case lang != "":
+		fmt.Fprintf(builder, "<div%s>\n", lang)
+		defer builder.WriteString("</div>\n")
 	}
 
-	// Add empty lines
-	for range section.EmptyLine {
-		builder.WriteString(`<div class="empty-line"></div>` + "\n")
+	// Keep the FB2 section ID as an anchor so links (e.g. note references) resolve
+	if section.ID != "" {
+		fmt.Fprintf(builder, "<a id=\"%s\"></a>\n", html.EscapeString(section.ID))
 	}
 
-	// Process nested sections
-	for i := range section.Section {
-		processSectionWithID(builder, &section.Section[i], depth+1, i, sectionID, imageMap)
+	// Add title if present; untitled sections get an anchor the table of contents can link to
+	if section.Title == nil || len(section.Title.Paragraph) == 0 {
+		fmt.Fprintf(builder, "<a id=\"%s\"></a>\n", html.EscapeString(sectionID))
+	} else {
+		tag := headingTag(depth + 1)
+		// One heading per title, so the outline and the section ID stay unique
+		text := joinTitleParagraphs(section.Title, nil) // Titles don't need images
+		// Ensure sectionID is safe for XML (no special characters)
+		safeID := html.EscapeString(sectionID)
+		fmt.Fprintf(builder, "<%s id=\"%s\">%s</%s>\n", tag, safeID, text, tag)
 	}
 
-	// Process poems
-	for i := range section.Poem {
-		poem := section.Poem[i]
-		processPoem(builder, &poem)
+	// Children of a titled section rank below its title
+	childDepth := depth
+	if section.Title != nil && len(section.Title.Paragraph) > 0 {
+		childDepth++
 	}
 
-	// Process citations
-	for i := range section.Cite {
-		cite := section.Cite[i]
-		processCite(builder, &cite, imageMap)
+	// Add the children in document order, so poems, citations, spacing and
+	// subsections stay where the author put them
+	for _, block := range section.OrderedBlocks() {
+		switch block.Kind {
+		case models.ParagraphBlock:
+			p := &section.Paragraph[block.Index]
+			if text := processParagraph(p, imageMap); text != "" {
+				fmt.Fprintf(builder, "<p%s>%s</p>\n", langAttrs(p.Lang), text)
+			}
+		case models.EmptyLineBlock:
+			builder.WriteString(`<div class="empty-line"></div>` + "\n")
+		case models.SubsectionBlock:
+			processSectionWithID(builder, &section.Section[block.Index], childDepth, block.Index, sectionID, imageMap)
+		case models.PoemBlock:
+			// Poem titles rank below the section title
+			processPoem(builder, &section.Poem[block.Index], childDepth+1, imageMap)
+		case models.CiteBlock:
+			processCite(builder, &section.Cite[block.Index], childDepth+1, imageMap)
+		case models.ImageBlock:
+			// Images outside paragraphs are full-page illustrations on a page of their own
+			if img := imageHTML(section.Image[block.Index], imageMap); img != "" {
+				fmt.Fprintf(builder, "<div class=\"illustration\">%s</div>\n", img)
+			}
+		}
 	}
 }
 
 // processParagraph processes a paragraph and preserves all text attributes
 func processParagraph(p *models.Paragraph, imageMap map[string]*ImageInfo) string {
-	var result strings.Builder
+	return renderInline(p.OrderedContent(), p.Strong, p.Emphasis, p.Link, p.Image, imageMap)
+}
 
-	// Start with base text
-	if p.Text != "" {
-		result.WriteString(html.EscapeString(p.Text))
-	}
-
-	// Process inline elements in order
-	// Note: Go's XML unmarshaling doesn't preserve exact order of mixed content,
-	// but we process elements to preserve their attributes
-
-	// Process links first (they might be nested in strong/emphasis)
-	for i := range p.Link {
-		link := p.Link[i]
-		linkHTML := processLink(&link, imageMap)
-		// Try to find and replace the link text in the paragraph text
-		if link.Text != "" {
-			escapedLinkText := html.EscapeString(link.Text)
-			current := result.String()
-			if strings.Contains(current, escapedLinkText) {
-				// Replace the text with the link HTML
-				result.Reset()
-				result.WriteString(strings.Replace(current, escapedLinkText, linkHTML, 1))
-			} else {
-				// If not found, append it
-				result.WriteString(" " + linkHTML)
-			}
-		} else {
-			result.WriteString(" " + linkHTML)
-		}
-	}
-
-	// Process strong elements (may contain nested elements)
-	for i := range p.Strong {
-		strong := p.Strong[i]
-		strongHTML := processStrong(&strong, imageMap)
-		// Try to find and replace
-		if strong.Text != "" || len(strong.Link) > 0 {
-			strongText := extractStrongText(&strong)
-			if strongText != "" {
-				escapedStrongText := html.EscapeString(strongText)
-				current := result.String()
-				if strings.Contains(current, escapedStrongText) {
-					result.Reset()
-					result.WriteString(strings.Replace(current, escapedStrongText, strongHTML, 1))
-				} else {
-					result.WriteString(" " + strongHTML)
-				}
-			} else {
-				result.WriteString(" " + strongHTML)
-			}
-		} else {
-			result.WriteString(" " + strongHTML)
-		}
-	}
-
-	// Process emphasis elements (may contain nested elements)
-	for i := range p.Emphasis {
-		emphasis := p.Emphasis[i]
-		emphasisHTML := processEmphasis(&emphasis, imageMap)
-		// Try to find and replace
-		if emphasis.Text != "" || len(emphasis.Link) > 0 {
-			emphasisText := extractEmphasisText(&emphasis)
-			if emphasisText != "" {
-				escapedEmphasisText := html.EscapeString(emphasisText)
-				current := result.String()
-				if strings.Contains(current, escapedEmphasisText) {
-					result.Reset()
-					result.WriteString(strings.Replace(current, escapedEmphasisText, emphasisHTML, 1))
-				} else {
-					result.WriteString(" " + emphasisHTML)
-				}
-			} else {
-				result.WriteString(" " + emphasisHTML)
-			}
-		} else {
-			result.WriteString(" " + emphasisHTML)
+// renderInline renders mixed content piece by piece in the given order, so
+// the markup of each element lands exactly where the element stood
+func renderInline(
+	content []models.Inline,
+	strong []models.Strong,
+	emphasis []models.Emphasis,
+	links []models.Link,
+	images []models.Image,
+	imageMap map[string]*ImageInfo,
+) string {
+	var result strings.Builder
+	for _, piece := range content {
+		switch piece.Kind {
+		case models.TextInline:
+			result.WriteString(html.EscapeString(piece.Text))
+		case models.StrongInline:
+			result.WriteString(processStrong(&strong[piece.Index], imageMap))
+		case models.EmphasisInline:
+			result.WriteString(processEmphasis(&emphasis[piece.Index], imageMap))
+		case models.LinkInline:
+			result.WriteString(processLink(&links[piece.Index], imageMap))
+		case models.ImageInline:
+			result.WriteString(imageHTML(images[piece.Index], imageMap))
 		}
 	}
+	return result.String()
+}
 
-	// Process images - insert inline
-	for _, image := range p.Image {
-		href := html.EscapeString(image.Href)
-		imgID := strings.TrimPrefix(href, "#")
-
-		var imgPath string
-		if imageMap != nil {
-			if imgInfo, exists := imageMap[imgID]; exists {
-				ext := getImageExtension(imgInfo.ContentType)
-				imgPath = fmt.Sprintf("images/%s%s", imgID, ext)
-			} else {
-				imgPath = fmt.Sprintf("images/%s.jpg", imgID)
-			}
-		} else {
-			imgPath = fmt.Sprintf("images/%s.jpg", imgID)
+// imageHTML returns the img element of an inline image. Without an image map
+// (tables of contents, previews) images are left out; an image whose binary is
+// missing becomes a short note, as an img pointing at no file breaks the EPUB.
+func imageHTML(image models.Image, imageMap map[string]*ImageInfo) string {
+	if imageMap == nil {
+		return ""
+	}
+	imgInfo, exists := imageMap[strings.TrimPrefix(image.Href, "#")]
+	if !exists {
+		note := "[Image unavailable]"
+		if alt := strings.TrimSpace(image.Alt); alt != "" {
+			note = "[Image: " + alt + "]"
 		}
-		result.WriteString(fmt.Sprintf(" <img src=\"%s\" alt=\"\"/>", html.EscapeString(imgPath)))
+		return fmt.Sprintf(`<span class="missing-image">%s</span>`, html.EscapeString(note))
 	}
-
-	return result.String()
+	return fmt.Sprintf("<img src=\"%s\" alt=\"%s\"/>",
+		html.EscapeString(imgInfo.Path()), html.EscapeString(image.Alt))
 }
 
 // processStrong processes a strong element and its nested content
 func processStrong(s *models.Strong, imageMap map[string]*ImageInfo) string {
-	var result strings.Builder
-
-	if s.Text != "" {
-		result.WriteString(html.EscapeString(s.Text))
-	}
-
-	// Process nested links
-	for i := range s.Link {
-		link := s.Link[i]
-		linkHTML := processLink(&link, imageMap)
-		if s.Text != "" && link.Text != "" {
-			escapedLinkText := html.EscapeString(link.Text)
-			current := result.String()
-			if strings.Contains(current, escapedLinkText) {
-				result.Reset()
-				result.WriteString(strings.Replace(current, escapedLinkText, linkHTML, 1))
-			} else {
-				result.WriteString(" " + linkHTML)
-			}
-		} else {
-			result.WriteString(linkHTML)
-		}
-	}
-
-	// Process nested emphasis
-	for i := range s.Emphasis {
-		emphasis := s.Emphasis[i]
-		emphasisHTML := processEmphasis(&emphasis, imageMap)
-		result.WriteString(emphasisHTML)
-	}
-
-	// Process nested strong
-	for i := range s.Strong {
-		nestedStrong := s.Strong[i]
-		nestedHTML := processStrong(&nestedStrong, imageMap)
-		result.WriteString(nestedHTML)
-	}
-
-	return "<strong>" + result.String() + "</strong>"
+	return "<strong>" + renderInline(s.OrderedContent(), s.Strong, s.Emphasis, s.Link, nil, imageMap) + "</strong>"
 }
 
 // processEmphasis processes an emphasis element and its nested content
 func processEmphasis(e *models.Emphasis, imageMap map[string]*ImageInfo) string {
-	var result strings.Builder
-
-	if e.Text != "" {
-		result.WriteString(html.EscapeString(e.Text))
-	}
-
-	// Process nested links
-	for i := range e.Link {
-		link := e.Link[i]
-		linkHTML := processLink(&link, imageMap)
-		if e.Text != "" && link.Text != "" {
-			escapedLinkText := html.EscapeString(link.Text)
-			current := result.String()
-			if strings.Contains(current, escapedLinkText) {
-				result.Reset()
-				result.WriteString(strings.Replace(current, escapedLinkText, linkHTML, 1))
-			} else {
-				result.WriteString(" " + linkHTML)
-			}
-		} else {
-			result.WriteString(linkHTML)
-		}
-	}
-
-	// Process nested strong
-	for i := range e.Strong {
-		strong := e.Strong[i]
-		strongHTML := processStrong(&strong, imageMap)
-		result.WriteString(strongHTML)
-	}
-
-	// Process nested emphasis
-	for i := range e.Emphasis {
-		nestedEmphasis := e.Emphasis[i]
-		nestedHTML := processEmphasis(&nestedEmphasis, imageMap)
-		result.WriteString(nestedHTML)
-	}
-
-	return "<em>" + result.String() + "</em>"
+	return "<em>" + renderInline(e.OrderedContent(), e.Strong, e.Emphasis, e.Link, nil, imageMap) + "</em>"
 }
 
 // processLink processes a link element
@@ -750,91 +970,199 @@ func processLink(l *models.Link, _ map[string]*ImageInfo) string {
 
 // extractStrongText extracts the text content from a strong element
 func extractStrongText(s *models.Strong) string {
-	var result strings.Builder
-	result.WriteString(s.Text)
-	for _, link := range s.Link {
-		result.WriteString(link.Text)
-	}
-	for i := range s.Emphasis {
-		emphasis := s.Emphasis[i]
-		result.WriteString(extractEmphasisText(&emphasis))
-	}
-	for i := range s.Strong {
-		nestedStrong := s.Strong[i]
-		result.WriteString(extractStrongText(&nestedStrong))
-	}
-	return result.String()
+	return inlineText(s.OrderedContent(), s.Strong, s.Emphasis, s.Link)
 }
 
 // extractEmphasisText extracts the text content from an emphasis element
 func extractEmphasisText(e *models.Emphasis) string {
+	return inlineText(e.OrderedContent(), e.Strong, e.Emphasis, e.Link)
+}
+
+// inlineText returns the plain text of mixed content in the given order
+func inlineText(content []models.Inline, strong []models.Strong, emphasis []models.Emphasis, links []models.Link) string {
 	var result strings.Builder
-	result.WriteString(e.Text)
-	for _, link := range e.Link {
-		result.WriteString(link.Text)
-	}
-	for i := range e.Strong {
-		strong := e.Strong[i]
-		result.WriteString(extractStrongText(&strong))
-	}
-	for i := range e.Emphasis {
-		nestedEmphasis := e.Emphasis[i]
-		result.WriteString(extractEmphasisText(&nestedEmphasis))
+	for _, piece := range content {
+		switch piece.Kind {
+		case models.TextInline:
+			result.WriteString(piece.Text)
+		case models.StrongInline:
+			result.WriteString(extractStrongText(&strong[piece.Index]))
+		case models.EmphasisInline:
+			result.WriteString(extractEmphasisText(&emphasis[piece.Index]))
+		case models.LinkInline:
+			result.WriteString(links[piece.Index].Text)
+		}
 	}
 	return result.String()
 }
 
-func processPoem(builder *strings.Builder, poem *models.Poem) {
-	builder.WriteString("<div class=\"poem\">\n")
+// processPoem renders a poem whose title is a heading of the given level;
+// stanza titles rank below it
+func processPoem(builder *strings.Builder, poem *models.Poem, level int, imageMap map[string]*ImageInfo) {
+	fmt.Fprintf(builder, "<div class=\"poem\"%s>\n", langAttrs(poem.Lang))
 
+	stanzaLevel := level
 	if poem.Title != nil {
-		builder.WriteString("<h3>")
-		for _, p := range poem.Title.Paragraph {
-			builder.WriteString(html.EscapeString(p.Text))
+		if title := joinTitleParagraphs(poem.Title, imageMap); title != "" {
+			tag := headingTag(level)
+			fmt.Fprintf(builder, "<%s class=\"poem-title\">%s</%s>\n", tag, title, tag)
+			stanzaLevel = level + 1
 		}
-		builder.WriteString("</h3>\n")
 	}
 
-	for _, stanza := range poem.Stanza {
+	for i := range poem.Epigraph {
+		processEpigraph(builder, &poem.Epigraph[i], stanzaLevel, imageMap)
+	}
+
+	for i := range poem.Stanza {
+		stanza := &poem.Stanza[i]
 		builder.WriteString("<div class=\"stanza\">\n")
+		if stanza.Title != nil {
+			if title := joinTitleParagraphs(stanza.Title, imageMap); title != "" {
+				tag := headingTag(stanzaLevel)
+				fmt.Fprintf(builder, "<%s class=\"stanza-title\">%s</%s>\n", tag, title, tag)
+			}
+		}
+		if stanza.Subtitle != nil {
+			if subtitle := processParagraph(stanza.Subtitle, imageMap); subtitle != "" {
+				fmt.Fprintf(builder, "<p class=\"stanza-subtitle\">%s</p>\n", subtitle)
+			}
+		}
 		for _, verse := range stanza.Verse {
-			fmt.Fprintf(builder, "<p class=\"verse\">%s</p>\n", html.EscapeString(verse.Text))
+			fmt.Fprintf(builder, "<p class=\"verse\"%s>%s</p>\n", langAttrs(verse.Lang), html.EscapeString(verse.Text))
 		}
 		builder.WriteString("</div>\n")
 	}
 
+	writeTextAuthors(builder, poem.TextAuthor, imageMap)
+
+	if date := strings.TrimSpace(poem.Date); date != "" {
+		fmt.Fprintf(builder, "<p class=\"date\">%s</p>\n", html.EscapeString(date))
+	}
+
+	builder.WriteString("</div>\n")
+}
+
+// processEpigraph renders an epigraph with its attribution; the titles of its
+// poems and citations are headings of the given level
+func processEpigraph(builder *strings.Builder, epigraph *models.Epigraph, level int, imageMap map[string]*ImageInfo) {
+	builder.WriteString("<div class=\"epigraph\">\n")
+	for i := range epigraph.Paragraph {
+		p := &epigraph.Paragraph[i]
+		if text := processParagraph(p, imageMap); text != "" {
+			fmt.Fprintf(builder, "<p%s>%s</p>\n", langAttrs(p.Lang), text)
+		}
+	}
+	for i := range epigraph.Poem {
+		processPoem(builder, &epigraph.Poem[i], level, imageMap)
+	}
+	for i := range epigraph.Cite {
+		processCite(builder, &epigraph.Cite[i], level, imageMap)
+	}
+	for range epigraph.EmptyLine {
+		builder.WriteString(`<div class="empty-line"></div>` + "\n")
+	}
+	writeTextAuthors(builder, epigraph.TextAuthor, imageMap)
 	builder.WriteString("</div>\n")
 }
 
-func processCite(builder *strings.Builder, cite *models.Cite, imageMap map[string]*ImageInfo) {
-	builder.WriteString("<blockquote class=\"cite\">\n")
-	for i := range cite.Paragraph {
-		p := cite.Paragraph[i]
-		text := processParagraph(&p, imageMap)
-		fmt.Fprintf(builder, "<p>%s</p>\n", text)
+// writeTextAuthors renders text-author attributions
+func writeTextAuthors(builder *strings.Builder, authors []models.Paragraph, imageMap map[string]*ImageInfo) {
+	for i := range authors {
+		if text := processParagraph(&authors[i], imageMap); text != "" {
+			fmt.Fprintf(builder, "<p class=\"text-author\">%s</p>\n", text)
+		}
+	}
+}
+
+// joinTitleParagraphs renders all paragraphs of a title as one line-broken heading text
+func joinTitleParagraphs(title *models.Title, imageMap map[string]*ImageInfo) string {
+	var parts []string
+	for i := range title.Paragraph {
+		if text := processParagraph(&title.Paragraph[i], imageMap); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "<br/>")
+}
+
+func processCite(builder *strings.Builder, cite *models.Cite, level int, imageMap map[string]*ImageInfo) {
+	if cite.ID != "" {
+		fmt.Fprintf(builder, "<blockquote class=\"cite\" id=\"%s\"%s>\n", html.EscapeString(cite.ID), langAttrs(cite.Lang))
+	} else {
+		fmt.Fprintf(builder, "<blockquote class=\"cite\"%s>\n", langAttrs(cite.Lang))
+	}
+	for _, block := range cite.OrderedBlocks() {
+		switch block.Kind {
+		case models.SubtitleBlock:
+			if text := processParagraph(&cite.Subtitle[block.Index], imageMap); text != "" {
+				fmt.Fprintf(builder, "<p class=\"subtitle\">%s</p>\n", text)
+			}
+		case models.ParagraphBlock:
+			p := &cite.Paragraph[block.Index]
+			fmt.Fprintf(builder, "<p%s>%s</p>\n", langAttrs(p.Lang), processParagraph(p, imageMap))
+		case models.PoemBlock:
+			processPoem(builder, &cite.Poem[block.Index], level, imageMap)
+		case models.EmptyLineBlock:
+			builder.WriteString(`<div class="empty-line"></div>` + "\n")
+		case models.TextAuthorBlock:
+			writeTextAuthors(builder, cite.TextAuthor[block.Index:block.Index+1], imageMap)
+		}
 	}
 	builder.WriteString("</blockquote>\n")
 }
 
 // ImageInfo stores image metadata
 type ImageInfo struct {
+	ID          string // ID of the stored copy; binaries with identical data share it
 	ContentType string
-	Data        []byte
+	Size        int64 // Size of the decoded image in bytes
+
+	encoded string // Base64 data of the binary, decoded while it is written
 }
 
-func collectImages(fb2 *models.FictionBook) map[string]*ImageInfo {
-	imageMap := make(map[string]*ImageInfo)
+// Path returns the location of the stored image relative to the OEBPS directory
+func (i *ImageInfo) Path() string {
+	return "images/" + i.ID + getImageExtension(i.ContentType)
+}
+
+// collectImages describes the book binaries, keyed by binary ID. Binaries with
+// identical data map to a single ImageInfo so the image is stored only once.
+// If several binaries share an ID, the first one is used.
+func collectImages(fb2 *models.FictionBook, opts *Options) map[string]*ImageInfo {
+	// Only the first binary with an ID is used, so only those are decoded
+	binaries := make([]models.Binary, 0, len(fb2.Binary))
+	seen := make(map[string]bool, len(fb2.Binary))
 	for _, binary := range fb2.Binary {
-		// Decode base64 data
-		data, err := base64.StdEncoding.DecodeString(binary.Data)
-		if err != nil {
+		if !seen[binary.ID] {
+			seen[binary.ID] = true
+			binaries = append(binaries, binary)
+		}
+	}
+
+	imageMap := make(map[string]*ImageInfo)
+	byHash := make(map[[sha256.Size]byte]*ImageInfo)
+	for i, decoded := range decodeBinaries(binaries) {
+		binary := binaries[i]
+		if decoded.Err != nil {
 			// Skip invalid base64 data
+			opts.reportWarning(Diagnostic{Message: fmt.Sprintf("skipped binary %q: invalid base64 data", binary.ID)})
 			continue
 		}
-		imageMap[binary.ID] = &ImageInfo{
-			ContentType: binary.ContentType,
-			Data:        data,
+
+		if info, exists := byHash[decoded.Hash]; exists {
+			imageMap[binary.ID] = info
+			continue
 		}
+
+		info := &ImageInfo{
+			ID:          binary.ID,
+			ContentType: imageContentType(binary.ID, binary.ContentType, decoded.Head, opts),
+			Size:        decoded.Size,
+			encoded:     binary.Data,
+		}
+		byHash[decoded.Hash] = info
+		imageMap[binary.ID] = info
 	}
 	return imageMap
 }
@@ -857,17 +1185,20 @@ func getImageExtension(contentType string) string {
 }
 
 func addBinaryResources(writer *zip.Writer, _ *models.FictionBook, imageMap map[string]*ImageInfo) error {
-	// Create images directory entry
 	for imgID, imgInfo := range imageMap {
-		ext := getImageExtension(imgInfo.ContentType)
-		path := fmt.Sprintf("OEBPS/images/%s%s", imgID, ext)
+		// Duplicates point to the stored copy and are not written again
+		if imgID != imgInfo.ID {
+			continue
+		}
+		path := "OEBPS/" + imgInfo.Path()
 
 		w, err := writer.Create(path)
 		if err != nil {
 			return fmt.Errorf("failed to create image file %s: %w", path, err)
 		}
 
-		_, err = w.Write(imgInfo.Data)
+		// Decode straight into the archive, so only one image is in memory at a time
+		_, err = io.Copy(w, binaryReader(imgInfo.encoded))
 		if err != nil {
 			return fmt.Errorf("failed to write image data %s: %w", path, err)
 		}
@@ -0,0 +1,732 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/lex/fb2epub/book"
+)
+
+// epubContainer is META-INF/container.xml, which points at the package document
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the part of the OPF package document needed to read a book
+type epubPackage struct {
+	Metadata struct {
+		Title       []string `xml:"title"`
+		Creator     []string `xml:"creator"`
+		Contributor []struct {
+			ID   string `xml:"id,attr"`
+			Role string `xml:"role,attr"` // EPUB 2 opf:role
+			Name string `xml:",chardata"`
+		} `xml:"contributor"`
+		Language    []string `xml:"language"`
+		Identifier  []string `xml:"identifier"`
+		Date        []string `xml:"date"`
+		Publisher   []string `xml:"publisher"`
+		Subject     []string `xml:"subject"`
+		Description []string `xml:"description"`
+		Meta        []struct {
+			Name     string `xml:"name,attr"`
+			Content  string `xml:"content,attr"`
+			Property string `xml:"property,attr"`
+			Refines  string `xml:"refines,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef  string `xml:"idref,attr"`
+		Linear string `xml:"linear,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// epubReader holds an open EPUB while its documents are mapped into a book
+type epubReader struct {
+	files     map[string]*zip.File
+	limits    *Limits
+	images    map[string]string // Zip path of each image to its resource ID
+	book      *book.Book
+	imageSize int64
+}
+
+// ParseEPUB reads an EPUB into the book model: the package metadata, the
+// spine documents as chapters split at their headings and the images as
+// resources. Documents outside the linear reading order or marked as
+// footnotes become notes. Errors caused by invalid input are returned as
+// *ParseError.
+func ParseEPUB(r io.ReaderAt, size int64) (*book.Book, error) {
+	return parseEPUB(context.Background(), r, size, &Limits{})
+}
+
+func parseEPUB(ctx context.Context, r io.ReaderAt, size int64, limits *Limits) (*book.Book, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, &ParseError{Err: fmt.Errorf("failed to open EPUB: %w", err)}
+	}
+	reader := &epubReader{
+		files:  make(map[string]*zip.File, len(archive.File)),
+		limits: limits,
+		images: make(map[string]string),
+		book:   &book.Book{},
+	}
+	for _, file := range archive.File {
+		reader.files[file.Name] = file
+	}
+
+	var container epubContainer
+	if err := reader.decodeXML("META-INF/container.xml", &container); err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, &ParseError{Err: errors.New("EPUB container lists no package document")}
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := reader.decodeXML(opfPath, &pkg); err != nil {
+		return nil, &ParseError{Err: err}
+	}
+
+	if err := reader.readMetadata(&pkg, path.Dir(opfPath)); err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[string]int, len(pkg.Manifest))
+	for i, item := range pkg.Manifest {
+		manifest[item.ID] = i
+	}
+	for _, itemref := range pkg.Spine {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		i, ok := manifest[itemref.IDRef]
+		if !ok {
+			continue
+		}
+		item := pkg.Manifest[i]
+		if hasProperty(item.Properties, "nav") ||
+			(item.MediaType != "application/xhtml+xml" && item.MediaType != "text/html") {
+			continue
+		}
+		if err := reader.readDocument(resolveEPUBPath(path.Dir(opfPath), item.Href), itemref.Linear == "no"); err != nil {
+			return nil, err
+		}
+	}
+	return reader.book, nil
+}
+
+// readMetadata maps the package metadata and loads the manifest images as resources
+func (r *epubReader) readMetadata(pkg *epubPackage, baseDir string) error {
+	metadata := &pkg.Metadata
+	r.book.Metadata = book.Metadata{
+		Title:      firstNonEmpty(metadata.Title),
+		Language:   firstNonEmpty(metadata.Language),
+		Identifier: firstNonEmpty(metadata.Identifier),
+		Date:       firstNonEmpty(metadata.Date),
+		Publisher:  firstNonEmpty(metadata.Publisher),
+	}
+	for _, creator := range metadata.Creator {
+		if creator = strings.TrimSpace(creator); creator != "" {
+			r.book.Metadata.Authors = append(r.book.Metadata.Authors, creator)
+		}
+	}
+	for _, subject := range metadata.Subject {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			r.book.Metadata.Genres = append(r.book.Metadata.Genres, genreCode(subject))
+		}
+	}
+	if description := firstNonEmpty(metadata.Description); description != "" {
+		for _, line := range strings.Split(description, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				r.book.Metadata.Annotation = append(r.book.Metadata.Annotation,
+					book.Block{Kind: book.Paragraph, Spans: []book.Span{{Text: line}}})
+			}
+		}
+	}
+
+	coverID := ""
+	var series book.Series
+	roles := make(map[string]string) // Contributor ID to the role refining it
+	for _, meta := range metadata.Meta {
+		switch {
+		case meta.Property == "role" && strings.HasPrefix(meta.Refines, "#"):
+			roles[strings.TrimPrefix(meta.Refines, "#")] = strings.TrimSpace(meta.Value)
+		case meta.Name == "cover":
+			coverID = meta.Content
+		case meta.Name == "calibre:series":
+			series.Name = strings.TrimSpace(meta.Content)
+		case meta.Name == "calibre:series_index":
+			series.Number = strings.TrimSpace(meta.Content)
+		case meta.Property == "belongs-to-collection" && series.Name == "":
+			series.Name = strings.TrimSpace(meta.Value)
+		case meta.Property == "group-position" && series.Number == "":
+			series.Number = strings.TrimSpace(meta.Value)
+		}
+	}
+	if series.Name != "" {
+		r.book.Metadata.Series = []book.Series{series}
+	}
+	for _, contributor := range metadata.Contributor {
+		role := contributor.Role
+		if refined, ok := roles[contributor.ID]; ok && contributor.ID != "" {
+			role = refined
+		}
+		if name := strings.TrimSpace(contributor.Name); name != "" && role == roleTranslator {
+			r.book.Metadata.Translators = append(r.book.Metadata.Translators, name)
+		}
+	}
+
+	for _, item := range pkg.Manifest {
+		if !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		id, err := r.loadImage(resolveEPUBPath(baseDir, item.Href), item.MediaType)
+		if err != nil {
+			return err
+		}
+		if id != "" && (item.ID == coverID || hasProperty(item.Properties, "cover-image")) && r.book.Cover == "" {
+			r.book.Cover = id
+		}
+	}
+	return nil
+}
+
+// loadImage adds the image at zipPath as a resource named after its file and
+// returns the resource ID, or "" when the archive does not contain it
+func (r *epubReader) loadImage(zipPath, contentType string) (string, error) {
+	file, ok := r.files[zipPath]
+	if !ok {
+		return "", nil
+	}
+	data, err := r.readFile(file)
+	if err != nil {
+		return "", &ParseError{Err: err}
+	}
+	r.imageSize += int64(len(data))
+	if r.limits.MaxBinarySize > 0 && r.imageSize > r.limits.MaxBinarySize {
+		return "", &LimitError{Limit: "binary size", Max: r.limits.MaxBinarySize}
+	}
+
+	id := path.Base(zipPath)
+	for n := 2; r.book.Resource(id) != nil; n++ {
+		id = fmt.Sprintf("%d-%s", n, path.Base(zipPath))
+	}
+	r.book.Resources = append(r.book.Resources, &book.Resource{ID: id, ContentType: contentType, Data: data})
+	r.images[zipPath] = id
+	return id, nil
+}
+
+func (r *epubReader) readFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer func() {
+		if closeErr := rc.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	return data, nil
+}
+
+func (r *epubReader) decodeXML(name string, v interface{}) error {
+	file, ok := r.files[name]
+	if !ok {
+		return fmt.Errorf("EPUB is missing %s", name)
+	}
+	data, err := r.readFile(file)
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// readDocument maps one XHTML content document into chapters
+func (r *epubReader) readDocument(docPath string, nonLinear bool) error {
+	file, ok := r.files[docPath]
+	if !ok {
+		return nil
+	}
+	data, err := r.readFile(file)
+	if err != nil {
+		return &ParseError{Err: err}
+	}
+
+	doc := &xhtmlDocument{reader: r, dir: path.Dir(docPath), file: path.Base(docPath)}
+	if err := doc.parse(string(data)); err != nil {
+		return err
+	}
+	chapters := doc.chapters
+	if len(chapters) == 0 || doc.frontMatter || doc.isCoverOnly() {
+		return nil
+	}
+	if doc.annotation {
+		if len(r.book.Metadata.Annotation) == 0 {
+			for _, chapter := range chapters {
+				r.book.Metadata.Annotation = append(r.book.Metadata.Annotation, chapter.Blocks...)
+			}
+		}
+		return nil
+	}
+
+	if !nonLinear && !doc.notes {
+		r.book.Chapters = append(r.book.Chapters, chapters...)
+		return nil
+	}
+	// A titled notes document keeps its title; its chapters are the notes
+	if len(chapters) == 1 && chapters[0].Title != "" && len(chapters[0].Children) > 0 {
+		notes := chapters[0]
+		notes.Children = append(blocksChapter(notes.Blocks), notes.Children...)
+		notes.Blocks = nil
+		r.book.Notes = append(r.book.Notes, notes)
+		return nil
+	}
+	r.book.Notes = append(r.book.Notes, &book.Chapter{Children: chapters})
+	return nil
+}
+
+// blocksChapter wraps loose blocks in an untitled chapter
+func blocksChapter(blocks []book.Block) []*book.Chapter {
+	if len(blocks) == 0 {
+		return nil
+	}
+	return []*book.Chapter{{Blocks: blocks}}
+}
+
+// xhtmlTagStyles are the inline elements mapped to span styles
+var xhtmlTagStyles = map[string]book.Style{
+	"b": book.Strong, "strong": book.Strong,
+	"i": book.Emphasis, "em": book.Emphasis, "cite": book.Emphasis, "dfn": book.Emphasis,
+	"s": book.Strikethrough, "strike": book.Strikethrough, "del": book.Strikethrough,
+	"code": book.Code, "tt": book.Code, "kbd": book.Code, "samp": book.Code,
+	"sup": book.Superscript, "sub": book.Subscript,
+}
+
+// xhtmlBlockTags end the current paragraph when they open or close
+var xhtmlBlockTags = map[string]bool{
+	"p": true, "div": true, "li": true, "dt": true, "dd": true, "pre": true, "td": true, "th": true,
+	"tr": true, "table": true, "ul": true, "ol": true, "dl": true, "section": true, "aside": true,
+	"article": true, "header": true, "footer": true, "figure": true, "figcaption": true,
+	"blockquote": true, "br": true, "hr": true,
+}
+
+// xhtmlSkipTags hold no reading content
+var xhtmlSkipTags = map[string]bool{"head": true, "script": true, "style": true, "nav": true}
+
+// xhtmlElement is an open element and the state it changed
+type xhtmlElement struct {
+	name      string
+	style     book.Style
+	href      string
+	container bool
+	heading   bool
+	skip      bool
+}
+
+// xhtmlContainer collects the children of a quote, poem, stanza or epigraph block
+type xhtmlContainer struct {
+	block book.Block
+}
+
+// chapterLevel is an open chapter and the heading level that started it
+type chapterLevel struct {
+	chapter *book.Chapter
+	level   int
+}
+
+// xhtmlDocument maps the body of one XHTML document into chapters
+type xhtmlDocument struct {
+	reader      *epubReader
+	dir         string
+	file        string
+	notes       bool
+	frontMatter bool // Cover, title page or table of contents, which the FB2 rebuilds from metadata
+	annotation  bool // Book description, duplicating the package metadata
+	chapters    []*book.Chapter
+	open        []chapterLevel
+	elements    []xhtmlElement
+	containers  []*xhtmlContainer
+	spans       []book.Span
+	kind        book.BlockKind
+	heading     strings.Builder
+	anchor      string // ID of an anchor waiting for the next heading
+	inBody      bool
+}
+
+func (d *xhtmlDocument) parse(content string) error {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &ParseError{Err: fmt.Errorf("failed to parse %s: %w", d.file, err)}
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if d.reader.limits.MaxDepth > 0 && len(d.elements) >= d.reader.limits.MaxDepth {
+				return &LimitError{Limit: "nesting depth", Max: int64(d.reader.limits.MaxDepth)}
+			}
+			d.start(t)
+		case xml.EndElement:
+			d.end()
+		case xml.CharData:
+			d.text(string(t))
+		}
+	}
+	d.flush()
+	return nil
+}
+
+func (d *xhtmlDocument) start(t xml.StartElement) {
+	name := strings.ToLower(t.Name.Local)
+	element := xhtmlElement{name: name, style: xhtmlTagStyles[name]}
+	class := " " + attrValue(t, "class") + " "
+	epubType := " " + attrValue(t, "type") + " "
+	id := attrValue(t, "id")
+
+	if name == "body" {
+		d.inBody = true
+		d.notes = strings.Contains(epubType, "notes ")
+		d.frontMatter = strings.Contains(epubType, " cover ") || strings.Contains(epubType, " titlepage ") ||
+			strings.Contains(epubType, " toc ")
+	}
+	if strings.Contains(epubType, " preamble ") || strings.Contains(epubType, " abstract ") {
+		d.annotation = true
+	}
+	if !d.inBody || d.skipping() || xhtmlSkipTags[name] {
+		element.skip = xhtmlSkipTags[name]
+		d.elements = append(d.elements, element)
+		return
+	}
+
+	if xhtmlBlockTags[name] {
+		d.flush()
+	}
+	// The first ID before any content names the next chapter, so an anchor
+	// placed in front of a heading wins over the heading's own ID
+	if id != "" && d.anchor == "" && len(d.spans) == 0 && len(d.containers) == 0 {
+		d.anchor = id
+	}
+
+	switch {
+	case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+		d.flush()
+		element.heading = true
+		if len(d.containers) > 0 {
+			element.heading = false
+			d.kind = book.Subtitle
+		}
+	case name == "blockquote":
+		element.container = d.openContainer(book.Quote, id)
+	case (name == "div" || name == "section" || name == "aside") && strings.Contains(class, " epigraph "):
+		element.container = d.openContainer(book.Epigraph, id)
+	case name == "div" && strings.Contains(class, " poem "):
+		element.container = d.openContainer(book.Poem, id)
+	case name == "div" && strings.Contains(class, " stanza "):
+		element.container = d.openContainer(book.Stanza, id)
+	case name == "p" && strings.Contains(class, " verse "):
+		d.kind = book.Verse
+	case name == "p" && strings.Contains(class, " text-author "):
+		d.kind = book.TextAuthor
+	case name == "p" && (strings.Contains(class, " subtitle ") || strings.Contains(class, "-subtitle ")):
+		d.kind = book.Subtitle
+	case name == "div" && strings.Contains(class, " empty-line "):
+		d.appendBlock(book.Block{Kind: book.EmptyLine})
+	case name == "a":
+		element.href = d.resolveHref(attrValue(t, "href"))
+	case name == "img" || name == "image":
+		src := attrValue(t, "src")
+		if name == "image" {
+			src = attrValue(t, "href")
+		}
+		d.image(src)
+	}
+	d.elements = append(d.elements, element)
+}
+
+func (d *xhtmlDocument) end() {
+	if len(d.elements) == 0 {
+		return
+	}
+	element := d.elements[len(d.elements)-1]
+	d.elements = d.elements[:len(d.elements)-1]
+	if element.name == "body" {
+		d.flush()
+		d.inBody = false
+		return
+	}
+	if !d.inBody || element.skip || d.skipping() {
+		return
+	}
+
+	switch {
+	case element.heading:
+		d.closeHeading(int(element.name[1] - '0'))
+	case element.container:
+		d.flush()
+		container := d.containers[len(d.containers)-1]
+		d.containers = d.containers[:len(d.containers)-1]
+		if len(container.block.Children) > 0 {
+			d.appendBlock(container.block)
+		}
+	case xhtmlBlockTags[element.name] || (len(element.name) == 2 && element.name[0] == 'h'):
+		d.flush()
+	}
+}
+
+func (d *xhtmlDocument) text(text string) {
+	if !d.inBody || d.skipping() {
+		return
+	}
+	if d.inHeading() {
+		d.heading.WriteString(text)
+		return
+	}
+	text = collapseSpace(text)
+	if n := len(d.spans); strings.HasPrefix(text, " ") && (n == 0 || strings.HasSuffix(d.spans[n-1].Text, " ")) {
+		text = text[1:]
+	}
+	if text == "" {
+		return
+	}
+
+	var style book.Style
+	href := ""
+	for _, element := range d.elements {
+		style |= element.style
+		if element.href != "" {
+			href = element.href
+		}
+	}
+	if n := len(d.spans); n > 0 && d.spans[n-1].Style == style && d.spans[n-1].Href == href && d.spans[n-1].ImageID == "" {
+		d.spans[n-1].Text += text
+		return
+	}
+	d.spans = append(d.spans, book.Span{Text: text, Style: style, Href: href})
+}
+
+// image adds an inline image to the paragraph, or an image block if the paragraph has no text
+func (d *xhtmlDocument) image(src string) {
+	if i := strings.IndexAny(src, "#?"); i >= 0 {
+		src = src[:i]
+	}
+	if unescaped, err := url.PathUnescape(src); err == nil {
+		src = unescaped
+	}
+	id, ok := d.reader.images[resolveEPUBPath(d.dir, src)]
+	if !ok || d.inHeading() {
+		return
+	}
+	if len(d.spans) == 0 {
+		d.appendBlock(book.Block{Kind: book.Image, ImageID: id})
+		return
+	}
+	d.spans = append(d.spans, book.Span{ImageID: id})
+}
+
+// resolveHref turns links between documents of the book into fragment links
+func (d *xhtmlDocument) resolveHref(href string) string {
+	if href == "" {
+		return ""
+	}
+	if parsed, err := url.Parse(href); err == nil && parsed.Scheme != "" {
+		return href
+	}
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		return href[i:]
+	}
+	return ""
+}
+
+// flush ends the current paragraph
+func (d *xhtmlDocument) flush() {
+	spans := d.spans
+	kind := d.kind
+	d.spans = nil
+	d.kind = book.Paragraph
+	if len(spans) == 0 {
+		return
+	}
+	spans[0].Text = strings.TrimLeft(spans[0].Text, " ")
+	spans[len(spans)-1].Text = strings.TrimRight(spans[len(spans)-1].Text, " ")
+	if book.SpansText(spans) == "" && !hasImageSpan(spans) {
+		return
+	}
+	d.appendBlock(book.Block{Kind: kind, Spans: spans})
+}
+
+func hasImageSpan(spans []book.Span) bool {
+	for _, span := range spans {
+		if span.ImageID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// appendBlock adds a block to the innermost open container or chapter
+func (d *xhtmlDocument) appendBlock(block book.Block) {
+	if n := len(d.containers); n > 0 {
+		d.containers[n-1].block.Children = append(d.containers[n-1].block.Children, block)
+		d.anchor = ""
+		return
+	}
+	chapter := d.currentChapter()
+	chapter.Blocks = append(chapter.Blocks, block)
+	d.anchor = ""
+}
+
+// currentChapter returns the innermost open chapter, starting an untitled one
+// for content before the first heading
+func (d *xhtmlDocument) currentChapter() *book.Chapter {
+	if n := len(d.open); n > 0 {
+		return d.open[n-1].chapter
+	}
+	chapter := &book.Chapter{ID: d.anchor}
+	d.anchor = ""
+	d.chapters = append(d.chapters, chapter)
+	d.open = append(d.open, chapterLevel{chapter: chapter, level: 0})
+	return chapter
+}
+
+func (d *xhtmlDocument) openContainer(kind book.BlockKind, id string) bool {
+	d.flush()
+	d.containers = append(d.containers, &xhtmlContainer{block: book.Block{Kind: kind, ID: id}})
+	return true
+}
+
+// closeHeading starts a chapter at the heading level, nested in the closest
+// open chapter with a higher level. Untitled content before the first heading
+// stays a sibling.
+func (d *xhtmlDocument) closeHeading(level int) {
+	title := strings.TrimSpace(collapseSpace(d.heading.String()))
+	d.heading.Reset()
+	if title == "" {
+		return
+	}
+
+	for n := len(d.open); n > 0 && (d.open[n-1].level >= level || d.open[n-1].level == 0); n-- {
+		d.open = d.open[:n-1]
+	}
+	chapter := &book.Chapter{ID: d.anchor, Title: title}
+	d.anchor = ""
+	if n := len(d.open); n > 0 {
+		d.open[n-1].chapter.Children = append(d.open[n-1].chapter.Children, chapter)
+	} else {
+		d.chapters = append(d.chapters, chapter)
+	}
+	d.open = append(d.open, chapterLevel{chapter: chapter, level: level})
+}
+
+func (d *xhtmlDocument) inHeading() bool {
+	for _, element := range d.elements {
+		if element.heading {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *xhtmlDocument) skipping() bool {
+	for _, element := range d.elements {
+		if element.skip {
+			return true
+		}
+	}
+	return false
+}
+
+// isCoverOnly reports whether the document shows nothing but the cover image
+func (d *xhtmlDocument) isCoverOnly() bool {
+	cover := d.reader.book.Cover
+	if cover == "" || len(d.chapters) != 1 {
+		return false
+	}
+	chapter := d.chapters[0]
+	return chapter.Title == "" && len(chapter.Children) == 0 && len(chapter.Blocks) == 1 &&
+		chapter.Blocks[0].Kind == book.Image && chapter.Blocks[0].ImageID == cover
+}
+
+// collapseSpace replaces runs of whitespace with a single space, as HTML renders them
+func collapseSpace(text string) string {
+	var result strings.Builder
+	space := false
+	for _, r := range text {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			space = true
+			continue
+		}
+		if space {
+			result.WriteByte(' ')
+			space = false
+		}
+		result.WriteRune(r)
+	}
+	if space {
+		result.WriteByte(' ')
+	}
+	return result.String()
+}
+
+// resolveEPUBPath resolves an href relative to the directory of the referring file
+func resolveEPUBPath(dir, href string) string {
+	if dir == "." || dir == "" {
+		return path.Clean(href)
+	}
+	return path.Join(dir, href)
+}
+
+func hasProperty(properties, property string) bool {
+	for _, p := range strings.Fields(properties) {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(values []string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
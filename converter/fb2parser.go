@@ -1,6 +1,7 @@
 package converter
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -11,6 +12,11 @@ import (
 
 // ParseFB2 parses an FB2 file and returns a FictionBook struct
 func ParseFB2(filePath string) (*models.FictionBook, error) {
+	return ParseFB2Context(context.Background(), filePath)
+}
+
+// ParseFB2Context parses an FB2 file like ParseFB2, giving up once ctx is done
+func ParseFB2Context(ctx context.Context, filePath string) (*models.FictionBook, error) {
 	//nolint:gosec // Path is controlled and validated
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -22,32 +28,18 @@ func ParseFB2(filePath string) (*models.FictionBook, error) {
 		}
 	}()
 
-	var fb2 models.FictionBook
-	decoder := xml.NewDecoder(file)
-
-	// Handle XML namespaces and encoding
-	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
-		return input, nil
-	}
-
-	if err := decoder.Decode(&fb2); err != nil {
-		return nil, fmt.Errorf("failed to parse FB2 XML: %w", err)
-	}
-
-	return &fb2, nil
+	fb2, err := ParseFB2FromReader(&contextReader{ctx: ctx, r: file})
+	return fb2, contextError(ctx, err)
 }
 
-// ParseFB2FromReader parses FB2 from an io.Reader
+// ParseFB2FromReader parses FB2 from an io.Reader. Documents may be UTF-8 or
+// UTF-16, with or without a byte order mark, and control characters XML does
+// not allow are removed from them.
 func ParseFB2FromReader(reader io.Reader) (*models.FictionBook, error) {
 	var fb2 models.FictionBook
-	decoder := xml.NewDecoder(reader)
-
-	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
-		return input, nil
-	}
-
-	if err := decoder.Decode(&fb2); err != nil {
-		return nil, fmt.Errorf("failed to parse FB2 XML: %w", err)
+	tracker := newTrackingDecoder(newXMLCharFilter(newUTF8Reader(reader)))
+	if err := xml.NewTokenDecoder(tracker).Decode(&fb2); err != nil {
+		return nil, fmt.Errorf("failed to parse FB2 XML: %w", tracker.locate(err))
 	}
 
 	return &fb2, nil
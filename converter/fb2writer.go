@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/lex/fb2epub/models"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// WriteFB2 writes fb2 as an FB2 XML document
+func WriteFB2(fb2 *models.FictionBook, w io.Writer) error {
+	document := *fb2
+	if document.Description.DocumentInfo.ProgramUsed == "" {
+		document.Description.DocumentInfo.ProgramUsed = "fb2epub"
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	root := xml.StartElement{
+		Name: xml.Name{Local: "FictionBook"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: fb2Namespace}},
+	}
+	if err := xml.NewEncoder(w).EncodeElement(&document, root); err != nil {
+		return fmt.Errorf("failed to write FB2 XML: %w", err)
+	}
+	return nil
+}
+
+// ConvertEPUBFileWithStatsContext converts the EPUB file at inputPath into an
+// FB2 file at outputPath, the reverse of ConvertFileWithStatsContext. Metadata
+// overrides and limits apply; options that shape the EPUB are ignored.
+func (c *Converter) ConvertEPUBFileWithStatsContext(ctx context.Context, inputPath, outputPath string) (*Stats, error) {
+	stats := &Stats{}
+
+	//nolint:gosec // Path is controlled by the caller
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return stats, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		if closeErr := input.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+	info, err := input.Stat()
+	if err != nil {
+		return stats, fmt.Errorf("failed to open file: %w", err)
+	}
+	stats.InputSize = info.Size()
+
+	opts := c.opts
+
+	opts.reportProgress(StageParsing, 0)
+	start := time.Now()
+	parseCtx, span := startSpan(ctx, "parse", attribute.String("input.format", "epub"))
+	b, err := parseEPUB(parseCtx, input, info.Size(), &opts.Limits)
+	endSpan(span, err)
+	stats.ParseDuration = time.Since(start)
+	if err != nil {
+		return stats, contextError(ctx, err)
+	}
+	fb2 := applyMetadataOverrides(FB2FromBook(b), &opts.Metadata)
+	stats.describe(&fb2.Description.TitleInfo)
+	stats.ImageCount = len(fb2.Binary)
+	stats.ChapterCount = countChapters(fb2.MainBody().Section)
+
+	opts.reportProgress(StagePackaging, 50)
+	start = time.Now()
+	_, span = startSpan(ctx, "generate", attribute.String("output.format", "fb2"))
+	err = writeFB2File(fb2, outputPath)
+	endSpan(span, err)
+	stats.GenerateDuration = time.Since(start)
+	if err != nil {
+		return stats, err
+	}
+	if info, err := os.Stat(outputPath); err == nil {
+		stats.OutputSize = info.Size()
+	}
+	opts.reportProgress(StageDone, 100)
+
+	return stats, nil
+}
+
+func writeFB2File(fb2 *models.FictionBook, outputPath string) error {
+	//nolint:gosec // Path is controlled by the caller
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create FB2 file: %w", err)
+	}
+	err = WriteFB2(fb2, output)
+	if closeErr := output.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,227 @@
+package converter
+
+import (
+	"archive/zip"
+	"crypto/sha1" //nolint:gosec // SHA-1 is mandated by the IDPF font obfuscation algorithm
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fontStylesheet is the stylesheet declaring embedded fonts, relative to OEBPS
+const fontStylesheet = "fonts.css"
+
+// obfuscatedFontLength is the number of leading bytes scrambled by the IDPF algorithm
+const obfuscatedFontLength = 1040
+
+// Font is a font file embedded into the EPUB
+type Font struct {
+	Name string // File name, e.g. "PTSerif-Bold.ttf"; the part before the first dash is the family
+	Data []byte
+}
+
+// fontMediaTypes maps supported font file extensions to their EPUB core media types
+var fontMediaTypes = map[string]string{
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// IsSupportedFont reports whether a file name has a font extension that can be embedded
+func IsSupportedFont(name string) bool {
+	_, ok := fontMediaTypes[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// LoadFonts reads every supported font file in dir, sorted by name
+func LoadFonts(dir string) ([]Font, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font directory: %w", err)
+	}
+
+	var fonts []Font
+	for _, entry := range entries {
+		if entry.IsDir() || !IsSupportedFont(entry.Name()) {
+			continue
+		}
+		//nolint:gosec // Path is built from a configured directory
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read font %s: %w", entry.Name(), err)
+		}
+		fonts = append(fonts, Font{Name: entry.Name(), Data: data})
+	}
+	sort.Slice(fonts, func(i, j int) bool { return fonts[i].Name < fonts[j].Name })
+	return fonts, nil
+}
+
+// embeddedFont is a font prepared for packaging
+type embeddedFont struct {
+	ID        string
+	Href      string // Path relative to OEBPS
+	MediaType string
+	Family    string
+	Weight    string
+	Style     string
+	Data      []byte
+}
+
+// prepareFonts sanitizes file names and derives the CSS face of each font.
+// Fonts with unsupported extensions or duplicate names are skipped.
+func prepareFonts(fonts []Font) []embeddedFont {
+	var result []embeddedFont
+	seen := make(map[string]bool)
+	for _, font := range fonts {
+		name := sanitizeFontName(font.Name)
+		mediaType, ok := fontMediaTypes[strings.ToLower(filepath.Ext(name))]
+		if !ok || len(font.Data) == 0 || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		family, variant, _ := strings.Cut(base, "-")
+		variant = strings.ToLower(variant)
+
+		face := embeddedFont{
+			ID:        fmt.Sprintf("font-%d", len(result)+1),
+			Href:      "fonts/" + name,
+			MediaType: mediaType,
+			Family:    family,
+			Weight:    "normal",
+			Style:     "normal",
+			Data:      font.Data,
+		}
+		if strings.Contains(variant, "bold") {
+			face.Weight = "bold"
+		}
+		if strings.Contains(variant, "italic") || strings.Contains(variant, "oblique") {
+			face.Style = "italic"
+		}
+		result = append(result, face)
+	}
+	return result
+}
+
+// sanitizeFontName keeps the base name of a font file and replaces characters
+// that are unsafe in archive paths and CSS URLs
+func sanitizeFontName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// buildFontCSS declares every font face and makes the first family the body font
+func buildFontCSS(fonts []embeddedFont) string {
+	var css strings.Builder
+	for _, font := range fonts {
+		fmt.Fprintf(&css, `@font-face {
+  font-family: "%s";
+  font-weight: %s;
+  font-style: %s;
+  src: url("%s");
+}
+`, font.Family, font.Weight, font.Style, font.Href)
+	}
+	if len(fonts) > 0 {
+		fmt.Fprintf(&css, "body { font-family: \"%s\", serif; }\n", fonts[0].Family)
+	}
+	return css.String()
+}
+
+// linkStylesheet adds a link to the font stylesheet at the end of the document head,
+// after the inline styles so the embedded body font takes precedence
+func linkStylesheet(document string, fonts []embeddedFont) string {
+	if len(fonts) == 0 {
+		return document
+	}
+	return strings.Replace(document, "</head>",
+		fmt.Sprintf("  <link rel=\"stylesheet\" type=\"text/css\" href=\"%s\"/>\n</head>", fontStylesheet), 1)
+}
+
+// addFontResources writes the font files, their stylesheet and, when the
+// fonts are obfuscated, META-INF/encryption.xml
+func addFontResources(writer *zip.Writer, fonts []embeddedFont, bookID string, obfuscate bool) error {
+	if len(fonts) == 0 {
+		return nil
+	}
+
+	w, err := writer.Create("OEBPS/" + fontStylesheet)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(buildFontCSS(fonts))); err != nil {
+		return err
+	}
+
+	for _, font := range fonts {
+		data := font.Data
+		if obfuscate {
+			data = obfuscateFont(data, bookID)
+		}
+		w, err := writer.Create("OEBPS/" + font.Href)
+		if err != nil {
+			return fmt.Errorf("failed to create font file %s: %w", font.Href, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write font data %s: %w", font.Href, err)
+		}
+	}
+
+	if !obfuscate {
+		return nil
+	}
+
+	var encryption strings.Builder
+	encryption.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+`)
+	for _, font := range fonts {
+		fmt.Fprintf(&encryption, `  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+    <enc:CipherData>
+      <enc:CipherReference URI="OEBPS/%s"/>
+    </enc:CipherData>
+  </enc:EncryptedData>
+`, font.Href)
+	}
+	encryption.WriteString("</encryption>")
+
+	w, err = writer.Create("META-INF/encryption.xml")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(encryption.String()))
+	return err
+}
+
+// obfuscateFont applies the IDPF font obfuscation algorithm: the first 1040 bytes
+// are XORed with the SHA-1 of the package unique identifier, whitespace removed.
+// The algorithm is symmetric, so applying it twice restores the font.
+func obfuscateFont(data []byte, bookID string) []byte {
+	id := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		default:
+			return r
+		}
+	}, bookID)
+	key := sha1.Sum([]byte(id)) //nolint:gosec // Mandated by the specification
+
+	result := append([]byte(nil), data...)
+	for i := 0; i < len(result) && i < obfuscatedFontLength; i++ {
+		result[i] ^= key[i%len(key)]
+	}
+	return result
+}
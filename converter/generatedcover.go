@@ -0,0 +1,181 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// generatedCoverID is the binary ID of a cover rendered for a book without one
+const generatedCoverID = "cover-generated"
+
+// Size and layout of generated covers, in pixels, in the 2:3 shape of most book covers
+const (
+	generatedCoverWidth  = 600
+	generatedCoverHeight = 900
+	generatedCoverMargin = 50
+)
+
+// generatedCoverColors are the backgrounds of generated covers, picked by title so
+// a book keeps its color and books side by side in a library differ
+var generatedCoverColors = []color.RGBA{
+	{0x2E, 0x4A, 0x62, 0xFF}, // Slate blue
+	{0x6B, 0x2D, 0x3C, 0xFF}, // Burgundy
+	{0x2F, 0x5D, 0x50, 0xFF}, // Pine
+	{0x5B, 0x43, 0x7A, 0xFF}, // Plum
+	{0x8A, 0x5A, 0x19, 0xFF}, // Ochre
+	{0x3B, 0x3B, 0x3B, 0xFF}, // Charcoal
+	{0x1F, 0x5F, 0x8B, 0xFF}, // Sea blue
+	{0x7A, 0x3E, 0x1D, 0xFF}, // Rust
+}
+
+// addGeneratedCover renders a PNG cover with the title and author of a book
+// and makes it the coverpage, for books without a cover image of their own
+func addGeneratedCover(fb2 *models.FictionBook, imageMap map[string]*ImageInfo, opts *Options) {
+	if _, taken := imageMap[generatedCoverID]; taken {
+		return
+	}
+
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
+	title := fb2.Description.TitleInfo.BookTitle
+	if title == "" {
+		title = l.Untitled
+	}
+	var authors []string
+	for _, author := range fb2.Description.TitleInfo.Author {
+		if name := buildAuthorName(author); name != "" {
+			authors = append(authors, name)
+		}
+	}
+
+	data, err := renderCoverImage(title, strings.Join(authors, ", "))
+	if err != nil {
+		opts.reportWarning(Diagnostic{Message: "could not generate a cover image: " + err.Error()})
+		return
+	}
+	imageMap[generatedCoverID] = &ImageInfo{
+		ID:          generatedCoverID,
+		ContentType: "image/png",
+		Size:        int64(len(data)),
+		encoded:     base64.StdEncoding.EncodeToString(data),
+	}
+	fb2.Description.TitleInfo.Coverpage = &models.Coverpage{
+		Image: []models.Image{{Href: "#" + generatedCoverID}},
+	}
+}
+
+// renderCoverImage draws title and author in white on a colored background
+// and returns the PNG. Long titles are wrapped and set smaller to fit.
+func renderCoverImage(title, author string) ([]byte, error) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(title))
+	background := generatedCoverColors[hash.Sum32()%uint32(len(generatedCoverColors))]
+
+	img := image.NewRGBA(image.Rect(0, 0, generatedCoverWidth, generatedCoverHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+	// The title is centered above a light rule and the author below it
+	ruleY := generatedCoverHeight * 2 / 3
+	rule := image.Rect(generatedCoverMargin, ruleY, generatedCoverWidth-generatedCoverMargin, ruleY+3)
+	draw.Draw(img, rule, &image.Uniform{C: color.NRGBA{0xFF, 0xFF, 0xFF, 0x99}}, image.Point{}, draw.Over)
+
+	titleArea := image.Rect(generatedCoverMargin, 2*generatedCoverMargin,
+		generatedCoverWidth-generatedCoverMargin, ruleY-generatedCoverMargin)
+	if err := drawText(img, gobold.TTF, title, titleArea, 60, 28, color.White); err != nil {
+		return nil, err
+	}
+	if author != "" {
+		authorArea := image.Rect(generatedCoverMargin, ruleY+generatedCoverMargin,
+			generatedCoverWidth-generatedCoverMargin, generatedCoverHeight-generatedCoverMargin)
+		if err := drawText(img, goregular.TTF, author, authorArea, 36, 20, color.NRGBA{0xEE, 0xEE, 0xEE, 0xFF}); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// drawText draws text centered in area with the font ttf, wrapped and set at
+// the largest size from largest down to smallest at which it fits. Lines that
+// do not fit at the smallest size are cut off.
+func drawText(img draw.Image, ttf []byte, text string, area image.Rectangle, largest, smallest float64,
+	textColor color.Color) error {
+	parsed, err := opentype.Parse(ttf)
+	if err != nil {
+		return err
+	}
+	for size := largest; ; size -= 4 {
+		face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return err
+		}
+		lines, fits := wrapText(face, text, area.Dx())
+		lineHeight := face.Metrics().Height.Ceil() * 5 / 4
+		maxLines := area.Dy() / lineHeight
+		if (fits && len(lines) <= maxLines) || size-4 < smallest {
+			if len(lines) > maxLines && maxLines > 0 {
+				lines = lines[:maxLines]
+				lines[maxLines-1] += "…"
+			}
+			drawLines(img, face, lines, area, lineHeight, textColor)
+			return face.Close()
+		}
+		_ = face.Close()
+	}
+}
+
+// wrapText breaks text into lines of whole words no wider than width pixels;
+// fits is false when a single word is wider
+func wrapText(face font.Face, text string, width int) (lines []string, fits bool) {
+	limit := fixed.I(width)
+	fits = true
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line == "" || font.MeasureString(face, candidate) <= limit {
+			line = candidate
+		} else {
+			lines = append(lines, line)
+			line = word
+		}
+		if font.MeasureString(face, word) > limit {
+			fits = false
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines, fits
+}
+
+// drawLines draws lines of text centered in area, lineHeight pixels apart
+func drawLines(img draw.Image, face font.Face, lines []string, area image.Rectangle, lineHeight int,
+	textColor color.Color) {
+	y := area.Min.Y + (area.Dy()-len(lines)*lineHeight)/2 + face.Metrics().Ascent.Ceil()
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(textColor), Face: face}
+	for _, line := range lines {
+		advance := drawer.MeasureString(line)
+		drawer.Dot = fixed.P(area.Min.X+(area.Dx()-advance.Ceil())/2, y)
+		drawer.DrawString(line)
+		y += lineHeight
+	}
+}
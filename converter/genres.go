@@ -0,0 +1,83 @@
+package converter
+
+import (
+	_ "embed" // Required for go:embed
+	"strings"
+)
+
+// genreTable lists the FB2 genre codes, one per line, with their names in the
+// languages of the header row; the first language is the fallback
+//
+//go:embed genres.tsv
+var genreTable string
+
+var (
+	// genreNames maps genre codes to their names by language
+	genreNames map[string]map[string]string
+	// genreLanguages are the languages of the table, the fallback first
+	genreLanguages []string
+	// genreCodes maps lowercased names in any language back to their code
+	genreCodes map[string]string
+)
+
+func init() {
+	lines := strings.Split(strings.TrimSpace(genreTable), "\n")
+	genreLanguages = strings.Split(lines[0], "\t")[1:]
+	genreNames = make(map[string]map[string]string, len(lines)-1)
+	genreCodes = make(map[string]string, (len(lines)-1)*len(genreLanguages))
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		names := make(map[string]string, len(genreLanguages))
+		for i, lang := range genreLanguages {
+			if i+1 < len(fields) && fields[i+1] != "" {
+				names[lang] = fields[i+1]
+				genreCodes[strings.ToLower(fields[i+1])] = fields[0]
+			}
+		}
+		genreNames[fields[0]] = names
+	}
+}
+
+// GenreName returns the name of an FB2 genre code in the given language,
+// falling back to English, or the code itself when it is not a known genre
+func GenreName(code, lang string) string {
+	code = strings.TrimSpace(code)
+	names, ok := genreNames[strings.ToLower(code)]
+	if !ok {
+		return code
+	}
+	if name, ok := names[baseLanguage(lang)]; ok {
+		return name
+	}
+	return names[genreLanguages[0]]
+}
+
+// genreNamesFor returns the names of genre codes in the given language,
+// skipping empty codes and repeated names
+func genreNamesFor(codes []string, lang string) []string {
+	var names []string
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		name := GenreName(code, lang)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// genreCode returns the FB2 genre code of a genre name in any language of the
+// table, or the name itself when it is not a known genre, such as a subject
+// of an EPUB that was not made from FB2
+func genreCode(name string) string {
+	name = strings.TrimSpace(name)
+	if _, ok := genreNames[strings.ToLower(name)]; ok {
+		return strings.ToLower(name)
+	}
+	if code, ok := genreCodes[strings.ToLower(name)]; ok {
+		return code
+	}
+	return name
+}
@@ -0,0 +1,46 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// headingTagPattern matches the opening and closing tags of h1 to h6
+var headingTagPattern = regexp.MustCompile(`<(/?)h([1-6])([\s>/])`)
+
+// headingTag returns the element of a heading of the given level; XHTML has no
+// level beyond h6, so deeper headings share it
+func headingTag(level int) string {
+	if level > 6 {
+		level = 6
+	}
+	return fmt.Sprintf("h%d", level)
+}
+
+// normalizeHeadings renumbers the headings of an XHTML document so that none
+// is more than one level below the heading before it. Section, poem and stanza
+// titles are generated to follow the outline of the table of contents; this
+// pass guarantees it for every document, whatever the nesting of the source.
+// Headings are never deepened.
+func normalizeHeadings(document string) string {
+	previous := 0 // Level of the last heading, 0 before the first
+	current := 0  // Level the open heading was renumbered to
+	return headingTagPattern.ReplaceAllStringFunc(document, func(tag string) string {
+		match := headingTagPattern.FindStringSubmatch(tag)
+		closing, level, rest := match[1] == "/", int(match[2][0]-'0'), match[3]
+		if closing {
+			if current == 0 {
+				return tag
+			}
+			level, current = current, 0
+			return "</h" + strconv.Itoa(level) + rest
+		}
+
+		if level > previous+1 {
+			level = previous + 1
+		}
+		previous, current = level, level
+		return "<h" + strconv.Itoa(level) + rest
+	})
+}
@@ -0,0 +1,217 @@
+package converter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// softHyphen marks a permitted line break inside a word
+const softHyphen = "\u00AD"
+
+// Hyphenation limits shared by all languages
+const (
+	minHyphenWordLength = 6 // Shorter words are never hyphenated
+	minHyphenLeft       = 2 // Letters kept before the first break
+)
+
+// hyphenator returns the rune offsets inside word where a soft hyphen may be inserted
+type hyphenator func(word []rune) []int
+
+// hyphenatorFor returns the hyphenator for a language code, or nil if the language is not supported
+func hyphenatorFor(lang string) hyphenator {
+	switch baseLanguage(lang) {
+	case "ru", "uk", "be", "bg":
+		return hyphenateCyrillic
+	case "en":
+		return hyphenateEnglish
+	default:
+		return nil
+	}
+}
+
+// baseLanguage returns the primary subtag of a language code ("en-US" -> "en")
+func baseLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if end := strings.IndexAny(lang, "-_"); end >= 0 {
+		lang = lang[:end]
+	}
+	return lang
+}
+
+// hyphenateText inserts soft hyphens into every sufficiently long word of text
+func hyphenateText(text string, hyphenate hyphenator) string {
+	runes := []rune(text)
+	var result strings.Builder
+	result.Grow(len(text))
+
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) {
+			result.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(runes) && unicode.IsLetter(runes[i]) {
+			i++
+		}
+		word := runes[start:i]
+
+		if len(word) < minHyphenWordLength {
+			result.WriteString(string(word))
+			continue
+		}
+
+		prev := 0
+		for _, pos := range hyphenate(word) {
+			result.WriteString(string(word[prev:pos]))
+			result.WriteString(softHyphen)
+			prev = pos
+		}
+		result.WriteString(string(word[prev:]))
+	}
+
+	return result.String()
+}
+
+// Character classes used by the Cyrillic rules
+const (
+	cyrillicVowels  = "аеёиоуыэюяєіїaeiouy"
+	cyrillicSpecial = "йъь"
+)
+
+// cyrillicRule is a class pattern (g = vowel, s = consonant, x = special sign)
+// with the offset of the break inside the matched letters
+type cyrillicRule struct {
+	pattern string
+	offset  int
+}
+
+// cyrillicRules implements P. Khristov's syllable hyphenation rules for Russian.
+// Rules are applied in order; later rules never match across an earlier break.
+var cyrillicRules = []cyrillicRule{
+	{"xgg", 1}, {"xgs", 1}, {"xsg", 1}, {"xss", 1},
+	{"gssssg", 3}, {"gsssg", 3}, {"gsssg", 2},
+	{"sgsg", 2}, {"gssg", 2}, {"sggg", 2}, {"sggs", 2},
+}
+
+func cyrillicClass(r rune) byte {
+	r = unicode.ToLower(r)
+	switch {
+	case strings.ContainsRune(cyrillicVowels, r):
+		return 'g'
+	case strings.ContainsRune(cyrillicSpecial, r):
+		return 'x'
+	default:
+		return 's'
+	}
+}
+
+func hyphenateCyrillic(word []rune) []int {
+	classes := make([]byte, len(word))
+	for i, r := range word {
+		classes[i] = cyrillicClass(r)
+	}
+
+	breaks := make(map[int]bool)
+	for _, rule := range cyrillicRules {
+		for i := 0; i+len(rule.pattern) <= len(classes); i++ {
+			if string(classes[i:i+len(rule.pattern)]) != rule.pattern || spansBreak(breaks, i, i+len(rule.pattern)) {
+				continue
+			}
+			breaks[i+rule.offset] = true
+		}
+	}
+
+	return sortedBreaks(breaks, len(word), minHyphenLeft, 2)
+}
+
+// englishDigraphs are consonant pairs that stay together on one side of a break
+var englishDigraphs = map[string]bool{
+	"ch": true, "ck": true, "gh": true, "kn": true, "ng": true, "ph": true,
+	"qu": true, "sh": true, "th": true, "wh": true, "wr": true,
+}
+
+// hyphenateEnglish splits English words between syllables using simple
+// vowel-consonant rules: VC-CV, V-CV and VC-CCV, keeping digraphs together.
+// It is a heuristic rather than a full pattern-based hyphenator.
+func hyphenateEnglish(word []rune) []int {
+	// Acronyms and words with digits or mixed scripts are left alone
+	lower := make([]rune, len(word))
+	upper := 0
+	for i, r := range word {
+		if r > unicode.MaxASCII {
+			return nil
+		}
+		if unicode.IsUpper(r) {
+			upper++
+		}
+		lower[i] = unicode.ToLower(r)
+	}
+	if upper > 1 {
+		return nil
+	}
+
+	isVowel := func(i int) bool {
+		if lower[i] == 'y' {
+			return i > 0 // Leading y is a consonant
+		}
+		return strings.ContainsRune("aeiou", lower[i])
+	}
+
+	breaks := make(map[int]bool)
+	for i := 0; i < len(lower); {
+		if !isVowel(i) {
+			i++
+			continue
+		}
+		// Find the consonant run following this vowel
+		j := i + 1
+		for j < len(lower) && !isVowel(j) {
+			j++
+		}
+		if j >= len(lower) {
+			break
+		}
+		consonants := j - (i + 1)
+		switch {
+		case consonants >= 2 && j == len(lower)-1 && lower[j] == 'e' && lower[j-1] == 'l':
+			breaks[j-2] = true // Consonant + "le" ending stays together ("ta-ble")
+		case consonants == 1:
+			breaks[i+1] = true // V-CV
+		case consonants == 2 && englishDigraphs[string(lower[i+1:j])]:
+			breaks[i+1] = true // V-CCV with digraph
+		case consonants >= 2:
+			breaks[i+2] = true // VC-CV, VC-CCV
+		}
+		i = j
+	}
+
+	// Silent final "e" does not form a syllable ("make" not "ma-ke")
+	if len(lower) > 2 && lower[len(lower)-1] == 'e' && !isVowel(len(lower)-2) {
+		delete(breaks, len(lower)-2)
+	}
+
+	return sortedBreaks(breaks, len(word), minHyphenLeft, 3)
+}
+
+// spansBreak reports whether an existing break lies strictly inside [start, end)
+func spansBreak(breaks map[int]bool, start, end int) bool {
+	for pos := start + 1; pos < end; pos++ {
+		if breaks[pos] {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedBreaks returns break positions in ascending order, dropping those too close to the word edges
+func sortedBreaks(breaks map[int]bool, length, minLeft, minRight int) []int {
+	var result []int
+	for pos := minLeft; pos <= length-minRight; pos++ {
+		if breaks[pos] {
+			result = append(result, pos)
+		}
+	}
+	return result
+}
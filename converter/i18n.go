@@ -0,0 +1,158 @@
+package converter
+
+// labels holds the text the converter generates itself: navigation entries
+// and fallbacks for missing titles
+type labels struct {
+	Cover           string
+	AboutBook       string
+	Content         string
+	TableOfContents string
+	Landmarks       string
+	Pages           string
+	Notes           string
+	Untitled        string
+	UntitledSection string
+	Chapter         string // Numbers untitled sections, e.g. "Chapter 3"
+	UnknownAuthor   string
+	Original        string // Introduces the original title and authors of a translation
+
+	// Colophon page
+	Colophon        string
+	SourceDocument  string
+	DocumentAuthors string
+	DocumentDate    string
+	ProgramUsed     string
+	ConvertedOn     string
+	ConvertedWith   string
+	CustomInfo      string // Custom information without a type
+	DocumentHistory string
+}
+
+var englishLabels = &labels{
+	Cover:           "Cover",
+	AboutBook:       "About This Book",
+	Content:         "Content",
+	TableOfContents: "Table of Contents",
+	Landmarks:       "Landmarks",
+	Pages:           "Pages",
+	Notes:           defaultNotesTitle,
+	Untitled:        defaultTitle,
+	UntitledSection: "Untitled Section",
+	Chapter:         "Chapter",
+	UnknownAuthor:   defaultAuthor,
+	Original:        "Original",
+	Colophon:        "Colophon",
+	SourceDocument:  "Source document",
+	DocumentAuthors: "Document authors",
+	DocumentDate:    "Document date",
+	ProgramUsed:     "Program used",
+	ConvertedOn:     "Converted on",
+	ConvertedWith:   "Converted with",
+	CustomInfo:      "Additional information",
+	DocumentHistory: "Document history",
+}
+
+// labelsByLanguage maps primary language subtags to translated labels
+var labelsByLanguage = map[string]*labels{
+	"en": englishLabels,
+	"ru": {
+		Cover:           "Обложка",
+		AboutBook:       "Аннотация",
+		Content:         "Текст",
+		TableOfContents: "Оглавление",
+		Landmarks:       "Ориентиры",
+		Pages:           "Страницы",
+		Notes:           "Примечания",
+		Untitled:        "Без названия",
+		UntitledSection: "Раздел без названия",
+		Chapter:         "Глава",
+		UnknownAuthor:   "Неизвестный автор",
+		Original:        "Оригинал",
+		Colophon:        "Выходные данные",
+		SourceDocument:  "Исходный документ",
+		DocumentAuthors: "Авторы документа",
+		DocumentDate:    "Дата документа",
+		ProgramUsed:     "Создан программой",
+		ConvertedOn:     "Дата конвертации",
+		ConvertedWith:   "Конвертер",
+		CustomInfo:      "Дополнительные сведения",
+		DocumentHistory: "История документа",
+	},
+	"uk": {
+		Cover:           "Обкладинка",
+		AboutBook:       "Анотація",
+		Content:         "Текст",
+		TableOfContents: "Зміст",
+		Landmarks:       "Орієнтири",
+		Pages:           "Сторінки",
+		Notes:           "Примітки",
+		Untitled:        "Без назви",
+		UntitledSection: "Розділ без назви",
+		Chapter:         "Розділ",
+		UnknownAuthor:   "Невідомий автор",
+		Original:        "Оригінал",
+		Colophon:        "Вихідні дані",
+		SourceDocument:  "Вихідний документ",
+		DocumentAuthors: "Автори документа",
+		DocumentDate:    "Дата документа",
+		ProgramUsed:     "Створено програмою",
+		ConvertedOn:     "Дата конвертації",
+		ConvertedWith:   "Конвертер",
+		CustomInfo:      "Додаткові відомості",
+		DocumentHistory: "Історія документа",
+	},
+	"de": {
+		Cover:           "Titelbild",
+		AboutBook:       "Über dieses Buch",
+		Content:         "Text",
+		TableOfContents: "Inhaltsverzeichnis",
+		Landmarks:       "Orientierungspunkte",
+		Pages:           "Seiten",
+		Notes:           "Anmerkungen",
+		Untitled:        "Ohne Titel",
+		UntitledSection: "Abschnitt ohne Titel",
+		Chapter:         "Kapitel",
+		UnknownAuthor:   "Unbekannter Autor",
+		Original:        "Originalausgabe",
+		Colophon:        "Impressum",
+		SourceDocument:  "Quelldokument",
+		DocumentAuthors: "Autoren des Dokuments",
+		DocumentDate:    "Datum des Dokuments",
+		ProgramUsed:     "Erstellt mit",
+		ConvertedOn:     "Konvertiert am",
+		ConvertedWith:   "Konvertiert mit",
+		CustomInfo:      "Weitere Angaben",
+		DocumentHistory: "Versionsgeschichte",
+	},
+	"fr": {
+		Cover:           "Couverture",
+		AboutBook:       "À propos du livre",
+		Content:         "Texte",
+		TableOfContents: "Table des matières",
+		Landmarks:       "Repères",
+		Pages:           "Pages",
+		Notes:           "Notes",
+		Untitled:        "Sans titre",
+		UntitledSection: "Section sans titre",
+		Chapter:         "Chapitre",
+		UnknownAuthor:   "Auteur inconnu",
+		Original:        "Œuvre originale",
+		Colophon:        "Colophon",
+		SourceDocument:  "Document source",
+		DocumentAuthors: "Auteurs du document",
+		DocumentDate:    "Date du document",
+		ProgramUsed:     "Créé avec",
+		ConvertedOn:     "Converti le",
+		ConvertedWith:   "Converti avec",
+		CustomInfo:      "Informations complémentaires",
+		DocumentHistory: "Historique du document",
+	},
+}
+
+// labelsFor returns the labels for a book language, falling back to English
+func labelsFor(lang string) *labels {
+	if l, ok := labelsByLanguage[baseLanguage(lang)]; ok {
+		return l
+	}
+	return englishLabels
+}
@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// sniffLength is how much of an image sniffImageType looks at
+const sniffLength = 512
+
+// decodedBinary describes a binary of the book after decoding. The decoded
+// data itself is not kept: it is decoded again straight into the EPUB.
+type decodedBinary struct {
+	Head []byte // First sniffLength bytes, to judge the content type
+	Size int64
+	Hash [sha256.Size]byte
+	Err  error
+}
+
+// decodeBinaries decodes and hashes the binaries of a book. Books with
+// hundreds of images spend most of their conversion here, so the work is
+// spread over a pool of workers, one per CPU; results keep the order of
+// binaries, so what follows does not depend on the scheduling.
+func decodeBinaries(binaries []models.Binary) []decodedBinary {
+	results := make([]decodedBinary, len(binaries))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(binaries) {
+		workers = len(binaries)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = decodeBinary(binaries[i].Data)
+			}
+		}()
+	}
+	for i := range binaries {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// decodeBinary streams the base64 data of one binary through a hash, so only
+// its first bytes are held in memory
+func decodeBinary(encoded string) decodedBinary {
+	hash := sha256.New()
+	head := &headWriter{limit: sniffLength}
+	size, err := io.Copy(io.MultiWriter(hash, head), binaryReader(encoded))
+	if err != nil {
+		return decodedBinary{Err: err}
+	}
+
+	result := decodedBinary{Head: head.data, Size: size}
+	hash.Sum(result.Hash[:0])
+	return result
+}
+
+// binaryReader returns a reader of the decoded data of a base64 binary
+func binaryReader(encoded string) io.Reader {
+	return base64.NewDecoder(base64.StdEncoding, strings.NewReader(encoded))
+}
+
+// headWriter keeps the first limit bytes written to it and discards the rest
+type headWriter struct {
+	data  []byte
+	limit int
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if room := w.limit - len(w.data); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		w.data = append(w.data, p[:room]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// reportMissingImages warns about each image that refers to a binary the book
+// does not have, or whose data could not be decoded
+func reportMissingImages(fb2 *models.FictionBook, imageMap map[string]*ImageInfo, opts *Options) {
+	var missing []string
+	for ref := range imageRefs(fb2) {
+		if _, exists := imageMap[ref]; !exists && ref != "" {
+			missing = append(missing, ref)
+		}
+	}
+	sort.Strings(missing)
+	for _, ref := range missing {
+		opts.reportWarning(Diagnostic{Message: fmt.Sprintf("image refers to missing binary %q", ref)})
+	}
+}
+
+// imageRefs returns the binary IDs referenced by the coverpage and every image of the book
+func imageRefs(fb2 *models.FictionBook) map[string]bool {
+	refs := make(map[string]bool)
+	titleInfo := &fb2.Description.TitleInfo
+	if titleInfo.Coverpage != nil {
+		addImageRefs(refs, titleInfo.Coverpage.Image)
+	}
+	if annotation := titleInfo.Annotation; annotation != nil {
+		paragraphImageRefs(refs, annotation.Subtitle)
+		paragraphImageRefs(refs, annotation.Paragraph)
+		poemImageRefs(refs, annotation.Poem)
+		citeImageRefs(refs, annotation.Cite)
+	}
+	for i := range fb2.Body {
+		body := &fb2.Body[i]
+		addImageRefs(refs, body.Image)
+		paragraphImageRefs(refs, body.Title.Paragraph)
+		epigraphImageRefs(refs, body.Epigraph)
+		for j := range body.Section {
+			sectionImageRefSet(refs, &body.Section[j])
+		}
+	}
+	return refs
+}
+
+func addImageRefs(refs map[string]bool, images []models.Image) {
+	for _, image := range images {
+		refs[strings.TrimPrefix(image.Href, "#")] = true
+	}
+}
+
+func paragraphImageRefs(refs map[string]bool, paragraphs []models.Paragraph) {
+	for i := range paragraphs {
+		addImageRefs(refs, paragraphs[i].Image)
+	}
+}
+
+func sectionImageRefSet(refs map[string]bool, section *models.Section) {
+	if section.Title != nil {
+		paragraphImageRefs(refs, section.Title.Paragraph)
+	}
+	paragraphImageRefs(refs, section.Paragraph)
+	addImageRefs(refs, section.Image)
+	poemImageRefs(refs, section.Poem)
+	citeImageRefs(refs, section.Cite)
+	for i := range section.Section {
+		sectionImageRefSet(refs, &section.Section[i])
+	}
+}
+
+func poemImageRefs(refs map[string]bool, poems []models.Poem) {
+	for i := range poems {
+		poem := &poems[i]
+		if poem.Title != nil {
+			paragraphImageRefs(refs, poem.Title.Paragraph)
+		}
+		epigraphImageRefs(refs, poem.Epigraph)
+		paragraphImageRefs(refs, poem.TextAuthor)
+	}
+}
+
+func epigraphImageRefs(refs map[string]bool, epigraphs []models.Epigraph) {
+	for i := range epigraphs {
+		epigraph := &epigraphs[i]
+		paragraphImageRefs(refs, epigraph.Paragraph)
+		paragraphImageRefs(refs, epigraph.TextAuthor)
+		poemImageRefs(refs, epigraph.Poem)
+		citeImageRefs(refs, epigraph.Cite)
+	}
+}
+
+func citeImageRefs(refs map[string]bool, cites []models.Cite) {
+	for i := range cites {
+		cite := &cites[i]
+		paragraphImageRefs(refs, cite.Subtitle)
+		paragraphImageRefs(refs, cite.Paragraph)
+		paragraphImageRefs(refs, cite.TextAuthor)
+		poemImageRefs(refs, cite.Poem)
+	}
+}
@@ -0,0 +1,51 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// epubImageTypes are the image media types EPUB reading systems must support
+var epubImageTypes = map[string]bool{
+	"image/jpeg":    true,
+	"image/png":     true,
+	"image/gif":     true,
+	"image/webp":    true,
+	"image/svg+xml": true,
+}
+
+// sniffImageType returns the media type of image data judged by its content,
+// or "" when the data is not an image EPUB supports
+func sniffImageType(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if epubImageTypes[contentType] {
+		return contentType
+	}
+
+	// SVG is XML and sniffs as text; look for its root element instead
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	if bytes.Contains(bytes.ToLower(head), []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return ""
+}
+
+// imageContentType returns the media type to store an image under. Declared
+// content types are often wrong (a PNG declared as JPEG), so the data decides;
+// data that is not a supported image keeps the declared type and is reported.
+func imageContentType(id, declared string, data []byte, opts *Options) string {
+	if sniffed := sniffImageType(data); sniffed != "" {
+		return sniffed
+	}
+	opts.reportWarning(Diagnostic{Message: fmt.Sprintf(
+		"binary %q is not a JPEG, PNG, GIF, WebP or SVG image (declared as %q)", id, declared)})
+	if strings.EqualFold(strings.TrimSpace(declared), "image/jpg") {
+		return "image/jpeg"
+	}
+	return declared
+}
@@ -0,0 +1,97 @@
+package converter
+
+import (
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// BookInfo summarizes the metadata and structure of a parsed FB2 document
+type BookInfo struct {
+	Title            string       `json:"title"`
+	Authors          []string     `json:"authors"`
+	Translators      []string     `json:"translators,omitempty"`
+	Language         string       `json:"language,omitempty"`
+	LanguageDetected bool         `json:"language_detected,omitempty"` // The book names no language, Language was guessed from its text
+	Genres           []string     `json:"genres,omitempty"`
+	GenreNames       []string     `json:"genre_names,omitempty"` // Names of the genres in the language of the book
+	Date             string       `json:"date,omitempty"`
+	Annotation       string       `json:"annotation,omitempty"` // Plain text of the annotation paragraphs
+	Series           []Series     `json:"series,omitempty"`
+	ChapterCount     int          `json:"chapter_count"` // Titled sections of the main body, at any depth
+	ImageCount       int          `json:"image_count"`
+	HasCover         bool         `json:"has_cover"`
+	History          []string     `json:"history,omitempty"` // Paragraphs of the document history
+	CustomInfo       []CustomInfo `json:"custom_info,omitempty"`
+	OriginalTitle    string       `json:"original_title,omitempty"` // Title of the original of a translated book
+	OriginalAuthors  []string     `json:"original_authors,omitempty"`
+	OriginalLanguage string       `json:"original_language,omitempty"`
+}
+
+// CustomInfo is free-form information about the book from its description
+type CustomInfo struct {
+	Type string `json:"type,omitempty"`
+	Text string `json:"text"`
+}
+
+// Series is a series the book belongs to and its position in it
+type Series struct {
+	Name   string `json:"name"`
+	Number string `json:"number,omitempty"`
+}
+
+// Inspect returns the metadata of a book without converting it.
+// Nested sequences (sub-series) follow their parent in Series.
+func Inspect(fb2 *models.FictionBook) *BookInfo {
+	titleInfo := &fb2.Description.TitleInfo
+
+	info := &BookInfo{
+		Title:        titleInfo.BookTitle,
+		Authors:      make([]string, 0, len(titleInfo.Author)),
+		Language:     titleInfo.Lang,
+		Genres:       titleInfo.Genre,
+		Date:         titleInfo.Date,
+		Annotation:   annotationText(titleInfo.Annotation),
+		Series:       flattenSequences(titleInfo.Sequence, nil),
+		ChapterCount: countChapters(fb2.MainBody().Section),
+		ImageCount:   len(fb2.Binary),
+		HasCover:     titleInfo.Coverpage != nil && len(titleInfo.Coverpage.Image) > 0,
+		History:      historyParagraphs(fb2.Description.DocumentInfo.History),
+	}
+	if strings.TrimSpace(info.Language) == "" {
+		info.Language = detectLanguage(fb2)
+		info.LanguageDetected = info.Language != ""
+	}
+	info.GenreNames = genreNamesFor(titleInfo.Genre, info.Language)
+	for _, author := range titleInfo.Author {
+		if name := buildAuthorName(author); name != "" {
+			info.Authors = append(info.Authors, name)
+		}
+	}
+	for _, translator := range titleInfo.Translator {
+		if name := buildAuthorName(translator); name != "" {
+			info.Translators = append(info.Translators, name)
+		}
+	}
+	original := originalOf(fb2)
+	info.OriginalTitle = original.Title
+	info.OriginalAuthors = original.Authors
+	info.OriginalLanguage = original.Language
+	for _, custom := range fb2.Description.CustomInfo {
+		if text := strings.Join(strings.Fields(custom.Text), " "); text != "" {
+			info.CustomInfo = append(info.CustomInfo, CustomInfo{Type: strings.TrimSpace(custom.InfoType), Text: text})
+		}
+	}
+	return info
+}
+
+// flattenSequences appends sequences and their sub-series depth-first, skipping unnamed ones
+func flattenSequences(sequences []models.Sequence, result []Series) []Series {
+	for _, sequence := range sequences {
+		if sequence.Name != "" {
+			result = append(result, Series{Name: sequence.Name, Number: sequence.Number})
+		}
+		result = flattenSequences(sequence.Sequence, result)
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// koboParagraphTags start a new paragraph number in Kobo span IDs
+var koboParagraphTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "dt": true, "dd": true, "td": true, "th": true, "pre": true,
+}
+
+// koboSkipTags hold text that is not reading content
+var koboSkipTags = map[string]bool{"style": true, "script": true}
+
+// sentenceClosers may follow a sentence terminator before the sentence ends
+const sentenceClosers = "\"'»”’)]"
+
+// addKoboSpans turns an XHTML content document into its KEPUB form: every
+// sentence is wrapped in a koboSpan numbered "kobo.<paragraph>.<sentence>",
+// which Kobo readers use for reading statistics, highlights and page turns,
+// and the body content is wrapped in the book-columns and book-inner divs.
+func addKoboSpans(document string) string {
+	bodyStart := strings.Index(document, "<body")
+	if bodyStart < 0 {
+		return document
+	}
+	bodyOpenEnd := strings.IndexByte(document[bodyStart:], '>')
+	bodyClose := strings.LastIndex(document, "</body>")
+	if bodyOpenEnd < 0 || bodyClose < 0 {
+		return document
+	}
+	bodyOpenEnd += bodyStart + 1
+
+	var result strings.Builder
+	result.Grow(len(document) * 2)
+	result.WriteString(document[:bodyOpenEnd])
+	result.WriteString(`<div id="book-columns"><div id="book-inner">`)
+
+	paragraph, sentence, skipDepth := 0, 0, 0
+	markup := document[bodyOpenEnd:bodyClose]
+	for len(markup) > 0 {
+		tagStart := strings.IndexByte(markup, '<')
+		if tagStart < 0 {
+			tagStart = len(markup)
+		}
+		if text := markup[:tagStart]; skipDepth > 0 || strings.TrimSpace(text) == "" {
+			result.WriteString(text)
+		} else {
+			for _, segment := range splitSentences(text) {
+				trimmed := strings.TrimLeftFunc(segment, unicode.IsSpace)
+				result.WriteString(segment[:len(segment)-len(trimmed)])
+				if trimmed == "" {
+					continue
+				}
+				sentence++
+				fmt.Fprintf(&result, `<span class="koboSpan" id="kobo.%d.%d">%s</span>`, paragraph, sentence, trimmed)
+			}
+		}
+		markup = markup[tagStart:]
+
+		tagEnd := strings.IndexByte(markup, '>')
+		if tagEnd < 0 {
+			result.WriteString(markup)
+			break
+		}
+		tag := markup[:tagEnd+1]
+		result.WriteString(tag)
+		name, closing, selfClosing := parseTagName(tag)
+		switch {
+		case koboSkipTags[name] && !selfClosing && closing:
+			if skipDepth > 0 {
+				skipDepth--
+			}
+		case koboSkipTags[name] && !selfClosing:
+			skipDepth++
+		case koboParagraphTags[name] && !closing:
+			paragraph++
+			sentence = 0
+		}
+		markup = markup[tagEnd+1:]
+	}
+
+	result.WriteString(`</div></div>`)
+	result.WriteString(document[bodyClose:])
+	return result.String()
+}
+
+// splitSentences cuts escaped text after each sentence terminator that is
+// followed by whitespace. The whitespace stays with the next segment.
+func splitSentences(text string) []string {
+	var segments []string
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if r != '.' && r != '!' && r != '?' && r != '…' {
+			continue
+		}
+
+		// Keep runs like "?!" or "..." and closing quotes with the sentence
+		for i < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[i:])
+			if next != '.' && next != '!' && next != '?' && next != '…' && !strings.ContainsRune(sentenceClosers, next) {
+				break
+			}
+			i += nextSize
+		}
+		if next, _ := utf8.DecodeRuneInString(text[i:]); i < len(text) && unicode.IsSpace(next) {
+			segments = append(segments, text[start:i])
+			start = i
+		}
+	}
+	return append(segments, text[start:])
+}
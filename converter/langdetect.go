@@ -0,0 +1,204 @@
+package converter
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// languageSampleLength is how many characters of a book detectLanguage looks at
+const languageSampleLength = 4000
+
+// minLanguageEvidence is how many letters, or stop words for languages written
+// in the Latin script, a sample needs before a language is guessed from it
+const minLanguageEvidence = 20
+
+// languageStopWords are frequent short words telling apart the languages written in the Latin script
+var languageStopWords = map[string][]string{
+	"en": {"the", "and", "of", "to", "was", "he", "that", "it", "his", "with", "is", "you", "for", "had", "she", "her"},
+	"de": {"der", "die", "und", "das", "nicht", "ist", "ich", "sie", "zu", "den", "mit", "sich", "es", "ein", "auf", "war"},
+	"fr": {"le", "la", "les", "et", "des", "est", "une", "il", "que", "qui", "dans", "pas", "du", "au", "je", "elle"},
+	"es": {"el", "la", "los", "las", "que", "y", "del", "se", "por", "una", "con", "no", "su", "para", "es", "lo"},
+	"it": {"il", "che", "di", "la", "non", "per", "del", "una", "sono", "gli", "con", "della", "ma", "si", "lo", "era"},
+	"pt": {"o", "que", "não", "da", "do", "uma", "os", "com", "para", "as", "em", "ele", "se", "mais", "como", "ao"},
+	"nl": {"de", "het", "een", "en", "van", "ik", "niet", "dat", "zijn", "op", "te", "hij", "met", "was", "maar", "voor"},
+	"pl": {"nie", "się", "w", "na", "i", "że", "z", "do", "to", "jest", "jak", "ale", "co", "tak", "po", "był"},
+	"cs": {"a", "se", "na", "je", "že", "to", "v", "ve", "jsem", "by", "ale", "jako", "jak", "tak", "už", "byl"},
+}
+
+// Letters found in one of the languages written in the Cyrillic script only
+const (
+	ukrainianLetters  = "іїєґ"
+	belarusianLetters = "ў"
+	serbianLetters    = "ђјљњћџ"
+)
+
+// detectLanguage guesses the language of a book that does not declare one from
+// the script and the stop words of the start of its text. It returns an empty
+// string when the text is too short to tell.
+func detectLanguage(fb2 *models.FictionBook) string {
+	return detectTextLanguage(languageSample(fb2))
+}
+
+// languageSample returns the first languageSampleLength characters of the
+// title, annotation and main text of a book
+func languageSample(fb2 *models.FictionBook) string {
+	var sample strings.Builder
+	add := func(text string) bool {
+		sample.WriteString(text)
+		sample.WriteByte(' ')
+		return sample.Len() < languageSampleLength
+	}
+
+	titleInfo := &fb2.Description.TitleInfo
+	if !add(titleInfo.BookTitle) || !add(annotationText(titleInfo.Annotation)) {
+		return sample.String()
+	}
+	var addSections func(sections []models.Section) bool
+	addSections = func(sections []models.Section) bool {
+		for i := range sections {
+			section := &sections[i]
+			if section.Title != nil {
+				for j := range section.Title.Paragraph {
+					if !add(extractParagraphText(&section.Title.Paragraph[j])) {
+						return false
+					}
+				}
+			}
+			for j := range section.Paragraph {
+				if !add(extractParagraphText(&section.Paragraph[j])) {
+					return false
+				}
+			}
+			if !addSections(section.Section) {
+				return false
+			}
+		}
+		return true
+	}
+	addSections(fb2.MainBody().Section)
+	return sample.String()
+}
+
+// detectTextLanguage guesses the language of text, or returns an empty string
+func detectTextLanguage(text string) string {
+	var latin, cyrillic, greek, hebrew, arabic, han, kana, hangul, georgian, armenian int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Greek, r):
+			greek++
+		case unicode.Is(unicode.Hebrew, r):
+			hebrew++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Georgian, r):
+			georgian++
+		case unicode.Is(unicode.Armenian, r):
+			armenian++
+		}
+	}
+
+	// The script with most letters decides, then the letters or words within it
+	scripts := []struct {
+		letters int
+		detect  func(string) string
+	}{
+		{latin, detectLatinLanguage},
+		{cyrillic, detectCyrillicLanguage},
+		{greek, fixedLanguage("el")},
+		{hebrew, fixedLanguage("he")},
+		{arabic, fixedLanguage("ar")},
+		// Japanese mixes kanji with kana, Chinese uses none
+		{han + kana, func(string) string {
+			if kana > 0 {
+				return "ja"
+			}
+			return "zh"
+		}},
+		{hangul, fixedLanguage("ko")},
+		{georgian, fixedLanguage("ka")},
+		{armenian, fixedLanguage("hy")},
+	}
+	best := 0
+	for i, script := range scripts {
+		if script.letters > scripts[best].letters {
+			best = i
+		}
+	}
+	if scripts[best].letters < minLanguageEvidence {
+		return ""
+	}
+	return scripts[best].detect(strings.ToLower(text))
+}
+
+// fixedLanguage returns a detector for a script written in one language
+func fixedLanguage(lang string) func(string) string {
+	return func(string) string {
+		return lang
+	}
+}
+
+// detectCyrillicLanguage tells the languages written in the Cyrillic script
+// apart by the letters only some of them use, Russian being the default. The
+// letters must outnumber those the language lacks, so a quote in another
+// language does not decide.
+func detectCyrillicLanguage(text string) string {
+	switch {
+	case countAny(text, serbianLetters) > countAny(text, "яюыэщ"):
+		return "sr"
+	case countAny(text, belarusianLetters) > 0 && countAny(text, "і") > countAny(text, "и"):
+		return "be"
+	case countAny(text, ukrainianLetters) > countAny(text, "ыэъё"):
+		return "uk"
+	case countAny(text, "ыэё") == 0 && countAny(text, "ъ") > countAny(text, "ь"):
+		// Bulgarian has no ы, э or ё and uses ъ as a vowel
+		return "bg"
+	}
+	return "ru"
+}
+
+// countAny counts the occurrences of any of chars in text
+func countAny(text, chars string) int {
+	count := 0
+	for _, r := range text {
+		if strings.ContainsRune(chars, r) {
+			count++
+		}
+	}
+	return count
+}
+
+// detectLatinLanguage picks the language whose stop words occur most often in
+// text, or returns an empty string when too few of them do
+func detectLatinLanguage(text string) string {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		counts[word]++
+	}
+
+	best, bestScore := "", 0
+	for _, lang := range []string{"en", "de", "fr", "es", "it", "pt", "nl", "pl", "cs"} {
+		score := 0
+		for _, word := range languageStopWords[lang] {
+			score += counts[word]
+		}
+		if score > bestScore {
+			best, bestScore = lang, score
+		}
+	}
+	if bestScore < minLanguageEvidence {
+		return ""
+	}
+	return best
+}
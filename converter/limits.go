@@ -0,0 +1,128 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// Limits caps the resources an input document may consume while it is parsed.
+// Zero fields are unlimited.
+type Limits struct {
+	MaxDepth        int   // Maximum element nesting depth
+	MaxBinarySize   int64 // Maximum total decoded size of the binaries, in bytes
+	MaxSectionDepth int   // Maximum nesting depth of sections
+	MaxSections     int   // Maximum number of sections
+	MaxParagraphs   int   // Maximum number of paragraphs
+}
+
+// LimitError reports that a document exceeded one of the configured limits
+type LimitError struct {
+	Limit string // Name of the exceeded limit
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("document exceeds the %s limit of %d", e.Limit, e.Max)
+}
+
+// limitedTokenReader passes tokens through while enforcing Limits. Entity
+// declarations are always rejected: encoding/xml never expands them, but a
+// document declaring them is crafted rather than a book.
+type limitedTokenReader struct {
+	decoder      xml.TokenReader
+	limits       *Limits
+	depth        int
+	inBinary     bool
+	binaryBytes  int64 // Base64 characters seen inside binary elements
+	sectionDepth int
+	sections     int
+	paragraphs   int
+}
+
+func (r *limitedTokenReader) Token() (xml.Token, error) {
+	token, err := r.decoder.Token()
+	if err != nil {
+		return token, err
+	}
+
+	switch t := token.(type) {
+	case xml.StartElement:
+		r.depth++
+		if r.limits.MaxDepth > 0 && r.depth > r.limits.MaxDepth {
+			return nil, &LimitError{Limit: "nesting depth", Max: int64(r.limits.MaxDepth)}
+		}
+		r.inBinary = t.Name.Local == "binary"
+		if err := r.countElement(t.Name.Local); err != nil {
+			return nil, err
+		}
+	case xml.EndElement:
+		r.depth--
+		r.inBinary = false
+		if t.Name.Local == "section" {
+			r.sectionDepth--
+		}
+	case xml.CharData:
+		if r.inBinary && r.limits.MaxBinarySize > 0 {
+			r.binaryBytes += int64(len(t) - countSpace(t))
+			if r.binaryBytes/4*3 > r.limits.MaxBinarySize {
+				return nil, &LimitError{Limit: "binary size", Max: r.limits.MaxBinarySize}
+			}
+		}
+	case xml.Directive:
+		if bytes.Contains(t, []byte("<!ENTITY")) {
+			return nil, fmt.Errorf("entity declarations are not allowed")
+		}
+	}
+	return token, nil
+}
+
+// countElement counts the sections and paragraphs of the document, failing
+// once there are more than the limits allow
+func (r *limitedTokenReader) countElement(name string) error {
+	switch name {
+	case "section":
+		r.sections++
+		r.sectionDepth++
+		if r.limits.MaxSections > 0 && r.sections > r.limits.MaxSections {
+			return &LimitError{Limit: "section count", Max: int64(r.limits.MaxSections)}
+		}
+		if r.limits.MaxSectionDepth > 0 && r.sectionDepth > r.limits.MaxSectionDepth {
+			return &LimitError{Limit: "section depth", Max: int64(r.limits.MaxSectionDepth)}
+		}
+	case "p":
+		r.paragraphs++
+		if r.limits.MaxParagraphs > 0 && r.paragraphs > r.limits.MaxParagraphs {
+			return &LimitError{Limit: "paragraph count", Max: int64(r.limits.MaxParagraphs)}
+		}
+	}
+	return nil
+}
+
+// countSpace returns the number of ASCII whitespace bytes in data
+func countSpace(data []byte) int {
+	count := 0
+	for _, b := range data {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			count++
+		}
+	}
+	return count
+}
+
+// parseFB2WithLimits parses an FB2 document like ParseFB2FromReader, failing
+// as soon as the document exceeds limits
+func parseFB2WithLimits(reader io.Reader, limits *Limits) (*models.FictionBook, error) {
+	tracker := newTrackingDecoder(reader)
+	var fb2 models.FictionBook
+	decoder := xml.NewTokenDecoder(&limitedTokenReader{decoder: tracker, limits: limits})
+	if err := decoder.Decode(&fb2); err != nil {
+		return nil, fmt.Errorf("failed to parse FB2 XML: %w", tracker.locate(err))
+	}
+
+	return &fb2, nil
+}
@@ -0,0 +1,148 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// Media types of the generated package resources
+const (
+	mediaTypeXHTML = "application/xhtml+xml"
+	mediaTypeNCX   = "application/x-dtbncx+xml"
+)
+
+// manifestItem is a resource listed in the OPF manifest
+type manifestItem struct {
+	ID         string
+	Href       string
+	MediaType  string
+	Properties []string
+}
+
+// buildManifest lists every resource of the package with its media type and
+// EPUB 3 properties. Images are sorted by ID so the output is stable.
+func buildManifest(
+	fb2 *models.FictionBook,
+	imageMap map[string]*ImageInfo,
+	backMatter []*backMatterBody,
+	fonts []embeddedFont,
+	colophon bool,
+) []manifestItem {
+	items := []manifestItem{
+		{ID: "ncx", Href: "toc.ncx", MediaType: mediaTypeNCX},
+		{ID: "nav", Href: "nav.xhtml", MediaType: mediaTypeXHTML, Properties: []string{"nav"}},
+		{ID: "cover", Href: "cover.xhtml", MediaType: mediaTypeXHTML},
+	}
+	if hasAnnotation(fb2) {
+		items = append(items, manifestItem{ID: "annotation", Href: annotationFile, MediaType: mediaTypeXHTML})
+	}
+	items = append(items, manifestItem{ID: "content", Href: "content.xhtml", MediaType: mediaTypeXHTML})
+
+	for _, bm := range backMatter {
+		items = append(items, manifestItem{ID: bm.ID, Href: bm.File, MediaType: mediaTypeXHTML})
+	}
+	if colophon {
+		items = append(items, manifestItem{ID: "colophon", Href: colophonFile, MediaType: mediaTypeXHTML})
+	}
+
+	// Duplicate binaries share one stored image, listed under its own ID
+	imageIDs := make([]string, 0, len(imageMap))
+	for imgID, imgInfo := range imageMap {
+		if imgID == imgInfo.ID {
+			imageIDs = append(imageIDs, imgID)
+		}
+	}
+	sort.Strings(imageIDs)
+
+	coverID := coverImageID(fb2, imageMap)
+	for _, imgID := range imageIDs {
+		imgInfo := imageMap[imgID]
+		item := manifestItem{
+			ID:        imgID,
+			Href:      imgInfo.Path(),
+			MediaType: imgInfo.ContentType,
+		}
+		if imgID == coverID {
+			item.Properties = []string{"cover-image"}
+		}
+		items = append(items, item)
+	}
+
+	if len(fonts) > 0 {
+		items = append(items, manifestItem{ID: "fonts-css", Href: fontStylesheet, MediaType: "text/css"})
+	}
+	for _, font := range fonts {
+		items = append(items, manifestItem{ID: font.ID, Href: font.Href, MediaType: font.MediaType})
+	}
+
+	return items
+}
+
+// writeManifestItems renders manifest items as OPF <item> elements
+func writeManifestItems(items []manifestItem) string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		line := fmt.Sprintf(`<item id="%s" href="%s" media-type="%s"`,
+			html.EscapeString(item.ID), html.EscapeString(item.Href), html.EscapeString(item.MediaType))
+		if len(item.Properties) > 0 {
+			line += fmt.Sprintf(` properties="%s"`, strings.Join(item.Properties, " "))
+		}
+		lines = append(lines, line+"/>")
+	}
+	return strings.Join(lines, "\n    ")
+}
+
+// landmark is a structural entry point of the book, listed in the OPF guide
+// and in the landmarks navigation of nav.xhtml
+type landmark struct {
+	GuideType string // EPUB 2 guide reference type
+	EpubType  string // EPUB 3 structural semantics
+	Title     string
+	Href      string
+}
+
+// buildLandmarks returns the main entry points of the book: cover, table of
+// contents, start of the text and the first notes body, if any
+func buildLandmarks(backMatter []*backMatterBody, l *labels) []landmark {
+	landmarks := []landmark{
+		{GuideType: "cover", EpubType: "cover", Title: l.Cover, Href: "cover.xhtml"},
+		{GuideType: "toc", EpubType: "toc", Title: l.TableOfContents, Href: "nav.xhtml#toc"},
+		{GuideType: "text", EpubType: "bodymatter", Title: l.Content, Href: "content.xhtml"},
+	}
+	for _, bm := range backMatter {
+		if bm.Notes {
+			landmarks = append(landmarks, landmark{
+				GuideType: "notes",
+				EpubType:  bm.EpubType(),
+				Title:     bm.Title,
+				Href:      bm.File,
+			})
+			break
+		}
+	}
+	return landmarks
+}
+
+// writeGuide renders landmarks as EPUB 2 guide references for older readers
+func writeGuide(landmarks []landmark) string {
+	lines := make([]string, 0, len(landmarks))
+	for _, lm := range landmarks {
+		lines = append(lines, fmt.Sprintf(`<reference type="%s" title="%s" href="%s"/>`,
+			lm.GuideType, html.EscapeString(lm.Title), html.EscapeString(lm.Href)))
+	}
+	return strings.Join(lines, "\n    ")
+}
+
+// writeLandmarksNav renders landmarks as the EPUB 3 landmarks navigation list
+func writeLandmarksNav(landmarks []landmark) string {
+	var nav strings.Builder
+	for _, lm := range landmarks {
+		fmt.Fprintf(&nav, "      <li><a epub:type=\"%s\" href=\"%s\">%s</a></li>\n",
+			lm.EpubType, html.EscapeString(lm.Href), html.EscapeString(lm.Title))
+	}
+	return nav.String()
+}
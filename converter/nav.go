@@ -4,64 +4,61 @@ import (
 	"archive/zip"
 	"fmt"
 	"html"
+	"html/template"
 	"strings"
 
 	"github.com/lex/fb2epub/models"
 )
 
 // addNavXHTML creates EPUB 3.0 navigation document
-// Note: defaultTitle is defined in epubgenerator.go
-func addNavXHTML(writer *zip.Writer, fb2 *models.FictionBook) error {
+func addNavXHTML(
+	writer *zip.Writer,
+	templates *template.Template,
+	fb2 *models.FictionBook,
+	opts *Options,
+	pages []pageMarker,
+) error {
 	w, err := writer.Create("OEBPS/nav.xhtml")
 	if err != nil {
 		return err
 	}
 
-	title := fb2.Description.TitleInfo.BookTitle
-	if title == "" {
-		title = defaultTitle //nolint: ineffassign // title is used in fmt.Sprintf below
-	}
+	l := labelsFor(fb2.Description.TitleInfo.Lang)
 
 	// Build TOC from sections
-	tocEntries := buildTOC(fb2)
+	tocEntries := buildShapedTOC(fb2, opts)
 
 	// Build nav list
 	var navList strings.Builder
 
 	// Add cover
-	navList.WriteString(`    <li><a href="cover.xhtml">Cover</a></li>
-`)
+	fmt.Fprintf(&navList, "    <li><a href=\"cover.xhtml\">%s</a></li>\n", html.EscapeString(l.Cover))
+
+	// Add annotation page
+	if hasAnnotation(fb2) {
+		fmt.Fprintf(&navList, "    <li><a href=\"%s\">%s</a></li>\n", annotationFile, html.EscapeString(l.AboutBook))
+	}
 
 	// Add content
-	navList.WriteString(`    <li><a href="content.xhtml">Content</a></li>
-`)
+	fmt.Fprintf(&navList, "    <li><a href=\"content.xhtml\">%s</a></li>\n", html.EscapeString(l.Content))
 
 	// Add all section entries
 	for _, entry := range tocEntries {
 		writeNavEntry(&navList, entry, 0)
 	}
 
-	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE html>
-<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
-<head>
-  <title>Table of Contents</title>
-  <style type="text/css">
-    nav { font-family: serif; }
-    ol { list-style-type: none; padding-left: 1em; }
-    li { margin: 0.5em 0; }
-    a { text-decoration: none; color: inherit; }
-    a:hover { text-decoration: underline; }
-  </style>
-</head>
-<body>
-  <nav epub:type="toc" id="toc">
-    <h1>Table of Contents</h1>
-    <ol>
-%s    </ol>
-  </nav>
-</body>
-</html>`, navList.String())
+	content, err := renderTemplate(templates, navTemplate, navData{
+		Title:          l.TableOfContents,
+		TOC:            markup(navList.String()),
+		LandmarksTitle: l.Landmarks,
+		Landmarks:      markup(writeLandmarksNav(buildLandmarks(collectBackMatter(fb2, opts.Notes), l))),
+		PageList:       markup(writePageListNav(pages, l)),
+	})
+	if err != nil {
+		return err
+	}
+	content = setDocumentLanguage(content, bookLanguage(fb2))
+	content = applyTheme(content, opts.Theme)
 
 	_, err = w.Write([]byte(content))
 	return err
@@ -75,9 +72,9 @@ func writeNavEntry(builder *strings.Builder, entry *TOCEntry, indent int) {
 	indentStr := strings.Repeat("      ", indent+1)
 
 	if entry.Title != "" {
-		escapedID := html.EscapeString(entry.ID)
+		escapedHref := html.EscapeString(entry.Href())
 		escapedTitle := html.EscapeString(entry.Title)
-		fmt.Fprintf(builder, `%s<li><a href="content.xhtml#%s">%s</a>`, indentStr, escapedID, escapedTitle)
+		fmt.Fprintf(builder, `%s<li><a href="%s">%s</a>`, indentStr, escapedHref, escapedTitle)
 
 		if len(entry.Children) > 0 {
 			builder.WriteString("\n")
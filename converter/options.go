@@ -0,0 +1,225 @@
+package converter
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// coverOverrideID is the binary ID used for a cover image supplied with the request
+const coverOverrideID = "cover-override"
+
+// Stage identifies a step of the conversion pipeline reported to progress callbacks
+type Stage string
+
+// Conversion stages in the order they are reported
+const (
+	StageParsing   Stage = "parsing"
+	StageImages    Stage = "images"
+	StagePackaging Stage = "packaging"
+	StageContent   Stage = "content"
+	StageResources Stage = "resources"
+	StageDone      Stage = "done"
+)
+
+// ProgressFunc receives progress updates during a conversion.
+// Percent is an approximate overall completion between 0 and 100.
+type ProgressFunc func(stage Stage, percent int)
+
+// Options controls optional behavior of EPUB generation
+type Options struct {
+	Metadata MetadataOverrides
+
+	// Strict validates the FB2 structure before converting and fails with a
+	// *ValidationError listing line/column diagnostics instead of producing a partial EPUB
+	Strict bool
+
+	// Lenient recovers from malformed markup by dropping the broken sections
+	// and converting the rest; each repair is reported to OnWarning
+	Lenient bool
+
+	// HTMLEntities accepts HTML entities such as &nbsp; and &mdash;, which XML
+	// does not define, by replacing them with the characters they stand for
+	// before parsing; CDATA sections are kept as they are
+	HTMLEntities bool
+
+	// Limits caps the nesting depth, size and embedded binary size of the input
+	Limits Limits
+
+	// PruneImages drops the binaries no image of the book refers to, which
+	// often bloat FB2 files, instead of packaging them into the EPUB
+	PruneImages bool
+
+	// DisableCoverDetection turns off guessing a cover image for books without a coverpage
+	DisableCoverDetection bool
+
+	// GenerateCover renders a cover image with the title and author for books
+	// left without one, so library apps show a thumbnail for every book
+	GenerateCover bool
+
+	// Theme selects the color scheme; ThemeNight keeps the book readable in
+	// the dark modes of reading systems by using only colors relative to theirs
+	Theme Theme
+
+	// Typography converts straight quotes, double hyphens and spaces after short
+	// prepositions according to the book language
+	Typography bool
+
+	// DropCaps sets the opening paragraph of each section without indentation
+	// and turns its first letter into a drop cap
+	DropCaps bool
+
+	// Hyphenate inserts soft hyphens into paragraph text (Russian and English rules)
+	Hyphenate bool
+
+	// PageLength, if positive, places a page break every PageLength characters
+	// and lists the pages in nav.xhtml and toc.ncx so they can be cited
+	PageLength int
+
+	// TOCDepth limits the nesting levels of toc.ncx and nav.xhtml; zero keeps the full section tree
+	TOCDepth int
+
+	// UntitledSections selects how sections without a title are listed in the
+	// table of contents: left out (UntitledOmit, the default), titled with their
+	// first sentence or numbered as chapters
+	UntitledSections UntitledSections
+
+	// FlattenSingleChild collapses table of contents entries that wrap a single child section
+	FlattenSingleChild bool
+
+	// Fonts are embedded into the EPUB and the first family becomes the body font
+	Fonts []Font
+
+	// ObfuscateFonts scrambles embedded fonts with the IDPF algorithm so they
+	// cannot be extracted from the EPUB as plain font files
+	ObfuscateFonts bool
+
+	// Notes selects how notes bodies are rendered: in documents outside the
+	// reading order (NotesPopup, the default) or as endnotes read after the text
+	Notes NotesMode
+
+	// Kepub produces a Kobo KEPUB: sentences are wrapped in koboSpan elements
+	// for reading statistics. Such files are conventionally named .kepub.epub.
+	Kepub bool
+
+	// TemplateDir, if set, is a directory of templates that replace the built-in
+	// templates of the same name (cover.xhtml, content.xhtml, nav.xhtml, toc.ncx,
+	// content.opf and others), parsed with html/template for every conversion
+	TemplateDir string
+
+	// Colophon appends a page recording the source document (its ID, authors,
+	// date and the program that made it), the conversion date and the converter version
+	Colophon bool
+
+	// PDF sets the page layout used when converting to PDF
+	PDF PDFOptions
+
+	// OnProgress, if set, is called as the conversion moves between stages
+	OnProgress ProgressFunc
+
+	// OnWarning, if set, receives problems that were skipped instead of failing
+	// the conversion, such as repaired markup and undecodable binaries
+	OnWarning func(Diagnostic)
+
+	// OnPreview, if set, receives the PreviewHTML of the first chapter once parsing completes
+	OnPreview func(html string)
+}
+
+// reportProgress forwards a progress update to the configured callback, if any
+func (o *Options) reportProgress(stage Stage, percent int) {
+	if o.OnProgress != nil {
+		o.OnProgress(stage, percent)
+	}
+}
+
+// reportWarning forwards a skipped problem to the configured callback, if any
+func (o *Options) reportWarning(warning Diagnostic) {
+	if o.OnWarning != nil {
+		o.OnWarning(warning)
+	}
+}
+
+// MetadataOverrides holds values that take precedence over the FB2 description.
+// Empty fields leave the corresponding FB2 metadata untouched.
+type MetadataOverrides struct {
+	Title            string
+	Author           string // Comma-separated list of author names
+	Language         string
+	Series           string
+	SeriesIndex      string
+	CoverImage       []byte
+	CoverContentType string
+}
+
+// DefaultOptions returns the options used by GenerateEPUB
+func DefaultOptions() *Options {
+	return &Options{}
+}
+
+// applyMetadataOverrides returns a copy of fb2 with the overridden metadata applied
+// and, when neither names a language, the detected one. The original book is never modified.
+func applyMetadataOverrides(fb2 *models.FictionBook, overrides *MetadataOverrides) *models.FictionBook {
+	book := *fb2
+	titleInfo := &book.Description.TitleInfo
+
+	if title := strings.TrimSpace(overrides.Title); title != "" {
+		titleInfo.BookTitle = title
+	}
+
+	if authors := parseAuthorOverride(overrides.Author); len(authors) > 0 {
+		titleInfo.Author = authors
+	}
+
+	if lang := strings.TrimSpace(overrides.Language); lang != "" {
+		titleInfo.Lang = lang
+	} else if strings.TrimSpace(titleInfo.Lang) == "" {
+		// Readers pick dictionaries and hyphenation by the language
+		titleInfo.Lang = detectLanguage(fb2)
+	}
+
+	if series := strings.TrimSpace(overrides.Series); series != "" {
+		titleInfo.Sequence = []models.Sequence{{Name: series, Number: strings.TrimSpace(overrides.SeriesIndex)}}
+	} else if index := strings.TrimSpace(overrides.SeriesIndex); index != "" && len(titleInfo.Sequence) > 0 {
+		titleInfo.Sequence = append([]models.Sequence(nil), titleInfo.Sequence...)
+		titleInfo.Sequence[0].Number = index
+	}
+
+	if len(overrides.CoverImage) > 0 {
+		contentType := overrides.CoverContentType
+		if contentType == "" {
+			contentType = "image/jpeg"
+		}
+		book.Binary = append(append([]models.Binary(nil), fb2.Binary...), models.Binary{
+			ID:          coverOverrideID,
+			ContentType: contentType,
+			Data:        base64.StdEncoding.EncodeToString(overrides.CoverImage),
+		})
+		titleInfo.Coverpage = &models.Coverpage{
+			Image: []models.Image{{Href: "#" + coverOverrideID}},
+		}
+	}
+
+	return &book
+}
+
+// parseAuthorOverride splits a comma-separated author list into FB2 authors.
+// The last word of each name is treated as the last name.
+func parseAuthorOverride(value string) []models.Author {
+	var authors []models.Author
+	for _, name := range strings.Split(value, ",") {
+		words := strings.Fields(name)
+		switch len(words) {
+		case 0:
+			continue
+		case 1:
+			authors = append(authors, models.Author{Nickname: words[0]})
+		default:
+			authors = append(authors, models.Author{
+				FirstName: strings.Join(words[:len(words)-1], " "),
+				LastName:  words[len(words)-1],
+			})
+		}
+	}
+	return authors
+}
@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// originalWork is the book a translation was made from, as described by the
+// src-title-info and src-lang of an FB2 document
+type originalWork struct {
+	Title    string
+	Authors  []string
+	Language string
+}
+
+// originalOf returns the original of a translated book; it is empty for books
+// that do not describe one
+func originalOf(fb2 *models.FictionBook) originalWork {
+	var original originalWork
+	original.Language = strings.TrimSpace(fb2.Description.TitleInfo.SrcLang)
+	if src := fb2.Description.SrcTitleInfo; src != nil {
+		original.Title = strings.TrimSpace(src.BookTitle)
+		for _, author := range src.Author {
+			if name := buildAuthorName(author); name != "" {
+				original.Authors = append(original.Authors, name)
+			}
+		}
+		if original.Language == "" {
+			original.Language = strings.TrimSpace(src.Lang)
+		}
+	}
+	return original
+}
+
+func (o originalWork) isEmpty() bool {
+	return o.Title == "" && len(o.Authors) == 0 && o.Language == ""
+}
+
+// attribution returns the original title and authors on one line, e.g.
+// "Война и мир — Лев Толстой"; empty when the book names neither
+func (o originalWork) attribution() string {
+	authors := strings.Join(o.Authors, ", ")
+	switch {
+	case o.Title != "" && authors != "":
+		return o.Title + " — " + authors
+	case o.Title != "":
+		return o.Title
+	}
+	return authors
+}
+
+// buildOriginalMeta describes the original of a translated book in the
+// package document as the schema.org work it is a translation of, refined with
+// its authors and language. Without an original title the book title is used.
+func buildOriginalMeta(fb2 *models.FictionBook, title string) string {
+	original := originalOf(fb2)
+	if original.isEmpty() {
+		return ""
+	}
+	if original.Title != "" {
+		title = original.Title
+	}
+
+	var meta strings.Builder
+	fmt.Fprintf(&meta, "\n    <meta property=\"schema:translationOfWork\" id=\"original\"%s>%s</meta>",
+		xmlLangAttr(original.Language), html.EscapeString(title))
+	for _, author := range original.Authors {
+		fmt.Fprintf(&meta, "\n    <meta refines=\"#original\" property=\"schema:author\">%s</meta>",
+			html.EscapeString(author))
+	}
+	if original.Language != "" {
+		fmt.Fprintf(&meta, "\n    <meta refines=\"#original\" property=\"schema:inLanguage\">%s</meta>",
+			html.EscapeString(original.Language))
+	}
+	return meta.String()
+}
+
+// xmlLangAttr returns an xml:lang attribute for lang, or nothing when it is empty
+func xmlLangAttr(lang string) string {
+	if lang == "" {
+		return ""
+	}
+	return fmt.Sprintf(` xml:lang="%s"`, html.EscapeString(lang))
+}
@@ -0,0 +1,215 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// pageMarker is a synthesized page break placed in an XHTML file
+type pageMarker struct {
+	Number int
+	File   string
+}
+
+// ID returns the anchor ID of the page break
+func (m pageMarker) ID() string {
+	return fmt.Sprintf("page-%d", m.Number)
+}
+
+// Href returns the link target of the page break relative to the OEBPS directory
+func (m pageMarker) Href() string {
+	return m.File + "#" + m.ID()
+}
+
+// paginator splits the text of the book into pages of a fixed number of characters.
+// It is fed documents in reading order so page numbers continue across files.
+// A nil paginator leaves documents untouched.
+type paginator struct {
+	pageLength int
+	count      int // Characters counted since the last page break
+	pages      []pageMarker
+}
+
+// newPaginator returns a paginator for pageLength characters per page, or nil if pageLength is not positive
+func newPaginator(pageLength int) *paginator {
+	if pageLength <= 0 {
+		return nil
+	}
+	return &paginator{pageLength: pageLength}
+}
+
+// Pages returns the page breaks inserted so far
+func (p *paginator) Pages() []pageMarker {
+	if p == nil {
+		return nil
+	}
+	return p.pages
+}
+
+// paginate inserts page-break markers into the body of an XHTML document.
+// The first page starts at the beginning of the first document; later breaks
+// are placed at the first word boundary after every pageLength characters.
+func (p *paginator) paginate(document, file string) string {
+	if p == nil {
+		return document
+	}
+
+	bodyStart := strings.Index(document, "<body")
+	if bodyStart < 0 {
+		return document
+	}
+	bodyEnd := strings.IndexByte(document[bodyStart:], '>')
+	if bodyEnd < 0 {
+		return document
+	}
+	bodyEnd += bodyStart + 1
+
+	var result strings.Builder
+	result.Grow(len(document))
+	result.WriteString(document[:bodyEnd])
+	if len(p.pages) == 0 {
+		result.WriteString(p.marker(file))
+	}
+
+	markup := document[bodyEnd:]
+	for len(markup) > 0 {
+		tagStart := strings.IndexByte(markup, '<')
+		if tagStart < 0 {
+			tagStart = len(markup)
+		}
+		p.writeText(&result, markup[:tagStart], file)
+		markup = markup[tagStart:]
+
+		tagEnd := strings.IndexByte(markup, '>')
+		if tagEnd < 0 {
+			result.WriteString(markup)
+			break
+		}
+		result.WriteString(markup[:tagEnd+1])
+		markup = markup[tagEnd+1:]
+	}
+
+	return result.String()
+}
+
+// writeText copies a run of escaped text, inserting page breaks at whitespace
+// once the current page is full. Whitespace-only runs are layout and not counted.
+func (p *paginator) writeText(result *strings.Builder, text, file string) {
+	if strings.TrimSpace(text) == "" {
+		result.WriteString(text)
+		return
+	}
+
+	for len(text) > 0 {
+		remaining := p.pageLength - p.count
+		cut := runeOffset(text, remaining)
+		if cut < 0 {
+			p.count += textLength(text)
+			result.WriteString(text)
+			return
+		}
+
+		// Move the break to the next whitespace so words are not split
+		space := strings.IndexFunc(text[cut:], unicode.IsSpace)
+		if space < 0 {
+			p.count += textLength(text)
+			result.WriteString(text)
+			return
+		}
+		cut += space
+
+		result.WriteString(text[:cut])
+		result.WriteString(p.marker(file))
+		p.count = 0
+		text = text[cut:]
+	}
+}
+
+// marker records a new page and returns its break element
+func (p *paginator) marker(file string) string {
+	page := pageMarker{Number: len(p.pages) + 1, File: file}
+	p.pages = append(p.pages, page)
+	return fmt.Sprintf(`<span epub:type="pagebreak" role="doc-pagebreak" id="%s" title="%d"></span>`,
+		page.ID(), page.Number)
+}
+
+// runeOffset returns the byte offset of the n-th character of escaped text,
+// or -1 if the text is shorter. Entities count as one character.
+func runeOffset(text string, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	for i := 0; i < len(text); {
+		if n == 0 {
+			return i
+		}
+		if text[i] == '&' {
+			if end := strings.IndexByte(text[i:], ';'); end > 0 {
+				i += end + 1
+				n--
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if string(r) != softHyphen {
+			n--
+		}
+		i += size
+	}
+	return -1
+}
+
+// textLength returns the number of characters in escaped text, ignoring soft hyphens
+func textLength(text string) int {
+	text = html.UnescapeString(text)
+	return utf8.RuneCountInString(text) - strings.Count(text, softHyphen)
+}
+
+// writePageListNav renders the EPUB 3 page-list navigation for nav.xhtml
+func writePageListNav(pages []pageMarker, l *labels) string {
+	if len(pages) == 0 {
+		return ""
+	}
+
+	var nav strings.Builder
+	fmt.Fprintf(&nav, `  <nav epub:type="page-list" id="page-list" hidden="hidden">
+    <h2>%s</h2>
+    <ol>
+`, html.EscapeString(l.Pages))
+	for _, page := range pages {
+		fmt.Fprintf(&nav, "      <li><a href=\"%s\">%d</a></li>\n", page.Href(), page.Number)
+	}
+	nav.WriteString(`    </ol>
+  </nav>
+`)
+	return nav.String()
+}
+
+// writeNCXPageList renders the NCX pageList, numbering play order from playOrder
+func writeNCXPageList(pages []pageMarker, playOrder int, l *labels) string {
+	if len(pages) == 0 {
+		return ""
+	}
+
+	var pageList strings.Builder
+	fmt.Fprintf(&pageList, `  <pageList>
+    <navLabel>
+      <text>%s</text>
+    </navLabel>
+`, html.EscapeString(l.Pages))
+	for _, page := range pages {
+		fmt.Fprintf(&pageList, `    <pageTarget id="%s" type="normal" value="%d" playOrder="%d">
+      <navLabel>
+        <text>%d</text>
+      </navLabel>
+      <content src="%s"/>
+    </pageTarget>
+`, page.ID(), page.Number, playOrder, page.Number, page.Href())
+		playOrder++
+	}
+	pageList.WriteString("  </pageList>\n")
+	return pageList.String()
+}
@@ -0,0 +1,562 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF decoding for image checks
+	_ "image/jpeg" // Register JPEG decoding for image checks
+	_ "image/png"  // Register PNG decoding for image checks
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/lex/fb2epub/book"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PDF layout defaults, used for zero PDFOptions fields
+const (
+	defaultPDFPageSize = "A4"
+	defaultPDFMargin   = 20 // millimetres
+	defaultPDFFont     = "Times"
+	defaultPDFFontSize = 11 // points
+)
+
+// pdfPageSizes lists the supported page sizes by their lower-case name
+var pdfPageSizes = map[string]bool{"a4": true, "a5": true, "a6": true, "letter": true, "legal": true}
+
+// pdfCoreFonts lists the built-in PDF font families, which need no font file
+// but only cover Western European scripts
+var pdfCoreFonts = map[string]bool{"times": true, "helvetica": true, "courier": true}
+
+// pdfImageTypes maps the image types PDF can embed to their gofpdf names
+var pdfImageTypes = map[string]string{"jpeg": "JPG", "png": "PNG", "gif": "GIF"}
+
+// pdfBodyFont is the family name TrueType fonts are registered under
+const pdfBodyFont = "body"
+
+// mmPerPoint converts font sizes to the millimetre page units
+const mmPerPoint = 25.4 / 72
+
+// PDFOptions controls the page layout of PDF output
+type PDFOptions struct {
+	PageSize string // A4 (default), A5, A6, Letter or Legal
+	Margin   int    // Page margins in millimetres; zero means 20
+	Font     string // Times (default), Helvetica or Courier; ignored when TrueType fonts are given
+	FontSize int    // Body text size in points; zero means 11
+}
+
+// Validate reports unsupported page sizes and fonts
+func (p *PDFOptions) Validate() error {
+	if p.PageSize != "" && !pdfPageSizes[strings.ToLower(p.PageSize)] {
+		return fmt.Errorf("unsupported page size %q, expected A4, A5, A6, Letter or Legal", p.PageSize)
+	}
+	if p.Font != "" && !pdfCoreFonts[strings.ToLower(p.Font)] {
+		return fmt.Errorf("unsupported font %q, expected Times, Helvetica or Courier", p.Font)
+	}
+	return nil
+}
+
+// WritePDF renders a book as a paginated PDF. The first TrueType family among
+// opts.Fonts becomes the text font; without one the core font of opts.PDF is
+// used, which only covers Western European scripts. Images PDF cannot embed
+// are skipped and reported to OnWarning.
+func WritePDF(b *book.Book, w io.Writer, opts *Options) error {
+	return writePDF(context.Background(), b, w, opts)
+}
+
+func writePDF(ctx context.Context, b *book.Book, w io.Writer, opts *Options) error {
+	if err := opts.PDF.Validate(); err != nil {
+		return err
+	}
+	r := newPDFRenderer(b, opts)
+
+	opts.reportProgress(StageContent, 10)
+	r.titlePage()
+	if len(b.Front) > 0 {
+		r.pdf.AddPage()
+		r.blocks(b.Front)
+	}
+	for i, chapter := range b.Chapters {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.pdf.AddPage()
+		r.chapter(chapter, 0)
+		opts.reportProgress(StageContent, 10+80*(i+1)/len(b.Chapters))
+	}
+	for _, notes := range b.Notes {
+		r.pdf.AddPage()
+		r.chapter(notes, 0)
+	}
+
+	opts.reportProgress(StagePackaging, 90)
+	if err := r.pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	opts.reportProgress(StageDone, 100)
+	return nil
+}
+
+// pdfRenderer lays out a book on PDF pages
+type pdfRenderer struct {
+	pdf       *gofpdf.Fpdf
+	book      *book.Book
+	opts      *Options
+	family    string
+	fontSize  float64
+	lineH     float64
+	margin    float64
+	translate func(string) string
+	images    map[string]*gofpdf.ImageInfoType // Registered images by resource ID
+	links     map[string]int                   // Link targets by chapter ID
+}
+
+func newPDFRenderer(b *book.Book, opts *Options) *pdfRenderer {
+	layout := opts.PDF
+	if layout.PageSize == "" {
+		layout.PageSize = defaultPDFPageSize
+	}
+	if layout.Margin == 0 {
+		layout.Margin = defaultPDFMargin
+	}
+	if layout.Font == "" {
+		layout.Font = defaultPDFFont
+	}
+	if layout.FontSize == 0 {
+		layout.FontSize = defaultPDFFontSize
+	}
+
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: "P",
+		UnitStr:        "mm",
+		SizeStr:        strings.ToLower(layout.PageSize),
+	})
+	r := &pdfRenderer{
+		pdf:      pdf,
+		book:     b,
+		opts:     opts,
+		fontSize: float64(layout.FontSize),
+		lineH:    float64(layout.FontSize) * mmPerPoint * 1.4,
+		margin:   float64(layout.Margin),
+		images:   make(map[string]*gofpdf.ImageInfoType),
+		links:    make(map[string]int),
+	}
+
+	margin := r.margin
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	if r.registerTrueTypeFonts() {
+		r.family = pdfBodyFont
+		r.translate = func(s string) string { return s }
+	} else {
+		r.family = layout.Font
+		r.translate = r.coreFontTranslator()
+	}
+
+	metadata := &b.Metadata
+	pdf.SetTitle(metadata.Title, true)
+	pdf.SetAuthor(strings.Join(metadata.Authors, ", "), true)
+	pdf.SetCreator("fb2epub", true)
+	pdf.SetFooterFunc(func() {
+		if pdf.PageNo() == 1 {
+			return
+		}
+		pdf.SetY(-margin / 2)
+		pdf.SetFont(r.family, "", r.fontSize*0.8)
+		pdf.CellFormat(0, r.lineH/2, fmt.Sprintf("%d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	var collect func(chapters []*book.Chapter)
+	collect = func(chapters []*book.Chapter) {
+		for _, chapter := range chapters {
+			if chapter.ID != "" {
+				r.links[chapter.ID] = pdf.AddLink()
+			}
+			collect(chapter.Children)
+		}
+	}
+	collect(b.Chapters)
+	collect(b.Notes)
+	return r
+}
+
+// registerTrueTypeFonts registers the faces of the first TrueType family in
+// opts.Fonts, reusing the regular face for missing ones. It reports whether a
+// family was registered.
+func (r *pdfRenderer) registerTrueTypeFonts() bool {
+	faces := make(map[string][]byte)
+	family := ""
+	for _, font := range prepareFonts(r.opts.Fonts) {
+		if strings.ToLower(filepath.Ext(font.Href)) != ".ttf" || (family != "" && font.Family != family) {
+			continue
+		}
+		family = font.Family
+		style := ""
+		if font.Weight == "bold" {
+			style += "B"
+		}
+		if font.Style == "italic" {
+			style += "I"
+		}
+		if _, exists := faces[style]; !exists {
+			faces[style] = font.Data
+		}
+	}
+	if family == "" {
+		return false
+	}
+
+	regular, ok := faces[""]
+	if !ok {
+		for _, style := range []string{"B", "I", "BI"} {
+			if data, exists := faces[style]; exists {
+				regular = data
+				break
+			}
+		}
+	}
+	for _, style := range []string{"", "B", "I", "BI"} {
+		data, exists := faces[style]
+		if !exists {
+			data = regular
+		}
+		r.pdf.AddUTF8FontFromBytes(pdfBodyFont, style, data)
+	}
+	if err := r.pdf.Error(); err != nil {
+		r.pdf.ClearError()
+		r.opts.reportWarning(Diagnostic{Message: fmt.Sprintf("font family %q cannot be used in PDF: %v", family, err)})
+		return false
+	}
+	return true
+}
+
+// coreFontTranslator encodes text for the core fonts, which replace
+// characters outside Windows-1252 with dots. The first such loss is reported
+// so that users know to supply a TrueType font.
+func (r *pdfRenderer) coreFontTranslator() func(string) string {
+	cp1252 := r.pdf.UnicodeTranslatorFromDescriptor("")
+	warned := false
+	return func(s string) string {
+		encoded := cp1252(s)
+		if !warned && strings.Count(encoded, ".") > strings.Count(s, ".") {
+			warned = true
+			r.opts.reportWarning(Diagnostic{Message: fmt.Sprintf(
+				"the %s font cannot show some characters of the book; embed a TrueType font for PDF output", r.family)})
+		}
+		return encoded
+	}
+}
+
+// titlePage shows the cover image, or the title and authors when the book has no cover
+func (r *pdfRenderer) titlePage() {
+	r.pdf.AddPage()
+	if r.book.Cover != "" {
+		if info := r.image(r.book.Cover); info != nil {
+			width, height := r.pdf.GetPageSize()
+			r.drawImage(r.book.Cover, info, width-2*r.margin, height-2*r.margin)
+			r.pdf.AddPage()
+		}
+	}
+
+	_, height := r.pdf.GetPageSize()
+	r.pdf.SetY(height / 3)
+	r.pdf.SetFont(r.family, "B", r.fontSize*2)
+	r.pdf.MultiCell(0, r.lineH*2, r.translate(r.book.Metadata.Title), "", "C", false)
+	r.pdf.Ln(r.lineH)
+	r.pdf.SetFont(r.family, "", r.fontSize*1.3)
+	r.pdf.MultiCell(0, r.lineH*1.3, r.translate(strings.Join(r.book.Metadata.Authors, ", ")), "", "C", false)
+	if genres := genreNamesFor(r.book.Metadata.Genres, r.book.Metadata.Language); len(genres) > 0 {
+		r.pdf.Ln(r.lineH)
+		r.pdf.SetFont(r.family, "I", r.fontSize)
+		r.pdf.MultiCell(0, r.lineH, r.translate(strings.Join(genres, ", ")), "", "C", false)
+	}
+	original := originalWork{Title: r.book.Metadata.OriginalTitle, Authors: r.book.Metadata.OriginalAuthors}
+	if attribution := original.attribution(); attribution != "" {
+		r.pdf.Ln(r.lineH)
+		r.pdf.SetFont(r.family, "", r.fontSize*0.9)
+		text := labelsFor(r.book.Metadata.Language).Original + ": " + attribution
+		r.pdf.MultiCell(0, r.lineH*0.9, r.translate(text), "", "C", false)
+	}
+	if len(r.book.Metadata.Annotation) > 0 {
+		r.pdf.Ln(r.lineH * 2)
+		r.blocks(r.book.Metadata.Annotation)
+	}
+}
+
+// chapter renders a chapter title, its blocks and its children
+func (r *pdfRenderer) chapter(chapter *book.Chapter, depth int) {
+	if link, ok := r.links[chapter.ID]; ok {
+		r.pdf.SetLink(link, -1, -1)
+	}
+	if chapter.Title != "" {
+		scale := 1.0
+		switch depth {
+		case 0:
+			scale = 1.6
+		case 1:
+			scale = 1.3
+		case 2:
+			scale = 1.1
+		}
+		r.pdf.Bookmark(r.translate(chapter.Title), depth, -1)
+		r.pdf.SetFont(r.family, "B", r.fontSize*scale)
+		r.pdf.MultiCell(0, r.lineH*scale, r.translate(chapter.Title), "", "L", false)
+		r.pdf.Ln(r.lineH / 2)
+	}
+	r.blocks(chapter.Blocks)
+	for _, child := range chapter.Children {
+		r.pdf.Ln(r.lineH)
+		r.chapter(child, depth+1)
+	}
+}
+
+func (r *pdfRenderer) blocks(blocks []book.Block) {
+	for i := range blocks {
+		r.block(&blocks[i])
+	}
+}
+
+func (r *pdfRenderer) block(block *book.Block) {
+	switch block.Kind {
+	case book.EmptyLine:
+		r.pdf.Ln(r.lineH)
+	case book.Image:
+		if info := r.image(block.ImageID); info != nil {
+			width, height := r.pdf.GetPageSize()
+			r.drawImage(block.ImageID, info, width-2*r.margin, (height-2*r.margin)/2)
+		}
+	case book.Subtitle:
+		r.pdf.SetFont(r.family, "B", r.fontSize)
+		r.pdf.MultiCell(0, r.lineH, r.translate(book.SpansText(block.Spans)), "", "C", false)
+		r.pdf.Ln(r.lineH / 3)
+	case book.TextAuthor:
+		r.pdf.SetFont(r.family, "I", r.fontSize)
+		r.pdf.MultiCell(0, r.lineH, r.translate(book.SpansText(block.Spans)), "", "R", false)
+	case book.Verse:
+		r.spans(block.Spans)
+		r.pdf.Ln(r.lineH)
+	case book.Quote, book.Poem, book.Epigraph:
+		indent := 10.0
+		if block.Kind == book.Epigraph {
+			width, _ := r.pdf.GetPageSize()
+			indent = (width - 2*r.margin) / 3
+		}
+		left, _, _, _ := r.pdf.GetMargins()
+		r.pdf.SetLeftMargin(left + indent)
+		r.pdf.SetX(left + indent)
+		r.blocks(block.Children)
+		r.pdf.SetLeftMargin(left)
+		r.pdf.SetX(left)
+		r.pdf.Ln(r.lineH / 3)
+	case book.Stanza:
+		r.blocks(block.Children)
+		r.pdf.Ln(r.lineH / 2)
+	default:
+		r.paragraph(block.Spans)
+	}
+}
+
+// paragraph justifies plain text and flows styled text left-aligned. Inline
+// images follow the text.
+func (r *pdfRenderer) paragraph(spans []book.Span) {
+	plain := true
+	var text strings.Builder
+	var images []string
+	var textSpans []book.Span
+	for _, span := range spans {
+		if span.ImageID != "" {
+			images = append(images, span.ImageID)
+			continue
+		}
+		textSpans = append(textSpans, span)
+		text.WriteString(span.Text)
+		if span.Style != 0 || span.Href != "" {
+			plain = false
+		}
+	}
+
+	if len(textSpans) > 0 {
+		if plain {
+			r.pdf.SetFont(r.family, "", r.fontSize)
+			r.pdf.MultiCell(0, r.lineH, r.translate(text.String()), "", "J", false)
+		} else {
+			r.spans(textSpans)
+			r.pdf.Ln(r.lineH)
+		}
+		r.pdf.Ln(r.lineH / 3)
+	}
+	for _, id := range images {
+		if info := r.image(id); info != nil {
+			width, height := r.pdf.GetPageSize()
+			r.drawImage(id, info, width-2*r.margin, (height-2*r.margin)/2)
+		}
+	}
+}
+
+// spans writes styled text runs; links inside the book jump to their chapter
+func (r *pdfRenderer) spans(spans []book.Span) {
+	for _, span := range spans {
+		if span.Text == "" {
+			continue
+		}
+		style := ""
+		if span.Style&book.Strong != 0 {
+			style += "B"
+		}
+		if span.Style&book.Emphasis != 0 {
+			style += "I"
+		}
+		if span.Style&book.Strikethrough != 0 {
+			style += "S"
+		}
+		r.pdf.SetFont(r.family, style, r.fontSize)
+		text := r.translate(span.Text)
+
+		link, linkURL := 0, ""
+		if strings.HasPrefix(span.Href, "#") {
+			link = r.links[strings.TrimPrefix(span.Href, "#")]
+		} else if span.Href != "" {
+			linkURL = span.Href
+		}
+		if link != 0 || linkURL != "" {
+			r.pdf.SetTextColor(0, 0, 160)
+		}
+
+		switch {
+		case span.Style&book.Superscript != 0:
+			r.pdf.SubWrite(r.lineH, text, r.fontSize*0.6, r.fontSize*0.4, link, linkURL)
+		case span.Style&book.Subscript != 0:
+			r.pdf.SubWrite(r.lineH, text, r.fontSize*0.6, -r.fontSize*0.2, link, linkURL)
+		case link != 0:
+			r.pdf.WriteLinkID(r.lineH, text, link)
+		case linkURL != "":
+			r.pdf.WriteLinkString(r.lineH, text, linkURL)
+		default:
+			r.pdf.Write(r.lineH, text)
+		}
+		r.pdf.SetTextColor(0, 0, 0)
+	}
+}
+
+// image registers an image resource on first use. Resources PDF cannot embed
+// are reported once and return nil.
+func (r *pdfRenderer) image(id string) *gofpdf.ImageInfoType {
+	if info, seen := r.images[id]; seen {
+		return info
+	}
+	r.images[id] = nil
+
+	resource := r.book.Resource(id)
+	if resource == nil {
+		return nil
+	}
+	_, format, err := image.DecodeConfig(bytes.NewReader(resource.Data))
+	imageType, supported := pdfImageTypes[format]
+	if err != nil || !supported {
+		r.opts.reportWarning(Diagnostic{Message: fmt.Sprintf("skipped image %q: not a JPEG, PNG or GIF image", id)})
+		return nil
+	}
+
+	info := r.pdf.RegisterImageOptionsReader(id, gofpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(resource.Data))
+	if err := r.pdf.Error(); err != nil {
+		r.pdf.ClearError()
+		r.opts.reportWarning(Diagnostic{Message: fmt.Sprintf("skipped image %q: %v", id, err)})
+		return nil
+	}
+	r.images[id] = info
+	return info
+}
+
+// drawImage centers an image at the current position, scaled down to fit the
+// given box, starting a new page when it does not fit on the current one
+func (r *pdfRenderer) drawImage(id string, info *gofpdf.ImageInfoType, maxWidth, maxHeight float64) {
+	width, height := info.Width(), info.Height()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	scale := 1.0
+	if width > maxWidth {
+		scale = maxWidth / width
+	}
+	if height*scale > maxHeight {
+		scale = maxHeight / height
+	}
+	width, height = width*scale, height*scale
+
+	_, pageHeight := r.pdf.GetPageSize()
+	if r.pdf.GetY()+height > pageHeight-r.margin {
+		r.pdf.AddPage()
+	}
+	pageWidth, _ := r.pdf.GetPageSize()
+	y := r.pdf.GetY()
+	r.pdf.ImageOptions(id, (pageWidth-width)/2, y, width, height, false, gofpdf.ImageOptions{}, 0, "")
+	r.pdf.SetY(y + height + r.lineH/2)
+}
+
+// ConvertFileToPDFWithStatsContext converts the FB2 file at inputPath into a
+// PDF file at outputPath, laid out as configured by Options.PDF. Metadata
+// overrides, strict and lenient parsing and limits apply as for EPUB output.
+func (c *Converter) ConvertFileToPDFWithStatsContext(ctx context.Context, inputPath, outputPath string) (*Stats, error) {
+	stats := &Stats{}
+
+	//nolint:gosec // Path is controlled by the caller
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return stats, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		if closeErr := input.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+	if info, err := input.Stat(); err == nil {
+		stats.InputSize = info.Size()
+	}
+
+	opts := c.opts
+
+	opts.reportProgress(StageParsing, 0)
+	start := time.Now()
+	fb2, err := parseInputContext(ctx, input, &opts)
+	stats.ParseDuration = time.Since(start)
+	if err != nil {
+		return stats, contextError(ctx, err)
+	}
+	fb2 = applyMetadataOverrides(fb2, &opts.Metadata)
+	stats.describe(&fb2.Description.TitleInfo)
+	stats.ImageCount = len(fb2.Binary)
+	stats.ChapterCount = countChapters(fb2.MainBody().Section)
+
+	start = time.Now()
+	generateCtx, span := startSpan(ctx, "generate", attribute.String("output.format", "pdf"))
+	err = writePDFFile(generateCtx, BookFromFB2(fb2), outputPath, &opts)
+	endSpan(span, err)
+	stats.GenerateDuration = time.Since(start)
+	if err != nil {
+		return stats, contextError(ctx, err)
+	}
+	if info, err := os.Stat(outputPath); err == nil {
+		stats.OutputSize = info.Size()
+	}
+
+	return stats, nil
+}
+
+func writePDFFile(ctx context.Context, b *book.Book, outputPath string, opts *Options) error {
+	//nolint:gosec // Path is controlled by the caller
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create PDF file: %w", err)
+	}
+	err = writePDF(ctx, b, output, opts)
+	if closeErr := output.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// maxPreviewSize caps the preview of a book whose first section holds most of the text
+const maxPreviewSize = 32 * 1024
+
+// previewImagePattern matches inline images, which a preview has no files for
+var previewImagePattern = regexp.MustCompile(`\s*<img [^>]*/>`)
+
+// PreviewHTML renders the first section of the main body as an XHTML fragment
+// without images, cut at an element boundary after about 32KB. It returns ""
+// for a book without sections.
+func PreviewHTML(fb2 *models.FictionBook) string {
+	sections := fb2.MainBody().Section
+	if len(sections) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	processSectionWithID(&builder, &sections[0], 0, 0, "", nil)
+	preview := previewImagePattern.ReplaceAllString(builder.String(), "")
+
+	if len(preview) > maxPreviewSize {
+		if cut := strings.LastIndex(preview[:maxPreviewSize], "\n"); cut > 0 {
+			preview = preview[:cut+1]
+		}
+	}
+	return preview
+}
+
+// reportPreview sends the preview of a parsed book to the configured callback, if any
+func (o *Options) reportPreview(fb2 *models.FictionBook) {
+	if o.OnPreview != nil {
+		o.OnPreview(PreviewHTML(fb2))
+	}
+}
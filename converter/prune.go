@@ -0,0 +1,45 @@
+package converter
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// pruneUnreferencedBinaries returns fb2 without the binaries that no image
+// refers to, and the decoded size of the binaries dropped. Books without a
+// coverpage keep the binaries named like a cover, as cover detection may use
+// them; fb2 itself is not modified.
+func pruneUnreferencedBinaries(fb2 *models.FictionBook, keepCoverCandidates bool) (*models.FictionBook, int64) {
+	refs := imageRefs(fb2)
+	keepCoverCandidates = keepCoverCandidates && fb2.Description.TitleInfo.Coverpage == nil
+
+	var kept []models.Binary
+	var saved int64
+	for _, binary := range fb2.Binary {
+		if refs[binary.ID] || (keepCoverCandidates && strings.Contains(strings.ToLower(binary.ID), "cover")) {
+			kept = append(kept, binary)
+			continue
+		}
+		saved += decodedSize(binary.Data)
+	}
+	if saved == 0 && len(kept) == len(fb2.Binary) {
+		return fb2, 0
+	}
+
+	pruned := *fb2
+	pruned.Binary = kept
+	return &pruned, saved
+}
+
+// decodedSize returns the size of base64 data once decoded, ignoring the line
+// breaks binaries are usually wrapped with
+func decodedSize(data string) int64 {
+	encoded := strings.Join(strings.Fields(data), "")
+	size := base64.StdEncoding.DecodedLen(len(encoded)) - strings.Count(encoded, "=")
+	if size < 0 {
+		return 0
+	}
+	return int64(size)
+}
@@ -0,0 +1,253 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// parseInput parses an FB2 document honoring the Strict and Lenient options.
+// Errors caused by invalid input are returned as *ParseError.
+func parseInput(r io.Reader, opts *Options) (*models.FictionBook, error) {
+	r = newUTF8Reader(r)
+	var entities *htmlEntityFilter
+	if opts.HTMLEntities {
+		entities = newHTMLEntityFilter(r)
+		r = entities
+	}
+	filter := newXMLCharFilter(r)
+	fb2, err := parseFilteredInput(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if entities != nil && entities.replaced > 0 {
+		opts.reportWarning(Diagnostic{
+			Message: fmt.Sprintf("replaced %d HTML entities", entities.replaced),
+		})
+	}
+	if filter.removed > 0 {
+		opts.reportWarning(Diagnostic{
+			Message: fmt.Sprintf("removed %d control characters not allowed in XML", filter.removed),
+		})
+	}
+	return fb2, nil
+}
+
+// parseFilteredInput parses the document of parseInput, whose invalid
+// characters are removed as it is read
+func parseFilteredInput(r io.Reader, opts *Options) (*models.FictionBook, error) {
+	if !opts.Strict && !opts.Lenient {
+		fb2, err := parseFB2WithLimits(r, &opts.Limits)
+		if err != nil {
+			return nil, &ParseError{Err: err}
+		}
+		return fb2, nil
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	if opts.Strict {
+		if diagnostics := Validate(bytes.NewReader(data)); len(diagnostics) > 0 {
+			return nil, &ParseError{Err: &ValidationError{Diagnostics: diagnostics}}
+		}
+	}
+
+	fb2, err := parseFB2WithLimits(bytes.NewReader(data), &opts.Limits)
+	var limitErr *LimitError
+	if err != nil && opts.Lenient && !errors.As(err, &limitErr) {
+		repaired := repairFB2(data, opts.reportWarning)
+		if recovered, recoverErr := parseFB2WithLimits(bytes.NewReader(repaired), &opts.Limits); recoverErr == nil {
+			return recovered, nil
+		}
+	}
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	return fb2, nil
+}
+
+// openElement is an element written to the repaired document and not yet closed
+type openElement struct {
+	name   string
+	offset int // Position of the start tag in the output
+}
+
+// repairer rewrites a malformed FB2 document into well-formed XML
+type repairer struct {
+	data  []byte
+	out   bytes.Buffer
+	stack []openElement
+	warn  func(Diagnostic)
+}
+
+// repairFB2 returns a well-formed copy of a malformed FB2 document. Markup
+// errors inside a section drop that section and resume at the next one,
+// unmatched tags are closed or ignored and a truncated document is closed.
+// Every repair is reported to warn.
+func repairFB2(data []byte, warn func(Diagnostic)) []byte {
+	r := &repairer{data: data, warn: warn}
+	for start := 0; start < len(data); {
+		start = r.copyTokens(start)
+	}
+	for len(r.stack) > 0 {
+		r.closeTop()
+	}
+	return r.out.Bytes()
+}
+
+// copyTokens copies tokens from data[start:] until the input ends or a syntax
+// error stops the decoder, and returns the offset to continue from
+func (r *repairer) copyTokens(start int) int {
+	decoder := xml.NewDecoder(bytes.NewReader(r.data[start:]))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			if len(r.stack) > 0 {
+				r.report(start, decoder, "document ends inside <%s>, closing open elements", r.stack[len(r.stack)-1].name)
+			}
+			return len(r.data)
+		}
+		if err != nil {
+			return r.skipMalformed(start, decoder, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			r.stack = append(r.stack, openElement{name: qualifiedName(t.Name), offset: r.out.Len()})
+			r.out.WriteString("<" + qualifiedName(t.Name))
+			for _, attr := range t.Attr {
+				r.out.WriteString(" " + qualifiedName(attr.Name) + `="`)
+				_ = xml.EscapeText(&r.out, []byte(attr.Value))
+				r.out.WriteString(`"`)
+			}
+			r.out.WriteString(">")
+		case xml.EndElement:
+			r.endElement(start, decoder, qualifiedName(t.Name))
+		case xml.CharData:
+			_ = xml.EscapeText(&r.out, t)
+		case xml.ProcInst:
+			fmt.Fprintf(&r.out, "<?%s %s?>", t.Target, t.Inst)
+		}
+	}
+}
+
+// endElement closes the innermost open element with the given name, closing
+// any elements left open inside it. Closing tags without a match are dropped.
+func (r *repairer) endElement(start int, decoder *xml.Decoder, name string) {
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		if r.stack[i].name != name {
+			continue
+		}
+		for len(r.stack) > i+1 {
+			r.report(start, decoder, "closing unclosed element <%s>", r.stack[len(r.stack)-1].name)
+			r.closeTop()
+		}
+		r.closeTop()
+		return
+	}
+	r.report(start, decoder, "ignoring unmatched closing tag </%s>", name)
+}
+
+func (r *repairer) closeTop() {
+	r.out.WriteString("</" + r.stack[len(r.stack)-1].name + ">")
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+// skipMalformed recovers from a syntax error. Inside a section the section is
+// dropped and copying resumes at the next section boundary; elsewhere the
+// broken tag is skipped.
+func (r *repairer) skipMalformed(start int, decoder *xml.Decoder, err error) int {
+	message := err.Error()
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		message = syntaxErr.Msg
+	}
+
+	pos := start + int(decoder.InputOffset())
+	if pos <= start {
+		pos = start + 1
+	}
+	if pos >= len(r.data) {
+		r.report(start, decoder, "skipped malformed markup: %s", message)
+		return len(r.data)
+	}
+
+	section := -1
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		if r.stack[i].name == "section" {
+			section = i
+			break
+		}
+	}
+
+	if section < 0 {
+		r.report(start, decoder, "skipped malformed markup: %s", message)
+		next := bytes.IndexByte(r.data[pos:], '<')
+		if next < 0 {
+			return len(r.data)
+		}
+		return pos + next
+	}
+
+	r.report(start, decoder, "skipped malformed section: %s", message)
+	r.out.Truncate(r.stack[section].offset)
+	r.stack = r.stack[:section]
+
+	next, boundary := nextBoundary(r.data, pos)
+	if boundary == "</section" {
+		// The closing tag belongs to the dropped section
+		end := bytes.IndexByte(r.data[next:], '>')
+		if end < 0 {
+			return len(r.data)
+		}
+		return next + end + 1
+	}
+	return next
+}
+
+// sectionBoundaries are the tags at which copying resumes after a dropped section
+var sectionBoundaries = []string{"<section", "</section", "<body", "</body", "<binary", "</FictionBook"}
+
+// nextBoundary returns the offset and text of the first section boundary at or
+// after pos, or len(data) if there is none
+func nextBoundary(data []byte, pos int) (int, string) {
+	next, boundary := len(data), ""
+	for _, candidate := range sectionBoundaries {
+		if i := bytes.Index(data[pos:], []byte(candidate)); i >= 0 && pos+i < next {
+			next, boundary = pos+i, candidate
+		}
+	}
+	return next, boundary
+}
+
+// report sends a warning positioned at the decoder's location in the whole document
+func (r *repairer) report(start int, decoder *xml.Decoder, format string, args ...interface{}) {
+	line, column := decoder.InputPos()
+	if line == 1 {
+		column += start - (bytes.LastIndexByte(r.data[:start], '\n') + 1)
+	}
+	line += bytes.Count(r.data[:start], []byte{'\n'})
+	r.warn(Diagnostic{Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// qualifiedName returns a raw token name with its namespace prefix
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
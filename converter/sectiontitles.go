@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lex/fb2epub/models"
+)
+
+// maxDerivedTitleLength is the length in characters of a navigation title
+// taken from the text of an untitled section before it is cut off
+const maxDerivedTitleLength = 60
+
+// UntitledSections selects how sections without a title appear in the table of contents
+type UntitledSections string
+
+// Supported ways of listing untitled sections; the empty value is UntitledOmit
+const (
+	// UntitledOmit leaves untitled sections out, listing only their titled subsections
+	UntitledOmit UntitledSections = "omit"
+
+	// UntitledFirstLine titles them with the first sentence of their text
+	UntitledFirstLine UntitledSections = "first-line"
+
+	// UntitledNumber titles them by their position among their siblings, e.g. "Chapter 3"
+	UntitledNumber UntitledSections = "number"
+)
+
+// Validate reports an unsupported way of listing untitled sections
+func (u UntitledSections) Validate() error {
+	switch u {
+	case "", UntitledOmit, UntitledFirstLine, UntitledNumber:
+		return nil
+	default:
+		return fmt.Errorf("unsupported untitled sections mode %q, expected omit, first-line or number", string(u))
+	}
+}
+
+// untitledSectionTitle returns the navigation title of an untitled section,
+// the index-th of its parent, or an empty string to leave it out
+func untitledSectionTitle(section *models.Section, index int, mode UntitledSections, l *labels) string {
+	switch mode {
+	case UntitledFirstLine:
+		if line := firstSentence(section); line != "" {
+			return line
+		}
+		return l.UntitledSection
+	case UntitledNumber:
+		return fmt.Sprintf("%s %d", l.Chapter, index+1)
+	default:
+		return ""
+	}
+}
+
+// firstSentence returns the first sentence of the first paragraph with text
+// in a section or its subsections, shortened to a whole word when long
+func firstSentence(section *models.Section) string {
+	for _, block := range section.OrderedBlocks() {
+		var text string
+		switch block.Kind {
+		case models.ParagraphBlock:
+			text = strings.Join(strings.Fields(extractParagraphText(&section.Paragraph[block.Index])), " ")
+		case models.SubsectionBlock:
+			text = firstSentence(&section.Section[block.Index])
+		}
+		if text == "" {
+			continue
+		}
+
+		// A sentence ends at a full stop, question or exclamation mark followed by a space
+		for i, r := range text {
+			if strings.ContainsRune(".?!…", r) && strings.HasPrefix(text[i+utf8.RuneLen(r):], " ") {
+				text = text[:i+utf8.RuneLen(r)]
+				break
+			}
+		}
+		return truncateTitle(text)
+	}
+	return ""
+}
+
+// truncateTitle shortens text to maxDerivedTitleLength characters, cutting at
+// the last word boundary and marking the cut with an ellipsis
+func truncateTitle(text string) string {
+	if utf8.RuneCountInString(text) <= maxDerivedTitleLength {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:maxDerivedTitleLength])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,;:—-") + "…"
+}
@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the templates the documents of an EPUB are rendered from
+const (
+	coverTemplate      = "cover.xhtml"
+	contentTemplate    = "content.xhtml"
+	annotationTemplate = "annotation.xhtml"
+	backMatterTemplate = "backmatter.xhtml"
+	colophonTemplate   = "colophon.xhtml"
+	navTemplate        = "nav.xhtml"
+	ncxTemplate        = "toc.ncx"
+	opfTemplate        = "content.opf"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// defaultTemplates are the built-in templates, used unless Options.TemplateDir is set
+var defaultTemplates = template.Must(parseDefaultTemplates())
+
+// parseDefaultTemplates parses the built-in templates, named after their files
+func parseDefaultTemplates() (*template.Template, error) {
+	return template.ParseFS(templateFiles, "templates/*")
+}
+
+// loadTemplates returns the templates of a conversion: the built-in ones with
+// those of the same name in dir put in their place. Every other file of dir is
+// parsed as well, so the overrides can share templates defined there.
+func loadTemplates(dir string) (*template.Template, error) {
+	if dir == "" {
+		return defaultTemplates, nil
+	}
+
+	// Executed templates cannot be cloned, so the defaults are parsed again
+	templates, err := parseDefaultTemplates()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		//nolint:gosec // Path comes from the server configuration
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", entry.Name(), err)
+		}
+		if _, err := templates.New(entry.Name()).Parse(string(data)); err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", entry.Name(), err)
+		}
+	}
+	return templates, nil
+}
+
+// xmlDeclaration starts every document. Templates leave it out, as
+// html/template would escape it.
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// renderTemplate executes the named template with data, after the XML
+// declaration. The templates escape the text of the book, and characters XML
+// does not allow at all, such as control characters in metadata sent with a
+// request, are removed, so the document is always well-formed.
+func renderTemplate(templates *template.Template, name string, data interface{}) (string, error) {
+	var document strings.Builder
+	document.WriteString(xmlDeclaration)
+	if err := templates.ExecuteTemplate(&document, name, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	content, _ := stripInvalidXMLChars(document.String())
+	return content, nil
+}
+
+// coverData is the data of the cover.xhtml template
+type coverData struct {
+	Title         string
+	Author        string
+	Genres        []string // Genre names in the language of the book
+	Image         string   // Path of the cover image; empty for a text cover
+	OriginalLabel string
+	Original      string // Title and authors of the original of a translation; empty otherwise
+	OriginalLang  string // Language of the original, if known
+}
+
+// pageData is the data of the content.xhtml and annotation.xhtml templates
+type pageData struct {
+	Title string
+	Body  template.HTML
+}
+
+// backMatterData is the data of the backmatter.xhtml template, rendered once per extra body
+type backMatterData struct {
+	Title string
+	ID    string // Anchor of the heading
+	Type  string // epub:type of the body, e.g. footnotes
+	Body  template.HTML
+}
+
+// colophonData is the data of the colophon.xhtml template
+type colophonData struct {
+	Title        string
+	Entries      []colophonEntry
+	HistoryTitle string
+	History      []string // Paragraphs of the document history
+}
+
+// navData is the data of the nav.xhtml template
+type navData struct {
+	Title          string
+	TOC            template.HTML // List items of the table of contents
+	LandmarksTitle string
+	Landmarks      template.HTML // List items of the landmarks
+	PageList       template.HTML // Page list nav, empty without pagination
+}
+
+// ncxData is the data of the toc.ncx template
+type ncxData struct {
+	BookID    string
+	Title     string
+	Depth     int
+	PageCount int
+	NavMap    template.HTML // navPoint elements
+	PageList  template.HTML // pageList element, empty without pagination
+}
+
+// opfData is the data of the content.opf template
+type opfData struct {
+	Title    string
+	Author   string
+	Language string
+	BookID   string
+	Modified string
+	Metadata template.HTML // Optional metadata elements: description, cover, contributors, series, accessibility
+	Manifest template.HTML
+	Spine    template.HTML
+	Guide    template.HTML
+}
+
+// markup marks a fragment built by the converter, whose text is escaped
+// already, to be inserted into a template as is
+func markup(fragment string) template.HTML {
+	return template.HTML(fragment) //nolint:gosec // Fragments are built from escaped values
+}
@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"html"
+	"strings"
+)
+
+// textSkipTags lists elements whose text must not be altered by text passes
+var textSkipTags = map[string]bool{
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"title": true, "style": true,
+}
+
+// textPass transforms a run of plain (unescaped) text taken from between tags
+type textPass func(text string) string
+
+// textPassesFor returns the text passes enabled by the options for a book language
+func textPassesFor(lang string, opts *Options) []textPass {
+	var passes []textPass
+	if opts.Typography {
+		passes = append(passes, newTypographer(lang).apply)
+	}
+	if opts.Hyphenate {
+		if hyphenate := hyphenatorFor(lang); hyphenate != nil {
+			passes = append(passes, func(text string) string {
+				return hyphenateText(text, hyphenate)
+			})
+		}
+	}
+	return passes
+}
+
+// applyTextPasses runs the passes over the text nodes of an XHTML document body.
+// Markup and the document head are left untouched.
+func applyTextPasses(document string, passes []textPass) string {
+	if len(passes) == 0 {
+		return document
+	}
+
+	bodyStart := strings.Index(document, "<body")
+	if bodyStart < 0 {
+		return document
+	}
+
+	return document[:bodyStart] + mapTextNodes(document[bodyStart:], func(text string) string {
+		for _, pass := range passes {
+			text = pass(text)
+		}
+		return text
+	})
+}
+
+// mapTextNodes applies fn to the unescaped text of every run outside tags,
+// skipping the content of elements listed in textSkipTags. The result of fn
+// is escaped again before it is written back.
+func mapTextNodes(markup string, fn func(string) string) string {
+	var result strings.Builder
+	result.Grow(len(markup))
+
+	skipDepth := 0
+	for len(markup) > 0 {
+		tagStart := strings.IndexByte(markup, '<')
+		if tagStart < 0 {
+			tagStart = len(markup)
+		}
+
+		if text := markup[:tagStart]; text != "" {
+			if skipDepth > 0 {
+				result.WriteString(text)
+			} else {
+				result.WriteString(html.EscapeString(fn(html.UnescapeString(text))))
+			}
+		}
+		markup = markup[tagStart:]
+		if markup == "" {
+			break
+		}
+
+		tagEnd := strings.IndexByte(markup, '>')
+		if tagEnd < 0 {
+			result.WriteString(markup)
+			break
+		}
+		tag := markup[:tagEnd+1]
+		result.WriteString(tag)
+		if name, closing, selfClosing := parseTagName(tag); textSkipTags[name] && !selfClosing {
+			if closing {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+			} else {
+				skipDepth++
+			}
+		}
+		markup = markup[tagEnd+1:]
+	}
+
+	return result.String()
+}
+
+// parseTagName extracts the lowercase element name of a tag and whether it is a closing or self-closing tag
+func parseTagName(tag string) (name string, closing, selfClosing bool) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(tag, "<"), ">")
+	if strings.HasPrefix(inner, "/") {
+		closing = true
+		inner = inner[1:]
+	}
+	if strings.HasSuffix(inner, "/") {
+		selfClosing = true
+	}
+	if end := strings.IndexAny(inner, " \t\n/"); end >= 0 {
+		inner = inner[:end]
+	}
+	return strings.ToLower(inner), closing, selfClosing
+}
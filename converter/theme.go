@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Theme selects the color scheme of the stylesheets of a book
+type Theme string
+
+// Supported themes; the empty theme is ThemeDefault
+const (
+	// ThemeDefault sets no colors beyond the few the templates use
+	ThemeDefault Theme = "default"
+
+	// ThemeNight makes every element take its colors from the reading system,
+	// so the text stays readable when a reader switches to dark or sepia mode
+	ThemeNight Theme = "night"
+)
+
+// nightStyles override any color in the templates or the text with colors
+// relative to those the reading system sets on the body
+const nightStyles = `  <style type="text/css">
+    html, body, h1, h2, h3, h4, h5, h6, p, div, span, a, em, strong, blockquote, li, nav {
+      color: inherit; background-color: transparent; border-color: currentColor;
+    }
+    a { text-decoration: underline; }
+    .text-author, .date, .genres, .missing-image { opacity: 0.75; }
+  </style>
+`
+
+// Validate reports an unsupported theme
+func (t Theme) Validate() error {
+	switch t {
+	case "", ThemeDefault, ThemeNight:
+		return nil
+	default:
+		return fmt.Errorf("unsupported theme %q, expected default or night", string(t))
+	}
+}
+
+// applyTheme adds the styles of the theme at the end of the document head,
+// after the styles of the template so they take precedence
+func applyTheme(document string, theme Theme) string {
+	if theme != ThemeNight {
+		return document
+	}
+	return strings.Replace(document, "</head>", nightStyles+"</head>", 1)
+}
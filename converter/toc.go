@@ -0,0 +1,59 @@
+package converter
+
+import "github.com/lex/fb2epub/models"
+
+// buildShapedTOC builds the table of contents and applies the nesting options
+func buildShapedTOC(fb2 *models.FictionBook, opts *Options) []*TOCEntry {
+	entries := buildTOC(fb2, opts)
+	if opts.FlattenSingleChild {
+		entries = flattenSingleChildTOC(entries)
+	}
+	if opts.TOCDepth > 0 {
+		entries = limitTOCDepth(entries, opts.TOCDepth)
+	}
+	return entries
+}
+
+// flattenSingleChildTOC collapses entries that wrap exactly one child.
+// A titled wrapper keeps its own title and link and adopts the children of its
+// only child; an untitled wrapper is replaced by the child itself.
+func flattenSingleChildTOC(entries []*TOCEntry) []*TOCEntry {
+	result := make([]*TOCEntry, 0, len(entries))
+	for _, entry := range entries {
+		flattened := *entry
+		for len(flattened.Children) == 1 {
+			child := flattened.Children[0]
+			if flattened.Title == "" {
+				flattened = *child
+				continue
+			}
+			flattened.Children = child.Children
+		}
+		flattened.Children = flattenSingleChildTOC(flattened.Children)
+		result = append(result, &flattened)
+	}
+	return result
+}
+
+// limitTOCDepth drops entries nested deeper than maxDepth levels.
+// Untitled entries only group their children and do not count as a level.
+func limitTOCDepth(entries []*TOCEntry, maxDepth int) []*TOCEntry {
+	if maxDepth <= 0 {
+		return nil
+	}
+
+	result := make([]*TOCEntry, 0, len(entries))
+	for _, entry := range entries {
+		limited := *entry
+		if limited.Title == "" {
+			limited.Children = limitTOCDepth(entry.Children, maxDepth)
+			if len(limited.Children) == 0 {
+				continue
+			}
+		} else {
+			limited.Children = limitTOCDepth(entry.Children, maxDepth-1)
+		}
+		result = append(result, &limited)
+	}
+	return result
+}
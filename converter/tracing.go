@@ -0,0 +1,46 @@
+package converter
+
+import (
+	"context"
+	"io"
+
+	"github.com/lex/fb2epub/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the steps of conversions as OpenTelemetry spans. Until the
+// program installs a tracer provider, as the server does when tracing is
+// configured, spans are not recorded and cost next to nothing.
+var tracer = otel.Tracer("github.com/lex/fb2epub/converter")
+
+// startSpan starts the span of a conversion step, as a child of the span of ctx if any
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span of a conversion step, marking it failed with err if set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// parseInputContext parses an FB2 document like parseInput in a "parse" span,
+// giving up once ctx is done
+func parseInputContext(ctx context.Context, r io.Reader, opts *Options) (*models.FictionBook, error) {
+	ctx, span := startSpan(ctx, "parse")
+	fb2, err := parseInput(&contextReader{ctx: ctx, r: r}, opts)
+	if err == nil {
+		span.SetAttributes(
+			attribute.Int("fb2.binaries", len(fb2.Binary)),
+			attribute.Int("fb2.bodies", len(fb2.Body)),
+		)
+	}
+	endSpan(span, err)
+	return fb2, err
+}
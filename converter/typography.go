@@ -0,0 +1,201 @@
+package converter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// noBreakSpace keeps two words on the same line
+const noBreakSpace = "\u00A0"
+
+// quoteStyle holds the quotation marks of a language for outer and nested quotes
+type quoteStyle struct {
+	open, close           string
+	innerOpen, innerClose string
+}
+
+// Quotation styles by language
+var (
+	englishQuotes = quoteStyle{"“", "”", "‘", "’"}
+	russianQuotes = quoteStyle{"«", "»", "„", "“"}
+	germanQuotes  = quoteStyle{"„", "“", "‚", "‘"}
+	frenchQuotes  = quoteStyle{"«" + noBreakSpace, noBreakSpace + "»", "“", "”"}
+)
+
+// shortWords lists, per language, the prepositions, conjunctions and particles
+// that must not be left hanging at the end of a line
+var shortWords = map[string]map[string]bool{
+	"ru": wordSet("а в и к о с у я во да до за из ко на не ни но об от по со"),
+	"uk": wordSet("а в є з і й о у я до за із на не ні по та як"),
+	"be": wordSet("а з і ў у я да за на не ні па ад"),
+	"bg": wordSet("а в и с о у до за из на не ни по от със във"),
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// typographer applies typographic conventions of one language to text runs.
+// It remembers the quote nesting level between runs so that quotes split by
+// inline markup are still paired correctly.
+type typographer struct {
+	quotes     quoteStyle
+	shortWords map[string]bool
+	depth      int
+}
+
+// newTypographer returns a typographer for a language code; unknown languages get English quotes
+func newTypographer(lang string) *typographer {
+	base := baseLanguage(lang)
+	t := &typographer{quotes: englishQuotes, shortWords: shortWords[base]}
+	switch base {
+	case "ru", "uk", "be", "bg":
+		t.quotes = russianQuotes
+	case "de":
+		t.quotes = germanQuotes
+	case "fr":
+		t.quotes = frenchQuotes
+	}
+	return t
+}
+
+// apply converts quotes and dashes and binds short words to the following word
+func (t *typographer) apply(text string) string {
+	text = replaceDashes(text)
+	text = t.replaceQuotes(text)
+	if t.shortWords != nil {
+		text = bindShortWords(text, t.shortWords)
+	}
+	return text
+}
+
+// replaceDashes turns double hyphens into em dashes and spaced hyphens into
+// em dashes kept on the line of the preceding word. A hyphen opening a run
+// (dialogue) becomes an em dash bound to the following word.
+func replaceDashes(text string) string {
+	text = strings.ReplaceAll(text, "---", "—")
+	text = strings.ReplaceAll(text, "--", "—")
+	text = strings.ReplaceAll(text, " - ", noBreakSpace+"— ")
+	text = strings.ReplaceAll(text, " — ", noBreakSpace+"— ")
+
+	if rest, ok := strings.CutPrefix(text, "- "); ok {
+		return "—" + noBreakSpace + rest
+	}
+	if rest, ok := strings.CutPrefix(text, "— "); ok {
+		return "—" + noBreakSpace + rest
+	}
+	return text
+}
+
+// replaceQuotes converts straight quotes to the typographer's quotation marks.
+// Whether a quote opens or closes is decided from its neighbours; a quote with
+// no letters on either side falls back to the current nesting level.
+func (t *typographer) replaceQuotes(text string) string {
+	if !strings.ContainsAny(text, `"'`) {
+		return text
+	}
+
+	runes := []rune(text)
+	var result strings.Builder
+	result.Grow(len(text))
+
+	for i, r := range runes {
+		var prev, next rune
+		if i > 0 {
+			prev = runes[i-1]
+		}
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch r {
+		case '"':
+			if t.opensQuote(prev, next) {
+				if t.depth == 0 {
+					result.WriteString(t.quotes.open)
+				} else {
+					result.WriteString(t.quotes.innerOpen)
+				}
+				t.depth++
+			} else {
+				if t.depth > 0 {
+					t.depth--
+				}
+				if t.depth == 0 {
+					result.WriteString(t.quotes.close)
+				} else {
+					result.WriteString(t.quotes.innerClose)
+				}
+			}
+		case '\'':
+			// Apostrophes inside or at the end of words ("don't", "students'") become
+			// typographic apostrophes; others are single quotes
+			if unicode.IsLetter(prev) || unicode.IsDigit(prev) || !t.opensQuote(prev, next) {
+				result.WriteRune('’')
+			} else {
+				result.WriteRune('‘')
+			}
+		default:
+			result.WriteRune(r)
+		}
+	}
+
+	return result.String()
+}
+
+// opensQuote reports whether a quote between prev and next starts a quotation.
+// Zero runes stand for the edges of the text run.
+func (t *typographer) opensQuote(prev, next rune) bool {
+	prevBoundary := prev == 0 || isQuoteBoundary(prev)
+	nextBoundary := next == 0 || unicode.IsSpace(next) || unicode.IsPunct(next) && next != '(' && next != '…'
+	switch {
+	case prevBoundary && !nextBoundary:
+		return true
+	case !prevBoundary && nextBoundary:
+		return false
+	default:
+		return t.depth == 0
+	}
+}
+
+// isQuoteBoundary reports whether a quote following r is at the start of a quotation
+func isQuoteBoundary(r rune) bool {
+	if unicode.IsSpace(r) {
+		return true
+	}
+	switch r {
+	case '(', '[', '{', '—', '–', '-', '«', '„', '“', '‘':
+		return true
+	}
+	return false
+}
+
+// bindShortWords replaces the space after each listed short word with a
+// non-breaking space, so the word is never left at the end of a line
+func bindShortWords(text string, words map[string]bool) string {
+	runes := []rune(text)
+	var result strings.Builder
+	result.Grow(len(text))
+
+	wordStart := 0
+	for i, r := range runes {
+		if r == ' ' && i > wordStart && words[strings.ToLower(string(runes[wordStart:i]))] {
+			result.WriteString(noBreakSpace)
+		} else {
+			result.WriteRune(r)
+		}
+		// Only letters preceded by a space or punctuation form a candidate word;
+		// parts of hyphenated words and letters glued to digits never match
+		if !unicode.IsLetter(r) && r != '-' && !unicode.IsDigit(r) {
+			wordStart = i + 1
+		} else if !unicode.IsLetter(r) {
+			wordStart = len(runes)
+		}
+	}
+
+	return result.String()
+}
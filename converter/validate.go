@@ -0,0 +1,282 @@
+package converter
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// fb2Namespace is the XML namespace of FictionBook 2 documents
+const fb2Namespace = "http://www.gribuser.ru/xml/fictionbook/2.0"
+
+// maxDiagnostics caps the number of problems reported for one document
+const maxDiagnostics = 100
+
+// Diagnostic describes a problem found in an FB2 document
+type Diagnostic struct {
+	Line    int    `json:"line,omitempty"` // Zero when the problem has no source position
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return d.Message
+	}
+	return fmt.Sprintf("line %d, column %d: %s", d.Line, d.Column, d.Message)
+}
+
+// ValidationError reports that a document failed strict validation
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		messages = append(messages, d.String())
+	}
+	return "invalid FB2 document: " + strings.Join(messages, "; ")
+}
+
+// inlineElements may appear inside paragraphs and other text containers
+var inlineElements = []string{"strong", "emphasis", "style", "a", "strikethrough", "sub", "sup", "code", "image"}
+
+// fb2Children lists the child elements the FB2 2.0 schema allows for each
+// structural element. Elements not listed here are not checked.
+var fb2Children = map[string][]string{
+	"FictionBook":    {"stylesheet", "description", "body", "binary"},
+	"description":    {"title-info", "src-title-info", "document-info", "publish-info", "custom-info", "output"},
+	"title-info":     titleInfoChildren,
+	"src-title-info": titleInfoChildren,
+	"author":         authorChildren,
+	"translator":     authorChildren,
+	"document-info": {"author", "program-used", "date", "src-url", "src-ocr", "id", "version", "history",
+		"publisher"},
+	"publish-info": {"book-name", "publisher", "city", "year", "isbn", "sequence"},
+	"coverpage":    {"image"},
+	"sequence":     {"sequence"},
+	"body":         {"image", "title", "epigraph", "section"},
+	"section": {"title", "epigraph", "image", "annotation", "section", "p", "poem", "subtitle", "cite",
+		"empty-line", "table"},
+	"title":         {"p", "empty-line"},
+	"epigraph":      {"p", "poem", "cite", "empty-line", "text-author"},
+	"annotation":    {"p", "poem", "cite", "subtitle", "empty-line", "table"},
+	"history":       {"p", "poem", "cite", "subtitle", "empty-line", "table"},
+	"poem":          {"title", "epigraph", "stanza", "text-author", "date"},
+	"stanza":        {"title", "subtitle", "v"},
+	"cite":          {"p", "poem", "empty-line", "subtitle", "table", "text-author"},
+	"table":         {"tr"},
+	"tr":            {"th", "td"},
+	"p":             inlineElements,
+	"v":             inlineElements,
+	"subtitle":      inlineElements,
+	"text-author":   inlineElements,
+	"th":            inlineElements,
+	"td":            inlineElements,
+	"strong":        inlineElements,
+	"emphasis":      inlineElements,
+	"style":         inlineElements,
+	"a":             inlineElements,
+	"strikethrough": inlineElements,
+	"sub":           inlineElements,
+	"sup":           inlineElements,
+	"code":          inlineElements,
+}
+
+var titleInfoChildren = []string{"genre", "author", "book-title", "annotation", "keywords", "date", "coverpage",
+	"lang", "src-lang", "translator", "sequence"}
+
+var authorChildren = []string{"first-name", "middle-name", "last-name", "nickname", "home-page", "email", "id"}
+
+// requiredTitleInfo lists the title-info elements the schema requires
+var requiredTitleInfo = []string{"genre", "author", "book-title", "lang"}
+
+// validator walks the token stream of a document and collects diagnostics
+type validator struct {
+	decoder     *xml.Decoder
+	diagnostics []Diagnostic
+	stack       []string
+	children    []map[string]bool // Child element names seen, per open element
+	ids         map[string]bool
+	binaries    map[string]bool
+	imageRefs   []Diagnostic // Unresolved image references, checked at the end
+	bodies      int
+	description bool
+}
+
+// Validate checks an FB2 document against the structure of the FictionBook 2.0
+// schema and returns the problems found, or nil if the document is valid.
+// Malformed XML stops validation at the first syntax error.
+func Validate(r io.Reader) []Diagnostic {
+	decoder := xml.NewDecoder(newUTF8Reader(r))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	v := &validator{
+		decoder:  decoder,
+		ids:      make(map[string]bool),
+		binaries: make(map[string]bool),
+	}
+	v.run()
+	if len(v.diagnostics) > maxDiagnostics {
+		v.diagnostics = v.diagnostics[:maxDiagnostics]
+	}
+	return v.diagnostics
+}
+
+func (v *validator) report(format string, args ...interface{}) {
+	line, column := v.decoder.InputPos()
+	v.diagnostics = append(v.diagnostics, Diagnostic{Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) run() {
+	var binaryData *strings.Builder
+	for {
+		token, err := v.decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				v.report("malformed XML: %s", syntaxErr.Msg)
+			} else {
+				v.report("malformed XML: %v", err)
+			}
+			return
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			v.startElement(t)
+			if t.Name.Local == "binary" {
+				binaryData = &strings.Builder{}
+			}
+		case xml.CharData:
+			if binaryData != nil {
+				binaryData.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "binary" && binaryData != nil {
+				data := strings.Join(strings.Fields(binaryData.String()), "")
+				if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+					v.report("binary data is not valid base64")
+				}
+				binaryData = nil
+			}
+			v.endElement(t)
+		}
+	}
+
+	if len(v.stack) > 0 {
+		v.report("unexpected end of document inside <%s>", v.stack[len(v.stack)-1])
+		return
+	}
+	if !v.description {
+		v.report("missing required element <description>")
+	}
+	if v.bodies == 0 {
+		v.report("missing required element <body>")
+	}
+	for _, ref := range v.imageRefs {
+		if !v.binaries[strings.TrimPrefix(ref.Message, "#")] {
+			v.diagnostics = append(v.diagnostics, Diagnostic{
+				Line:    ref.Line,
+				Column:  ref.Column,
+				Message: fmt.Sprintf("image references unknown binary %q", ref.Message),
+			})
+		}
+	}
+}
+
+func (v *validator) startElement(t xml.StartElement) {
+	name := t.Name.Local
+
+	if len(v.stack) == 0 {
+		if name != "FictionBook" {
+			v.report("root element must be <FictionBook>, got <%s>", name)
+		} else if t.Name.Space != fb2Namespace {
+			v.report("root element must be in the %s namespace", fb2Namespace)
+		}
+	} else {
+		parent := v.stack[len(v.stack)-1]
+		if allowed, checked := fb2Children[parent]; checked && !containsString(allowed, name) {
+			v.report("unexpected element <%s> in <%s>", name, parent)
+		}
+		v.children[len(v.children)-1][name] = true
+	}
+
+	switch name {
+	case "description":
+		v.description = true
+	case "body":
+		v.bodies++
+	case "binary":
+		id, contentType := attrValue(t, "id"), attrValue(t, "content-type")
+		if id == "" {
+			v.report("<binary> is missing the id attribute")
+		}
+		if contentType == "" {
+			v.report("<binary> is missing the content-type attribute")
+		}
+		v.binaries[id] = true
+	case "image":
+		href := attrValue(t, "href")
+		if href == "" {
+			v.report("<image> is missing the href attribute")
+		} else if strings.HasPrefix(href, "#") {
+			line, column := v.decoder.InputPos()
+			v.imageRefs = append(v.imageRefs, Diagnostic{Line: line, Column: column, Message: href})
+		}
+	}
+
+	if id := attrValue(t, "id"); id != "" && name != "binary" {
+		if v.ids[id] {
+			v.report("duplicate id %q", id)
+		}
+		v.ids[id] = true
+	}
+
+	v.stack = append(v.stack, name)
+	v.children = append(v.children, make(map[string]bool))
+}
+
+func (v *validator) endElement(t xml.EndElement) {
+	name := t.Name.Local
+	children := v.children[len(v.children)-1]
+
+	if name == "title-info" {
+		for _, required := range requiredTitleInfo {
+			if !children[required] {
+				v.report("<title-info> is missing required element <%s>", required)
+			}
+		}
+	}
+
+	v.stack = v.stack[:len(v.stack)-1]
+	v.children = v.children[:len(v.children)-1]
+}
+
+// attrValue returns the value of an attribute by local name, in any namespace
+func attrValue(t xml.StartElement, name string) string {
+	for _, attr := range t.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package converter
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCharRefLength is the length of the longest character reference, &#x10FFFF;
+const maxCharRefLength = 10
+
+// isXMLChar reports whether r may appear in an XML 1.0 document: control
+// characters other than tab and line breaks, surrogates and U+FFFE/U+FFFF may not
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= utf8.MaxRune)
+}
+
+// stripInvalidXMLChars removes the characters XML 1.0 does not allow and
+// invalid UTF-8 from s, returning the result and how many were removed
+func stripInvalidXMLChars(s string) (string, int) {
+	valid := func(i int) (int, bool) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		return size, isXMLChar(r) && !(r == utf8.RuneError && size == 1)
+	}
+
+	// Documents rarely contain any, so only copy once one is found
+	i := 0
+	for i < len(s) {
+		size, ok := valid(i)
+		if !ok {
+			break
+		}
+		i += size
+	}
+	if i == len(s) {
+		return s, 0
+	}
+
+	var clean strings.Builder
+	clean.Grow(len(s))
+	clean.WriteString(s[:i])
+	removed := 0
+	for i < len(s) {
+		size, ok := valid(i)
+		if ok {
+			clean.WriteString(s[i : i+size])
+		} else {
+			removed++
+		}
+		i += size
+	}
+	return clean.String(), removed
+}
+
+// streamFilter rewrites a document chunk by chunk as it is read. Constructs
+// the rewrite looks at start with one of the bytes of starts and are at most
+// lookahead bytes long; those starting near the end of a chunk are held back
+// until the next read completes them, so rewrite always sees them whole.
+type streamFilter struct {
+	r         io.Reader
+	starts    string
+	lookahead int
+	rewrite   func(data []byte) []byte
+
+	buf     []byte
+	out     []byte
+	pending []byte // Construct cut off at the end of the last read
+	err     error
+}
+
+func newStreamFilter(r io.Reader, starts string, lookahead int, rewrite func([]byte) []byte) *streamFilter {
+	return &streamFilter{r: r, starts: starts, lookahead: lookahead, rewrite: rewrite, buf: make([]byte, 32*1024)}
+}
+
+func (f *streamFilter) Read(p []byte) (int, error) {
+	for len(f.out) == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+		n, err := f.r.Read(f.buf)
+		data := f.buf[:n]
+		if len(f.pending) > 0 {
+			data = append(f.pending, data...)
+			f.pending = nil
+		}
+		if err == nil {
+			window := len(data) - f.lookahead
+			if window < 0 {
+				window = 0
+			}
+			if start := bytes.IndexAny(data[window:], f.starts); start >= 0 {
+				f.pending = append([]byte(nil), data[window+start:]...)
+				data = data[:window+start]
+			}
+		}
+		f.out = f.rewrite(data)
+		f.err = err
+	}
+
+	n := copy(p, f.out)
+	f.out = f.out[n:]
+	return n, nil
+}
+
+// xmlCharFilter removes the control characters XML 1.0 does not allow from a
+// document as it is read, along with character references to them such as
+// &#1;, which encoding/xml rejects as well. FB2 files made by some tools are
+// full of them.
+type xmlCharFilter struct {
+	*streamFilter
+	removed int // Characters and references removed so far
+}
+
+// newXMLCharFilter returns a filter reading the document from r
+func newXMLCharFilter(r io.Reader) *xmlCharFilter {
+	f := &xmlCharFilter{}
+	f.streamFilter = newStreamFilter(r, "&", maxCharRefLength, f.filter)
+	return f
+}
+
+// filter returns data without the characters and references XML does not allow
+func (f *xmlCharFilter) filter(data []byte) []byte {
+	clean := data[:0]
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' {
+			f.removed++
+			continue
+		}
+		if b == '&' && i+1 < len(data) && data[i+1] == '#' {
+			if end := bytes.IndexByte(data[i:], ';'); end > 0 && end <= maxCharRefLength {
+				if r, ok := parseCharRef(data[i+2 : i+end]); ok && !isXMLChar(r) {
+					f.removed++
+					i += end
+					continue
+				}
+			}
+		}
+		clean = append(clean, b)
+	}
+	return clean
+}
+
+// parseCharRef parses the number of a character reference, such as 65 or x41
+func parseCharRef(ref []byte) (rune, bool) {
+	base := 10
+	if len(ref) > 0 && ref[0] == 'x' {
+		base, ref = 16, ref[1:]
+	}
+	n, err := strconv.ParseUint(string(ref), base, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
@@ -0,0 +1,193 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bytes of the document shown before and after the position of an XML error
+const (
+	snippetBefore = 60
+	snippetAfter  = 20
+)
+
+// XMLError reports where an FB2 document stopped parsing: the position, the
+// elements open at that point and the text around it. Offsets count bytes of
+// the document as decoded to UTF-8, which are those of the file for UTF-8
+// books without control characters.
+type XMLError struct {
+	Line    int
+	Column  int
+	Offset  int64
+	Element string // Path of the open elements, e.g. FictionBook/body/section/p
+	Snippet string // The text leading up to the error and just after it
+	Err     error
+}
+
+func (e *XMLError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "line %d, column %d (byte %d)", e.Line, e.Column, e.Offset)
+	if e.Element != "" {
+		fmt.Fprintf(&b, " in <%s>", e.Element)
+	}
+	b.WriteString(": " + e.message())
+	if e.Snippet != "" {
+		fmt.Fprintf(&b, " near %q", e.Snippet)
+	}
+	return b.String()
+}
+
+func (e *XMLError) Unwrap() error {
+	return e.Err
+}
+
+// message describes the error without the position encoding/xml adds to
+// syntax errors, and explains the end of a truncated document
+func (e *XMLError) message() string {
+	var syntaxErr *xml.SyntaxError
+	switch {
+	case errors.Is(e.Err, io.EOF):
+		return "document has no root element"
+	case errors.As(e.Err, &syntaxErr) && syntaxErr.Msg == "unexpected EOF":
+		if open := strings.LastIndexByte(e.Element, '/'); e.Element != "" {
+			return fmt.Sprintf("document ends before </%s> is closed", e.Element[open+1:])
+		}
+		return "document ends unexpectedly"
+	case errors.As(e.Err, &syntaxErr):
+		return syntaxErr.Msg
+	}
+	return e.Err.Error()
+}
+
+// trackingDecoder reads the tokens of a document while keeping what is needed
+// to tell where an error occurred: the open elements and the latest bytes read
+type trackingDecoder struct {
+	decoder *xml.Decoder
+	input   *tailReader
+	open    []string
+}
+
+func newTrackingDecoder(r io.Reader) *trackingDecoder {
+	input := &tailReader{r: bufio.NewReader(r)}
+	decoder := xml.NewDecoder(input)
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return &trackingDecoder{decoder: decoder, input: input}
+}
+
+func (d *trackingDecoder) Token() (xml.Token, error) {
+	token, err := d.decoder.Token()
+	if err != nil {
+		return token, err
+	}
+	switch t := token.(type) {
+	case xml.StartElement:
+		d.open = append(d.open, t.Name.Local)
+	case xml.EndElement:
+		if len(d.open) > 0 {
+			d.open = d.open[:len(d.open)-1]
+		}
+	}
+	return token, nil
+}
+
+// locate wraps an error met while decoding in an *XMLError telling where it
+// occurred. Errors reading the input, such as a cancelled conversion, are
+// returned as they are.
+func (d *trackingDecoder) locate(err error) error {
+	if err == nil || d.input.err != nil {
+		return err
+	}
+	line, column := d.decoder.InputPos()
+	return &XMLError{
+		Line:    line,
+		Column:  column,
+		Offset:  d.decoder.InputOffset(),
+		Element: strings.Join(d.open, "/"),
+		Snippet: d.input.snippet(),
+		Err:     err,
+	}
+}
+
+// tailReader passes bytes to the XML decoder one at a time, so nothing is read
+// past the position of the decoder, and remembers the latest of them
+type tailReader struct {
+	r    *bufio.Reader
+	tail []byte
+	err  error // The first error reading the input other than its end
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.remember(p[:n]...)
+	t.fail(err)
+	return n, err
+}
+
+func (t *tailReader) ReadByte() (byte, error) {
+	b, err := t.r.ReadByte()
+	if err == nil {
+		t.remember(b)
+	}
+	t.fail(err)
+	return b, err
+}
+
+func (t *tailReader) remember(data ...byte) {
+	t.tail = append(t.tail, data...)
+	if len(t.tail) > 2*snippetBefore {
+		t.tail = append(t.tail[:0], t.tail[len(t.tail)-snippetBefore:]...)
+	}
+}
+
+func (t *tailReader) fail(err error) {
+	if err != nil && err != io.EOF && t.err == nil {
+		t.err = err
+	}
+}
+
+// snippet returns the last bytes read and the next few ones, on one line
+func (t *tailReader) snippet() string {
+	before := t.tail
+	if len(before) > snippetBefore {
+		before = before[len(before)-snippetBefore:]
+	}
+	// Skip the continuation bytes of a character cut off at the start
+	for len(before) > 0 && !utf8.RuneStart(before[0]) {
+		before = before[1:]
+	}
+	after, _ := t.r.Peek(snippetAfter)
+	text := strings.ToValidUTF8(string(before)+string(after), "")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// LocateXMLError reads an FB2 document to its end without converting it and
+// returns an *XMLError telling where it breaks, or nil when it is well-formed
+// and within limits. Documents are read like conversions read them: UTF-16
+// is decoded and control characters XML does not allow are removed.
+func LocateXMLError(r io.Reader, limits *Limits) error {
+	if limits == nil {
+		limits = &Limits{}
+	}
+	tracker := newTrackingDecoder(newXMLCharFilter(newUTF8Reader(r)))
+	tokens := &limitedTokenReader{decoder: tracker, limits: limits}
+	seenElement := false
+	for {
+		token, err := tokens.Token()
+		if errors.Is(err, io.EOF) && seenElement {
+			return nil
+		}
+		if err != nil {
+			return tracker.locate(err)
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			seenElement = true
+		}
+	}
+}
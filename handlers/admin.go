@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// RequireAdminKey protects admin routes with the configured admin API key.
+// The key is accepted from the X-Admin-Key header, as a Bearer token, or as
+// the Basic auth password for OPDS readers, which support nothing else.
+func RequireAdminKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Load()
+		if cfg.AdminAPIKey == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Admin API is disabled",
+			})
+			return
+		}
+
+		if !hasAdminKey(c, cfg) {
+			c.Header("WWW-Authenticate", `Basic realm="fb2epub"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid or missing admin API key",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// adminKey returns the admin key sent with a request: the X-Admin-Key header,
+// the Basic auth password or a Bearer token, in that order
+func adminKey(c *gin.Context) string {
+	key := c.GetHeader("X-Admin-Key")
+	if _, password, ok := c.Request.BasicAuth(); ok && key == "" {
+		key = password
+	}
+	if key == "" {
+		key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	return key
+}
+
+// hasAdminKey reports whether the request carries the configured admin key
+func hasAdminKey(c *gin.Context, cfg *config.Config) bool {
+	return cfg.AdminAPIKey != "" && subtle.ConstantTimeCompare([]byte(adminKey(c)), []byte(cfg.AdminAPIKey)) == 1
+}
+
+// GetStorageStats reports temp directory usage and job statistics
+func GetStorageStats(c *gin.Context) {
+	cfg := config.Load()
+
+	usage, dirCount := tempDirUsage(cfg.TempDir)
+
+	jobCounts := map[string]int{
+		JobStatusPending:    0,
+		JobStatusProcessing: 0,
+		JobStatusCompleted:  0,
+		JobStatusFailed:     0,
+	}
+	var oldest *ConversionJob
+	jobs := listJobs()
+	for _, job := range jobs {
+		jobCounts[job.Snapshot().Status]++
+		if oldest == nil || job.CreatedAt.Before(oldest.CreatedAt) {
+			oldest = job
+		}
+	}
+
+	response := gin.H{
+		"temp_dir":         cfg.TempDir,
+		"disk_usage_bytes": usage,
+		"job_directories":  dirCount,
+		"total_jobs":       len(jobs),
+		"jobs_by_status":   jobCounts,
+	}
+	if oldest != nil {
+		response["oldest_job_created_at"] = oldest.CreatedAt
+		response["oldest_job_age_seconds"] = int64(time.Since(oldest.CreatedAt).Seconds())
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ForceCleanup runs a cleanup immediately, removing the jobs past their
+// retention. The optional max_age parameter (a Go duration such as "30m")
+// instead removes every finished job older than max_age.
+func ForceCleanup(c *gin.Context) {
+	cfg := config.Load()
+
+	// Without max_age, jobs are removed once past their own retention
+	value := c.Query("max_age")
+	if value == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"removed": cleanupExpiredJobs(cfg.TempDir, cfg.JobRetention),
+			"max_age": cfg.JobRetention.String(),
+		})
+		return
+	}
+
+	maxAge, err := time.ParseDuration(value)
+	if err != nil || maxAge < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid max_age %q, expected a duration such as 30m or 2h", value),
+		})
+		return
+	}
+
+	removed := cleanupJobsOlderThan(cfg.TempDir, maxAge)
+
+	c.JSON(http.StatusOK, gin.H{
+		"removed": removed,
+		"max_age": maxAge.String(),
+	})
+}
+
+// tempDirUsage returns the total size of files under dir and the number of job directories
+func tempDirUsage(dir string) (int64, int) {
+	var total int64
+	dirCount := 0
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil //nolint:nilerr // Skip unreadable entries
+		}
+		if entry.IsDir() {
+			if path != dir && filepath.Dir(path) == dir {
+				dirCount++
+			}
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, dirCount
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/converter"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// amqpRetryDelay is how long the ingester waits before reconnecting to the broker
+const amqpRetryDelay = 5 * time.Second
+
+// QueueRequest is a conversion request consumed from the message queue. The
+// book is either inlined as base64 content or referenced by its path in
+// AMQP_INPUT_DIR.
+type QueueRequest struct {
+	Filename     string             `json:"filename"`
+	Path         string             `json:"path,omitempty"`
+	Content      []byte             `json:"content,omitempty"`
+	OutputFormat string             `json:"output_format,omitempty"`
+	Metadata     *QueueBookMetadata `json:"metadata,omitempty"`
+	Strict       bool               `json:"strict,omitempty"`
+	Lenient      bool               `json:"lenient,omitempty"`
+}
+
+// QueueBookMetadata overrides the metadata of a book converted from the queue
+type QueueBookMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Series      string `json:"series,omitempty"`
+	SeriesIndex string `json:"series_index,omitempty"`
+}
+
+// QueueEvent is published once a request from the queue is converted or refused
+type QueueEvent struct {
+	JobID       string `json:"job_id,omitempty"`
+	Status      string `json:"status"`
+	Filename    string `json:"filename,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Error       string `json:"error,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+	LibraryPath string `json:"library_path,omitempty"`
+}
+
+// QueueIngester converts books requested through a RabbitMQ queue with the
+// regular job pipeline and publishes an event for each once it is done.
+// Conversions appear in the status, download and history endpoints like
+// uploaded ones.
+type QueueIngester struct {
+	cfg *config.Config
+}
+
+// NewQueueIngester returns an ingester using the broker and queues of cfg
+func NewQueueIngester(cfg *config.Config) *QueueIngester {
+	return &QueueIngester{cfg: cfg}
+}
+
+// Run consumes conversion requests until ctx is canceled, reconnecting to the
+// broker when the connection is lost
+func (q *QueueIngester) Run(ctx context.Context) {
+	log.Printf("Consuming conversion requests from queue %s", q.cfg.AMQPQueue)
+	for ctx.Err() == nil {
+		if err := q.consume(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Warning: message queue connection failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(amqpRetryDelay):
+		}
+	}
+	log.Printf("Message queue ingestion stopped")
+}
+
+// consume handles the requests of one broker connection until it closes
+func (q *QueueIngester) consume(ctx context.Context) error {
+	conn, err := amqp.Dial(q.cfg.AMQPURL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{q.cfg.AMQPQueue, q.cfg.AMQPEventsQueue} {
+		if _, err := channel.QueueDeclare(name, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("declaring queue %s: %w", name, err)
+		}
+	}
+	// Unacknowledged requests stay with the broker, so only take as many as are converted at once
+	if err := channel.Qos(q.cfg.AMQPPrefetch, 0, false); err != nil {
+		return err
+	}
+	deliveries, err := channel.Consume(q.cfg.AMQPQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case delivery, ok := <-deliveries:
+			if !ok {
+				return errors.New("the broker closed the channel")
+			}
+			go q.handle(ctx, channel, delivery)
+		}
+	}
+}
+
+// handle converts the book of a delivery, publishes the event to its reply
+// queue or the events queue and acknowledges it
+func (q *QueueIngester) handle(ctx context.Context, channel *amqp.Channel, delivery amqp.Delivery) {
+	event := q.Convert(delivery.Body)
+
+	body, err := json.Marshal(event)
+	if err == nil {
+		queue := q.cfg.AMQPEventsQueue
+		if delivery.ReplyTo != "" {
+			queue = delivery.ReplyTo
+		}
+		err = channel.PublishWithContext(ctx, "", queue, false, false, amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: delivery.CorrelationId,
+			DeliveryMode:  amqp.Persistent,
+			Timestamp:     time.Now(),
+			Body:          body,
+		})
+	}
+	if err != nil {
+		// The job itself finished, so the request is not converted again
+		log.Printf("Warning: failed to publish the event of job %s: %v", event.JobID, err)
+	}
+	if ackErr := delivery.Ack(false); ackErr != nil {
+		log.Printf("Warning: failed to acknowledge the request of job %s: %v", event.JobID, ackErr)
+	}
+}
+
+// Convert runs the conversion requested by a message body and returns the
+// event describing its outcome. Malformed requests yield a failed event
+// without a job.
+func (q *QueueIngester) Convert(body []byte) *QueueEvent {
+	// Limits and options follow the current configuration, like those of uploads
+	cfg := config.Load()
+
+	var req QueueRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return &QueueEvent{Status: JobStatusFailed, Error: fmt.Sprintf("Invalid request: %v", err)}
+	}
+	if req.Filename == "" {
+		req.Filename = filepath.Base(req.Path)
+	}
+	event := &QueueEvent{Status: JobStatusFailed, Filename: req.Filename}
+
+	file, size, err := q.openRequestFile(cfg, &req)
+	if err != nil {
+		event.Error = err.Error()
+		return event
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+	if size > cfg.MaxFileSize {
+		event.Error = fmt.Sprintf("File too large. Maximum size is %.2f MB", float64(cfg.MaxFileSize)/(1024*1024))
+		return event
+	}
+
+	fileType, err := detectUploadType(file, req.Filename)
+	if err != nil || fileType == uploadUnsupported {
+		event.Error = "Invalid file type. Expected .fb2, .xml, .fb2.zip or .epub file"
+		return event
+	}
+	format, err := resolveOutputFormat(fileType, "", req.OutputFormat)
+	if err != nil {
+		event.Error = fmt.Sprintf("Invalid conversion: %v", err)
+		return event
+	}
+
+	opts := defaultConversionOptions(cfg)
+	opts.Strict = req.Strict
+	opts.Lenient = req.Lenient
+	if override := req.Metadata; override != nil {
+		opts.Metadata = converter.MetadataOverrides{
+			Title:       override.Title,
+			Author:      override.Author,
+			Language:    override.Language,
+			Series:      override.Series,
+			SeriesIndex: override.SeriesIndex,
+		}
+	}
+
+	job, err := createJob(cfg, file, req.Filename, size, fileType, format, opts)
+	if err != nil {
+		event.Error = err.Error()
+		return event
+	}
+	putJob(job)
+	auditReceivedJob(job, size, "from queue "+cfg.AMQPQueue)
+	log.Printf("Job %s started from queue %s", job.ID, cfg.AMQPQueue)
+
+	// Queued requests wait their turn like uploads
+	finished := make(chan struct{})
+	queueConversion(cfg, job, opts, func() { close(finished) })
+	<-finished
+
+	state := job.Snapshot()
+	event.JobID = job.ID
+	event.Status = state.Status
+	event.Title = state.Title
+	event.Error = state.Error
+	if state.Status == JobStatusCompleted {
+		event.DownloadURL = fmt.Sprintf("/api/v1/download/%s", job.ID)
+		event.LibraryPath = state.LibraryPath
+	}
+	return event
+}
+
+// openRequestFile returns the book of a request with its size: the inlined
+// content, or the referenced file, which must lie in AMQP_INPUT_DIR
+func (q *QueueIngester) openRequestFile(cfg *config.Config, req *QueueRequest) (multipart.File, int64, error) {
+	if len(req.Content) > 0 {
+		return memoryFile{bytes.NewReader(req.Content)}, int64(len(req.Content)), nil
+	}
+	if req.Path == "" {
+		return nil, 0, &jobError{http.StatusBadRequest, "Request has neither content nor a path"}
+	}
+	if cfg.AMQPInputDir == "" {
+		return nil, 0, &jobError{http.StatusBadRequest,
+			"File references are disabled, set AMQP_INPUT_DIR or inline the content"}
+	}
+
+	dir, err := filepath.Abs(cfg.AMQPInputDir)
+	if err != nil {
+		return nil, 0, &jobError{http.StatusInternalServerError, fmt.Sprintf("Invalid AMQP_INPUT_DIR: %v", err)}
+	}
+	path := req.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, 0, &jobError{http.StatusBadRequest, fmt.Sprintf("Path %s is outside AMQP_INPUT_DIR", req.Path)}
+	}
+
+	//nolint:gosec // Path is checked to lie in AMQP_INPUT_DIR
+	file, err := os.Open(filepath.Join(dir, rel))
+	if err != nil {
+		return nil, 0, &jobError{http.StatusNotFound, fmt.Sprintf("Failed to open %s", req.Path)}
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+		return nil, 0, &jobError{http.StatusBadRequest, fmt.Sprintf("Path %s is not a regular file", req.Path)}
+	}
+	return file, info.Size(), nil
+}
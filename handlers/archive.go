@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errArchiveTooLarge reports an archive whose FB2 would decompress beyond the configured limits
+var errArchiveTooLarge = errors.New("archive decompresses beyond the allowed size")
+
+// extractFB2FromZip writes the first .fb2 entry of a zip archive to destPath.
+// Entries declaring more than maxSize bytes or a compression ratio above maxRatio
+// are rejected, and the copy is capped at maxSize in case the headers lie.
+func extractFB2FromZip(archive io.ReaderAt, archiveSize int64, destPath string, maxSize, maxRatio int64) error {
+	reader, err := zip.NewReader(archive, archiveSize)
+	if err != nil {
+		return fmt.Errorf("not a valid zip archive: %w", err)
+	}
+
+	var entry *zip.File
+	for _, f := range reader.File {
+		if !f.FileInfo().IsDir() && strings.EqualFold(filepath.Ext(f.Name), ".fb2") {
+			entry = f
+			break
+		}
+	}
+	if entry == nil {
+		return errors.New("archive contains no .fb2 file")
+	}
+
+	if entry.UncompressedSize64 > uint64(maxSize) {
+		return errArchiveTooLarge
+	}
+	if entry.CompressedSize64 > 0 && entry.UncompressedSize64/entry.CompressedSize64 > uint64(maxRatio) {
+		return fmt.Errorf("%w: compression ratio exceeds %d:1", errArchiveTooLarge, maxRatio)
+	}
+
+	src, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", entry.Name, err)
+	}
+	defer func() {
+		if closeErr := src.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	//nolint:gosec // Path is controlled and validated
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(dest, io.LimitReader(src, maxSize+1))
+	if closeErr := dest.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", entry.Name, err)
+	}
+	if written > maxSize {
+		return errArchiveTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Events of the audit log of a job, in the order they usually happen
+const (
+	auditReceived   = "received"   // The book was stored and the job registered
+	auditQueued     = "queued"     // The job waits for a conversion slot
+	auditStarted    = "started"    // The conversion started
+	auditStage      = "stage"      // The conversion moved on to another stage
+	auditValidated  = "validated"  // The book was parsed and, in strict mode, validated
+	auditWarning    = "warning"    // A problem skipped by a lenient conversion, or a failed hook
+	auditCompleted  = "completed"  // The result is ready
+	auditFailed     = "failed"     // The conversion failed
+	auditRetried    = "retried"    // An admin restarted the failed job
+	auditDownloaded = "downloaded" // The result was downloaded
+	auditCleaned    = "cleaned"    // The job and its files were removed
+)
+
+// maxAuditEntries bounds the audit log of a job; later entries are dropped
+const maxAuditEntries = 500
+
+// auditLogRetention is how long the audit log of a job is kept once the job
+// was cleaned up, for looking into problems reported after the fact
+const auditLogRetention = 24 * time.Hour
+
+// AuditEntry is one event in the audit log of a job
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Message string    `json:"message,omitempty"`
+}
+
+// jobAuditLog is the append-only audit trail of a job
+type jobAuditLog struct {
+	entries   []AuditEntry
+	cleanedAt time.Time // When the job was cleaned up; zero while it exists
+}
+
+var (
+	// auditLogs are kept apart from the jobs so they outlive cleanup
+	auditLogs  = make(map[string]*jobAuditLog)
+	auditMutex sync.Mutex // Guards auditLogs
+)
+
+// auditJob appends an event to the audit log of a job, with a message
+// formatted from format and args
+func auditJob(jobID, event, format string, args ...interface{}) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	trail, exists := auditLogs[jobID]
+	if !exists {
+		trail = &jobAuditLog{}
+		auditLogs[jobID] = trail
+	}
+	if len(trail.entries) >= maxAuditEntries {
+		return
+	}
+	trail.entries = append(trail.entries, AuditEntry{
+		Time:    time.Now().UTC(),
+		Event:   event,
+		Message: fmt.Sprintf(format, args...),
+	})
+	if event == auditCleaned {
+		trail.cleanedAt = time.Now()
+	}
+}
+
+// auditReceivedJob records that a job was registered, along with where the
+// book came from
+func auditReceivedJob(job *ConversionJob, size int64, source string) {
+	auditJob(job.ID, auditReceived, "Received %s (%d bytes) %s, converting to %s",
+		job.Filename, size, source, job.OutputFormat)
+}
+
+// auditDownload records a request for the result of a job. Readers resuming
+// a download ask for byte ranges, which are told apart from whole files.
+func auditDownload(c *gin.Context, jobID string) {
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+	if byteRange := c.GetHeader("Range"); byteRange != "" {
+		auditJob(jobID, auditDownloaded, "Range %s", byteRange)
+		return
+	}
+	auditJob(jobID, auditDownloaded, "Whole file")
+}
+
+// jobAuditEntries returns a copy of the audit log of a job
+func jobAuditEntries(jobID string) ([]AuditEntry, bool) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	trail, exists := auditLogs[jobID]
+	if !exists {
+		return nil, false
+	}
+	return append([]AuditEntry(nil), trail.entries...), true
+}
+
+// pruneAuditLogs drops the audit logs of jobs cleaned up longer than
+// auditLogRetention ago
+func pruneAuditLogs(now time.Time) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	for jobID, trail := range auditLogs {
+		if !trail.cleanedAt.IsZero() && now.Sub(trail.cleanedAt) > auditLogRetention {
+			delete(auditLogs, jobID)
+		}
+	}
+}
+
+// GetJobLog returns the audit log of a job: when it was received, started,
+// moved through the conversion stages, finished, downloaded and cleaned up.
+// Logs are kept for a day after their job is cleaned up.
+func GetJobLog(c *gin.Context) {
+	jobID := c.Param("id")
+
+	entries, exists := jobAuditEntries(jobID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job log not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job_id":  jobID,
+		"entries": entries,
+	})
+}
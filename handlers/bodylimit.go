@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// requestBodyOverhead is the room left above MaxFileSize for multipart
+// boundaries, headers and the option fields sent along with a book
+const requestBodyOverhead = 1024 * 1024
+
+// LimitRequestBody rejects request bodies larger than MaxFileSize plus room for
+// multipart overhead with 413. Bodies declaring a larger Content-Length are
+// refused before they are read; chunked bodies fail once they pass the limit.
+// Handlers still check the size of the book itself.
+func LimitRequestBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Load()
+		limit := cfg.MaxFileSize + requestBodyOverhead
+
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("Request too large. Maximum size: %d bytes (%.2f MB)",
+					limit, float64(limit)/(1024*1024)),
+			})
+			return
+		}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
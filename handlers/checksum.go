@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // Content-MD5 checks integrity, not authenticity
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadChecksum is a digest of the uploaded file a client may send, as a
+// header or as a form field of the same meaning
+type uploadChecksum struct {
+	header string
+	field  string
+	name   string
+	hash   func() hash.Hash
+}
+
+var uploadChecksums = []uploadChecksum{
+	{header: "Content-MD5", field: "content_md5", name: "MD5", hash: md5.New},
+	{header: "X-Checksum-SHA256", field: "checksum_sha256", name: "SHA-256", hash: sha256.New},
+}
+
+// verifyUploadChecksum checks the uploaded file against the checksums sent
+// with the request, hex or base64 encoded, so a truncated or corrupted upload
+// is refused rather than converted into an incomplete book. The file is read
+// from the start and left there. Errors are *jobError values.
+func verifyUploadChecksum(c *gin.Context, file io.ReadSeeker) error {
+	for _, checksum := range uploadChecksums {
+		value := strings.TrimSpace(c.GetHeader(checksum.header))
+		if value == "" {
+			formString(c, checksum.field, &value)
+		}
+		if value == "" {
+			continue
+		}
+
+		h := checksum.hash()
+		expected, err := decodeChecksum(value, h.Size())
+		if err != nil {
+			return &jobError{http.StatusBadRequest, fmt.Sprintf("Invalid %s checksum %q: %v", checksum.name, value, err)}
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return &jobError{http.StatusInternalServerError, "Failed to read uploaded file"}
+		}
+		if _, err := io.Copy(h, file); err != nil {
+			return &jobError{http.StatusInternalServerError, "Failed to read uploaded file"}
+		}
+		if actual := h.Sum(nil); !bytes.Equal(actual, expected) {
+			return &jobError{http.StatusBadRequest, fmt.Sprintf(
+				"%s checksum mismatch: the upload has %s, expected %s. It may be truncated, upload it again",
+				checksum.name, hex.EncodeToString(actual), hex.EncodeToString(expected))}
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return &jobError{http.StatusInternalServerError, "Failed to read uploaded file"}
+	}
+	return nil
+}
+
+// decodeChecksum decodes a digest of size bytes sent in hex or base64
+func decodeChecksum(value string, size int) ([]byte, error) {
+	var digest []byte
+	var err error
+	if len(value) == hex.EncodedLen(size) {
+		digest, err = hex.DecodeString(value)
+	} else {
+		digest, err = base64.StdEncoding.DecodeString(value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expected hex or base64")
+	}
+	if len(digest) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(digest))
+	}
+	return digest, nil
+}
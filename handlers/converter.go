@@ -2,12 +2,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,7 +24,6 @@ import (
 )
 
 var (
-	conversionJobs    = make(map[string]*ConversionJob)
 	completedJobCount = 0        // Counter for completed conversions
 	cleanupMutex      sync.Mutex // Mutex for cleanup operations
 )
@@ -30,22 +36,301 @@ const (
 	JobStatusFailed     = "failed"
 )
 
-// ConversionJob represents a file conversion job
+// ConversionJob represents a file conversion job. Once the job is registered,
+// the fields its conversion updates (status, progress, outcome, options and
+// content key, library path, delivery state and retention) are changed through
+// its setters and read through Snapshot.
 type ConversionJob struct {
 	ID        string    `json:"id"`
 	Status    string    `json:"status"` // pending, processing, completed, failed
 	CreatedAt time.Time `json:"created_at"`
 	FilePath  string    `json:"-"`
 	Error     string    `json:"error,omitempty"`
+	Stats     *JobStats `json:"stats,omitempty"`
+
+	// Diagnostics lists the problems found by strict validation
+	Diagnostics []converter.Diagnostic `json:"diagnostics,omitempty"`
+
+	// Warnings lists the problems skipped by a lenient conversion and the
+	// post-conversion hooks that failed
+	Warnings []converter.Diagnostic `json:"warnings,omitempty"`
+
+	// Progress of a running conversion, as reported by the converter
+	Stage    string `json:"stage,omitempty"`
+	Progress int    `json:"progress"`
+
+	// Preview is the HTML of the first chapter, available once the book is parsed
+	Preview string `json:"-"`
+
+	// Filename is the name of the uploaded file and Title the title of the converted book
+	Filename string `json:"filename,omitempty"`
+	Title    string `json:"title,omitempty"`
+
+	// InputPath and Options are kept so a failed conversion can be retried
+	InputPath string             `json:"-"`
+	Options   *converter.Options `json:"-"`
+
+	// OutputFormat is "epub", "pdf", or "fb2" for EPUB books converted back to FB2
+	OutputFormat string `json:"-"`
+
+	// Delivery is set when the result is to be emailed once the conversion completes
+	Delivery *JobDelivery `json:"-"`
+
+	// Retention is how long the finished job is kept; zero means defaultJobRetention
+	Retention time.Duration `json:"-"`
+
+	// ContentKey identifies the book and options converted, to detect repeated uploads
+	ContentKey string `json:"-"`
+
+	// LibraryPath is where the result was stored in the library, relative to LIBRARY_DIR
+	LibraryPath string `json:"-"`
+
+	// APIKey is the client key the job was started with, counted against its quotas
+	APIKey string `json:"-"`
+
+	// lastAccess is when the status or result of the job was last requested,
+	// in Unix nanoseconds; zero until then
+	lastAccess atomic.Int64
+
+	// mu guards the fields that change while the job is running or waiting
+	mu sync.RWMutex
+}
+
+// JobSnapshot is a consistent copy of the changing state of a job
+type JobSnapshot struct {
+	Status      string
+	Stage       string
+	Progress    int
+	Preview     string
+	Error       string
+	Diagnostics []converter.Diagnostic
+	Warnings    []converter.Diagnostic
+	Stats       *JobStats
+	Title       string
+	LibraryPath string
+	Delivery    *JobDelivery // A copy of the delivery state; nil without a delivery
+	Options     *converter.Options
+	ContentKey  string
+	ExpiresAt   time.Time
+}
+
+// Snapshot returns the current state of the job, read at once so that its
+// fields agree with each other
+func (j *ConversionJob) Snapshot() JobSnapshot {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	snapshot := JobSnapshot{
+		Status:      j.Status,
+		Stage:       j.Stage,
+		Progress:    j.Progress,
+		Preview:     j.Preview,
+		Error:       j.Error,
+		Diagnostics: j.Diagnostics,
+		Warnings:    j.Warnings,
+		Stats:       j.Stats,
+		Title:       j.Title,
+		LibraryPath: j.LibraryPath,
+		Options:     j.Options,
+		ContentKey:  j.ContentKey,
+		ExpiresAt:   j.expiresAt(),
+	}
+	if j.Delivery != nil {
+		delivery := *j.Delivery
+		snapshot.Delivery = &delivery
+	}
+	return snapshot
+}
+
+// Finished reports whether the job has completed or failed
+func (s JobSnapshot) Finished() bool {
+	return s.Status == JobStatusCompleted || s.Status == JobStatusFailed
+}
+
+// setStatus moves the job to status
+func (j *ConversionJob) setStatus(status string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Status = status
+}
+
+// setProgress records the stage and percentage reported by the converter
+func (j *ConversionJob) setProgress(stage string, percent int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Stage = stage
+	j.Progress = percent
+}
+
+// setPreview records the HTML of the first chapter
+func (j *ConversionJob) setPreview(preview string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Preview = preview
+}
+
+// setResult records the statistics, book title and warnings of a finished conversion
+func (j *ConversionJob) setResult(stats *JobStats, title string, warnings []converter.Diagnostic) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Stats = stats
+	j.Title = title
+	j.Warnings = warnings
+}
+
+// addWarnings appends warnings to those of the conversion
+func (j *ConversionJob) addWarnings(warnings []converter.Diagnostic) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Warnings = append(append([]converter.Diagnostic(nil), j.Warnings...), warnings...)
+}
+
+// fail marks the job failed with message and, for books that did not
+// validate, the problems found
+func (j *ConversionJob) fail(message string, diagnostics []converter.Diagnostic) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Error = message
+	j.Diagnostics = diagnostics
+	j.Status = JobStatusFailed
+}
+
+// setLibraryPath records where the result was stored in the library
+func (j *ConversionJob) setLibraryPath(path string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.LibraryPath = path
+}
+
+// setDeliveryOutcome records whether the result was mailed; the job must have a delivery
+func (j *ConversionJob) setDeliveryOutcome(status, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Delivery.Status = status
+	j.Delivery.Error = message
+}
+
+// extendRetention keeps the job at least retention after its last access
+func (j *ConversionJob) extendRetention(retention time.Duration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if retention > j.Retention {
+		j.Retention = retention
+	}
+}
+
+// restart clears the outcome of a failed job so it can be converted again with
+// opts, returning the error it failed with. The content key is recomputed, so
+// the job is only taken for a duplicate of uploads with the new options.
+func (j *ConversionJob) restart(opts *converter.Options) string {
+	key, err := conversionKey(j.InputPath, j.OutputFormat, opts)
+	if err != nil {
+		log.Printf("Warning: failed to hash %s: %v", j.InputPath, err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	previousError := j.Error
+	j.Status = JobStatusProcessing
+	j.Error = ""
+	j.Diagnostics = nil
+	j.Warnings = nil
+	j.Stats = nil
+	j.Stage = ""
+	j.Progress = 0
+	j.Preview = ""
+	j.Options = opts
+	j.ContentKey = key
+	return previousError
+}
+
+// Touch records that the job was just accessed, postponing its expiry
+func (j *ConversionJob) Touch() {
+	j.lastAccess.Store(time.Now().UnixNano())
+}
+
+// LastAccessedAt returns when the status or result of the job was last
+// requested, or its creation time if never
+func (j *ConversionJob) LastAccessedAt() time.Time {
+	if nanos := j.lastAccess.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return j.CreatedAt
+}
+
+// ExpiresAt returns when the job and its files become eligible for cleanup:
+// its retention after it was last accessed, so polling and downloading keep it
+func (j *ConversionJob) ExpiresAt() time.Time {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.expiresAt()
+}
+
+// expiresAt is ExpiresAt for callers holding j.mu
+func (j *ConversionJob) expiresAt() time.Time {
+	if j.Retention > 0 {
+		return j.LastAccessedAt().Add(j.Retention)
+	}
+	return j.LastAccessedAt().Add(defaultJobRetention)
+}
+
+// JobStats holds the metrics of a finished conversion
+type JobStats struct {
+	InputSize          int64   `json:"input_size_bytes"`
+	OutputSize         int64   `json:"output_size_bytes"`
+	SizeRatio          float64 `json:"size_ratio"` // Output size divided by input size
+	ImageCount         int     `json:"image_count"`
+	PrunedImageBytes   int64   `json:"pruned_image_bytes,omitempty"` // Size of the unreferenced images left out
+	ChapterCount       int     `json:"chapter_count"`
+	ParseDurationMs    int64   `json:"parse_duration_ms"`
+	GenerateDurationMs int64   `json:"generate_duration_ms"`
+}
+
+// newJobStats converts converter statistics into their API representation
+func newJobStats(stats *converter.Stats) *JobStats {
+	if stats == nil {
+		return nil
+	}
+	jobStats := &JobStats{
+		InputSize:          stats.InputSize,
+		OutputSize:         stats.OutputSize,
+		ImageCount:         stats.ImageCount,
+		PrunedImageBytes:   stats.PrunedImageBytes,
+		ChapterCount:       stats.ChapterCount,
+		ParseDurationMs:    stats.ParseDuration.Milliseconds(),
+		GenerateDurationMs: stats.GenerateDuration.Milliseconds(),
+	}
+	if stats.InputSize > 0 && stats.OutputSize > 0 {
+		jobStats.SizeRatio = float64(stats.OutputSize) / float64(stats.InputSize)
+	}
+	return jobStats
 }
 
 // ConvertFB2ToEPUB handles the conversion request
 func ConvertFB2ToEPUB(c *gin.Context) {
 	cfg := config.Load()
 
+	// A retried request with the same Idempotency-Key gets the job of the first
+	finish, handled := beginIdempotentRequest(c)
+	if handled {
+		return
+	}
+	defer finish()
+
+	// Clients waiting for 100 Continue are refused before they send the book
+	if refuseBeforeUpload(c, cfg) {
+		return
+	}
+
 	// Check file size - set MaxBytesReader with a buffer to handle large files
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxFileSize)
 
+	// Books may also be posted as the raw request body
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		convertRawUpload(c, cfg)
+		return
+	}
+
 	// Parse multipart form with increased size limit
 	// Note: This must be set before parsing
 	if err := c.Request.ParseMultipartForm(cfg.MaxFileSize); err != nil {
@@ -66,7 +351,7 @@ func ConvertFB2ToEPUB(c *gin.Context) {
 	}
 
 	// Get file from form
-	file, header, err := c.Request.FormFile("file")
+	file, header, err := formUploadFile(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "No file provided or invalid file",
@@ -79,15 +364,154 @@ func ConvertFB2ToEPUB(c *gin.Context) {
 		}
 	}()
 
-	// Validate file extension
-	ext := filepath.Ext(header.Filename)
-	if ext != ".fb2" && ext != ".xml" {
+	// Validate file type; books are also accepted zipped as .fb2.zip
+	fileType, err := detectUploadType(file, header.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read uploaded file",
+		})
+		return
+	}
+	if fileType == uploadUnsupported {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid file type. Expected .fb2, .xml, .fb2.zip or .epub file",
+		})
+		return
+	}
+
+	// Read optional conversion options (metadata overrides)
+	opts, err := parseConversionOptions(c, cfg)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid file type. Expected .fb2 or .xml file",
+			"error": fmt.Sprintf("Invalid conversion options: %v", err),
 		})
 		return
 	}
 
+	startConversion(c, cfg, file, header.Filename, header.Size, fileType, opts)
+}
+
+// startConversion stores an uploaded book in a new job directory, extracting it
+// from zipped uploads, and converts it in the background. It responds with the
+// job ID and returns true, or responds with an error when the book cannot be stored.
+func startConversion(c *gin.Context, cfg *config.Config, file multipart.File, filename string, size int64,
+	fileType uploadType, opts *converter.Options) bool {
+	format, err := outputFormat(c, fileType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid conversion: %v", err),
+		})
+		return false
+	}
+
+	deliverTo, err := deliveryAddress(c, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid delivery: %v", err),
+		})
+		return false
+	}
+
+	retention, err := jobRetention(c, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid retention: %v", err),
+		})
+		return false
+	}
+
+	// Refuse uploads that do not match the checksum sent with them
+	if err := verifyUploadChecksum(c, file); err != nil {
+		status := http.StatusInternalServerError
+		var jobErr *jobError
+		if errors.As(err, &jobErr) {
+			status = jobErr.Status
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+
+	job, err := createJob(cfg, file, filename, size, fileType, format, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var jobErr *jobError
+		if errors.As(err, &jobErr) {
+			status = jobErr.Status
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	if deliverTo != "" {
+		job.Delivery = &JobDelivery{To: deliverTo, Status: DeliveryPending}
+	}
+	job.Retention = retention
+	job.APIKey = c.GetString(apiKeyContextKey)
+
+	// Point repeated uploads at the previous result; deliveries always run anew
+	if force, _ := formBool(c, "force", false); cfg.DeduplicateUploads && !force && deliverTo == "" {
+		if existing := findDuplicateJob(job.ContentKey); existing != nil {
+			if removeErr := os.RemoveAll(filepath.Dir(job.InputPath)); removeErr != nil {
+				log.Printf("Warning: failed to remove %s: %v", filepath.Dir(job.InputPath), removeErr)
+			}
+			// Keep the result at least as long as the new request asked for
+			existing.Touch()
+			existing.extendRetention(retention)
+			log.Printf("Upload of %s matches job %s, not converting again", filename, existing.ID)
+			recordIdempotentJob(c, existing.ID)
+			c.JSON(http.StatusOK, duplicateResponse(existing))
+			return true
+		}
+	}
+
+	// Only conversions that actually run count against the quotas of the API key
+	if !reserveQuota(c, cfg, size) {
+		if removeErr := os.RemoveAll(filepath.Dir(job.InputPath)); removeErr != nil {
+			log.Printf("Warning: failed to remove %s: %v", filepath.Dir(job.InputPath), removeErr)
+		}
+		return false
+	}
+	putJob(job)
+	auditReceivedJob(job, size, "over HTTP")
+	recordIdempotentJob(c, job.ID)
+
+	// Process conversion asynchronously, as soon as a conversion slot is free
+	queueConversion(cfg, job, opts, nil)
+
+	// Return job ID immediately
+	state := job.Snapshot()
+	response := gin.H{
+		"job_id":     job.ID,
+		"status":     state.Status,
+		"message":    "Conversion started",
+		"expires_at": state.ExpiresAt,
+	}
+	if state.Status == JobStatusPending {
+		response["message"] = "Conversion queued"
+		addQueuePosition(response, job)
+	}
+	c.JSON(http.StatusAccepted, response)
+	return true
+}
+
+// jobError is a failure to create a job, with the HTTP status it maps to
+type jobError struct {
+	Status  int
+	Message string
+}
+
+func (e *jobError) Error() string {
+	return e.Message
+}
+
+// createJob stores a book in a new job directory, extracting it from zipped
+// uploads, and returns the job converting it to format. The job is not yet
+// registered or started. Errors are *jobError values.
+func createJob(cfg *config.Config, file multipart.File, filename string, size int64,
+	fileType uploadType, format string, opts *converter.Options) (*ConversionJob, error) {
 	// Create job ID
 	jobID := uuid.New().String()
 
@@ -95,91 +519,218 @@ func ConvertFB2ToEPUB(c *gin.Context) {
 	// Ensure base temp directory exists first
 	//nolint:gosec // 0755 needed for Docker volume mounts
 	if err := os.MkdirAll(cfg.TempDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to create base temporary directory: %v", err),
-		})
-		return
+		return nil, &jobError{http.StatusInternalServerError,
+			fmt.Sprintf("Failed to create base temporary directory: %v", err)}
+	}
+
+	// Refuse the job if the upload and its EPUB would not fit on disk
+	if err := checkDiskSpace(cfg.TempDir, size, cfg.MinFreeDiskSpace); err != nil {
+		return nil, &jobError{http.StatusInsufficientStorage, fmt.Sprintf("Insufficient storage: %v", err)}
 	}
 
 	tempDir := filepath.Join(cfg.TempDir, jobID)
 	//nolint:gosec // 0755 needed for Docker volume mounts
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to create temporary directory: %v", err),
-		})
-		return
+		return nil, &jobError{http.StatusInternalServerError,
+			fmt.Sprintf("Failed to create temporary directory: %v", err)}
 	}
 
-	// Save uploaded file
-	inputPath := filepath.Join(tempDir, "input.fb2")
-	//nolint:gosec // Path is controlled and validated
-	outFile, err := os.Create(inputPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save uploaded file",
-		})
-		return
+	// Save uploaded file, extracting the book from zipped uploads
+	inputPath := filepath.Join(tempDir, "input."+uploadFormat(fileType))
+	if fileType == uploadZip {
+		err := extractFB2FromZip(file, size, inputPath, cfg.MaxDecompressedSize, cfg.MaxCompressionRatio)
+		if err != nil {
+			if removeErr := os.RemoveAll(tempDir); removeErr != nil {
+				log.Printf("Warning: failed to remove %s: %v", tempDir, removeErr)
+			}
+			status := http.StatusBadRequest
+			if errors.Is(err, errArchiveTooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			return nil, &jobError{status, fmt.Sprintf("Invalid archive: %v", err)}
+		}
+	} else if err := saveUpload(file, inputPath); err != nil {
+		if removeErr := os.RemoveAll(tempDir); removeErr != nil {
+			log.Printf("Warning: failed to remove %s: %v", tempDir, removeErr)
+		}
+		return nil, &jobError{http.StatusInternalServerError, "Failed to save uploaded file"}
 	}
 
-	_, err = io.Copy(outFile, file)
-	if closeErr := outFile.Close(); closeErr != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save uploaded file",
-		})
-		return
-	}
+	key, err := conversionKey(inputPath, format, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to save uploaded file",
-		})
-		return
+		log.Printf("Warning: failed to hash %s: %v", inputPath, err)
 	}
 
-	// Create job
-	job := &ConversionJob{
+	return &ConversionJob{
 		ID:        jobID,
 		Status:    "processing",
 		CreatedAt: time.Now(),
-		FilePath:  filepath.Join(tempDir, "output.epub"),
-	}
-	conversionJobs[jobID] = job
+		FilePath:  filepath.Join(tempDir, "output."+format),
+		Filename:  filename,
+		InputPath: inputPath,
+		Options:   opts,
+		Retention: cfg.JobRetention,
 
-	// Process conversion asynchronously
-	go processConversion(jobID, inputPath, job.FilePath, cfg)
+		OutputFormat: format,
+		ContentKey:   key,
+	}, nil
+}
 
-	// Return job ID immediately
-	c.JSON(http.StatusAccepted, gin.H{
-		"job_id":  jobID,
-		"status":  "processing",
-		"message": "Conversion started",
-	})
+// saveUpload writes an uploaded file to path
+func saveUpload(file io.Reader, path string) error {
+	//nolint:gosec // Path is controlled and validated
+	outFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(outFile, file)
+	if closeErr := outFile.Close(); closeErr != nil {
+		return closeErr
+	}
+	return err
 }
 
-func processConversion(jobID, inputPath, outputPath string, cfg *config.Config) {
-	job := conversionJobs[jobID]
+func processConversion(job *ConversionJob, inputPath, outputPath string, cfg *config.Config, opts *converter.Options) {
+	jobID := job.ID
 	defer func() {
-		// Cleanup input file after processing
+		// Cleanup input file after a successful conversion; failed ones keep it for a retry
+		if job.Snapshot().Status != JobStatusCompleted {
+			return
+		}
 		if removeErr := os.Remove(inputPath); removeErr != nil {
 			_ = removeErr
 		}
 	}()
 
-	// Parse FB2
-	fb2, err := converter.ParseFB2(inputPath)
+	// Parse FB2 and generate EPUB
+	var warnings []converter.Diagnostic
+	opts.OnWarning = func(warning converter.Diagnostic) {
+		warnings = append(warnings, warning)
+		auditJob(jobID, auditWarning, "%s", warning.Message)
+	}
+	var currentStage converter.Stage
+	opts.OnProgress = func(stage converter.Stage, percent int) {
+		if stage != currentStage {
+			if currentStage == converter.StageParsing {
+				validated := "The book was parsed"
+				if opts.Strict {
+					validated += " and passed strict validation"
+				}
+				auditJob(jobID, auditValidated, "%s", validated)
+			}
+			auditJob(jobID, auditStage, "%s", stage)
+			currentStage = stage
+		}
+		job.setProgress(string(stage), percent)
+		jobEvents.publish(jobID, jobEvent{
+			Name: eventProgress,
+			Data: gin.H{"stage": stage, "percent": percent},
+		})
+	}
+	opts.OnPreview = func(preview string) {
+		job.setPreview(preview)
+		jobEvents.publish(jobID, jobEvent{Name: eventPreview, Data: gin.H{"html": preview}})
+	}
+	// Whatever the outcome, record the job and tell event streams it has finished
+	defer func() {
+		recordHistory(cfg, job)
+		jobEvents.publish(jobID, jobEvent{Name: eventStatus, Data: jobStatusResponse(job, job.Snapshot()), Final: true})
+	}()
+	ctx, span := startJobSpan(context.Background(), job)
+	defer span.End()
+	// A panic fails the job rather than the server, and is reported with its stack
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			reportPanic(ctx, job, recovered)
+			message := fmt.Sprintf("Internal error: %v", recovered)
+			auditJob(jobID, auditFailed, "%s", message)
+			job.fail(message, nil)
+			recordFailure(jobID, message)
+			log.Printf("Job %s panicked: %v\n%s", jobID, recovered, debug.Stack())
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConversionTimeout)
+	defer cancel()
+	if cfg.MaxConversionMemory > 0 {
+		var cancelCause context.CancelCauseFunc
+		ctx, cancelCause = context.WithCancelCause(ctx)
+		defer cancelCause(nil)
+		go watchMemory(ctx, cancelCause, cfg.MaxConversionMemory)
+	}
+	convert, inputName, outputName := converter.New(opts).ConvertFileWithStatsContext, "FB2", "EPUB"
+	switch job.OutputFormat {
+	case formatFB2:
+		convert, inputName, outputName = converter.New(opts).ConvertEPUBFileWithStatsContext, "EPUB", "FB2"
+	case formatPDF:
+		convert, outputName = converter.New(opts).ConvertFileToPDFWithStatsContext, "PDF"
+	}
+	auditJob(jobID, auditStarted, "Converting %s to %s", inputName, outputName)
+	stats, err := convert(ctx, inputPath, outputPath)
+	jobStats, title := newJobStats(stats), ""
+	if stats != nil {
+		title = stats.Title
+	}
+	job.setResult(jobStats, title, warnings)
 	if err != nil {
-		job.Status = JobStatusFailed
-		job.Error = fmt.Sprintf("Failed to parse FB2: %v", err)
+		var parseErr *converter.ParseError
+		var validationErr *converter.ValidationError
+		var message string
+		var diagnostics []converter.Diagnostic
+		overBudget := errors.Is(context.Cause(ctx), errMemoryBudget)
+		if errors.Is(err, context.DeadlineExceeded) || overBudget {
+			message = fmt.Sprintf("Resource limit exceeded: conversion timed out after %s", cfg.ConversionTimeout)
+			if overBudget {
+				message = fmt.Sprintf("Resource limit exceeded: memory in use by the server went over %d MB",
+					cfg.MaxConversionMemory/(1024*1024))
+			}
+			// Nothing of an aborted job can be downloaded, so drop its directory now
+			tempDir := filepath.Dir(outputPath)
+			if removeErr := os.RemoveAll(tempDir); removeErr != nil {
+				log.Printf("Warning: failed to remove %s: %v", tempDir, removeErr)
+			}
+		} else if errors.As(err, &validationErr) {
+			message = fmt.Sprintf("Invalid FB2: %d problem(s) found", len(validationErr.Diagnostics))
+			diagnostics = validationErr.Diagnostics
+		} else if errors.As(err, &parseErr) {
+			message = fmt.Sprintf("Failed to parse %s: %v", inputName, err)
+		} else {
+			message = fmt.Sprintf("Failed to generate %s: %v", outputName, err)
+		}
+		// Books that do not parse or validate are the submitter's problem, not ours
+		if parseErr == nil && validationErr == nil {
+			reportFailure(ctx, job, message, err)
+		}
+		auditJob(jobID, auditFailed, "%s", message)
+		job.fail(message, diagnostics)
+		recordFailure(jobID, message)
+		log.Printf("Job %s failed after %dms parse, %dms generate: %s",
+			jobID, jobStats.ParseDurationMs, jobStats.GenerateDurationMs, message)
 		return
 	}
 
-	// Generate EPUB
-	if err := converter.GenerateEPUB(fb2, outputPath); err != nil {
-		job.Status = JobStatusFailed
-		job.Error = fmt.Sprintf("Failed to generate EPUB: %v", err)
-		return
+	// Hooks run before the result is offered, so they may rewrite it
+	if len(cfg.PostConversionHooks) > 0 {
+		hookWarnings := runHooks(cfg, job, stats)
+		for _, warning := range hookWarnings {
+			auditJob(jobID, auditWarning, "%s", warning.Message)
+		}
+		job.addWarnings(hookWarnings)
 	}
 
-	job.Status = JobStatusCompleted
+	auditJob(jobID, auditCompleted, "%d bytes written in %dms", jobStats.OutputSize,
+		jobStats.ParseDurationMs+jobStats.GenerateDurationMs)
+	job.setStatus(JobStatusCompleted)
+	log.Printf("Job %s completed: %d -> %d bytes, %d images, %d chapters, parse %dms, generate %dms",
+		jobID, jobStats.InputSize, jobStats.OutputSize, jobStats.ImageCount, jobStats.ChapterCount,
+		jobStats.ParseDurationMs, jobStats.GenerateDurationMs)
+
+	if cfg.LibraryDir != "" {
+		storeInLibrary(cfg, job, stats)
+	}
+	if job.Delivery != nil {
+		deliverJob(cfg, job)
+	}
 
 	// Increment completed job counter and trigger cleanup if needed
 	cleanupMutex.Lock()
@@ -200,36 +751,88 @@ func processConversion(jobID, inputPath, outputPath string, cfg *config.Config)
 func GetConversionStatus(c *gin.Context) {
 	jobID := c.Param("id")
 
-	job, exists := conversionJobs[jobID]
+	job, exists := getJob(jobID)
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Job not found",
 		})
 		return
 	}
+	job.Touch()
 
+	// The ETag and the body are built from one snapshot, so they describe the same state
+	state := job.Snapshot()
+
+	// Pollers sending the ETag of the state they have get 304 until it changes
+	etag := statusETag(job, state)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, jobStatusResponse(job, state))
+}
+
+// jobStatusResponse builds the status endpoint representation of a job in state
+func jobStatusResponse(job *ConversionJob, state JobSnapshot) gin.H {
 	response := gin.H{
-		"id":         job.ID,
-		"status":     job.Status,
-		"created_at": job.CreatedAt,
+		"id":               job.ID,
+		"status":           state.Status,
+		"created_at":       job.CreatedAt,
+		"last_accessed_at": job.LastAccessedAt(),
+		"expires_at":       state.ExpiresAt,
+	}
+
+	if state.Status == JobStatusPending {
+		addQueuePosition(response, job)
+	}
+
+	if state.Status == JobStatusProcessing {
+		response["stage"] = state.Stage
+		response["progress"] = state.Progress
+	}
+
+	if state.Status == JobStatusCompleted {
+		response["download_url"] = fmt.Sprintf("/api/v1/download/%s", job.ID)
+	}
+
+	if state.Status == JobStatusFailed {
+		response["error"] = state.Error
+		if len(state.Diagnostics) > 0 {
+			response["diagnostics"] = state.Diagnostics
+		}
+	}
+
+	if state.Stats != nil {
+		response["stats"] = state.Stats
+	}
+
+	if len(state.Warnings) > 0 {
+		response["warnings"] = state.Warnings
 	}
 
-	if job.Status == JobStatusCompleted {
-		response["download_url"] = fmt.Sprintf("/api/v1/download/%s", jobID)
+	if state.Delivery != nil {
+		response["delivery"] = state.Delivery
 	}
 
-	if job.Status == JobStatusFailed {
-		response["error"] = job.Error
+	if state.LibraryPath != "" {
+		response["library_path"] = state.LibraryPath
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response
 }
 
-// DownloadEPUB handles EPUB file download
+// DownloadEPUB handles EPUB file download; IDs ending in .zip download the
+// results of the job as a ZIP archive
 func DownloadEPUB(c *gin.Context) {
 	jobID := c.Param("id")
+	if strings.HasSuffix(jobID, zipDownloadSuffix) {
+		downloadZIP(c, strings.TrimSuffix(jobID, zipDownloadSuffix))
+		return
+	}
 
-	job, exists := conversionJobs[jobID]
+	job, exists := getJob(jobID)
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Job not found",
@@ -237,39 +840,136 @@ func DownloadEPUB(c *gin.Context) {
 		return
 	}
 
-	if job.Status != JobStatusCompleted {
+	job.Touch()
+
+	state := job.Snapshot()
+	if state.Status != JobStatusCompleted {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Conversion not completed yet",
 		})
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(job.FilePath); os.IsNotExist(err) {
+	path, err := jobFilePath(config.Load().TempDir, job)
+	if err != nil {
+		log.Printf("Warning: refusing download of job %s: %v", jobID, err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "EPUB file not found",
+		})
+		return
+	}
+
+	//nolint:gosec // Path is checked to lie in the job directory
+	file, err := os.Open(path)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "EPUB file not found",
 		})
 		return
 	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read EPUB file",
+		})
+		return
+	}
 
 	// Set headers for file download
-	c.Header("Content-Type", "application/epub+zip")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"book_%s.epub\"", jobID))
+	c.Header("Content-Type", outputContentType(job))
+	c.Header("Content-Disposition", contentDisposition(downloadFilename(job), "book_"+jobID+outputExtension(job)))
+	c.Header("ETag", epubETag(jobID, info))
+
+	auditDownload(c, jobID)
+
+	// ServeContent adds Content-Length and Last-Modified and answers Range,
+	// If-Range, If-None-Match and If-Modified-Since requests
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), file)
+}
+
+// outputExtension returns the file extension of a job's result; Kobo readers
+// only treat files named .kepub.epub as KEPUB
+func outputExtension(job *ConversionJob) string {
+	switch job.OutputFormat {
+	case formatFB2:
+		return ".fb2"
+	case formatPDF:
+		return ".pdf"
+	}
+	if opts := job.Snapshot().Options; opts != nil && opts.Kepub {
+		return ".kepub.epub"
+	}
+	return ".epub"
+}
+
+// epubETag returns a strong validator for a finished EPUB. The file is never
+// rewritten once the job completes, so its size and modification time identify it.
+func epubETag(jobID string, info os.FileInfo) string {
+	return fmt.Sprintf("\"%s-%x-%x\"", jobID, info.Size(), info.ModTime().UnixNano())
+}
 
-	// Send file
-	c.File(job.FilePath)
+// defaultJobRetention is how long finished jobs without a retention of their own are kept
+const defaultJobRetention = time.Hour
+
+// jobRetention reads the optional retention form field of a convert request,
+// a duration such as 30m or 12h within the configured bounds. Without it the
+// configured default applies.
+func jobRetention(c *gin.Context, cfg *config.Config) (time.Duration, error) {
+	var value string
+	formString(c, "retention", &value)
+	if value == "" {
+		return cfg.JobRetention, nil
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration such as 30m or 12h", value)
+	}
+	if retention < cfg.MinJobRetention || retention > cfg.MaxJobRetention {
+		return 0, fmt.Errorf("%s is outside the allowed range of %s to %s", retention, cfg.MinJobRetention, cfg.MaxJobRetention)
+	}
+	return retention, nil
 }
 
-// cleanupOldJobs removes old job directories from the temp folder
+// cleanupOldJobs removes expired job directories and stale chunked uploads from the temp folder
 func cleanupOldJobs(cfg *config.Config) {
+	_ = cleanupExpiredJobs(cfg.TempDir, cfg.JobRetention)
+	_ = cleanupStaleUploads(cfg.TempDir, defaultUploadRetention)
+}
+
+// cleanupExpiredJobs removes finished jobs past their expiry. Directories of
+// jobs no longer in memory are removed once older than orphanAge. It returns
+// the number of directories removed.
+func cleanupExpiredJobs(tempDir string, orphanAge time.Duration) int {
+	return cleanupJobs(tempDir, orphanAge, func(job *ConversionJob, now time.Time) bool {
+		return now.After(job.ExpiresAt())
+	})
+}
+
+// cleanupJobsOlderThan removes finished job directories older than maxAge,
+// whatever their retention, and returns the number of directories removed
+func cleanupJobsOlderThan(tempDir string, maxAge time.Duration) int {
+	return cleanupJobs(tempDir, maxAge, func(job *ConversionJob, now time.Time) bool {
+		return now.Sub(job.CreatedAt) > maxAge
+	})
+}
+
+// cleanupJobs removes the directories of finished jobs for which expired
+// returns true, and those of jobs no longer in memory older than orphanAge
+func cleanupJobs(tempDir string, orphanAge time.Duration, expired func(job *ConversionJob, now time.Time) bool) int {
 	// Use mutex to prevent concurrent cleanup operations
 	cleanupMutex.Lock()
 	defer cleanupMutex.Unlock()
 
 	// Get all directories in temp folder
-	entries, err := os.ReadDir(cfg.TempDir)
+	entries, err := os.ReadDir(tempDir)
 	if err != nil {
-		return
+		return 0
 	}
 
 	now := time.Now()
@@ -287,27 +987,23 @@ func cleanupOldJobs(cfg *config.Config) {
 		}
 
 		// Get job info
-		job, exists := conversionJobs[jobID]
-		jobDir := filepath.Join(cfg.TempDir, jobID)
+		job, exists := getJob(jobID)
+		jobDir := filepath.Join(tempDir, jobID)
 
 		// Cleanup conditions:
-		// 1. Job doesn't exist in memory (old job) and directory is older than 1 hour
-		// 2. Job is completed and older than 1 hour
-		// 3. Job is failed and older than 1 hour
+		// 1. Job doesn't exist in memory (old job) and directory is older than orphanAge
+		// 2. Job is completed or failed and has expired
 		shouldCleanup := false
 		if !exists {
 			// Job not in memory, check directory age
 			info, err := os.Stat(jobDir)
 			if err == nil {
-				if now.Sub(info.ModTime()) > time.Hour {
+				if now.Sub(info.ModTime()) > orphanAge {
 					shouldCleanup = true
 				}
 			}
-		} else if job.Status == JobStatusCompleted || job.Status == JobStatusFailed {
-			// Job is completed or failed, check if older than 1 hour
-			if now.Sub(job.CreatedAt) > time.Hour {
-				shouldCleanup = true
-			}
+		} else if state := job.Snapshot(); state.Finished() {
+			shouldCleanup = expired(job, now)
 		}
 
 		if shouldCleanup {
@@ -316,30 +1012,30 @@ func cleanupOldJobs(cfg *config.Config) {
 				cleanedCount++
 				// Remove from memory if exists
 				if exists {
-					delete(conversionJobs, jobID)
+					removeJob(jobID)
+					auditJob(jobID, auditCleaned, "Removed %s job created at %s", job.Snapshot().Status,
+						job.CreatedAt.UTC().Format(time.RFC3339))
 				}
 			}
 		}
 	}
 
-	// Log cleanup result (in production, you might want to use a proper logger)
-	if cleanedCount > 0 {
-		// Suppress unused variable warning - in production, log this
-		_ = cleanedCount
-	}
+	pruneAuditLogs(now)
+	return cleanedCount
 }
 
 // GetConversionJob returns a conversion job by ID (for testing)
 func GetConversionJob(jobID string) *ConversionJob {
-	return conversionJobs[jobID]
+	job, _ := getJob(jobID)
+	return job
 }
 
 // SetConversionJob sets a conversion job (for testing)
 func SetConversionJob(job *ConversionJob) {
-	conversionJobs[job.ID] = job
+	putJob(job)
 }
 
 // DeleteConversionJob deletes a conversion job (for testing)
 func DeleteConversionJob(jobID string) {
-	delete(conversionJobs, jobID)
+	removeJob(jobID)
 }
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// corsExposedHeaders are the response headers browser clients may read
+const corsExposedHeaders = "Content-Disposition, Content-Length, ETag, Last-Modified, Location, Upload-Offset, Idempotent-Replayed"
+
+// corsMaxAge is how long browsers may cache a preflight response, in seconds
+const corsMaxAge = "600"
+
+// CORS allows browser front-ends on the configured origins to call the API.
+// Preflight requests are answered directly; nothing is added when no origins are configured.
+func CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Load()
+		origin := c.GetHeader("Origin")
+		if len(cfg.CORSAllowedOrigins) == 0 || origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		allowed, wildcard := corsOriginAllowed(cfg.CORSAllowedOrigins, origin)
+		if !allowed {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "Origin not allowed",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if wildcard {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+
+		if preflight {
+			c.Header("Access-Control-Allow-Methods", strings.Join(cfg.CORSAllowedMethods, ", "))
+			c.Header("Access-Control-Allow-Headers", strings.Join(cfg.CORSAllowedHeaders, ", "))
+			c.Header("Access-Control-Max-Age", corsMaxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// corsOriginAllowed reports whether origin is in the allowed list and whether it matched "*"
+func corsOriginAllowed(allowedOrigins []string, origin string) (allowed, wildcard bool) {
+	for _, allowedOrigin := range allowedOrigins {
+		if allowedOrigin == "*" {
+			return true, true
+		}
+		if strings.EqualFold(allowedOrigin, origin) {
+			return true, false
+		}
+	}
+	return false, false
+}
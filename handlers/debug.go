@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// processStart is when the service started, for the uptime in runtime statistics
+var processStart = time.Now()
+
+// Pprof serves the net/http/pprof profiles. It is mounted at /debug/pprof/*profile,
+// the path pprof links its profiles under.
+func Pprof(c *gin.Context) {
+	switch c.Param("profile") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
+
+// GetRuntimeStats reports goroutines, heap usage and garbage collection
+// statistics, to watch memory during large conversions
+func GetRuntimeStats(c *gin.Context) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	gc := gin.H{
+		"cycles":            memStats.NumGC,
+		"next_target_bytes": memStats.NextGC,
+		"pause_total_ms":    time.Duration(memStats.PauseTotalNs).Milliseconds(), //nolint:gosec // Pause totals fit in int64
+		"cpu_fraction":      memStats.GCCPUFraction,
+	}
+	if memStats.NumGC > 0 {
+		gc["last_at"] = time.Unix(0, int64(memStats.LastGC)) //nolint:gosec // Nanoseconds since the epoch fit in int64
+		gc["last_pause_ms"] = float64(memStats.PauseNs[(memStats.NumGC+255)%256]) / 1e6
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"uptime_seconds":    int64(time.Since(processStart).Seconds()),
+		"goroutines":        runtime.NumGoroutine(),
+		"cpus":              runtime.NumCPU(),
+		"go_version":        runtime.Version(),
+		"total_alloc_bytes": memStats.TotalAlloc,
+		"sys_bytes":         memStats.Sys,
+		"heap": gin.H{
+			"alloc_bytes":    memStats.HeapAlloc,
+			"in_use_bytes":   memStats.HeapInuse,
+			"idle_bytes":     memStats.HeapIdle,
+			"released_bytes": memStats.HeapReleased,
+			"sys_bytes":      memStats.HeapSys,
+			"objects":        memStats.HeapObjects,
+		},
+		"gc": gc,
+	})
+}
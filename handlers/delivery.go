@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// Delivery states of a job sent by email
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// JobDelivery tracks the email delivery of a job's result
+type JobDelivery struct {
+	To     string `json:"to"`
+	Status string `json:"status"` // pending, sent, failed
+	Error  string `json:"error,omitempty"`
+}
+
+// deliveryAddress reads the optional email form field of a convert request.
+// It returns an empty address when none was requested, and an error when
+// delivery is disabled or the address is invalid or not on the allowed domains.
+func deliveryAddress(c *gin.Context, cfg *config.Config) (string, error) {
+	var value string
+	formString(c, "email", &value)
+	if value == "" {
+		return "", nil
+	}
+	if cfg.SMTPHost == "" {
+		return "", fmt.Errorf("email delivery is disabled")
+	}
+
+	address, err := mail.ParseAddress(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q", value)
+	}
+	if !deliveryDomainAllowed(address.Address, cfg.DeliveryDomains) {
+		return "", fmt.Errorf("delivery to %s is not allowed", address.Address)
+	}
+	return address.Address, nil
+}
+
+// deliveryDomainAllowed reports whether address is in one of the domains or
+// their subdomains; an empty list allows every domain
+func deliveryDomainAllowed(address string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	domain := strings.ToLower(address[strings.LastIndex(address, "@")+1:])
+	for _, allowed := range domains {
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverJob mails the result of a completed job to its delivery address and
+// records the outcome on the job; failures do not fail the conversion itself
+func deliverJob(cfg *config.Config, job *ConversionJob) {
+	if err := sendJobResult(cfg, job); err != nil {
+		job.setDeliveryOutcome(DeliveryFailed, err.Error())
+		log.Printf("Job %s delivery to %s failed: %v", job.ID, job.Delivery.To, err)
+		return
+	}
+	job.setDeliveryOutcome(DeliverySent, "")
+	log.Printf("Job %s delivered to %s", job.ID, job.Delivery.To)
+}
+
+func sendJobResult(cfg *config.Config, job *ConversionJob) error {
+	path, err := jobFilePath(cfg.TempDir, job)
+	if err != nil {
+		return err
+	}
+	//nolint:gosec // Path is checked to lie in the job directory
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read converted book: %w", err)
+	}
+
+	from := cfg.SMTPFrom
+	if from == "" {
+		from = cfg.SMTPUsername
+	}
+	message, err := buildDeliveryMessage(from, job, data)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	if err := smtp.SendMail(addr, auth, from, []string{job.Delivery.To}, message); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// resultFilename names the result of a job after the uploaded file
+func resultFilename(job *ConversionJob) string {
+	name := strings.TrimSuffix(filepath.Base(job.Filename), filepath.Ext(job.Filename))
+	name = strings.TrimSuffix(name, ".fb2") // Zipped books are named book.fb2.zip
+	if name == "" || name == "." {
+		name = "book_" + job.ID
+	}
+	return name + outputExtension(job)
+}
+
+// buildDeliveryMessage composes a MIME email with the converted book attached
+func buildDeliveryMessage(from string, job *ConversionJob, data []byte) ([]byte, error) {
+	title := job.Snapshot().Title
+	if title == "" {
+		title = job.Filename
+	}
+	name := resultFilename(job)
+
+	var message bytes.Buffer
+	writer := multipart.NewWriter(&message)
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", job.Delivery.To)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", writer.Boundary())
+
+	text, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(text, "%s, converted from %s.\r\n", title, job.Filename)
+
+	attachment, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {outputContentType(job)},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		fmt.Fprintf(attachment, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(attachment, "%s\r\n", encoded)
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import "fmt"
+
+// outputSizeFactor estimates disk needed per uploaded byte: the saved input
+// plus an EPUB of roughly the same size
+const outputSizeFactor = 2
+
+// checkDiskSpace verifies that dir has room for an upload of uploadSize bytes
+// while keeping at least minFree bytes available. Platforms where free space
+// cannot be determined are not checked.
+func checkDiskSpace(dir string, uploadSize, minFree int64) error {
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return nil //nolint:nilerr // Unknown free space must not block conversions
+	}
+
+	required := uint64(minFree) + uint64(uploadSize)*outputSizeFactor //nolint:gosec // Both values are non-negative
+	if free < required {
+		return fmt.Errorf("%.2f MB free, %.2f MB required to accept this file",
+			float64(free)/(1024*1024), float64(required)/(1024*1024))
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package handlers
+
+import "errors"
+
+// freeDiskSpace is not implemented on this platform; the disk space guard is skipped
+func freeDiskSpace(_ string) (uint64, error) {
+	return 0, errors.New("free disk space check not supported on this platform")
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import "strings"
+
+// downloadFilename names the result of a job for downloads: after the book
+// title when it is known, otherwise after the uploaded file. Control and
+// reserved characters are removed, as for names in the library.
+func downloadFilename(job *ConversionJob) string {
+	name := libraryName(job.Snapshot().Title)
+	if name == "" {
+		name = libraryName(strings.TrimSuffix(resultFilename(job), outputExtension(job)))
+	}
+	if name == "" {
+		name = "book_" + job.ID
+	}
+	return name + outputExtension(job)
+}
+
+// contentDisposition returns an attachment Content-Disposition header naming
+// the file name, as described by RFC 6266. Names that are not plain ASCII are
+// sent percent-encoded in filename* (RFC 5987), with fallback as the filename
+// for the few clients that do not understand it.
+func contentDisposition(name, fallback string) string {
+	if isHeaderSafe(name) {
+		return `attachment; filename="` + name + `"`
+	}
+	if !isHeaderSafe(fallback) {
+		fallback = "download"
+	}
+	return `attachment; filename="` + fallback + `"; filename*=UTF-8''` + encodeRFC5987(name)
+}
+
+// isHeaderSafe reports whether a file name can be sent as a quoted string in a
+// header as is: printable ASCII without quotes or backslashes
+func isHeaderSafe(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeRFC5987 percent-encodes the UTF-8 bytes of value outside the attr-char
+// set of RFC 5987
+func encodeRFC5987(value string) string {
+	const hex = "0123456789ABCDEF"
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+			strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			encoded.WriteByte(c)
+			continue
+		}
+		encoded.WriteByte('%')
+		encoded.WriteByte(hex[c>>4])
+		encoded.WriteByte(hex[c&0x0f])
+	}
+	return encoded.String()
+}
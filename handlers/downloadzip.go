@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"archive/zip"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// zipDownloadSuffix is appended to a job ID to download its results as one ZIP
+const zipDownloadSuffix = ".zip"
+
+// jobOutput is a result file of a job, with the name it is downloaded as
+type jobOutput struct {
+	Name string
+	Path string
+}
+
+// jobOutputs returns the result files of a completed job. Every job converts a
+// single book today, so there is one; archives are named to hold several.
+func jobOutputs(cfg *config.Config, job *ConversionJob) ([]jobOutput, error) {
+	filePath, err := jobFilePath(cfg.TempDir, job)
+	if err != nil {
+		return nil, err
+	}
+	return []jobOutput{{Name: downloadFilename(job), Path: filePath}}, nil
+}
+
+// downloadZIP streams the results of a job as a ZIP archive built as it is
+// sent, so no copy of the results is staged on disk
+func downloadZIP(c *gin.Context, jobID string) {
+	job, exists := getJob(jobID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+
+	job.Touch()
+
+	if job.Snapshot().Status != JobStatusCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Conversion not completed yet",
+		})
+		return
+	}
+
+	outputs, err := jobOutputs(config.Load(), job)
+	if err != nil {
+		log.Printf("Warning: refusing download of job %s: %v", jobID, err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "EPUB file not found",
+		})
+		return
+	}
+	// Check every file up front, as errors can no longer be reported once streaming starts
+	for _, output := range outputs {
+		if _, err := os.Stat(output.Path); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "EPUB file not found",
+			})
+			return
+		}
+	}
+
+	name := strings.TrimSuffix(downloadFilename(job), outputExtension(job)) + zipDownloadSuffix
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDisposition(name, "book_"+jobID+zipDownloadSuffix))
+	c.Status(http.StatusOK)
+	auditJob(jobID, auditDownloaded, "As a ZIP archive")
+
+	archive := zip.NewWriter(c.Writer)
+	for _, output := range outputs {
+		if err := addZIPEntry(archive, output.Name, output.Path); err != nil {
+			// Headers are sent; the truncated archive fails to open on the client
+			log.Printf("Warning: failed to stream %s of job %s: %v", output.Name, jobID, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Warning: failed to finish the archive of job %s: %v", jobID, err)
+	}
+}
+
+// addZIPEntry copies the file at filePath into archive as name. Results are
+// already compressed, so they are stored as they are.
+func addZIPEntry(archive *zip.Writer, name, filePath string) error {
+	//nolint:gosec // Path is checked to lie in the job directory
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Store
+	entry, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/converter"
+)
+
+// conversionKey identifies a conversion by the SHA-256 of the stored book, the
+// output format and the options, so only uploads producing the same result match
+func conversionKey(inputPath, format string, opts *converter.Options) (string, error) {
+	//nolint:gosec // Path is controlled by the job
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := input.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, input); err != nil {
+		return "", err
+	}
+
+	// Large binary options are hashed as they are, the rest through their printed value
+	settings := *opts
+	settings.OnProgress, settings.OnWarning, settings.OnPreview = nil, nil, nil
+	settings.Fonts = nil
+	settings.Metadata.CoverImage = nil
+	fmt.Fprintf(hash, "\x00%s\x00%#v", format, settings)
+	for _, font := range opts.Fonts {
+		fmt.Fprintf(hash, "\x00%s\x00", font.Name)
+		hash.Write(font.Data)
+	}
+	hash.Write([]byte{0})
+	hash.Write(opts.Metadata.CoverImage)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// findDuplicateJob returns the newest job with the same conversion key that is
+// running or completed and whose result can still be downloaded
+func findDuplicateJob(key string) *ConversionJob {
+	if key == "" {
+		return nil
+	}
+
+	var newest *ConversionJob
+	now := time.Now()
+	for _, job := range listJobs() {
+		state := job.Snapshot()
+		if state.ContentKey != key {
+			continue
+		}
+		if state.Status == JobStatusFailed || !state.ExpiresAt.After(now) {
+			continue
+		}
+		if state.Status == JobStatusCompleted {
+			if _, err := os.Stat(job.FilePath); err != nil {
+				continue
+			}
+		}
+		if newest == nil || job.CreatedAt.After(newest.CreatedAt) {
+			newest = job
+		}
+	}
+	return newest
+}
+
+// duplicateResponse describes the existing job returned for a repeated upload
+func duplicateResponse(job *ConversionJob) gin.H {
+	state := job.Snapshot()
+	response := gin.H{
+		"job_id":     job.ID,
+		"status":     state.Status,
+		"message":    "Already converted",
+		"duplicate":  true,
+		"expires_at": state.ExpiresAt,
+	}
+	if state.Status == JobStatusCompleted {
+		response["download_url"] = fmt.Sprintf("/api/v1/download/%s", job.ID)
+	}
+	return response
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+// statusETag returns a weak validator of the status of a job in state, derived
+// from the state its status response reports rather than the serialized
+// response. The access and expiry times, which change with every request, are
+// left out, so a poller sees a new ETag only when the conversion moves on.
+func statusETag(job *ConversionJob, state JobSnapshot) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%d\x00%s\x00%d\x00%d\x00%t\x00%s",
+		job.ID, state.Status, state.Stage, state.Progress, state.Error,
+		len(state.Diagnostics), len(state.Warnings), state.Stats != nil, state.LibraryPath)
+	if state.Delivery != nil {
+		fmt.Fprintf(hash, "\x00%s\x00%s", state.Delivery.Status, state.Delivery.Error)
+	}
+	if position, wait, ok := conversions.position(job); ok {
+		fmt.Fprintf(hash, "\x00%d\x00%d", position, int64(math.Ceil(wait.Seconds())))
+	}
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as RFC 9110 requires for If-None-Match
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Event stream event names
+const (
+	eventProgress = "progress" // Stage and percent of a running conversion
+	eventPreview  = "preview"  // HTML preview of the first chapter
+	eventStatus   = "status"   // Job state, as returned by the status endpoint
+)
+
+// eventBufferSize is how many events a slow subscriber may fall behind before
+// further events are dropped for it; a conversion emits about ten
+const eventBufferSize = 32
+
+// eventKeepAlive is how often an idle stream sends a comment so proxies keep it open
+const eventKeepAlive = 15 * time.Second
+
+// jobEvent is a job update pushed to event stream subscribers
+type jobEvent struct {
+	JobID string
+	Name  string
+	Data  interface{}
+	Final bool // Last event of the job; streams end after sending it
+}
+
+// jobEventHub fans job events out to the streams subscribed to each job
+type jobEventHub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan jobEvent]struct{}
+}
+
+var jobEvents = &jobEventHub{subscribers: make(map[string]map[chan jobEvent]struct{})}
+
+// subscribe returns a channel receiving the events published for any of jobIDs
+func (h *jobEventHub) subscribe(jobIDs ...string) chan jobEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan jobEvent, eventBufferSize*len(jobIDs))
+	for _, jobID := range jobIDs {
+		if h.subscribers[jobID] == nil {
+			h.subscribers[jobID] = make(map[chan jobEvent]struct{})
+		}
+		h.subscribers[jobID][ch] = struct{}{}
+	}
+	return ch
+}
+
+// unsubscribe stops delivering the events of jobIDs to ch
+func (h *jobEventHub) unsubscribe(ch chan jobEvent, jobIDs ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, jobID := range jobIDs {
+		delete(h.subscribers[jobID], ch)
+		if len(h.subscribers[jobID]) == 0 {
+			delete(h.subscribers, jobID)
+		}
+	}
+}
+
+// publish delivers an event to every subscriber of jobID without blocking
+func (h *jobEventHub) publish(jobID string, event jobEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	event.JobID = jobID
+	for ch := range h.subscribers[jobID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// StreamJobEvents streams the progress of a conversion job as Server-Sent Events:
+// "progress" while it runs, "preview" once the book is parsed and a final "status"
+func StreamJobEvents(c *gin.Context) {
+	jobID := c.Param("id")
+
+	job, exists := getJob(jobID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+	job.Touch()
+
+	// Subscribe before reading the job so no transition is missed in between
+	events := jobEvents.subscribe(jobID)
+	defer jobEvents.unsubscribe(events, jobID)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+
+	state := job.Snapshot()
+	if state.Finished() {
+		c.SSEvent(eventStatus, jobStatusResponse(job, state))
+		return
+	}
+	c.SSEvent(eventProgress, gin.H{"stage": state.Stage, "percent": state.Progress})
+	if state.Preview != "" {
+		c.SSEvent(eventPreview, gin.H{"html": state.Preview})
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			c.SSEvent(event.Name, event.Data)
+			return !event.Final
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// maxStreamedJobs caps how many jobs one status stream may follow
+const maxStreamedJobs = 100
+
+// StreamJobStatus streams the state transitions of the jobs listed in the
+// "jobs" query parameter (comma-separated IDs) as Server-Sent Events. Each job
+// first gets a "status" event with its current state and another on every
+// transition; the stream closes once all jobs have completed or failed.
+func StreamJobStatus(c *gin.Context) {
+	jobIDs := splitJobIDs(c.Query("jobs"))
+	if len(jobIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No jobs given. Use ?jobs=<id>,<id>",
+		})
+		return
+	}
+	if len(jobIDs) > maxStreamedJobs {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Too many jobs. Maximum: %d", maxStreamedJobs),
+		})
+		return
+	}
+
+	jobs := make([]*ConversionJob, 0, len(jobIDs))
+	for _, jobID := range jobIDs {
+		job, exists := getJob(jobID)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("Job not found: %s", jobID),
+			})
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Subscribe before reading the jobs so no transition is missed in between
+	events := jobEvents.subscribe(jobIDs...)
+	defer jobEvents.unsubscribe(events, jobIDs...)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+
+	running := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		state := job.Snapshot()
+		c.SSEvent(eventStatus, jobStatusResponse(job, state))
+		if !state.Finished() {
+			running[job.ID] = true
+		}
+	}
+	c.Writer.Flush()
+	if len(running) == 0 {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			if event.Name != eventStatus || !running[event.JobID] {
+				return true
+			}
+			c.SSEvent(eventStatus, event.Data)
+			if event.Final {
+				delete(running, event.JobID)
+			}
+			return len(running) > 0
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// splitJobIDs parses a comma-separated list of job IDs, dropping empty and repeated ones
+func splitJobIDs(value string) []string {
+	var jobIDs []string
+	seen := make(map[string]bool)
+	for _, jobID := range strings.Split(value, ",") {
+		if jobID = strings.TrimSpace(jobID); jobID != "" && !seen[jobID] {
+			seen[jobID] = true
+			jobIDs = append(jobIDs, jobID)
+		}
+	}
+	return jobIDs
+}
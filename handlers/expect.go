@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// expectsContinue reports whether the client sent Expect: 100-continue and
+// waits for the server before sending the body
+func expectsContinue(c *gin.Context) bool {
+	return strings.EqualFold(strings.TrimSpace(c.GetHeader("Expect")), "100-continue")
+}
+
+// refuseBeforeUpload answers a convert request that sent Expect: 100-continue
+// with the errors that can be told from its headers alone, and returns true
+// when it did: a raw upload declaring more than MAX_FILE_SIZE, a quota of the
+// API key already used up, or too little disk space for the declared size.
+// net/http only sends 100 Continue once the handler reads the body, so a
+// refused client never sends the book. Other requests are checked once their
+// body is read, as before, so that for example an upload matching an earlier
+// job is still returned while the daily quota is used up.
+func refuseBeforeUpload(c *gin.Context, cfg *config.Config) bool {
+	if !expectsContinue(c) {
+		return false
+	}
+
+	// The Content-Length of a multipart body includes the form around the
+	// book, so only a raw body declares the size of the book itself
+	var size int64
+	if !strings.HasPrefix(c.ContentType(), "multipart/") && c.Request.ContentLength > 0 {
+		size = c.Request.ContentLength
+	}
+	if size > cfg.MaxFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File too large. Maximum size: %d bytes (%.2f MB)",
+				cfg.MaxFileSize, float64(cfg.MaxFileSize)/(1024*1024)),
+		})
+		return true
+	}
+
+	if key := c.GetString(apiKeyContextKey); key != "" {
+		if refusal := checkQuota(key, cfg, size); refusal != nil {
+			refuseQuota(c, refusal)
+			return true
+		}
+	}
+
+	if c.Request.ContentLength > 0 {
+		if err := checkDiskSpace(cfg.TempDir, c.Request.ContentLength, cfg.MinFreeDiskSpace); err != nil {
+			c.JSON(http.StatusInsufficientStorage, gin.H{
+				"error": fmt.Sprintf("Insufficient storage: %v", err),
+			})
+			return true
+		}
+	}
+	return false
+}
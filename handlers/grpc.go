@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/conversionpb"
+	"github.com/lex/fb2epub/converter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcChunkSize is the size of the chunks results are streamed in
+const grpcChunkSize = 64 * 1024
+
+// grpcMessageOverhead is allowed on top of MAX_FILE_SIZE for the other fields
+// of a convert request, like the form overhead of uploads
+const grpcMessageOverhead = 1 << 20
+
+// grpcAPIKeyContextKey is the context key of the API key a gRPC call was made with
+type grpcAPIKeyContextKey struct{}
+
+// ConversionService serves conversions over gRPC. Jobs are the same as those
+// of the REST API, so a book converted over one can be polled and downloaded
+// over the other.
+type ConversionService struct {
+	conversionpb.UnimplementedConversionServiceServer
+}
+
+// NewGRPCServer returns a gRPC server offering the conversion service. Calls
+// need the keys of API_KEYS, or the admin key, as x-api-key or Bearer
+// authorization metadata, like the REST routes.
+func NewGRPCServer(cfg *config.Config) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(int(cfg.MaxFileSize)+grpcMessageOverhead),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := authorizeGRPC(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo,
+			handler grpc.StreamHandler) error {
+			if _, err := authorizeGRPC(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	conversionpb.RegisterConversionServiceServer(server, &ConversionService{})
+	return server
+}
+
+// authorizeGRPC checks the API key of a call, returning a context carrying it
+// for the quotas. Without API_KEYS every call is allowed.
+func authorizeGRPC(ctx context.Context) (context.Context, error) {
+	cfg := config.Load()
+	if len(cfg.APIKeys) == 0 {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(name string) string {
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	if admin := first("x-admin-key"); cfg.AdminAPIKey != "" && knownAPIKey(admin, []string{cfg.AdminAPIKey}) {
+		return ctx, nil
+	}
+
+	key := first("x-api-key")
+	if key == "" {
+		key = strings.TrimPrefix(first("authorization"), "Bearer ")
+	}
+	if key == "" {
+		return nil, status.Error(codes.Unauthenticated, "Missing API key, send it as x-api-key metadata or a Bearer token")
+	}
+	if !knownAPIKey(key, cfg.APIKeys) {
+		return nil, status.Error(codes.PermissionDenied, "Invalid API key")
+	}
+	return context.WithValue(ctx, grpcAPIKeyContextKey{}, key), nil
+}
+
+// grpcCode returns the gRPC code of an HTTP error status
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests, http.StatusInsufficientStorage:
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
+
+// Convert starts the conversion of a book sent in the request, with the
+// server's default options and the metadata and validation mode it sets
+func (s *ConversionService) Convert(ctx context.Context,
+	req *conversionpb.ConvertRequest) (*conversionpb.ConvertResponse, error) {
+	cfg := config.Load()
+	size := int64(len(req.GetContent()))
+	if size == 0 {
+		return nil, status.Error(codes.InvalidArgument, "No file content sent")
+	}
+	if size > cfg.MaxFileSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "File too large. Maximum size is %.2f MB",
+			float64(cfg.MaxFileSize)/(1024*1024))
+	}
+
+	file := memoryFile{bytes.NewReader(req.GetContent())}
+	fileType, err := detectUploadType(file, req.GetFilename())
+	if err != nil || fileType == uploadUnsupported {
+		return nil, status.Error(codes.InvalidArgument, "Invalid file type. Expected .fb2, .xml, .fb2.zip or .epub file")
+	}
+	format, err := resolveOutputFormat(fileType, "", req.GetOutputFormat())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid conversion: %v", err)
+	}
+	opts := defaultConversionOptions(cfg)
+	opts.Strict = req.GetStrict()
+	opts.Lenient = req.GetLenient()
+	if override := req.GetMetadata(); override != nil {
+		opts.Metadata = converter.MetadataOverrides{
+			Title:       override.GetTitle(),
+			Author:      override.GetAuthor(),
+			Language:    override.GetLanguage(),
+			Series:      override.GetSeries(),
+			SeriesIndex: override.GetSeriesIndex(),
+		}
+	}
+
+	job, err := createJob(cfg, file, req.GetFilename(), size, fileType, format, opts)
+	if err != nil {
+		code := codes.Internal
+		var jobErr *jobError
+		if errors.As(err, &jobErr) {
+			code = grpcCode(jobErr.Status)
+		}
+		return nil, status.Error(code, err.Error())
+	}
+
+	// Only conversions that actually run count against the quotas of the API key
+	if key, _ := ctx.Value(grpcAPIKeyContextKey{}).(string); key != "" {
+		if refusal := chargeQuota(key, cfg, size); refusal != nil {
+			if removeErr := os.RemoveAll(filepath.Dir(job.InputPath)); removeErr != nil {
+				log.Printf("Warning: failed to remove %s: %v", filepath.Dir(job.InputPath), removeErr)
+			}
+			return nil, status.Error(grpcCode(refusal.Status), refusal.Message)
+		}
+		job.APIKey = key
+	}
+	putJob(job)
+	auditReceivedJob(job, size, "over gRPC")
+	log.Printf("Job %s started over gRPC", job.ID)
+
+	queueConversion(cfg, job, opts, nil)
+
+	response := &conversionpb.ConvertResponse{JobId: job.ID, Status: job.Snapshot().Status}
+	if position, _, ok := conversions.position(job); ok {
+		response.QueuePosition = int32(position)
+	}
+	return response, nil
+}
+
+// GetStatus returns the status of a job
+func (s *ConversionService) GetStatus(_ context.Context,
+	req *conversionpb.GetStatusRequest) (*conversionpb.JobStatus, error) {
+	job, exists := getJob(req.GetJobId())
+	if !exists {
+		return nil, status.Error(codes.NotFound, "Job not found")
+	}
+	job.Touch()
+
+	state := job.Snapshot()
+	response := &conversionpb.JobStatus{
+		Id:        job.ID,
+		Status:    state.Status,
+		CreatedAt: job.CreatedAt.Unix(),
+		ExpiresAt: state.ExpiresAt.Unix(),
+	}
+	switch state.Status {
+	case JobStatusPending:
+		if position, wait, ok := conversions.position(job); ok {
+			response.QueuePosition = int32(position)
+			response.EstimatedWaitSeconds = int64(math.Ceil(wait.Seconds()))
+		}
+	case JobStatusProcessing:
+		response.Stage = state.Stage
+		response.Progress = int32(state.Progress)
+	case JobStatusFailed:
+		response.Error = state.Error
+		response.Diagnostics = grpcDiagnostics(state.Diagnostics)
+	}
+	response.Warnings = grpcDiagnostics(state.Warnings)
+	return response, nil
+}
+
+// grpcDiagnostics converts diagnostics to their gRPC messages
+func grpcDiagnostics(diagnostics []converter.Diagnostic) []*conversionpb.Diagnostic {
+	var messages []*conversionpb.Diagnostic
+	for _, diagnostic := range diagnostics {
+		messages = append(messages, &conversionpb.Diagnostic{
+			Line:    int32(diagnostic.Line),
+			Column:  int32(diagnostic.Column),
+			Message: diagnostic.Message,
+		})
+	}
+	return messages
+}
+
+// Download streams the result of a completed job; the first chunk carries
+// its file name and media type
+func (s *ConversionService) Download(req *conversionpb.DownloadRequest,
+	stream conversionpb.ConversionService_DownloadServer) error {
+	job, exists := getJob(req.GetJobId())
+	if !exists {
+		return status.Error(codes.NotFound, "Job not found")
+	}
+	job.Touch()
+
+	if job.Snapshot().Status != JobStatusCompleted {
+		return status.Error(codes.FailedPrecondition, "Conversion not completed yet")
+	}
+
+	filePath, err := jobFilePath(config.Load().TempDir, job)
+	if err != nil {
+		log.Printf("Warning: refusing download of job %s: %v", job.ID, err)
+		return status.Error(codes.NotFound, "EPUB file not found")
+	}
+	//nolint:gosec // Path is checked to lie in the job directory
+	file, err := os.Open(filePath)
+	if err != nil {
+		return status.Error(codes.NotFound, "EPUB file not found")
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	chunk := &conversionpb.DownloadChunk{
+		Filename:    downloadFilename(job),
+		ContentType: outputContentType(job),
+	}
+	buf := make([]byte, grpcChunkSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			chunk.Data = buf[:n]
+			if sendErr := stream.Send(chunk); sendErr != nil {
+				return sendErr
+			}
+			chunk = &conversionpb.DownloadChunk{}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "Failed to read the result: %v", err)
+		}
+	}
+}
+
+// memoryFile is an upload held in memory, such as the content of a gRPC request
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
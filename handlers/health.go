@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// conversionFailure is the most recent failed conversion, reported by /health
+type conversionFailure struct {
+	JobID string    `json:"job_id"`
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
+}
+
+var (
+	lastFailure      *conversionFailure
+	lastFailureMutex sync.Mutex
+)
+
+// recordFailure remembers a failed conversion for the health report
+func recordFailure(jobID, message string) {
+	lastFailureMutex.Lock()
+	defer lastFailureMutex.Unlock()
+	lastFailure = &conversionFailure{JobID: jobID, Error: message, At: time.Now()}
+}
+
+// HealthCheck reports that the service is up. The conversion pipeline is
+// described too (jobs by status, queue depth, last failure, free space in the
+// temp directory) unless an admin key is configured and the request lacks it.
+// A temp directory below MIN_FREE_DISK_SPACE reports the status "degraded".
+func HealthCheck(c *gin.Context) {
+	cfg := config.Load()
+
+	response := gin.H{
+		"status":  "ok",
+		"service": "fb2epub",
+	}
+
+	free, err := freeDiskSpace(cfg.TempDir)
+	if err == nil && free < uint64(cfg.MinFreeDiskSpace) { //nolint:gosec // MinFreeDiskSpace is non-negative
+		response["status"] = "degraded"
+	}
+
+	if cfg.AdminAPIKey != "" && !hasAdminKey(c, cfg) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	jobCounts := map[string]int{
+		JobStatusPending:    0,
+		JobStatusProcessing: 0,
+		JobStatusCompleted:  0,
+		JobStatusFailed:     0,
+	}
+	for _, job := range listJobs() {
+		jobCounts[job.Snapshot().Status]++
+	}
+	response["jobs_by_status"] = jobCounts
+	// Jobs waiting for a free conversion worker (CONVERSION_WORKERS) are pending
+	response["queue_depth"] = jobCounts[JobStatusPending]
+	if err == nil {
+		response["temp_dir_free_bytes"] = free
+	}
+
+	lastFailureMutex.Lock()
+	if lastFailure != nil {
+		response["last_failure"] = *lastFailure
+	}
+	lastFailureMutex.Unlock()
+
+	c.JSON(http.StatusOK, response)
+}
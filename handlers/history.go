@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+	_ "github.com/mattn/go-sqlite3" // SQLite driver for the conversion history
+)
+
+// Page size limits of the history endpoint
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// historySchema creates the conversion history table. Times are stored in UTC
+// so they compare correctly as text.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS conversions (
+	job_id      TEXT PRIMARY KEY,
+	filename    TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	error       TEXT NOT NULL,
+	input_size  INTEGER NOT NULL,
+	output_size INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	created_at  TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS conversions_created_at ON conversions (created_at);
+`
+
+// HistoryEntry is a finished conversion recorded in the history database
+type HistoryEntry struct {
+	JobID       string    `json:"job_id"`
+	Filename    string    `json:"filename"`
+	Title       string    `json:"title"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	InputSize   int64     `json:"input_size_bytes"`
+	OutputSize  int64     `json:"output_size_bytes"`
+	DurationMs  int64     `json:"duration_ms"` // Parse and generate time
+	CreatedAt   time.Time `json:"created_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	DownloadURL string    `json:"download_url,omitempty"` // Set while the EPUB is still available
+}
+
+// historyStore persists conversion history in SQLite, independently of the temp files
+type historyStore struct {
+	db *sql.DB
+}
+
+var (
+	historyMutex  sync.Mutex
+	historyStores = make(map[string]*historyStore) // Open databases by path
+)
+
+// openHistory returns the history database at path, creating it on first use
+func openHistory(path string) (*historyStore, error) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	if store, ok := historyStores[path]; ok {
+		return store, nil
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %w", err)
+	}
+
+	store := &historyStore{db: db}
+	historyStores[path] = store
+	return store, nil
+}
+
+// record stores a finished conversion, replacing an earlier record of the same job
+func (h *historyStore) record(entry *HistoryEntry) error {
+	_, err := h.db.Exec(`INSERT OR REPLACE INTO conversions
+		(job_id, filename, title, status, error, input_size, output_size, duration_ms, created_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.JobID, entry.Filename, entry.Title, entry.Status, entry.Error,
+		entry.InputSize, entry.OutputSize, entry.DurationMs,
+		entry.CreatedAt.UTC(), entry.FinishedAt.UTC())
+	return err
+}
+
+// prune deletes the conversions created before cutoff and returns how many were removed
+func (h *historyStore) prune(cutoff time.Time) (int64, error) {
+	result, err := h.db.Exec(`DELETE FROM conversions WHERE created_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// list returns up to limit conversions, newest first
+func (h *historyStore) list(limit int) ([]HistoryEntry, error) {
+	rows, err := h.db.Query(`SELECT
+		job_id, filename, title, status, error, input_size, output_size, duration_ms, created_at, finished_at
+		FROM conversions ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	entries := make([]HistoryEntry, 0)
+	for rows.Next() {
+		var entry HistoryEntry
+		if err := rows.Scan(&entry.JobID, &entry.Filename, &entry.Title, &entry.Status, &entry.Error,
+			&entry.InputSize, &entry.OutputSize, &entry.DurationMs, &entry.CreatedAt, &entry.FinishedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// recordHistory adds a finished job to the history database, if one is configured,
+// and drops the entries older than the history retention
+func recordHistory(cfg *config.Config, job *ConversionJob) {
+	if cfg.HistoryDB == "" {
+		return
+	}
+
+	store, err := openHistory(cfg.HistoryDB)
+	if err != nil {
+		log.Printf("Warning: conversion history unavailable: %v", err)
+		return
+	}
+
+	state := job.Snapshot()
+	entry := &HistoryEntry{
+		JobID:      job.ID,
+		Filename:   job.Filename,
+		Title:      state.Title,
+		Status:     state.Status,
+		Error:      state.Error,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: time.Now(),
+	}
+	if state.Stats != nil {
+		entry.InputSize = state.Stats.InputSize
+		entry.OutputSize = state.Stats.OutputSize
+		entry.DurationMs = state.Stats.ParseDurationMs + state.Stats.GenerateDurationMs
+	}
+	if err := store.record(entry); err != nil {
+		log.Printf("Warning: failed to record job %s in history: %v", job.ID, err)
+	}
+
+	if _, err := store.prune(time.Now().Add(-cfg.HistoryRetention)); err != nil {
+		log.Printf("Warning: failed to prune conversion history: %v", err)
+	}
+}
+
+// GetHistory lists recent conversions from the history database, newest first.
+// Books whose EPUB is still available carry a download URL.
+func GetHistory(c *gin.Context) {
+	cfg := config.Load()
+	if cfg.HistoryDB == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Conversion history is disabled",
+		})
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > maxHistoryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid limit. Expected 1 to %d", maxHistoryLimit),
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	store, err := openHistory(cfg.HistoryDB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to open history: %v", err),
+		})
+		return
+	}
+
+	entries, err := store.list(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read history: %v", err),
+		})
+		return
+	}
+
+	for i := range entries {
+		if job, exists := getJob(entries[i].JobID); exists && job.Snapshot().Status == JobStatusCompleted {
+			entries[i].DownloadURL = fmt.Sprintf("/api/v1/download/%s", job.ID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+	})
+}
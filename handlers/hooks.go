@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/converter"
+)
+
+// maxHookOutput is how much of the output of a failed hook is kept in its warning
+const maxHookOutput = 500
+
+// hookWaitDelay is how long a timed out hook is waited for once killed, for
+// the processes it started that still hold its output open
+const hookWaitDelay = time.Second
+
+// runHooks runs the POST_CONVERSION_HOOKS on the result of a job one after
+// another. Each gets the path of the result as its last argument and the
+// metadata of the book in FB2EPUB_ environment variables, and may change the
+// file in place. Failures do not fail the job; they are returned as warnings.
+func runHooks(cfg *config.Config, job *ConversionJob, stats *converter.Stats) []converter.Diagnostic {
+	var warnings []converter.Diagnostic
+	for _, hook := range cfg.PostConversionHooks {
+		if err := runHook(cfg, hook, job, stats); err != nil {
+			log.Printf("Warning: hook %q failed for job %s: %v", hook, job.ID, err)
+			warnings = append(warnings, converter.Diagnostic{
+				Message: fmt.Sprintf("Post-conversion hook %s failed: %v", filepath.Base(strings.Fields(hook)[0]), err),
+			})
+		}
+	}
+	return warnings
+}
+
+// runHook runs one hook command on the result of a job
+func runHook(cfg *config.Config, hook string, job *ConversionJob, stats *converter.Stats) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HookTimeout)
+	defer cancel()
+
+	args := append(strings.Fields(hook), job.FilePath)
+	//nolint:gosec // Hooks are configured by the administrator
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = filepath.Dir(job.FilePath)
+	cmd.Env = append(os.Environ(), hookEnv(job, stats)...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = hookWaitDelay
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", cfg.HookTimeout)
+	}
+	if err != nil {
+		if text := strings.TrimSpace(output.String()); text != "" {
+			if len(text) > maxHookOutput {
+				text = "..." + text[len(text)-maxHookOutput:]
+			}
+			return fmt.Errorf("%w: %s", err, text)
+		}
+		return err
+	}
+	return nil
+}
+
+// hookEnv returns the environment variables describing a job to its hooks
+func hookEnv(job *ConversionJob, stats *converter.Stats) []string {
+	env := []string{
+		"FB2EPUB_JOB_ID=" + job.ID,
+		"FB2EPUB_OUTPUT_PATH=" + job.FilePath,
+		"FB2EPUB_OUTPUT_FORMAT=" + job.OutputFormat,
+		"FB2EPUB_FILENAME=" + job.Filename,
+		"FB2EPUB_TITLE=" + job.Snapshot().Title,
+	}
+	if stats != nil {
+		env = append(env,
+			"FB2EPUB_AUTHORS="+strings.Join(stats.Authors, ", "),
+			"FB2EPUB_SERIES="+stats.Series,
+		)
+	}
+	return env
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyKeyHeader carries a client-chosen key naming a convert request,
+// so a request retried after a lost response is not converted twice
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength limits the length of idempotency keys
+const maxIdempotencyKeyLength = 255
+
+// idempotencyContextKey is the gin context key of the claimed idempotency key of a request
+const idempotencyContextKey = "idempotencyKey"
+
+var (
+	// idempotentJobs maps the idempotency keys of convert requests, scoped by
+	// API key, to the ID of the job they started; an empty ID marks a request
+	// still being handled
+	idempotentJobs = make(map[string]string)
+	// idempotencyKeys maps the IDs of jobs returned for an idempotency key
+	// back to the set of those keys; a duplicate upload can add another key
+	// to an existing job. They are freed when the job is removed.
+	idempotencyKeys = make(map[string]map[string]bool)
+	idempotencyMux  sync.Mutex // Guards idempotentJobs and idempotencyKeys
+)
+
+// beginIdempotentRequest claims the Idempotency-Key of a convert request. It
+// responds and returns handled when the key was used before: with the job of
+// the first request, or with a conflict while that request is still being
+// handled. Otherwise the returned finish must be called once the request is
+// handled; it frees the key again unless a job was started under it.
+func beginIdempotentRequest(c *gin.Context) (finish func(), handled bool) {
+	key := strings.TrimSpace(c.GetHeader(idempotencyKeyHeader))
+	if key == "" {
+		return func() {}, false
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Idempotency-Key too long, maximum length: %d characters", maxIdempotencyKeyLength),
+		})
+		return nil, true
+	}
+	scoped := c.GetString(apiKeyContextKey) + "\x00" + key
+
+	idempotencyMux.Lock()
+	jobID, used := idempotentJobs[scoped]
+	var job *ConversionJob
+	if used && jobID != "" {
+		var exists bool
+		if job, exists = getJob(jobID); !exists {
+			used = false
+			delete(idempotencyKeys[jobID], scoped)
+			if len(idempotencyKeys[jobID]) == 0 {
+				delete(idempotencyKeys, jobID)
+			}
+		}
+	}
+	if !used {
+		idempotentJobs[scoped] = ""
+	}
+	idempotencyMux.Unlock()
+
+	switch {
+	case job != nil:
+		job.Touch()
+		c.Header("Idempotent-Replayed", "true")
+		c.JSON(http.StatusOK, idempotentResponse(job))
+		return nil, true
+	case used:
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A request with this Idempotency-Key is still being handled",
+		})
+		return nil, true
+	}
+
+	c.Set(idempotencyContextKey, scoped)
+	return func() {
+		idempotencyMux.Lock()
+		defer idempotencyMux.Unlock()
+		if idempotentJobs[scoped] == "" {
+			delete(idempotentJobs, scoped)
+		}
+	}, false
+}
+
+// forgetIdempotentJob frees the idempotency keys of a removed job, so they
+// can be used again
+func forgetIdempotentJob(jobID string) {
+	idempotencyMux.Lock()
+	defer idempotencyMux.Unlock()
+	for scoped := range idempotencyKeys[jobID] {
+		if idempotentJobs[scoped] == jobID {
+			delete(idempotentJobs, scoped)
+		}
+	}
+	delete(idempotencyKeys, jobID)
+}
+
+// recordIdempotentJob remembers the job started by a request under its
+// idempotency key, if it claimed one
+func recordIdempotentJob(c *gin.Context, jobID string) {
+	scoped := c.GetString(idempotencyContextKey)
+	if scoped == "" {
+		return
+	}
+	idempotencyMux.Lock()
+	defer idempotencyMux.Unlock()
+	idempotentJobs[scoped] = jobID
+	if idempotencyKeys[jobID] == nil {
+		idempotencyKeys[jobID] = make(map[string]bool)
+	}
+	idempotencyKeys[jobID][scoped] = true
+}
+
+// idempotentResponse describes the job returned for a repeated request
+func idempotentResponse(job *ConversionJob) gin.H {
+	state := job.Snapshot()
+	response := gin.H{
+		"job_id":     job.ID,
+		"status":     state.Status,
+		"message":    "Already requested",
+		"expires_at": state.ExpiresAt,
+	}
+	if state.Status == JobStatusCompleted {
+		response["download_url"] = fmt.Sprintf("/api/v1/download/%s", job.ID)
+	}
+	return response
+}
+
+// IdempotencyKeysInUse returns the number of remembered idempotency keys (for testing)
+func IdempotencyKeysInUse() int {
+	idempotencyMux.Lock()
+	defer idempotencyMux.Unlock()
+	return len(idempotentJobs)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var (
+	conversionJobs = make(map[string]*ConversionJob)
+	jobsMutex      sync.RWMutex // Guards conversionJobs
+)
+
+// getJob returns the job with the given ID
+func getJob(jobID string) (*ConversionJob, bool) {
+	jobsMutex.RLock()
+	defer jobsMutex.RUnlock()
+	job, exists := conversionJobs[jobID]
+	return job, exists
+}
+
+// putJob stores a job, replacing any job with the same ID
+func putJob(job *ConversionJob) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	conversionJobs[job.ID] = job
+}
+
+// removeJob deletes a job from the store and frees its idempotency key
+func removeJob(jobID string) {
+	jobsMutex.Lock()
+	delete(conversionJobs, jobID)
+	jobsMutex.Unlock()
+	forgetIdempotentJob(jobID)
+}
+
+// listJobs returns a snapshot of all known jobs
+func listJobs() []*ConversionJob {
+	jobsMutex.RLock()
+	defer jobsMutex.RUnlock()
+	jobs := make([]*ConversionJob, 0, len(conversionJobs))
+	for _, job := range conversionJobs {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// isJobID reports whether id has the form of the job and upload IDs the
+// service hands out: a UUID in its canonical lowercase form
+func isJobID(id string) bool {
+	parsed, err := uuid.Parse(id)
+	return err == nil && parsed.String() == id
+}
+
+// ValidateIDParam rejects requests whose :id path parameter is not a job or
+// upload ID with 400, before any handler looks it up or builds a path from it.
+// Routes without an :id parameter pass through, and a .zip suffix, which asks
+// for a download as an archive, is allowed.
+func ValidateIDParam() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := strings.TrimSuffix(c.Param("id"), zipDownloadSuffix); id != "" && !isJobID(id) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid ID, expected a UUID",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// jobFilePath returns the result file of a job after checking that it lies in
+// the job's own directory under tempDir, so a job put into the store with a
+// crafted ID or path cannot be used to read other files
+func jobFilePath(tempDir string, job *ConversionJob) (string, error) {
+	if !isJobID(job.ID) {
+		return "", fmt.Errorf("job ID %q is not a UUID", job.ID)
+	}
+	dir, err := filepath.Abs(filepath.Join(tempDir, job.ID))
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.Abs(job.FilePath)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s of job %s is outside %s", job.FilePath, job.ID, dir)
+	}
+	return path, nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/converter"
+)
+
+// Library layout settings
+const (
+	unknownAuthor       = "Unknown Author"
+	maxLibraryNameRunes = 100 // Longer names are cut, keeping paths within filesystem limits
+	maxLibraryDuplicate = 1000
+)
+
+// storeInLibrary copies the result of a completed job into the library as
+// Author/Series/Title.ext, or Author/Title.ext for books outside a series.
+// A book already stored under the same name is kept, and the new one is stored
+// as "Title (2).ext". Failures are logged and do not fail the job.
+func storeInLibrary(cfg *config.Config, job *ConversionJob, stats *converter.Stats) {
+	relPath, err := copyToLibrary(cfg.LibraryDir, job, stats)
+	if err != nil {
+		log.Printf("Warning: failed to store job %s in the library: %v", job.ID, err)
+		return
+	}
+	job.setLibraryPath(relPath)
+	log.Printf("Job %s stored in the library as %s", job.ID, relPath)
+}
+
+func copyToLibrary(libraryDir string, job *ConversionJob, stats *converter.Stats) (string, error) {
+	dir, name := libraryLocation(job, stats)
+	//nolint:gosec // 0755 needed for Docker volume mounts
+	if err := os.MkdirAll(filepath.Join(libraryDir, dir), 0755); err != nil {
+		return "", err
+	}
+
+	//nolint:gosec // Path is controlled by the job
+	source, err := os.Open(job.FilePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := source.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	// Write to a temporary file first so the library never holds partial books
+	temp, err := os.CreateTemp(filepath.Join(libraryDir, dir), ".store-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(temp, source); err != nil {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+		return "", err
+	}
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(temp.Name())
+		return "", err
+	}
+	//nolint:gosec // Books in the library are meant to be shared
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		_ = os.Remove(temp.Name())
+		return "", err
+	}
+
+	ext := outputExtension(job)
+	for n := 1; n <= maxLibraryDuplicate; n++ {
+		candidate := name + ext
+		if n > 1 {
+			candidate = fmt.Sprintf("%s (%d)%s", name, n, ext)
+		}
+		relPath := filepath.Join(dir, candidate)
+		target := filepath.Join(libraryDir, relPath)
+		// Claim the name exclusively, so concurrent jobs cannot overwrite each other
+		//nolint:gosec // Path is built from sanitized metadata
+		placeholder, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err == nil {
+			_ = placeholder.Close()
+			err = os.Rename(temp.Name(), target)
+		}
+		if err != nil {
+			_ = os.Remove(temp.Name())
+			return "", err
+		}
+		return filepath.ToSlash(relPath), nil
+	}
+	_ = os.Remove(temp.Name())
+	return "", fmt.Errorf("too many books named %s in %s", name, dir)
+}
+
+// libraryLocation returns the directory of a book in the library, relative to
+// the library root, and its file name without extension
+func libraryLocation(job *ConversionJob, stats *converter.Stats) (dir, name string) {
+	author := ""
+	if len(stats.Authors) > 0 {
+		author = libraryName(stats.Authors[0])
+	}
+	if author == "" {
+		author = unknownAuthor
+	}
+
+	dir = author
+	if series := libraryName(stats.Series); series != "" {
+		dir = filepath.Join(author, series)
+	}
+
+	name = libraryName(stats.Title)
+	if name == "" {
+		name = libraryName(strings.TrimSuffix(resultFilename(job), outputExtension(job)))
+	}
+	return dir, name
+}
+
+// libraryName turns metadata into a file or directory name that is valid on
+// common filesystems: separators and reserved characters become spaces, runs
+// of spaces are collapsed and leading dots are dropped so names are never
+// hidden or special
+func libraryName(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return ' '
+		}
+		return r
+	}, value)
+	value = strings.Join(strings.Fields(value), " ")
+
+	if runes := []rune(value); len(runes) > maxLibraryNameRunes {
+		value = strings.TrimSpace(string(runes[:maxLibraryNameRunes]))
+	}
+	return strings.TrimRight(strings.TrimLeft(value, ". "), ". ")
+}
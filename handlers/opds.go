@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// OPDS catalog media types and link relations
+const (
+	opdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	opdsAcquisitionRel  = "http://opds-spec.org/acquisition"
+	opdsFeedPath        = "/api/v1/opds"
+	atomNamespace       = "http://www.w3.org/2005/Atom"
+)
+
+// opdsFeed is an OPDS 1.2 acquisition feed
+type opdsFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []opdsLink  `xml:"link"`
+	Entries []opdsEntry `xml:"entry"`
+}
+
+// opdsEntry is a downloadable book of the feed
+type opdsEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Content string     `xml:"content"`
+	Links   []opdsLink `xml:"link"`
+}
+
+type opdsLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// GetOPDSCatalog serves the recent conversions from the history database whose
+// books are still available as an OPDS acquisition feed, so e-readers can
+// browse and download them. Failed and expired conversions are left out.
+func GetOPDSCatalog(c *gin.Context) {
+	cfg := config.Load()
+	if cfg.HistoryDB == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Conversion history is disabled",
+		})
+		return
+	}
+
+	store, err := openHistory(cfg.HistoryDB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to open history: %v", err),
+		})
+		return
+	}
+
+	history, err := store.list(defaultHistoryLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read history: %v", err),
+		})
+		return
+	}
+
+	feed := opdsFeed{
+		Xmlns:   atomNamespace,
+		ID:      "urn:fb2epub:conversions",
+		Title:   "Recent conversions",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []opdsLink{
+			{Rel: "self", Href: opdsFeedPath, Type: opdsAcquisitionType},
+			{Rel: "start", Href: opdsFeedPath, Type: opdsAcquisitionType},
+		},
+		Entries: make([]opdsEntry, 0, len(history)),
+	}
+	for _, entry := range history {
+		job, exists := getJob(entry.JobID)
+		if !exists || job.Snapshot().Status != JobStatusCompleted {
+			continue
+		}
+		title := entry.Title
+		if title == "" {
+			title = entry.Filename
+		}
+		feed.Entries = append(feed.Entries, opdsEntry{
+			ID:      "urn:uuid:" + entry.JobID,
+			Title:   title,
+			Updated: entry.FinishedAt.UTC().Format(time.RFC3339),
+			Content: fmt.Sprintf("Converted from %s", entry.Filename),
+			Links: []opdsLink{{
+				Rel:  opdsAcquisitionRel,
+				Href: fmt.Sprintf("/api/v1/download/%s", entry.JobID),
+				Type: outputContentType(job),
+			}},
+		})
+	}
+
+	output, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to build catalog: %v", err),
+		})
+		return
+	}
+	c.Data(http.StatusOK, opdsAcquisitionType+";charset=utf-8", append([]byte(xml.Header), output...))
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	_ "embed" // Required for go:embed
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the OpenAPI 3 document describing the REST API.
+// Update openapi.json whenever a route or its payload changes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the raw OpenAPI document
+func OpenAPISpec() []byte {
+	return openAPISpec
+}
+
+// GetOpenAPISpec serves the OpenAPI specification
+func GetOpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPISpec)
+}
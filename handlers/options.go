@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/converter"
+)
+
+// maxCoverImageSize limits the size of a cover image uploaded with a conversion request
+const maxCoverImageSize = 10 * 1024 * 1024 // 10MB
+
+// Limits for font files uploaded with a conversion request
+const (
+	maxFontSize      = 10 * 1024 * 1024 // 10MB per font
+	maxUploadedFonts = 8
+)
+
+// supportedCoverTypes lists the image types accepted as cover overrides
+var supportedCoverTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// parseOptionsForm parses the optional multipart form of a request that carries
+// conversion options but no book, such as a retry. Other bodies are left to gin.
+func parseOptionsForm(c *gin.Context, cfg *config.Config) error {
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		return nil
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxFileSize)
+	return c.Request.ParseMultipartForm(cfg.MaxFileSize)
+}
+
+// parseConversionOptions builds conversion options from the multipart form fields
+// of a convert request. The form must already be parsed.
+func parseConversionOptions(c *gin.Context, cfg *config.Config) (*converter.Options, error) {
+	return amendConversionOptions(c, cfg, defaultConversionOptions(cfg))
+}
+
+// defaultConversionOptions returns the options of a conversion that sets none,
+// limited as configured
+func defaultConversionOptions(cfg *config.Config) *converter.Options {
+	opts := converter.DefaultOptions()
+	opts.Limits = ConversionLimits(cfg)
+	opts.TemplateDir = cfg.TemplatesDir
+	return opts
+}
+
+// ConversionLimits returns the limits input documents are held to, as configured
+func ConversionLimits(cfg *config.Config) converter.Limits {
+	return converter.Limits{
+		MaxDepth:        cfg.MaxXMLDepth,
+		MaxBinarySize:   cfg.MaxBinarySize,
+		MaxSectionDepth: cfg.MaxSectionDepth,
+		MaxSections:     cfg.MaxSections,
+		MaxParagraphs:   cfg.MaxParagraphs,
+	}
+}
+
+// amendConversionOptions returns a copy of base with the options sent in the
+// form fields of the request applied; absent fields keep their base value.
+// The form must already be parsed.
+func amendConversionOptions(c *gin.Context, cfg *config.Config, base *converter.Options) (*converter.Options, error) {
+	amended := *base
+	opts := &amended
+
+	formString(c, "title", &opts.Metadata.Title)
+	formString(c, "author", &opts.Metadata.Author)
+	formString(c, "language", &opts.Metadata.Language)
+	formString(c, "series", &opts.Metadata.Series)
+	formString(c, "series_index", &opts.Metadata.SeriesIndex)
+
+	strict, err := formBool(c, "strict", opts.Strict)
+	if err != nil {
+		return nil, err
+	}
+	opts.Strict = strict
+
+	if opts.Lenient, err = formBool(c, "lenient", opts.Lenient); err != nil {
+		return nil, err
+	}
+
+	if opts.HTMLEntities, err = formBool(c, "html_entities", opts.HTMLEntities); err != nil {
+		return nil, err
+	}
+
+	if opts.PruneImages, err = formBool(c, "prune_images", opts.PruneImages); err != nil {
+		return nil, err
+	}
+
+	detectCover, err := formBool(c, "detect_cover", !opts.DisableCoverDetection)
+	if err != nil {
+		return nil, err
+	}
+	opts.DisableCoverDetection = !detectCover
+
+	if opts.GenerateCover, err = formBool(c, "generate_cover", opts.GenerateCover); err != nil {
+		return nil, err
+	}
+
+	if opts.DropCaps, err = formBool(c, "drop_caps", opts.DropCaps); err != nil {
+		return nil, err
+	}
+
+	formString(c, "theme", (*string)(&opts.Theme))
+	if err := opts.Theme.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.Hyphenate, err = formBool(c, "hyphenate", opts.Hyphenate); err != nil {
+		return nil, err
+	}
+
+	if opts.Typography, err = formBool(c, "typography", opts.Typography); err != nil {
+		return nil, err
+	}
+
+	if opts.TOCDepth, err = formNonNegativeInt(c, "toc_depth", opts.TOCDepth); err != nil {
+		return nil, err
+	}
+
+	formString(c, "untitled_sections", (*string)(&opts.UntitledSections))
+	if err := opts.UntitledSections.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.FlattenSingleChild, err = formBool(c, "flatten_single_child", opts.FlattenSingleChild); err != nil {
+		return nil, err
+	}
+
+	if opts.PageLength, err = formNonNegativeInt(c, "page_length", opts.PageLength); err != nil {
+		return nil, err
+	}
+
+	formString(c, "notes", (*string)(&opts.Notes))
+	if err := opts.Notes.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.Kepub, err = formBool(c, "kepub", opts.Kepub); err != nil {
+		return nil, err
+	}
+
+	if opts.Colophon, err = formBool(c, "colophon", opts.Colophon); err != nil {
+		return nil, err
+	}
+
+	formString(c, "pdf_page_size", &opts.PDF.PageSize)
+	formString(c, "pdf_font", &opts.PDF.Font)
+	if opts.PDF.Margin, err = formNonNegativeInt(c, "pdf_margin", opts.PDF.Margin); err != nil {
+		return nil, err
+	}
+	if opts.PDF.FontSize, err = formNonNegativeInt(c, "pdf_font_size", opts.PDF.FontSize); err != nil {
+		return nil, err
+	}
+	if err := opts.PDF.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := parseFontOptions(c, cfg, opts); err != nil {
+		return nil, err
+	}
+
+	cover, _, err := c.Request.FormFile("cover")
+	if err == http.ErrMissingFile {
+		return opts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid cover image: %w", err)
+	}
+	defer func() {
+		if closeErr := cover.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(cover, maxCoverImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cover image: %w", err)
+	}
+	if len(data) > maxCoverImageSize {
+		return nil, fmt.Errorf("cover image too large, maximum size: %d bytes", maxCoverImageSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !supportedCoverTypes[contentType] {
+		return nil, fmt.Errorf("unsupported cover image type %q, expected JPEG, PNG or GIF", contentType)
+	}
+
+	opts.Metadata.CoverImage = data
+	opts.Metadata.CoverContentType = contentType
+
+	return opts, nil
+}
+
+// formString sets *value to a trimmed text form field when the field is present
+func formString(c *gin.Context, name string, value *string) {
+	if field, ok := c.GetPostForm(name); ok {
+		*value = strings.TrimSpace(field)
+	}
+}
+
+// formBool reads a boolean form field, returning def when the field is absent
+func formBool(c *gin.Context, name string, def bool) (bool, error) {
+	value := strings.TrimSpace(c.PostForm(name))
+	if value == "" {
+		return def, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q for %s, expected true or false", value, name)
+	}
+	return parsed, nil
+}
+
+// formNonNegativeInt reads a non-negative integer form field, returning def when the field is absent
+func formNonNegativeInt(c *gin.Context, name string, def int) (int, error) {
+	value := strings.TrimSpace(c.PostForm(name))
+	if value == "" {
+		return def, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid value %q for %s, expected a non-negative integer", value, name)
+	}
+	return parsed, nil
+}
+
+// parseFontOptions collects the fonts to embed: the server font directory when
+// embed_fonts is set, plus any font files uploaded in the fonts field. A request
+// sending either replaces the fonts of opts.
+func parseFontOptions(c *gin.Context, cfg *config.Config, opts *converter.Options) error {
+	embedServerFonts, err := formBool(c, "embed_fonts", false)
+	if err != nil {
+		return err
+	}
+	var uploads []*multipart.FileHeader
+	if c.Request.MultipartForm != nil {
+		uploads = c.Request.MultipartForm.File["fonts"]
+	}
+	if embedServerFonts || len(uploads) > 0 {
+		opts.Fonts = nil
+	}
+
+	if embedServerFonts {
+		if cfg.FontsDir == "" {
+			return fmt.Errorf("embed_fonts requested but no server fonts are configured")
+		}
+		fonts, err := converter.LoadFonts(cfg.FontsDir)
+		if err != nil {
+			return err
+		}
+		opts.Fonts = append(opts.Fonts, fonts...)
+	}
+
+	if opts.ObfuscateFonts, err = formBool(c, "obfuscate_fonts", opts.ObfuscateFonts); err != nil {
+		return err
+	}
+
+	if len(uploads) > maxUploadedFonts {
+		return fmt.Errorf("too many fonts, maximum: %d", maxUploadedFonts)
+	}
+	for _, header := range uploads {
+		if !converter.IsSupportedFont(header.Filename) {
+			return fmt.Errorf("unsupported font %q, expected .ttf, .otf, .woff or .woff2", header.Filename)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return fmt.Errorf("invalid font %q: %w", header.Filename, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(file, maxFontSize+1))
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read font %q: %w", header.Filename, err)
+		}
+		if len(data) > maxFontSize {
+			return fmt.Errorf("font %q too large, maximum size: %d bytes", header.Filename, maxFontSize)
+		}
+		opts.Fonts = append(opts.Fonts, converter.Font{Name: header.Filename, Data: data})
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/converter"
+)
+
+// recentDurations is how many of the latest conversion durations are averaged
+// to estimate the wait of queued jobs
+const recentDurations = 20
+
+// conversionQueue limits how many conversions run at once. Jobs started
+// beyond the limit stay pending and are run in the order they were queued as
+// running conversions finish.
+type conversionQueue struct {
+	mu        sync.Mutex
+	limit     int // Maximum number of running conversions; zero is unlimited
+	running   int
+	waiting   []queuedConversion
+	durations []time.Duration // Latest conversion durations, oldest first
+}
+
+// queuedConversion is a job waiting for its conversion to start
+type queuedConversion struct {
+	job *ConversionJob
+	run func()
+}
+
+// conversions runs the conversions of all jobs
+var conversions = &conversionQueue{}
+
+// queueConversion converts a registered job in the background once the
+// CONVERSION_WORKERS limit allows it, calling done afterwards if set. Jobs
+// started with an API key hold a concurrent job slot of the key, which is
+// freed once the conversion finishes.
+func queueConversion(cfg *config.Config, job *ConversionJob, opts *converter.Options, done func()) {
+	conversions.enqueue(job, cfg.ConversionWorkers, func() {
+		processConversion(job, job.InputPath, job.FilePath, cfg, opts)
+		releaseQuotaSlot(job.APIKey)
+		if done != nil {
+			done()
+		}
+	})
+}
+
+// addQueuePosition adds the place of a pending job in the queue and, once
+// known, the seconds it is expected to wait to a response about it
+func addQueuePosition(response gin.H, job *ConversionJob) {
+	position, wait, ok := conversions.position(job)
+	if !ok {
+		return
+	}
+	response["queue_position"] = position
+	if wait > 0 {
+		response["estimated_wait_seconds"] = int64(math.Ceil(wait.Seconds()))
+	}
+}
+
+// enqueue runs the conversion of job in the background, at once if fewer than
+// limit conversions are running and otherwise once its turn comes; the job is
+// pending until then. A limit of zero runs every conversion at once.
+func (q *conversionQueue) enqueue(job *ConversionJob, limit int, run func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.limit = limit
+	if q.limit > 0 && (q.running >= q.limit || len(q.waiting) > 0) {
+		job.setStatus(JobStatusPending)
+		q.waiting = append(q.waiting, queuedConversion{job: job, run: run})
+		auditJob(job.ID, auditQueued, "Waiting for a free slot at position %d", len(q.waiting))
+		return
+	}
+	q.start(job, run)
+}
+
+// start runs a conversion in the background; q.mu must be held
+func (q *conversionQueue) start(job *ConversionJob, run func()) {
+	job.setStatus(JobStatusProcessing)
+	q.running++
+	go func() {
+		started := time.Now()
+		run()
+		q.finish(time.Since(started))
+	}()
+}
+
+// finish records the duration of a finished conversion and starts the
+// conversions waiting for its slot
+func (q *conversionQueue) finish(duration time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running--
+	q.durations = append(q.durations, duration)
+	if len(q.durations) > recentDurations {
+		q.durations = q.durations[1:]
+	}
+	for len(q.waiting) > 0 && (q.limit <= 0 || q.running < q.limit) {
+		next := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.start(next.job, next.run)
+	}
+}
+
+// position returns the 1-based place of a pending job in the queue, and an
+// estimate of how long it will wait to start, zero until a conversion has
+// finished to base it on. ok is false when the job is not queued.
+func (q *conversionQueue) position(job *ConversionJob) (position int, wait time.Duration, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, queued := range q.waiting {
+		if queued.job != job {
+			continue
+		}
+		position = i + 1
+		if len(q.durations) > 0 && q.limit > 0 {
+			var total time.Duration
+			for _, duration := range q.durations {
+				total += duration
+			}
+			// Each round of limit conversions takes about the average duration
+			rounds := (position + q.limit - 1) / q.limit
+			wait = total / time.Duration(len(q.durations)) * time.Duration(rounds)
+		}
+		return position, wait, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// apiKeyContextKey is the gin context key of the API key a request was made with
+const apiKeyContextKey = "apiKey"
+
+// keyUsage counts what an API key converted on one UTC day, and the
+// conversions of the key waiting or running, whatever day they started
+type keyUsage struct {
+	Day         string
+	Conversions int
+	Bytes       int64
+	Active      int
+}
+
+var (
+	quotaUsage = make(map[string]*keyUsage)
+	quotaMutex sync.Mutex
+)
+
+// RequireAPIKey protects client routes with the keys of API_KEYS, sent as an
+// X-API-Key header or a Bearer token. The admin key is accepted as well and
+// is not subject to quotas. Without API_KEYS the routes are open to everyone.
+func RequireAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.Load()
+		if len(cfg.APIKeys) == 0 || hasAdminKey(c, cfg) {
+			c.Next()
+			return
+		}
+
+		key := apiKey(c)
+		if key == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="fb2epub"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Missing API key, send it as an X-API-Key header or a Bearer token",
+			})
+			return
+		}
+		if !knownAPIKey(key, cfg.APIKeys) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Invalid API key",
+			})
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// apiKey returns the client key sent with a request: the X-API-Key header or a Bearer token
+func apiKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+// knownAPIKey reports whether key is one of the configured keys
+func knownAPIKey(key string, keys []string) bool {
+	known := false
+	for _, candidate := range keys {
+		// Compare with every key so the time taken does not reveal which one matched
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			known = true
+		}
+	}
+	return known
+}
+
+// usageFor returns the usage of key on the day of now, starting a new count
+// on a new day. The caller must hold quotaMutex.
+func usageFor(key string, now time.Time) *keyUsage {
+	day := now.UTC().Format("2006-01-02")
+	usage, exists := quotaUsage[key]
+	if !exists || usage.Day != day {
+		previous := usage
+		usage = &keyUsage{Day: day}
+		if previous != nil {
+			usage.Active = previous.Active
+		}
+		quotaUsage[key] = usage
+	}
+	return usage
+}
+
+// quotaResetsAt returns when the daily quotas start over: the next UTC midnight
+func quotaResetsAt(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// holdQuotaSlot counts a conversion of key as running without checking its
+// quotas, for retries of jobs it already started
+func holdQuotaSlot(key string) {
+	if key == "" {
+		return
+	}
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	usageFor(key, time.Now()).Active++
+}
+
+// releaseQuotaSlot frees the concurrent job slot a conversion of key held,
+// once it has finished or was dropped before running
+func releaseQuotaSlot(key string) {
+	if key == "" {
+		return
+	}
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+	if usage, exists := quotaUsage[key]; exists && usage.Active > 0 {
+		usage.Active--
+	}
+}
+
+// reserveQuota counts a conversion of size bytes against the quotas of the API
+// key of the request and holds one of its concurrent job slots, which
+// releaseQuotaSlot frees. When that would exceed a quota it answers 429, or 403
+// for an upload larger than the whole daily byte quota, and returns false.
+// Requests without a key, on an open API or from the admin, are not limited.
+func reserveQuota(c *gin.Context, cfg *config.Config, size int64) bool {
+	key := c.GetString(apiKeyContextKey)
+	if key == "" {
+		return true
+	}
+
+	refusal := chargeQuota(key, cfg, size)
+	if refusal == nil {
+		return true
+	}
+	refuseQuota(c, refusal)
+	return false
+}
+
+// refuseQuota answers a request refused for exceeding a quota
+func refuseQuota(c *gin.Context, refusal *quotaRefusal) {
+	if refusal.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(refusal.RetryAfter.Seconds())+1))
+	}
+	response := gin.H{
+		"error": refusal.Message,
+	}
+	if refusal.Usage != nil {
+		response["usage"] = refusal.Usage
+	}
+	c.JSON(refusal.Status, response)
+}
+
+// quotaRefusal is why a conversion exceeding a quota is refused
+type quotaRefusal struct {
+	Status     int
+	Message    string
+	Usage      gin.H         // Usage of the key, when a daily or concurrent quota is reached
+	RetryAfter time.Duration // Time until the daily quotas start over, when one of them is reached
+}
+
+// chargeQuota counts a conversion of size bytes against the quotas of key and
+// holds one of its concurrent job slots, returning why it is refused instead
+// when that would exceed a quota
+func chargeQuota(key string, cfg *config.Config, size int64) *quotaRefusal {
+	return applyQuota(key, cfg, size, true)
+}
+
+// checkQuota returns why a conversion of size bytes would be refused by the
+// quotas of key, without counting it
+func checkQuota(key string, cfg *config.Config, size int64) *quotaRefusal {
+	return applyQuota(key, cfg, size, false)
+}
+
+// applyQuota checks a conversion of size bytes against the quotas of key and,
+// when charge is set and it is allowed, counts it. The check and the count
+// are made at once, so parallel requests cannot all take the last slot.
+func applyQuota(key string, cfg *config.Config, size int64, charge bool) *quotaRefusal {
+	if cfg.QuotaBytesPerDay > 0 && size > cfg.QuotaBytesPerDay {
+		return &quotaRefusal{Status: http.StatusForbidden,
+			Message: fmt.Sprintf("Upload of %d bytes exceeds the daily quota of %d bytes", size, cfg.QuotaBytesPerDay)}
+	}
+
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+
+	now := time.Now()
+	usage := usageFor(key, now)
+	refusal := &quotaRefusal{Status: http.StatusTooManyRequests}
+	switch {
+	case cfg.QuotaConcurrentJobs > 0 && usage.Active >= cfg.QuotaConcurrentJobs:
+		refusal.Message = fmt.Sprintf("Concurrent job quota of %d reached, wait for a conversion to finish", cfg.QuotaConcurrentJobs)
+	case cfg.QuotaConversionsPerDay > 0 && usage.Conversions >= cfg.QuotaConversionsPerDay:
+		refusal.Message = fmt.Sprintf("Daily quota of %d conversions reached", cfg.QuotaConversionsPerDay)
+		refusal.RetryAfter = quotaResetsAt(now).Sub(now)
+	case cfg.QuotaBytesPerDay > 0 && usage.Bytes+size > cfg.QuotaBytesPerDay:
+		refusal.Message = fmt.Sprintf("Daily quota of %d bytes would be exceeded, %d bytes left today",
+			cfg.QuotaBytesPerDay, cfg.QuotaBytesPerDay-usage.Bytes)
+		refusal.RetryAfter = quotaResetsAt(now).Sub(now)
+	default:
+		if charge {
+			usage.Conversions++
+			usage.Bytes += size
+			usage.Active++
+		}
+		return nil
+	}
+	refusal.Usage = usageReport(usage, cfg, now)
+	return refusal
+}
+
+// usageReport describes the usage of a key against its quotas; a limit of 0 is unlimited
+func usageReport(usage *keyUsage, cfg *config.Config, now time.Time) gin.H {
+	return gin.H{
+		"day":                   usage.Day,
+		"conversions":           usage.Conversions,
+		"conversions_limit":     cfg.QuotaConversionsPerDay,
+		"bytes":                 usage.Bytes,
+		"bytes_limit":           cfg.QuotaBytesPerDay,
+		"concurrent_jobs":       usage.Active,
+		"concurrent_jobs_limit": cfg.QuotaConcurrentJobs,
+		"resets_at":             quotaResetsAt(now),
+	}
+}
+
+// GetUsage reports what the API key of the request converted today and its quotas
+func GetUsage(c *gin.Context) {
+	key := c.GetString(apiKeyContextKey)
+	if key == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Usage is only tracked for requests made with one of the API_KEYS",
+		})
+		return
+	}
+
+	cfg := config.Load()
+	quotaMutex.Lock()
+	defer quotaMutex.Unlock()
+
+	now := time.Now()
+	c.JSON(http.StatusOK, usageReport(usageFor(key, now), cfg, now))
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// rawUploadTypes maps the Content-Type of a raw body upload to its document kind.
+// Generic binary bodies are sniffed like files without an extension.
+var rawUploadTypes = map[string]uploadType{
+	"application/x-fictionbook+xml": uploadFB2,
+	"application/x-fictionbook":     uploadFB2,
+	"application/xml":               uploadFB2,
+	"text/xml":                      uploadFB2,
+	"application/zip":               uploadZip,
+	"application/x-zip-compressed":  uploadZip,
+	"application/epub+zip":          uploadEPUB,
+	"application/octet-stream":      uploadUnsupported,
+}
+
+// convertRawUpload converts a book posted as the raw request body rather than
+// a multipart form. Conversion options are read from the query string and the
+// file name from the Content-Disposition header or the filename query parameter.
+func convertRawUpload(c *gin.Context, cfg *config.Config) {
+	fileType, known := rawUploadTypes[c.ContentType()]
+	if !known {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Unsupported Content-Type. Send multipart/form-data, application/x-fictionbook+xml, application/zip or application/epub+zip",
+		})
+		return
+	}
+
+	//nolint:gosec // 0755 needed for Docker volume mounts
+	if err := os.MkdirAll(cfg.TempDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to create base temporary directory: %v", err),
+		})
+		return
+	}
+
+	// Spool the body to disk: zipped books need random access and the job needs its size
+	spool, err := os.CreateTemp(cfg.TempDir, "raw-upload-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to save uploaded file",
+		})
+		return
+	}
+	defer func() {
+		if closeErr := spool.Close(); closeErr != nil {
+			_ = closeErr
+		}
+		if removeErr := os.Remove(spool.Name()); removeErr != nil {
+			log.Printf("Warning: failed to remove %s: %v", spool.Name(), removeErr)
+		}
+	}()
+
+	size, err := io.Copy(spool, c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("File too large. Maximum size: %d bytes (%.2f MB)",
+					cfg.MaxFileSize, float64(cfg.MaxFileSize)/(1024*1024)),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to read request body: %v", err),
+		})
+		return
+	}
+	if size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No file provided or invalid file",
+		})
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read uploaded file",
+		})
+		return
+	}
+
+	if fileType == uploadUnsupported {
+		if fileType, err = detectUploadType(spool, ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read uploaded file",
+			})
+			return
+		}
+		if fileType == uploadUnsupported {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid file type. Expected an FB2 document, a zipped book or an EPUB",
+			})
+			return
+		}
+	}
+
+	useQueryAsForm(c)
+	opts, err := parseConversionOptions(c, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid conversion options: %v", err),
+		})
+		return
+	}
+
+	startConversion(c, cfg, spool, rawUploadFilename(c, fileType), size, fileType, opts)
+}
+
+// useQueryAsForm exposes the query string as the request form, so form based
+// option parsing reads the options of a raw upload unchanged
+func useQueryAsForm(c *gin.Context) {
+	query := c.Request.URL.Query()
+	c.Request.PostForm = query
+	c.Request.MultipartForm = &multipart.Form{Value: query}
+}
+
+// rawUploadFilename names a raw upload after its Content-Disposition header or
+// filename query parameter, falling back to a name matching its type
+func rawUploadFilename(c *gin.Context, fileType uploadType) string {
+	filename := c.Query("filename")
+	if _, params, err := mime.ParseMediaType(c.GetHeader("Content-Disposition")); err == nil && params["filename"] != "" {
+		filename = params["filename"]
+	}
+	if filename = filepath.Base(filename); filename != "." && filename != "/" {
+		return filename
+	}
+
+	switch fileType {
+	case uploadZip:
+		return "book.fb2.zip"
+	case uploadEPUB:
+		return "book.epub"
+	}
+	return "book.fb2"
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"runtime/metrics"
+	"time"
+)
+
+// memorySampleInterval is how often the heap is checked during a conversion
+const memorySampleInterval = 10 * time.Millisecond
+
+// liveHeapMetric is the runtime metric of the heap bytes marked live by the
+// last GC, so garbage that is not swept yet does not count
+const liveHeapMetric = "/gc/heap/live:bytes"
+
+// errMemoryBudget is the cause of conversions aborted while the live heap of the server is beyond MAX_CONVERSION_MEMORY
+var errMemoryBudget = errors.New("memory budget exceeded")
+
+// watchMemory cancels a conversion with errMemoryBudget once the live heap
+// of the whole process is over budget bytes, until ctx is done. Go cannot
+// tell which goroutine allocated what, so the budget bounds the server
+// rather than any one book, and every conversion running while it is
+// exceeded is aborted.
+func watchMemory(ctx context.Context, cancel context.CancelCauseFunc, budget int64) {
+	sample := []metrics.Sample{{Name: liveHeapMetric}}
+	heapBytes := func() int64 {
+		metrics.Read(sample)
+		if sample[0].Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+		return int64(sample[0].Value.Uint64())
+	}
+
+	ticker := time.NewTicker(memorySampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if heapBytes() > budget {
+				cancel(errMemoryBudget)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lex/fb2epub/config"
+)
+
+// uploadsDirName is the directory of TEMP_DIR holding unfinished chunked uploads
+const uploadsDirName = "uploads"
+
+// defaultUploadRetention is how long an idle chunked upload is kept before cleanup
+const defaultUploadRetention = 24 * time.Hour
+
+// uploadOffsetHeader carries the byte offset of a chunk, as in the tus protocol
+const uploadOffsetHeader = "Upload-Offset"
+
+// chunkedUpload is a book being uploaded in several requests
+type chunkedUpload struct {
+	mu        sync.Mutex // Held while a chunk is written
+	ID        string
+	Filename  string
+	Size      int64 // Declared total size
+	Offset    int64 // Bytes received so far
+	Path      string
+	UpdatedAt time.Time
+}
+
+var (
+	chunkedUploads = make(map[string]*chunkedUpload)
+	uploadsMutex   sync.RWMutex // Guards chunkedUploads
+)
+
+// getUpload returns the chunked upload with the given ID
+func getUpload(uploadID string) (*chunkedUpload, bool) {
+	uploadsMutex.RLock()
+	defer uploadsMutex.RUnlock()
+	upload, exists := chunkedUploads[uploadID]
+	return upload, exists
+}
+
+// removeUpload forgets a chunked upload and deletes its data
+func removeUpload(upload *chunkedUpload) {
+	uploadsMutex.Lock()
+	delete(chunkedUploads, upload.ID)
+	uploadsMutex.Unlock()
+
+	if err := os.Remove(upload.Path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to remove %s: %v", upload.Path, err)
+	}
+}
+
+// uploadResponse builds the JSON representation of a chunked upload
+func uploadResponse(upload *chunkedUpload) gin.H {
+	return gin.H{
+		"upload_id":  upload.ID,
+		"filename":   upload.Filename,
+		"size":       upload.Size,
+		"offset":     upload.Offset,
+		"complete":   upload.Offset == upload.Size,
+		"upload_url": fmt.Sprintf("/api/v1/uploads/%s", upload.ID),
+		"expires_at": upload.UpdatedAt.Add(defaultUploadRetention),
+	}
+}
+
+// CreateUpload starts a chunked upload of a book whose name and total size are
+// given as JSON. The chunks are then sent with PatchUpload and the conversion
+// started with CompleteUpload.
+func CreateUpload(c *gin.Context) {
+	cfg := config.Load()
+
+	var request struct {
+		Filename string `json:"filename"`
+		Size     int64  `json:"size"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid upload request: %v", err),
+		})
+		return
+	}
+	request.Filename = filepath.Base(strings.TrimSpace(request.Filename))
+	if request.Filename == "" || request.Filename == "." || request.Filename == "/" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload filename is required",
+		})
+		return
+	}
+	if request.Size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Upload size must be positive",
+		})
+		return
+	}
+	if request.Size > cfg.MaxFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("File too large. Maximum size: %d bytes (%.2f MB)",
+				cfg.MaxFileSize, float64(cfg.MaxFileSize)/(1024*1024)),
+		})
+		return
+	}
+
+	uploadsDir := filepath.Join(cfg.TempDir, uploadsDirName)
+	//nolint:gosec // 0755 needed for Docker volume mounts
+	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to create upload directory: %v", err),
+		})
+		return
+	}
+
+	// Refuse the upload now rather than after most of it has been sent
+	if err := checkDiskSpace(uploadsDir, request.Size, cfg.MinFreeDiskSpace); err != nil {
+		c.JSON(http.StatusInsufficientStorage, gin.H{
+			"error": fmt.Sprintf("Insufficient storage: %v", err),
+		})
+		return
+	}
+
+	upload := &chunkedUpload{
+		ID:        uuid.New().String(),
+		Filename:  request.Filename,
+		Size:      request.Size,
+		UpdatedAt: time.Now(),
+	}
+	upload.Path = filepath.Join(uploadsDir, upload.ID)
+	//nolint:gosec // Path is controlled
+	file, err := os.Create(upload.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create upload file",
+		})
+		return
+	}
+	if closeErr := file.Close(); closeErr != nil {
+		_ = closeErr
+	}
+
+	uploadsMutex.Lock()
+	chunkedUploads[upload.ID] = upload
+	uploadsMutex.Unlock()
+
+	c.Header("Location", fmt.Sprintf("/api/v1/uploads/%s", upload.ID))
+	c.JSON(http.StatusCreated, uploadResponse(upload))
+}
+
+// GetUpload reports how many bytes of a chunked upload were received, so an
+// interrupted client knows where to resume
+func GetUpload(c *gin.Context) {
+	upload, exists := getUpload(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	c.Header(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	c.JSON(http.StatusOK, uploadResponse(upload))
+}
+
+// PatchUpload appends the request body to a chunked upload. The Upload-Offset
+// header must match the bytes received so far. Whatever arrives before a
+// dropped connection is kept, so the client resumes from the reported offset.
+func PatchUpload(c *gin.Context) {
+	upload, exists := getUpload(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Missing or invalid Upload-Offset header",
+		})
+		return
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	if offset != upload.Offset {
+		c.Header(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  fmt.Sprintf("Offset mismatch. Expected %d", upload.Offset),
+			"offset": upload.Offset,
+		})
+		return
+	}
+
+	//nolint:gosec // Path is controlled
+	file, err := os.OpenFile(upload.Path, os.O_WRONLY, 0)
+	if err != nil {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Upload data is no longer available",
+		})
+		return
+	}
+	written, copyErr := writeChunk(file, c.Request.Body, upload.Offset, upload.Size-upload.Offset)
+	if closeErr := file.Close(); closeErr != nil && copyErr == nil {
+		copyErr = closeErr
+	}
+	upload.Offset += written
+	upload.UpdatedAt = time.Now()
+	c.Header(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+
+	if errors.Is(copyErr, errChunkTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":  fmt.Sprintf("Chunk exceeds the declared upload size of %d bytes", upload.Size),
+			"offset": upload.Offset,
+		})
+		return
+	}
+	if copyErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  fmt.Sprintf("Failed to receive chunk: %v", copyErr),
+			"offset": upload.Offset,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, uploadResponse(upload))
+}
+
+// errChunkTooLarge is returned when a chunk runs past the declared upload size
+var errChunkTooLarge = errors.New("chunk exceeds the declared upload size")
+
+// writeChunk copies at most remaining bytes of chunk into file at offset and
+// returns how many were written
+func writeChunk(file *os.File, chunk io.Reader, offset, remaining int64) (int64, error) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, io.LimitReader(chunk, remaining))
+	if err != nil {
+		return written, err
+	}
+
+	// Anything left means the client sent more than it declared
+	var extra [1]byte
+	if n, _ := chunk.Read(extra[:]); n > 0 {
+		return written, errChunkTooLarge
+	}
+	return written, nil
+}
+
+// CompleteUpload converts a fully received chunked upload. Conversion options
+// may be sent as form fields, as with the convert endpoint.
+func CompleteUpload(c *gin.Context) {
+	cfg := config.Load()
+
+	upload, exists := getUpload(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return
+	}
+
+	if err := parseOptionsForm(c, cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse form data: %v", err),
+		})
+		return
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+
+	if upload.Offset != upload.Size {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  fmt.Sprintf("Upload incomplete: %d of %d bytes received", upload.Offset, upload.Size),
+			"offset": upload.Offset,
+		})
+		return
+	}
+
+	//nolint:gosec // Path is controlled
+	file, err := os.Open(upload.Path)
+	if err != nil {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Upload data is no longer available",
+		})
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	fileType, err := detectUploadType(file, upload.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Failed to read uploaded file",
+		})
+		return
+	}
+	if fileType == uploadUnsupported {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid file type. Expected .fb2, .xml, .fb2.zip or .epub file",
+		})
+		return
+	}
+
+	opts, err := parseConversionOptions(c, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid conversion options: %v", err),
+		})
+		return
+	}
+
+	if startConversion(c, cfg, file, upload.Filename, upload.Size, fileType, opts) {
+		removeUpload(upload)
+	}
+}
+
+// DeleteUpload abandons a chunked upload and deletes the data received so far
+func DeleteUpload(c *gin.Context) {
+	upload, exists := getUpload(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Upload not found",
+		})
+		return
+	}
+
+	upload.mu.Lock()
+	defer upload.mu.Unlock()
+	removeUpload(upload)
+	c.Status(http.StatusNoContent)
+}
+
+// cleanupStaleUploads removes chunked uploads idle for longer than maxAge,
+// including leftover files of uploads no longer in memory, and returns how many were removed
+func cleanupStaleUploads(tempDir string, maxAge time.Duration) int {
+	uploadsDir := filepath.Join(tempDir, uploadsDirName)
+	entries, err := os.ReadDir(uploadsDir)
+	if err != nil {
+		return 0
+	}
+
+	now := time.Now()
+	removed := 0
+	for _, entry := range entries {
+		if upload, exists := getUpload(entry.Name()); exists {
+			upload.mu.Lock()
+			stale := now.Sub(upload.UpdatedAt) > maxAge
+			if stale {
+				removeUpload(upload)
+				removed++
+			}
+			upload.mu.Unlock()
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) <= maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(uploadsDir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+)
+
+// retryMutex serializes retries so a job cannot be restarted twice at once
+var retryMutex sync.Mutex
+
+// RetryConversion re-runs a failed conversion from its stored input file. Options
+// sent as form fields amend those of the original request; without any, the
+// conversion is repeated as it was. The job keeps its ID and download URL.
+func RetryConversion(c *gin.Context) {
+	cfg := config.Load()
+	jobID := c.Param("id")
+
+	job, exists := getJob(jobID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+
+	if err := parseOptionsForm(c, cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse form data: %v", err),
+		})
+		return
+	}
+
+	retryMutex.Lock()
+	defer retryMutex.Unlock()
+
+	failed := job.Snapshot()
+	if failed.Status != JobStatusFailed {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Only failed jobs can be retried, job is %s", failed.Status),
+		})
+		return
+	}
+
+	if job.InputPath == "" || failed.Options == nil {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Input file is no longer available. Upload the book again",
+		})
+		return
+	}
+	if _, err := os.Stat(job.InputPath); err != nil {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Input file is no longer available. Upload the book again",
+		})
+		return
+	}
+
+	opts, err := amendConversionOptions(c, cfg, failed.Options)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid conversion options: %v", err),
+		})
+		return
+	}
+
+	// Start over from a clean state; the previous outcome is replaced
+	previousError := job.restart(opts)
+	auditJob(job.ID, auditRetried, "Restarted after: %s", previousError)
+	holdQuotaSlot(job.APIKey)
+
+	queueConversion(cfg, job, opts, nil)
+
+	state := job.Snapshot()
+	response := gin.H{
+		"job_id":     job.ID,
+		"status":     state.Status,
+		"message":    "Conversion restarted",
+		"expires_at": state.ExpiresAt,
+	}
+	if state.Status == JobStatusPending {
+		response["message"] = "Conversion queued"
+		addQueuePosition(response, job)
+	}
+	c.JSON(http.StatusAccepted, response)
+}
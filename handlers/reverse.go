@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Document formats named by the from and to query parameters of the convert endpoint
+const (
+	formatEPUB = "epub"
+	formatFB2  = "fb2"
+	formatPDF  = "pdf"
+)
+
+// uploadFormat returns the document format an upload of the given type holds
+func uploadFormat(fileType uploadType) string {
+	if fileType == uploadEPUB {
+		return formatEPUB
+	}
+	return formatFB2
+}
+
+// outputFormat resolves the from and to query parameters against the type of
+// the uploaded file. FB2 books convert to EPUB or PDF and EPUB books back to
+// FB2, so either parameter may be omitted.
+func outputFormat(c *gin.Context, fileType uploadType) (string, error) {
+	return resolveOutputFormat(fileType, c.Query("from"), c.Query("to"))
+}
+
+// resolveOutputFormat returns the format an upload of fileType converts to
+// when from and to, either of which may be empty, are requested
+func resolveOutputFormat(fileType uploadType, from, to string) (string, error) {
+	input := uploadFormat(fileType)
+	if from != "" && from != input {
+		return "", fmt.Errorf("uploaded file is not %s, but from=%s was requested", articleFormat(from), from)
+	}
+	if to == "" {
+		to = formatEPUB
+		if input == formatEPUB {
+			to = formatFB2
+		}
+	}
+	if (input == formatFB2 && to != formatEPUB && to != formatPDF) || (input == formatEPUB && to != formatFB2) {
+		return "", fmt.Errorf("conversion from %s to %s is not supported", input, to)
+	}
+	return to, nil
+}
+
+// articleFormat names a format with its indefinite article
+func articleFormat(format string) string {
+	switch format {
+	case formatEPUB:
+		return "an EPUB"
+	case formatFB2:
+		return "an FB2 book"
+	default:
+		return "a " + format + " document"
+	}
+}
+
+// outputContentType returns the MIME type of a job's result
+func outputContentType(job *ConversionJob) string {
+	switch job.OutputFormat {
+	case formatFB2:
+		return "application/x-fictionbook+xml"
+	case formatPDF:
+		return "application/pdf"
+	}
+	return "application/epub+zip"
+}
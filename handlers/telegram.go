@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lex/fb2epub/config"
+)
+
+// Long polling settings of the Telegram bot
+const (
+	telegramPollTimeout = 30 * time.Second
+	telegramRetryDelay  = 5 * time.Second
+)
+
+// telegramHelp is the reply to messages without a document
+const telegramHelp = "Send me an FB2 book (.fb2 or .fb2.zip) and I will reply with the EPUB. " +
+	"EPUB books are converted back to FB2."
+
+// telegramResponse is the envelope of every Bot API response
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text     string            `json:"text"`
+	Document *telegramDocument `json:"document"`
+}
+
+type telegramDocument struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+}
+
+type telegramFile struct {
+	FilePath string `json:"file_path"`
+}
+
+// TelegramBot converts books sent to a Telegram bot through the regular job
+// pipeline and replies with the result. Conversions appear in the status,
+// download and history endpoints like uploaded ones.
+type TelegramBot struct {
+	cfg    *config.Config
+	client *http.Client
+	offset int64 // ID of the next update to fetch
+}
+
+// NewTelegramBot returns a bot using the token and API URL of cfg
+func NewTelegramBot(cfg *config.Config) *TelegramBot {
+	return &TelegramBot{
+		cfg:    cfg,
+		client: &http.Client{Timeout: telegramPollTimeout + 30*time.Second},
+	}
+}
+
+// Run polls the Bot API for messages until ctx is canceled. Each document is
+// converted in its own goroutine.
+func (b *TelegramBot) Run(ctx context.Context) {
+	log.Printf("Telegram bot started")
+	for ctx.Err() == nil {
+		updates, err := b.getUpdates(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Warning: Telegram polling failed: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(telegramRetryDelay):
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			b.offset = update.UpdateID + 1
+			if update.Message == nil {
+				continue
+			}
+			if update.Message.Document == nil {
+				b.sendMessage(ctx, update.Message, telegramHelp)
+				continue
+			}
+			go b.convertDocument(ctx, update.Message)
+		}
+	}
+	log.Printf("Telegram bot stopped")
+}
+
+// convertDocument downloads a book sent in chat, converts it and replies with
+// the result or the reason it failed
+func (b *TelegramBot) convertDocument(ctx context.Context, message *telegramMessage) {
+	// Limits and options follow the current configuration, like those of uploads
+	cfg := config.Load()
+	document := message.Document
+	if document.FileSize > cfg.MaxFileSize {
+		b.sendMessage(ctx, message, fmt.Sprintf("The file is too large. Maximum size is %.2f MB",
+			float64(cfg.MaxFileSize)/(1024*1024)))
+		return
+	}
+
+	input, err := b.downloadFile(ctx, cfg, document.FileID)
+	if err != nil {
+		log.Printf("Warning: failed to download Telegram file %s: %v", document.FileName, err)
+		b.sendMessage(ctx, message, "Failed to download the file, please try again")
+		return
+	}
+	defer func() {
+		if closeErr := input.Close(); closeErr != nil {
+			_ = closeErr
+		}
+		if removeErr := os.Remove(input.Name()); removeErr != nil {
+			_ = removeErr
+		}
+	}()
+
+	fileType, err := detectUploadType(input, document.FileName)
+	if err != nil || fileType == uploadUnsupported {
+		b.sendMessage(ctx, message, "Invalid file type. Expected .fb2, .xml, .fb2.zip or .epub file")
+		return
+	}
+	format := formatEPUB
+	if fileType == uploadEPUB {
+		format = formatFB2
+	}
+
+	info, err := input.Stat()
+	if err != nil {
+		b.sendMessage(ctx, message, "Failed to read the file, please try again")
+		return
+	}
+	opts := defaultConversionOptions(cfg)
+	job, err := createJob(cfg, input, document.FileName, info.Size(), fileType, format, opts)
+	if err != nil {
+		b.sendMessage(ctx, message, err.Error())
+		return
+	}
+	putJob(job)
+	auditReceivedJob(job, info.Size(), fmt.Sprintf("from Telegram chat %d", message.Chat.ID))
+	log.Printf("Job %s started from Telegram chat %d", job.ID, message.Chat.ID)
+
+	// Chat uploads wait their turn like the others
+	finished := make(chan struct{})
+	queueConversion(cfg, job, opts, func() { close(finished) })
+	<-finished
+	if state := job.Snapshot(); state.Status != JobStatusCompleted {
+		b.sendMessage(ctx, message, state.Error)
+		return
+	}
+	if err := b.sendDocument(ctx, message, job); err != nil {
+		log.Printf("Warning: failed to send job %s to Telegram: %v", job.ID, err)
+	}
+}
+
+// getUpdates long-polls for the updates after the last one seen
+func (b *TelegramBot) getUpdates(ctx context.Context) ([]telegramUpdate, error) {
+	params := url.Values{
+		"offset":          {strconv.FormatInt(b.offset, 10)},
+		"timeout":         {strconv.Itoa(int(telegramPollTimeout.Seconds()))},
+		"allowed_updates": {`["message"]`},
+	}
+	var updates []telegramUpdate
+	err := b.call(ctx, "getUpdates", params, &updates)
+	return updates, err
+}
+
+// downloadFile saves a file sent to the bot in a temporary file, rewound for reading
+func (b *TelegramBot) downloadFile(ctx context.Context, cfg *config.Config, fileID string) (*os.File, error) {
+	var file telegramFile
+	if err := b.call(ctx, "getFile", url.Values{"file_id": {fileID}}, &file); err != nil {
+		return nil, err
+	}
+
+	fileURL := fmt.Sprintf("%s/file/bot%s/%s", b.cfg.TelegramAPIURL, b.cfg.TelegramBotToken, file.FilePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %s", redactToken(err, b.cfg.TelegramBotToken))
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
+
+	//nolint:gosec // 0755 needed for Docker volume mounts
+	if err := os.MkdirAll(cfg.TempDir, 0755); err != nil {
+		return nil, err
+	}
+	output, err := os.CreateTemp(cfg.TempDir, "telegram-*")
+	if err != nil {
+		return nil, err
+	}
+	written, err := io.Copy(output, io.LimitReader(resp.Body, cfg.MaxFileSize+1))
+	if err == nil && written > cfg.MaxFileSize {
+		err = fmt.Errorf("file exceeds %d bytes", cfg.MaxFileSize)
+	}
+	if err == nil {
+		_, err = output.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = output.Close()
+		_ = os.Remove(output.Name())
+		return nil, err
+	}
+	return output, nil
+}
+
+// sendMessage replies to a message with text
+func (b *TelegramBot) sendMessage(ctx context.Context, message *telegramMessage, text string) {
+	params := url.Values{
+		"chat_id":             {strconv.FormatInt(message.Chat.ID, 10)},
+		"text":                {text},
+		"reply_to_message_id": {strconv.FormatInt(message.MessageID, 10)},
+	}
+	if err := b.call(ctx, "sendMessage", params, nil); err != nil {
+		log.Printf("Warning: failed to send Telegram message: %v", err)
+	}
+}
+
+// sendDocument replies to a message with the result of a completed job
+func (b *TelegramBot) sendDocument(ctx context.Context, message *telegramMessage, job *ConversionJob) error {
+	//nolint:gosec // Path is controlled by the job
+	file, err := os.Open(job.FilePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"chat_id":             strconv.FormatInt(message.Chat.ID, 10),
+		"reply_to_message_id": strconv.FormatInt(message.MessageID, 10),
+	}
+	if title := job.Snapshot().Title; title != "" {
+		fields["caption"] = title
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	part, err := writer.CreateFormFile("document", resultFilename(job))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.methodURL("sendDocument"), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return b.do(req, nil)
+}
+
+// call invokes a Bot API method with form parameters and decodes its result
+func (b *TelegramBot) call(ctx context.Context, method string, params url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.methodURL(method),
+		bytes.NewBufferString(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return b.do(req, result)
+}
+
+func (b *TelegramBot) do(req *http.Request, result interface{}) error {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// Errors from the client include the URL, which contains the token
+		return fmt.Errorf("request failed: %s", redactToken(err, b.cfg.TelegramBotToken))
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	var response telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("invalid response with status %d: %w", resp.StatusCode, err)
+	}
+	if !response.OK {
+		return fmt.Errorf("bot API error: %s", response.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+func (b *TelegramBot) methodURL(method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", b.cfg.TelegramAPIURL, b.cfg.TelegramBotToken, method)
+}
+
+// redactToken returns the message of err with the bot token hidden
+func redactToken(err error, token string) string {
+	return strings.ReplaceAll(err.Error(), token, "<token>")
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/lex/fb2epub/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// telemetryFlushTimeout is how long buffered spans and error reports are
+// given to be sent when the server stops
+const telemetryFlushTimeout = 5 * time.Second
+
+// tracer records each conversion job as a span, the parent of the spans of
+// its parsing, image processing and packaging recorded by the converter
+var tracer = otel.Tracer("github.com/lex/fb2epub/handlers")
+
+// StartTelemetry sets up the optional reporting of the configuration:
+// conversion traces exported to the OpenTelemetry collector at
+// OTEL_EXPORTER_OTLP_ENDPOINT, and failed conversions and panics reported to
+// the Sentry project of SENTRY_DSN. The other OTEL_ variables, such as
+// OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME, are honored. The returned
+// function sends what is still buffered and should be called before exiting.
+func StartTelemetry(cfg *config.Config) (flush func(), err error) {
+	var flushers []func(ctx context.Context)
+	flush = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetryFlushTimeout)
+		defer cancel()
+		for _, flusher := range flushers {
+			flusher(ctx)
+		}
+	}
+
+	if cfg.OTLPEndpoint != "" {
+		exporter, err := otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.OTLPEndpoint, "/")+"/v1/traces"))
+		if err != nil {
+			return flush, fmt.Errorf("failed to set up trace export: %w", err)
+		}
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the service name
+		res, err := resource.New(context.Background(),
+			resource.WithAttributes(attribute.String("service.name", "fb2epub")),
+			resource.WithFromEnv(),
+			resource.WithTelemetrySDK(),
+		)
+		if err != nil {
+			return flush, fmt.Errorf("failed to describe the service for tracing: %w", err)
+		}
+		provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		flushers = append(flushers, func(ctx context.Context) {
+			if err := provider.Shutdown(ctx); err != nil {
+				log.Printf("Warning: failed to export the remaining traces: %v", err)
+			}
+		})
+	}
+
+	if cfg.SentryDSN != "" {
+		err := sentry.Init(sentry.ClientOptions{
+			Dsn:              cfg.SentryDSN,
+			Environment:      cfg.Environment,
+			AttachStacktrace: true,
+		})
+		if err != nil {
+			return flush, fmt.Errorf("failed to set up Sentry: %w", err)
+		}
+		flushers = append(flushers, func(ctx context.Context) {
+			deadline, _ := ctx.Deadline()
+			sentry.Flush(time.Until(deadline))
+		})
+	}
+
+	return flush, nil
+}
+
+// startJobSpan starts the span of the conversion of a job
+func startJobSpan(ctx context.Context, job *ConversionJob) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "conversion", trace.WithAttributes(
+		attribute.String("job.id", job.ID),
+		attribute.String("job.filename", job.Filename),
+		attribute.String("job.output_format", job.OutputFormat),
+	))
+}
+
+// reportFailure marks the span of a job failed with err and the message the
+// job fails with, and reports the failure to Sentry, tagged with the job, its
+// book and its trace, so the report can be matched with the trace and the audit log
+func reportFailure(ctx context.Context, job *ConversionJob, message string, err error) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, message)
+
+	hub := sentry.CurrentHub()
+	if hub.Client() == nil {
+		return
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		tagJob(scope, span, job)
+		hub.CaptureException(err)
+	})
+}
+
+// reportPanic reports a panic during the conversion of a job to Sentry with
+// the stack of the panic; it must be called from the deferred function that
+// recovered it
+func reportPanic(ctx context.Context, job *ConversionJob, recovered interface{}) {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(fmt.Errorf("panic: %v", recovered))
+	span.SetStatus(codes.Error, "panic")
+
+	hub := sentry.CurrentHub()
+	if hub.Client() == nil {
+		return
+	}
+	hub.WithScope(func(scope *sentry.Scope) {
+		tagJob(scope, span, job)
+		hub.RecoverWithContext(ctx, recovered)
+	})
+}
+
+// ReportPanic reports a panic recovered while handling a request to Sentry,
+// when SENTRY_DSN is set; it must be called from the deferred function that
+// recovered it
+func ReportPanic(ctx context.Context, recovered interface{}) {
+	if hub := sentry.CurrentHub(); hub.Client() != nil {
+		hub.RecoverWithContext(ctx, recovered)
+	}
+}
+
+// tagJob adds the job and book a Sentry report is about to its scope
+func tagJob(scope *sentry.Scope, span trace.Span, job *ConversionJob) {
+	state := job.Snapshot()
+	scope.SetTag("job_id", job.ID)
+	scope.SetTag("output_format", job.OutputFormat)
+	scope.SetTag("stage", state.Stage)
+	if spanContext := span.SpanContext(); spanContext.HasTraceID() {
+		scope.SetTag("trace_id", spanContext.TraceID().String())
+	}
+	book := map[string]interface{}{
+		"filename": job.Filename,
+		"title":    state.Title,
+	}
+	if state.Stats != nil {
+		book["input_size_bytes"] = state.Stats.InputSize
+	}
+	scope.SetContext("book", book)
+}
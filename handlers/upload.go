@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadFieldNames are the multipart fields a book is accepted under;
+// "files[]" is what generic upload widgets send
+var uploadFieldNames = []string{"file", "files[]"}
+
+// sniffSize is how much of an upload without extension is examined for its type
+const sniffSize = 64 * 1024
+
+// uploadType is the kind of document an upload holds
+type uploadType int
+
+const (
+	uploadUnsupported uploadType = iota
+	uploadFB2
+	uploadZip
+	uploadEPUB
+)
+
+// zipSignature starts every zip archive
+var zipSignature = []byte("PK\x03\x04")
+
+// epubMimetype is the first, uncompressed entry of every EPUB, which starts
+// right after the 30 byte zip local file header
+var epubMimetype = []byte("mimetypeapplication/epub+zip")
+
+// formUploadFile returns the first file uploaded under one of uploadFieldNames
+func formUploadFile(c *gin.Context) (multipart.File, *multipart.FileHeader, error) {
+	for _, name := range uploadFieldNames {
+		file, header, err := c.Request.FormFile(name)
+		if err != http.ErrMissingFile {
+			return file, header, err
+		}
+	}
+	return nil, nil, http.ErrMissingFile
+}
+
+// detectUploadType classifies an upload by its file name extension. Files
+// without an extension are sniffed instead: a FictionBook root element marks
+// an FB2 document, an EPUB mimetype entry an EPUB and any other zip signature
+// a zipped FB2 document. The file is rewound.
+func detectUploadType(file io.ReadSeeker, filename string) (uploadType, error) {
+	switch filepath.Ext(filename) {
+	case ".fb2", ".xml":
+		return uploadFB2, nil
+	case ".zip":
+		return uploadZip, nil
+	case ".epub":
+		return uploadEPUB, nil
+	case "":
+	default:
+		return uploadUnsupported, nil
+	}
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return uploadUnsupported, err
+	}
+	head = head[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return uploadUnsupported, err
+	}
+
+	if bytes.HasPrefix(head, zipSignature) {
+		if len(head) > 30 && bytes.HasPrefix(head[30:], epubMimetype) {
+			return uploadEPUB, nil
+		}
+		return uploadZip, nil
+	}
+	if hasFictionBookRoot(head) {
+		return uploadFB2, nil
+	}
+	return uploadUnsupported, nil
+}
+
+// hasFictionBookRoot reports whether the first element of an XML prefix is FictionBook
+func hasFictionBookRoot(head []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(head))
+	decoder.Strict = false
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == "FictionBook"
+		}
+	}
+}
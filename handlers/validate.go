@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/converter"
+)
+
+// openUploadedFB2 returns the FB2 file of a multipart request. On failure the
+// error response has been written and ok is false. The caller closes the file.
+func openUploadedFB2(c *gin.Context) (file multipart.File, ok bool) {
+	cfg := config.Load()
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxFileSize)
+	if err := c.Request.ParseMultipartForm(cfg.MaxFileSize); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse form data: %v", err),
+		})
+		return nil, false
+	}
+
+	file, header, err := formUploadFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No file provided or invalid file",
+		})
+		return nil, false
+	}
+
+	if fileType, err := detectUploadType(file, header.Filename); err != nil || fileType != uploadFB2 {
+		_ = file.Close()
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid file type. Expected .fb2 or .xml file",
+		})
+		return nil, false
+	}
+
+	return file, true
+}
+
+// ValidateFB2 checks an uploaded FB2 document without converting it (a dry run
+// of strict mode) and reports every problem with its line and column
+func ValidateFB2(c *gin.Context) {
+	file, ok := openUploadedFB2(c)
+	if !ok {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	diagnostics := converter.Validate(file)
+	if diagnostics == nil {
+		diagnostics = []converter.Diagnostic{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":       len(diagnostics) == 0,
+		"diagnostics": diagnostics,
+	})
+}
+
+// InspectFB2 returns the metadata of an uploaded FB2 document without converting it
+func InspectFB2(c *gin.Context) {
+	file, ok := openUploadedFB2(c)
+	if !ok {
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	fb2, err := converter.ParseFB2FromReader(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to parse FB2: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, converter.Inspect(fb2))
+}
@@ -2,20 +2,61 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/converter"
 	"github.com/lex/fb2epub/handlers"
 )
 
 func main() {
-	// Load configuration
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file; environment variables take precedence")
+	check := flag.Bool("check", false, "Validate the configuration and exit")
+	diagnose := flag.String("diagnose", "", "Report where an FB2 file fails to parse, with its line, column, byte offset and open elements, and exit")
+	flag.Parse()
+
+	// Load configuration, failing fast on a broken configuration file
+	if *configFile != "" {
+		if err := config.UseFile(*configFile); err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		log.Printf("Using configuration file %s", *configFile)
+	}
 	cfg := config.Load()
 
+	// Fail at startup rather than on the first request
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if *check {
+		log.Printf("Configuration OK")
+		return
+	}
+	if *diagnose != "" {
+		os.Exit(diagnoseFile(*diagnose, cfg))
+	}
+	go reloadOnHangup()
+
+	// Optional trace export and error reporting (OTEL_EXPORTER_OTLP_ENDPOINT, SENTRY_DSN)
+	flushTelemetry, err := handlers.StartTelemetry(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start telemetry: %v", err)
+	}
+	if cfg.OTLPEndpoint != "" || cfg.SentryDSN != "" {
+		go flushOnExit(flushTelemetry)
+	}
+
 	// Set Gin mode based on environment
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -25,7 +66,8 @@ func main() {
 	router := gin.New()
 	router.Use(gin.Logger())
 
-	// Set maximum multipart form size (default is 32MB, increase to match config)
+	// Buffer multipart files up to MaxFileSize in memory before spilling to disk;
+	// this does not limit the request size, see LimitRequestBody
 	router.MaxMultipartMemory = cfg.MaxFileSize
 
 	// Custom recovery middleware to return JSON errors instead of HTML
@@ -34,6 +76,7 @@ func main() {
 			if err := recover(); err != nil {
 				// Log the error
 				log.Printf("Panic recovered: %v", err)
+				handlers.ReportPanic(c.Request.Context(), err)
 
 				// Return JSON error for API routes
 				if len(c.Request.URL.Path) >= 4 && c.Request.URL.Path[:4] == "/api" {
@@ -49,6 +92,12 @@ func main() {
 		c.Next()
 	})
 
+	// Allow browser front-ends on other origins (CORS_ALLOWED_ORIGINS)
+	router.Use(handlers.CORS())
+
+	// Refuse request bodies well beyond MAX_FILE_SIZE before reading them
+	router.Use(handlers.LimitRequestBody())
+
 	// Serve static files (CSS, JS)
 	router.Static("/static", "./web/static")
 
@@ -57,20 +106,74 @@ func main() {
 		c.File("./web/index.html")
 	})
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": "fb2epub",
-		})
+	// Serve API documentation (Swagger UI)
+	router.GET("/docs", func(c *gin.Context) {
+		c.File("./web/swagger.html")
 	})
 
-	// API routes
-	api := router.Group("/api/v1")
+	// Health check endpoint
+	router.GET("/health", handlers.HealthCheck)
+
+	// API routes; :id parameters must be job or upload IDs
+	api := router.Group("/api/v1", handlers.ValidateIDParam())
 	{
-		api.POST("/convert", handlers.ConvertFB2ToEPUB)
-		api.GET("/status/:id", handlers.GetConversionStatus)
-		api.GET("/download/:id", handlers.DownloadEPUB)
+		// Client routes (require one of the API_KEYS when any are configured)
+		client := api.Group("", handlers.RequireAPIKey())
+		client.POST("/convert", handlers.ConvertFB2ToEPUB)
+		client.POST("/validate", handlers.ValidateFB2)
+		client.POST("/inspect", handlers.InspectFB2)
+		client.GET("/status/:id", handlers.GetConversionStatus)
+		client.GET("/events", handlers.StreamJobStatus)
+		client.GET("/events/:id", handlers.StreamJobEvents)
+		client.GET("/download/:id", handlers.DownloadEPUB)
+		client.GET("/jobs/:id/log", handlers.GetJobLog)
+		client.POST("/uploads", handlers.CreateUpload)
+		client.GET("/uploads/:id", handlers.GetUpload)
+		client.PATCH("/uploads/:id", handlers.PatchUpload)
+		client.DELETE("/uploads/:id", handlers.DeleteUpload)
+		client.POST("/uploads/:id/complete", handlers.CompleteUpload)
+		client.GET("/usage", handlers.GetUsage)
+		api.GET("/history", handlers.RequireAdminKey(), handlers.GetHistory)
+		api.GET("/opds", handlers.RequireAdminKey(), handlers.GetOPDSCatalog)
+		api.POST("/jobs/:id/retry", handlers.RequireAdminKey(), handlers.RetryConversion)
+		api.GET("/openapi.json", handlers.GetOpenAPISpec)
+
+		// Admin routes (require ADMIN_API_KEY)
+		admin := api.Group("/admin", handlers.RequireAdminKey())
+		admin.GET("/storage", handlers.GetStorageStats)
+		admin.POST("/cleanup", handlers.ForceCleanup)
+	}
+
+	// Profiling and runtime statistics for admins (DEBUG_ENDPOINTS)
+	if cfg.DebugEndpoints {
+		debug := router.Group("/debug", handlers.RequireAdminKey())
+		debug.GET("/stats", handlers.GetRuntimeStats)
+		debug.GET("/pprof/*profile", handlers.Pprof)
+		debug.POST("/pprof/*profile", handlers.Pprof) // pprof symbol lookups are POSTed
+	}
+
+	// Convert books sent to the Telegram bot (TELEGRAM_BOT_TOKEN)
+	if cfg.TelegramBotToken != "" {
+		go handlers.NewTelegramBot(cfg).Run(context.Background())
+	}
+
+	// Convert books requested through a RabbitMQ queue (AMQP_URL)
+	if cfg.AMQPURL != "" {
+		go handlers.NewQueueIngester(cfg).Run(context.Background())
+	}
+
+	// Serve the conversion service over gRPC as well (GRPC_PORT)
+	if cfg.GRPCPort != "" {
+		listener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC on port %s: %v", cfg.GRPCPort, err)
+		}
+		log.Printf("Starting gRPC server on :%s", cfg.GRPCPort)
+		go func() {
+			if err := handlers.NewGRPCServer(cfg).Serve(listener); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
 	}
 
 	// Start server with custom configuration
@@ -90,3 +193,60 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// diagnoseFile reads an FB2 file as a conversion would and prints where it
+// breaks, returning the exit status: 0 when it parses, 1 otherwise
+func diagnoseFile(path string, cfg *config.Config) int {
+	//nolint:gosec // Path is given on the command line
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open %s: %v", path, err)
+		return 1
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			_ = closeErr
+		}
+	}()
+
+	limits := handlers.ConversionLimits(cfg)
+	if err := converter.LocateXMLError(file, &limits); err != nil {
+		fmt.Printf("%s: %v\n", path, err)
+		return 1
+	}
+	fmt.Printf("%s: no XML errors found\n", path)
+	return 0
+}
+
+// flushOnExit sends the buffered traces and error reports when the process
+// is asked to stop, then exits
+func flushOnExit(flush func()) {
+	stops := make(chan os.Signal, 1)
+	signal.Notify(stops, syscall.SIGINT, syscall.SIGTERM)
+	<-stops
+	flush()
+	os.Exit(0)
+}
+
+// reloadOnHangup re-reads the configuration file whenever the process receives
+// SIGHUP. Handlers load the configuration per request, so new uploads and
+// conversions use the new settings while running jobs keep theirs.
+func reloadOnHangup() {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		path, ignored, err := config.Reload()
+		switch {
+		case err != nil:
+			log.Printf("Warning: configuration not reloaded, keeping the previous settings: %v", err)
+		case path == "":
+			log.Printf("Received SIGHUP, but no configuration file is in use")
+		default:
+			log.Printf("Reloaded configuration from %s", path)
+			if len(ignored) > 0 {
+				log.Printf("Warning: changes to %s take effect only after a restart",
+					strings.Join(ignored, ", "))
+			}
+		}
+	}
+}
@@ -1,31 +1,93 @@
 // Package models provides data structures for FB2 (FictionBook 2.0) format.
 package models
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"strings"
+)
 
 // FictionBook represents the root element of FB2 format
 type FictionBook struct {
 	XMLName     xml.Name    `xml:"FictionBook"`
 	Description Description `xml:"description"`
-	Body        Body        `xml:"body"`
+	Body        []Body      `xml:"body"`
 	Binary      []Binary    `xml:"binary"`
 }
 
+// MainBody returns the main body of the book (the first body element).
+// An empty body is returned if the book has none.
+func (fb *FictionBook) MainBody() *Body {
+	if len(fb.Body) == 0 {
+		return &Body{}
+	}
+	return &fb.Body[0]
+}
+
+// ExtraBodies returns the bodies following the main one (notes, comments, etc.)
+func (fb *FictionBook) ExtraBodies() []Body {
+	if len(fb.Body) < 2 {
+		return nil
+	}
+	return fb.Body[1:]
+}
+
 // Description contains metadata about the book
 type Description struct {
 	TitleInfo    TitleInfo    `xml:"title-info"`
+	SrcTitleInfo *TitleInfo   `xml:"src-title-info,omitempty"` // Title info of the original of a translated book
 	PublishInfo  PublishInfo  `xml:"publish-info,omitempty"`
 	DocumentInfo DocumentInfo `xml:"document-info,omitempty"`
+	CustomInfo   []CustomInfo `xml:"custom-info,omitempty"`
+}
+
+// CustomInfo is free-form information about the book, kept by the tools and
+// libraries that handled it
+type CustomInfo struct {
+	InfoType string `xml:"info-type,attr"`
+	Text     string `xml:",chardata"`
 }
 
 // TitleInfo contains book title and author information
 type TitleInfo struct {
-	Genre      []string `xml:"genre"`
-	Author     []Author `xml:"author"`
-	BookTitle  string   `xml:"book-title"`
-	Annotation string   `xml:"annotation,omitempty"`
-	Date       string   `xml:"date,omitempty"`
-	Lang       string   `xml:"lang,omitempty"`
+	Genre      []string    `xml:"genre"`
+	Author     []Author    `xml:"author"`
+	BookTitle  string      `xml:"book-title"`
+	Annotation *Annotation `xml:"annotation,omitempty"`
+	Date       string      `xml:"date,omitempty"`
+	Coverpage  *Coverpage  `xml:"coverpage,omitempty"`
+	Lang       string      `xml:"lang,omitempty"`
+	SrcLang    string      `xml:"src-lang,omitempty"` // Language of the original of a translated book
+	Translator []Author    `xml:"translator,omitempty"`
+	Sequence   []Sequence  `xml:"sequence,omitempty"`
+}
+
+// Annotation is the formatted description of a book
+type Annotation struct {
+	ID        string      `xml:"id,attr,omitempty"`
+	Subtitle  []Paragraph `xml:"subtitle,omitempty"`
+	Paragraph []Paragraph `xml:"p"`
+	Poem      []Poem      `xml:"poem,omitempty"`
+	Cite      []Cite      `xml:"cite,omitempty"`
+	EmptyLine []EmptyLine `xml:"empty-line"`
+}
+
+// HasContent reports whether the annotation has anything to show.
+// A nil annotation has no content.
+func (a *Annotation) HasContent() bool {
+	return a != nil && (len(a.Subtitle) > 0 || len(a.Paragraph) > 0 || len(a.Poem) > 0 || len(a.Cite) > 0)
+}
+
+// Sequence names a series the book belongs to and the book's position in it.
+// Nested sequences describe sub-series.
+type Sequence struct {
+	Name     string     `xml:"name,attr"`
+	Number   string     `xml:"number,attr,omitempty"`
+	Sequence []Sequence `xml:"sequence,omitempty"`
+}
+
+// Coverpage references the cover image of the book
+type Coverpage struct {
+	Image []Image `xml:"image"`
 }
 
 // Author represents book author
@@ -47,18 +109,21 @@ type PublishInfo struct {
 
 // DocumentInfo contains document metadata
 type DocumentInfo struct {
-	Author      []Author `xml:"author,omitempty"`
-	ProgramUsed string   `xml:"program-used,omitempty"`
-	Date        string   `xml:"date,omitempty"`
-	ID          string   `xml:"id,omitempty"`
-	Version     string   `xml:"version,omitempty"`
+	Author      []Author    `xml:"author,omitempty"`
+	ProgramUsed string      `xml:"program-used,omitempty"`
+	Date        string      `xml:"date,omitempty"`
+	ID          string      `xml:"id,omitempty"`
+	Version     string      `xml:"version,omitempty"`
+	History     *Annotation `xml:"history,omitempty"` // Changes made to the document, one paragraph per version
 }
 
 // Body represents the main content of the book
 type Body struct {
-	Name    string    `xml:"name,attr,omitempty"`
-	Title   Title     `xml:"title,omitempty"`
-	Section []Section `xml:"section"`
+	Name     string     `xml:"name,attr,omitempty"`
+	Image    []Image    `xml:"image,omitempty"` // Shown before the text, such as a frontispiece
+	Title    Title      `xml:"title,omitempty"`
+	Epigraph []Epigraph `xml:"epigraph,omitempty"`
+	Section  []Section  `xml:"section"`
 }
 
 // Title represents a title element
@@ -68,21 +133,233 @@ type Title struct {
 
 // Section represents a section of the book
 type Section struct {
+	ID        string      `xml:"id,attr,omitempty"`
+	Lang      string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
 	Title     *Title      `xml:"title,omitempty"`
 	Section   []Section   `xml:"section"`
 	Paragraph []Paragraph `xml:"p"`
 	Poem      []Poem      `xml:"poem,omitempty"`
 	Cite      []Cite      `xml:"cite,omitempty"`
 	EmptyLine []EmptyLine `xml:"empty-line"`
+	Image     []Image     `xml:"image,omitempty"` // Full-page illustrations between the paragraphs
+
+	// Blocks lists the children of the section in document order; the typed
+	// slices above lose how they interleave
+	Blocks []SectionBlock `xml:"-"`
+}
+
+// SectionBlockKind identifies the slice of a Section or Cite a block belongs to
+type SectionBlockKind int
+
+// Kinds of section blocks
+const (
+	ParagraphBlock SectionBlockKind = iota
+	EmptyLineBlock
+	SubsectionBlock
+	PoemBlock
+	CiteBlock
+	ImageBlock
+	SubtitleBlock   // Only in cites
+	TextAuthorBlock // Only in cites
+)
+
+// SectionBlock refers to a child of a section by kind and index in its slice
+type SectionBlock struct {
+	Kind  SectionBlockKind
+	Index int
+}
+
+// UnmarshalXML decodes a section like the struct tags describe, additionally
+// recording the order of its children
+func (s *Section) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*s = Section{}
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Local == "id":
+			s.ID = attr.Value
+		case attr.Name.Local == "lang" && attr.Name.Space == xmlNamespace:
+			s.Lang = attr.Value
+		}
+	}
+
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := s.decodeChild(d, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeChild decodes one child element of a section; unknown elements are skipped
+func (s *Section) decodeChild(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "title":
+		s.Title = &Title{}
+		return d.DecodeElement(s.Title, &start)
+	case "section":
+		var child Section
+		if err := d.DecodeElement(&child, &start); err != nil {
+			return err
+		}
+		s.Blocks = append(s.Blocks, SectionBlock{Kind: SubsectionBlock, Index: len(s.Section)})
+		s.Section = append(s.Section, child)
+	case "p":
+		var p Paragraph
+		if err := d.DecodeElement(&p, &start); err != nil {
+			return err
+		}
+		s.Blocks = append(s.Blocks, SectionBlock{Kind: ParagraphBlock, Index: len(s.Paragraph)})
+		s.Paragraph = append(s.Paragraph, p)
+	case "poem":
+		var poem Poem
+		if err := d.DecodeElement(&poem, &start); err != nil {
+			return err
+		}
+		s.Blocks = append(s.Blocks, SectionBlock{Kind: PoemBlock, Index: len(s.Poem)})
+		s.Poem = append(s.Poem, poem)
+	case "cite":
+		var cite Cite
+		if err := d.DecodeElement(&cite, &start); err != nil {
+			return err
+		}
+		s.Blocks = append(s.Blocks, SectionBlock{Kind: CiteBlock, Index: len(s.Cite)})
+		s.Cite = append(s.Cite, cite)
+	case "image":
+		var image Image
+		if err := d.DecodeElement(&image, &start); err != nil {
+			return err
+		}
+		s.Blocks = append(s.Blocks, SectionBlock{Kind: ImageBlock, Index: len(s.Image)})
+		s.Image = append(s.Image, image)
+	case "empty-line":
+		s.Blocks = append(s.Blocks, SectionBlock{Kind: EmptyLineBlock, Index: len(s.EmptyLine)})
+		s.EmptyLine = append(s.EmptyLine, EmptyLine{})
+		return d.Skip()
+	default:
+		return d.Skip()
+	}
+	return nil
+}
+
+// OrderedBlocks returns the children of the section in document order.
+// Sections built in code rather than decoded may have no recorded order, or
+// one that no longer matches their slices; their children are then grouped by
+// type: paragraphs, empty lines, subsections, poems, citations and images.
+func (s *Section) OrderedBlocks() []SectionBlock {
+	counts := map[SectionBlockKind]int{
+		ParagraphBlock:  len(s.Paragraph),
+		EmptyLineBlock:  len(s.EmptyLine),
+		SubsectionBlock: len(s.Section),
+		PoemBlock:       len(s.Poem),
+		CiteBlock:       len(s.Cite),
+		ImageBlock:      len(s.Image),
+	}
+	kinds := []SectionBlockKind{ParagraphBlock, EmptyLineBlock, SubsectionBlock, PoemBlock, CiteBlock, ImageBlock}
+	return orderBlocks(s.Blocks, kinds, counts)
+}
+
+// orderBlocks returns the recorded order of blocks if it lists every child
+// counted in counts exactly once, and otherwise the children grouped by kind
+// in the order of kinds
+func orderBlocks(recorded []SectionBlock, kinds []SectionBlockKind, counts map[SectionBlockKind]int) []SectionBlock {
+	if blocksMatch(recorded, counts) {
+		return recorded
+	}
+
+	var blocks []SectionBlock
+	for _, kind := range kinds {
+		for i := 0; i < counts[kind]; i++ {
+			blocks = append(blocks, SectionBlock{Kind: kind, Index: i})
+		}
+	}
+	return blocks
+}
+
+// blocksMatch reports whether the recorded order lists every child exactly once
+func blocksMatch(recorded []SectionBlock, counts map[SectionBlockKind]int) bool {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if len(recorded) != total {
+		return false
+	}
+	next := make(map[SectionBlockKind]int, len(counts))
+	for _, block := range recorded {
+		if block.Index != next[block.Kind] || block.Index >= counts[block.Kind] {
+			return false
+		}
+		next[block.Kind]++
+	}
+	return true
+}
+
+// MarshalXML writes a section with its children in document order
+func (s *Section) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if s.ID != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "id"}, Value: s.ID})
+	}
+	if s.Lang != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: xmlNamespace, Local: "lang"}, Value: s.Lang})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if s.Title != nil {
+		if err := e.EncodeElement(s.Title, xml.StartElement{Name: xml.Name{Local: "title"}}); err != nil {
+			return err
+		}
+	}
+	for _, block := range s.OrderedBlocks() {
+		if err := s.encodeBlock(e, block); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeBlock writes one child of a section
+func (s *Section) encodeBlock(e *xml.Encoder, block SectionBlock) error {
+	element := func(name string) xml.StartElement {
+		return xml.StartElement{Name: xml.Name{Local: name}}
+	}
+	switch block.Kind {
+	case ParagraphBlock:
+		return e.EncodeElement(&s.Paragraph[block.Index], element("p"))
+	case EmptyLineBlock:
+		return e.EncodeElement(&s.EmptyLine[block.Index], element("empty-line"))
+	case SubsectionBlock:
+		return e.EncodeElement(&s.Section[block.Index], element("section"))
+	case PoemBlock:
+		return e.EncodeElement(&s.Poem[block.Index], element("poem"))
+	case CiteBlock:
+		return e.EncodeElement(&s.Cite[block.Index], element("cite"))
+	case ImageBlock:
+		return e.EncodeElement(&s.Image[block.Index], element("image"))
+	}
+	return nil
 }
 
 // Paragraph represents a paragraph
 type Paragraph struct {
+	Lang     string     `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
 	Text     string     `xml:",chardata"`
 	Strong   []Strong   `xml:"strong"`
 	Emphasis []Emphasis `xml:"emphasis"`
 	Image    []Image    `xml:"image,omitempty"`
 	Link     []Link     `xml:"a,omitempty"`
+
+	// Content lists the text runs and inline elements of the paragraph in
+	// document order; Text and the typed slices above lose how they interleave
+	Content []Inline `xml:"-"`
 }
 
 // Strong represents bold text (can contain nested elements)
@@ -91,6 +368,7 @@ type Strong struct {
 	Strong   []Strong   `xml:"strong,omitempty"`
 	Emphasis []Emphasis `xml:"emphasis,omitempty"`
 	Link     []Link     `xml:"a,omitempty"`
+	Content  []Inline   `xml:"-"` // Document order, like the content of a Paragraph
 }
 
 // Emphasis represents italic text (can contain nested elements)
@@ -99,11 +377,287 @@ type Emphasis struct {
 	Strong   []Strong   `xml:"strong,omitempty"`
 	Emphasis []Emphasis `xml:"emphasis,omitempty"`
 	Link     []Link     `xml:"a,omitempty"`
+	Content  []Inline   `xml:"-"` // Document order, like the content of a Paragraph
+}
+
+// InlineKind identifies what a piece of mixed content is
+type InlineKind int
+
+// Kinds of inline content
+const (
+	TextInline InlineKind = iota
+	StrongInline
+	EmphasisInline
+	LinkInline
+	ImageInline // Only in paragraphs
+)
+
+// Inline is a piece of mixed content: a run of text, or an inline element
+// referred to by kind and index in its slice
+type Inline struct {
+	Kind  InlineKind
+	Index int
+	Text  string // The text of a TextInline
+}
+
+// inlineTarget is where decodeInline stores the content of an element
+type inlineTarget struct {
+	text     *string
+	strong   *[]Strong
+	emphasis *[]Emphasis
+	link     *[]Link
+	image    *[]Image // Nil where images are skipped
+	content  *[]Inline
+}
+
+// decodeInline reads mixed content up to the end of the current element,
+// recording its order; unknown elements are skipped
+func decodeInline(d *xml.Decoder, target inlineTarget) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.CharData:
+			*target.text += string(t)
+			content := *target.content
+			if last := len(content) - 1; last >= 0 && content[last].Kind == TextInline {
+				content[last].Text += string(t)
+			} else {
+				*target.content = append(content, Inline{Kind: TextInline, Text: string(t)})
+			}
+		case xml.StartElement:
+			if err := target.decodeChild(d, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
 }
 
+// decodeChild decodes one inline element
+func (target inlineTarget) decodeChild(d *xml.Decoder, start xml.StartElement) error {
+	switch {
+	case start.Name.Local == "strong":
+		var strong Strong
+		if err := d.DecodeElement(&strong, &start); err != nil {
+			return err
+		}
+		*target.content = append(*target.content, Inline{Kind: StrongInline, Index: len(*target.strong)})
+		*target.strong = append(*target.strong, strong)
+	case start.Name.Local == "emphasis":
+		var emphasis Emphasis
+		if err := d.DecodeElement(&emphasis, &start); err != nil {
+			return err
+		}
+		*target.content = append(*target.content, Inline{Kind: EmphasisInline, Index: len(*target.emphasis)})
+		*target.emphasis = append(*target.emphasis, emphasis)
+	case start.Name.Local == "a":
+		var link Link
+		if err := d.DecodeElement(&link, &start); err != nil {
+			return err
+		}
+		*target.content = append(*target.content, Inline{Kind: LinkInline, Index: len(*target.link)})
+		*target.link = append(*target.link, link)
+	case start.Name.Local == "image" && target.image != nil:
+		var image Image
+		if err := d.DecodeElement(&image, &start); err != nil {
+			return err
+		}
+		*target.content = append(*target.content, Inline{Kind: ImageInline, Index: len(*target.image)})
+		*target.image = append(*target.image, image)
+	default:
+		return d.Skip()
+	}
+	return nil
+}
+
+// encodeInline writes mixed content in the given order
+func encodeInline(e *xml.Encoder, content []Inline, strong []Strong, emphasis []Emphasis, links []Link, images []Image) error {
+	element := func(name string) xml.StartElement {
+		return xml.StartElement{Name: xml.Name{Local: name}}
+	}
+	for _, piece := range content {
+		var err error
+		switch piece.Kind {
+		case TextInline:
+			err = e.EncodeToken(xml.CharData(piece.Text))
+		case StrongInline:
+			err = e.EncodeElement(&strong[piece.Index], element("strong"))
+		case EmphasisInline:
+			err = e.EncodeElement(&emphasis[piece.Index], element("emphasis"))
+		case LinkInline:
+			err = e.EncodeElement(&links[piece.Index], element("a"))
+		case ImageInline:
+			err = e.EncodeElement(&images[piece.Index], element("image"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderInline returns the recorded content if it matches text and lists
+// every element counted in counts exactly once, and otherwise the text
+// followed by the elements grouped by kind in the order of kinds
+func orderInline(recorded []Inline, text string, kinds []InlineKind, counts map[InlineKind]int) []Inline {
+	if inlineMatches(recorded, text, counts) {
+		return recorded
+	}
+
+	var content []Inline
+	if text != "" {
+		content = append(content, Inline{Kind: TextInline, Text: text})
+	}
+	for _, kind := range kinds {
+		for i := 0; i < counts[kind]; i++ {
+			content = append(content, Inline{Kind: kind, Index: i})
+		}
+	}
+	return content
+}
+
+// inlineMatches reports whether recorded content holds exactly text and the counted elements
+func inlineMatches(recorded []Inline, text string, counts map[InlineKind]int) bool {
+	var recordedText strings.Builder
+	next := make(map[InlineKind]int, len(counts))
+	for _, piece := range recorded {
+		if piece.Kind == TextInline {
+			recordedText.WriteString(piece.Text)
+			continue
+		}
+		if piece.Index != next[piece.Kind] || piece.Index >= counts[piece.Kind] {
+			return false
+		}
+		next[piece.Kind]++
+	}
+	for kind, count := range counts {
+		if next[kind] != count {
+			return false
+		}
+	}
+	return recordedText.String() == text
+}
+
+// UnmarshalXML decodes a paragraph like the struct tags describe, additionally
+// recording the order of its content
+func (p *Paragraph) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*p = Paragraph{}
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "lang" && attr.Name.Space == xmlNamespace {
+			p.Lang = attr.Value
+		}
+	}
+	return decodeInline(d, inlineTarget{
+		text: &p.Text, strong: &p.Strong, emphasis: &p.Emphasis, link: &p.Link, image: &p.Image, content: &p.Content,
+	})
+}
+
+// OrderedContent returns the content of the paragraph in document order.
+// Paragraphs built in code without a matching recorded order have their text
+// first, followed by links, strong and emphasized text, and images.
+func (p *Paragraph) OrderedContent() []Inline {
+	return orderInline(p.Content, p.Text,
+		[]InlineKind{LinkInline, StrongInline, EmphasisInline, ImageInline},
+		map[InlineKind]int{
+			LinkInline: len(p.Link), StrongInline: len(p.Strong), EmphasisInline: len(p.Emphasis), ImageInline: len(p.Image),
+		})
+}
+
+// MarshalXML writes a paragraph with its content in document order
+func (p *Paragraph) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if p.Lang != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: xmlNamespace, Local: "lang"}, Value: p.Lang})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeInline(e, p.OrderedContent(), p.Strong, p.Emphasis, p.Link, p.Image); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML decodes strong text, recording the order of its content
+func (s *Strong) UnmarshalXML(d *xml.Decoder, _ xml.StartElement) error {
+	*s = Strong{}
+	return decodeInline(d, inlineTarget{
+		text: &s.Text, strong: &s.Strong, emphasis: &s.Emphasis, link: &s.Link, content: &s.Content,
+	})
+}
+
+// OrderedContent returns the content of the strong text in document order,
+// like Paragraph.OrderedContent
+func (s *Strong) OrderedContent() []Inline {
+	return orderInline(s.Content, s.Text,
+		[]InlineKind{LinkInline, EmphasisInline, StrongInline},
+		map[InlineKind]int{LinkInline: len(s.Link), EmphasisInline: len(s.Emphasis), StrongInline: len(s.Strong)})
+}
+
+// MarshalXML writes strong text with its content in document order
+func (s *Strong) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeInline(e, s.OrderedContent(), s.Strong, s.Emphasis, s.Link, nil); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML decodes emphasized text, recording the order of its content
+func (em *Emphasis) UnmarshalXML(d *xml.Decoder, _ xml.StartElement) error {
+	*em = Emphasis{}
+	return decodeInline(d, inlineTarget{
+		text: &em.Text, strong: &em.Strong, emphasis: &em.Emphasis, link: &em.Link, content: &em.Content,
+	})
+}
+
+// OrderedContent returns the content of the emphasized text in document
+// order, like Paragraph.OrderedContent
+func (em *Emphasis) OrderedContent() []Inline {
+	return orderInline(em.Content, em.Text,
+		[]InlineKind{LinkInline, StrongInline, EmphasisInline},
+		map[InlineKind]int{LinkInline: len(em.Link), StrongInline: len(em.Strong), EmphasisInline: len(em.Emphasis)})
+}
+
+// MarshalXML writes emphasized text with its content in document order
+func (em *Emphasis) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeInline(e, em.OrderedContent(), em.Strong, em.Emphasis, em.Link, nil); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xlinkNamespace is the namespace of href attributes in well-formed FB2 documents
+const xlinkNamespace = "http://www.w3.org/1999/xlink"
+
+// xmlNamespace is the namespace of the xml:lang attribute
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
 // Image represents an image reference
 type Image struct {
 	Href string `xml:"http://www.w3.org/1999/xlink href,attr"`
+	Alt  string `xml:"alt,attr,omitempty"` // Text alternative, if the author gave one
+}
+
+// UnmarshalXML reads the href attribute in any namespace, so images declared
+// as l:href, xlink:href or a plain href are all found
+func (img *Image) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	img.Href = hrefAttr(start.Attr)
+	img.Alt = ""
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "alt" {
+			img.Alt = attr.Value
+		}
+	}
+	return d.Skip()
 }
 
 // Link represents a hyperlink
@@ -113,28 +667,197 @@ type Link struct {
 	Text string `xml:",chardata"`
 }
 
+// UnmarshalXML reads the href attribute in any namespace, like Image
+func (l *Link) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var link struct {
+		Type string `xml:"type,attr"`
+		Text string `xml:",chardata"`
+	}
+	if err := d.DecodeElement(&link, &start); err != nil {
+		return err
+	}
+	*l = Link{Href: hrefAttr(start.Attr), Type: link.Type, Text: link.Text}
+	return nil
+}
+
+// hrefAttr returns the href attribute, preferring the xlink namespace but
+// accepting any prefix (declared or not) and no namespace at all
+func hrefAttr(attrs []xml.Attr) string {
+	href := ""
+	for _, attr := range attrs {
+		if attr.Name.Local != "href" {
+			continue
+		}
+		if attr.Name.Space == xlinkNamespace {
+			return attr.Value
+		}
+		if href == "" {
+			href = attr.Value
+		}
+	}
+	return href
+}
+
 // Poem represents a poem
 type Poem struct {
-	Title      *Title   `xml:"title,omitempty"`
-	Stanza     []Stanza `xml:"stanza"`
-	Date       string   `xml:"date,omitempty"`
-	TextAuthor []Author `xml:"text-author,omitempty"`
+	Lang       string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title      *Title      `xml:"title,omitempty"`
+	Epigraph   []Epigraph  `xml:"epigraph,omitempty"`
+	Stanza     []Stanza    `xml:"stanza"`
+	TextAuthor []Paragraph `xml:"text-author,omitempty"`
+	Date       string      `xml:"date,omitempty"`
 }
 
 // Stanza represents a stanza in a poem
 type Stanza struct {
-	Verse []Verse `xml:"v"`
+	Title    *Title     `xml:"title,omitempty"`
+	Subtitle *Paragraph `xml:"subtitle,omitempty"`
+	Verse    []Verse    `xml:"v"`
+}
+
+// Epigraph represents an epigraph of a body, poem or section
+type Epigraph struct {
+	Paragraph  []Paragraph `xml:"p"`
+	Poem       []Poem      `xml:"poem,omitempty"`
+	Cite       []Cite      `xml:"cite,omitempty"`
+	EmptyLine  []EmptyLine `xml:"empty-line"`
+	TextAuthor []Paragraph `xml:"text-author,omitempty"`
 }
 
 // Verse represents a verse line
 type Verse struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
 	Text string `xml:",chardata"`
 }
 
 // Cite represents a citation
 type Cite struct {
-	TextAuthor []Author    `xml:"text-author,omitempty"`
+	ID         string      `xml:"id,attr,omitempty"`
+	Lang       string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Subtitle   []Paragraph `xml:"subtitle,omitempty"`
 	Paragraph  []Paragraph `xml:"p"`
+	Poem       []Poem      `xml:"poem,omitempty"`
+	EmptyLine  []EmptyLine `xml:"empty-line"`
+	TextAuthor []Paragraph `xml:"text-author,omitempty"`
+
+	// Blocks lists the children of the cite in document order, like the
+	// blocks of a Section
+	Blocks []SectionBlock `xml:"-"`
+}
+
+// UnmarshalXML decodes a cite like the struct tags describe, additionally
+// recording the order of its children
+func (c *Cite) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*c = Cite{}
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Local == "id":
+			c.ID = attr.Value
+		case attr.Name.Local == "lang" && attr.Name.Space == xmlNamespace:
+			c.Lang = attr.Value
+		}
+	}
+
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := c.decodeChild(d, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeChild decodes one child element of a cite; unknown elements are skipped
+func (c *Cite) decodeChild(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "subtitle", "p", "text-author":
+		var p Paragraph
+		if err := d.DecodeElement(&p, &start); err != nil {
+			return err
+		}
+		switch start.Name.Local {
+		case "subtitle":
+			c.Blocks = append(c.Blocks, SectionBlock{Kind: SubtitleBlock, Index: len(c.Subtitle)})
+			c.Subtitle = append(c.Subtitle, p)
+		case "p":
+			c.Blocks = append(c.Blocks, SectionBlock{Kind: ParagraphBlock, Index: len(c.Paragraph)})
+			c.Paragraph = append(c.Paragraph, p)
+		default:
+			c.Blocks = append(c.Blocks, SectionBlock{Kind: TextAuthorBlock, Index: len(c.TextAuthor)})
+			c.TextAuthor = append(c.TextAuthor, p)
+		}
+	case "poem":
+		var poem Poem
+		if err := d.DecodeElement(&poem, &start); err != nil {
+			return err
+		}
+		c.Blocks = append(c.Blocks, SectionBlock{Kind: PoemBlock, Index: len(c.Poem)})
+		c.Poem = append(c.Poem, poem)
+	case "empty-line":
+		c.Blocks = append(c.Blocks, SectionBlock{Kind: EmptyLineBlock, Index: len(c.EmptyLine)})
+		c.EmptyLine = append(c.EmptyLine, EmptyLine{})
+		return d.Skip()
+	default:
+		return d.Skip()
+	}
+	return nil
+}
+
+// OrderedBlocks returns the children of the cite in document order. Cites
+// without a matching recorded order have their children grouped by type:
+// subtitles, paragraphs, poems, empty lines and text authors.
+func (c *Cite) OrderedBlocks() []SectionBlock {
+	counts := map[SectionBlockKind]int{
+		SubtitleBlock:   len(c.Subtitle),
+		ParagraphBlock:  len(c.Paragraph),
+		PoemBlock:       len(c.Poem),
+		EmptyLineBlock:  len(c.EmptyLine),
+		TextAuthorBlock: len(c.TextAuthor),
+	}
+	kinds := []SectionBlockKind{SubtitleBlock, ParagraphBlock, PoemBlock, EmptyLineBlock, TextAuthorBlock}
+	return orderBlocks(c.Blocks, kinds, counts)
+}
+
+// MarshalXML writes a cite with its children in document order
+func (c *Cite) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if c.ID != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "id"}, Value: c.ID})
+	}
+	if c.Lang != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Space: xmlNamespace, Local: "lang"}, Value: c.Lang})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	element := func(name string) xml.StartElement {
+		return xml.StartElement{Name: xml.Name{Local: name}}
+	}
+	for _, block := range c.OrderedBlocks() {
+		var err error
+		switch block.Kind {
+		case SubtitleBlock:
+			err = e.EncodeElement(&c.Subtitle[block.Index], element("subtitle"))
+		case ParagraphBlock:
+			err = e.EncodeElement(&c.Paragraph[block.Index], element("p"))
+		case PoemBlock:
+			err = e.EncodeElement(&c.Poem[block.Index], element("poem"))
+		case EmptyLineBlock:
+			err = e.EncodeElement(&c.EmptyLine[block.Index], element("empty-line"))
+		case TextAuthorBlock:
+			err = e.EncodeElement(&c.TextAuthor[block.Index], element("text-author"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
 }
 
 // EmptyLine represents an empty line
@@ -3,6 +3,7 @@ package config_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/lex/fb2epub/config"
 )
@@ -97,6 +98,109 @@ func TestLoad_EnvironmentVariables(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "admin api key",
+			envVars: map[string]string{
+				"ADMIN_API_KEY": "secret",
+			},
+			validate: func(t *testing.T, cfg *config.Config) {
+				if cfg.AdminAPIKey != "secret" {
+					t.Errorf("Expected admin API key 'secret', got %s", cfg.AdminAPIKey)
+				}
+			},
+		},
+		{
+			name: "custom min free disk space",
+			envVars: map[string]string{
+				"MIN_FREE_DISK_SPACE": "0",
+			},
+			validate: func(t *testing.T, cfg *config.Config) {
+				if cfg.MinFreeDiskSpace != 0 {
+					t.Errorf("Expected min free disk space 0, got %d", cfg.MinFreeDiskSpace)
+				}
+			},
+		},
+		{
+			name: "fonts dir",
+			envVars: map[string]string{
+				"FONTS_DIR": "/usr/share/fb2epub/fonts",
+			},
+			validate: func(t *testing.T, cfg *config.Config) {
+				if cfg.FontsDir != "/usr/share/fb2epub/fonts" {
+					t.Errorf("Expected fonts dir '/usr/share/fb2epub/fonts', got %s", cfg.FontsDir)
+				}
+			},
+		},
+		{
+			name: "cors settings",
+			envVars: map[string]string{
+				"CORS_ALLOWED_ORIGINS": "https://a.example.com, https://b.example.com,",
+				"CORS_ALLOWED_METHODS": "GET",
+			},
+			validate: func(t *testing.T, cfg *config.Config) {
+				if len(cfg.CORSAllowedOrigins) != 2 || cfg.CORSAllowedOrigins[1] != "https://b.example.com" {
+					t.Errorf("Expected two trimmed CORS origins, got %v", cfg.CORSAllowedOrigins)
+				}
+				if len(cfg.CORSAllowedMethods) != 1 || cfg.CORSAllowedMethods[0] != "GET" {
+					t.Errorf("Expected CORS methods [GET], got %v", cfg.CORSAllowedMethods)
+				}
+				if len(cfg.CORSAllowedHeaders) == 0 {
+					t.Error("CORS headers should have a default")
+				}
+			},
+		},
+		{
+			name: "conversion safeguards",
+			envVars: map[string]string{
+				"CONVERSION_TIMEOUT":    "90s",
+				"MAX_XML_DEPTH":         "64",
+				"MAX_SECTION_DEPTH":     "8",
+				"MAX_PARAGRAPHS":        "-1",
+				"MAX_DECOMPRESSED_SIZE": "1048576",
+				"MAX_COMPRESSION_RATIO": "invalid",
+			},
+			validate: func(t *testing.T, cfg *config.Config) {
+				if cfg.ConversionTimeout != 90*time.Second {
+					t.Errorf("Expected conversion timeout 90s, got %s", cfg.ConversionTimeout)
+				}
+				if cfg.MaxXMLDepth != 64 {
+					t.Errorf("Expected max XML depth 64, got %d", cfg.MaxXMLDepth)
+				}
+				if cfg.MaxSectionDepth != 8 {
+					t.Errorf("Expected max section depth 8, got %d", cfg.MaxSectionDepth)
+				}
+				if cfg.MaxSections != 20000 {
+					t.Errorf("Expected default max sections 20000, got %d", cfg.MaxSections)
+				}
+				if cfg.MaxParagraphs != 1000000 {
+					t.Errorf("Expected default max paragraphs 1000000 for an invalid value, got %d", cfg.MaxParagraphs)
+				}
+				if cfg.MaxDecompressedSize != 1048576 {
+					t.Errorf("Expected max decompressed size 1048576, got %d", cfg.MaxDecompressedSize)
+				}
+				if cfg.MaxCompressionRatio != 100 {
+					t.Errorf("Expected default compression ratio 100 for an invalid value, got %d", cfg.MaxCompressionRatio)
+				}
+				if cfg.MaxBinarySize != 100*1024*1024 {
+					t.Errorf("Expected default max binary size 100MB, got %d", cfg.MaxBinarySize)
+				}
+			},
+		},
+		{
+			name: "conversion history",
+			envVars: map[string]string{
+				"HISTORY_DB":        "/data/history.db",
+				"HISTORY_RETENTION": "168h",
+			},
+			validate: func(t *testing.T, cfg *config.Config) {
+				if cfg.HistoryDB != "/data/history.db" {
+					t.Errorf("Expected history database '/data/history.db', got %s", cfg.HistoryDB)
+				}
+				if cfg.HistoryRetention != 168*time.Hour {
+					t.Errorf("Expected history retention 168h, got %s", cfg.HistoryRetention)
+				}
+			},
+		},
 		{
 			name: "all variables",
 			envVars: map[string]string{
@@ -0,0 +1,211 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/config"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+port: 9090
+temp_dir: /srv/books
+max_file_size: 1048576
+smtp:
+  host: smtp.example.com
+  port: 2525
+cors:
+  allowed_origins: [https://a.example, https://b.example]
+`,
+		"config.toml": `
+port = 9090
+temp_dir = "/srv/books"
+max_file_size = 1048576
+
+[smtp]
+host = "smtp.example.com"
+port = 2525
+
+[cors]
+allowed_origins = ["https://a.example", "https://b.example"]
+`,
+	}
+
+	for name, content := range files {
+		os.Clearenv()
+		path := writeConfigFile(t, name, content)
+		os.Setenv("CONFIG_FILE", path)
+
+		cfg := config.Load()
+		if cfg.ConfigFile != path {
+			t.Errorf("%s: expected ConfigFile %s, got %s", name, path, cfg.ConfigFile)
+		}
+		if cfg.Port != "9090" || cfg.TempDir != "/srv/books" || cfg.MaxFileSize != 1048576 {
+			t.Errorf("%s: expected the top-level settings, got port %s, temp dir %s, max size %d",
+				name, cfg.Port, cfg.TempDir, cfg.MaxFileSize)
+		}
+		if cfg.SMTPHost != "smtp.example.com" || cfg.SMTPPort != 2525 {
+			t.Errorf("%s: expected the smtp section, got %s:%d", name, cfg.SMTPHost, cfg.SMTPPort)
+		}
+		expectedOrigins := []string{"https://a.example", "https://b.example"}
+		if !reflect.DeepEqual(cfg.CORSAllowedOrigins, expectedOrigins) {
+			t.Errorf("%s: expected origins %v, got %v", name, expectedOrigins, cfg.CORSAllowedOrigins)
+		}
+		if cfg.ConversionTimeout != 5*time.Minute {
+			t.Errorf("%s: settings missing from the file should keep their defaults", name)
+		}
+	}
+	os.Clearenv()
+}
+
+func TestLoad_EnvironmentOverridesConfigFile(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	os.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yml", "port: 9090\nenvironment: production\n"))
+	os.Setenv("PORT", "7070")
+
+	cfg := config.Load()
+	if cfg.Port != "7070" {
+		t.Errorf("Expected the environment to override the file port, got %s", cfg.Port)
+	}
+	if cfg.Environment != "production" {
+		t.Errorf("Expected the file environment, got %s", cfg.Environment)
+	}
+}
+
+func TestReadFile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"config.json": `{"port": 9090}`,
+		"broken.yaml": "port: [9090",
+		"nested.toml": "origins = [{ url = \"x\" }]",
+	}
+	for name, content := range tests {
+		if _, err := config.ReadFile(writeConfigFile(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := config.ReadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestReadFile_Example(t *testing.T) {
+	values, err := config.ReadFile("../../config.example.yaml")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v, want nil", err)
+	}
+	expected := map[string]string{
+		"SMTP_HOST":                "smtp.example.com",
+		"HISTORY_DB":               "/var/lib/fb2epub/history.db",
+		"JOB_RETENTION":            "1h",
+		"MAX_XML_DEPTH":            "256",
+		"DELIVERY_ALLOWED_DOMAINS": "kindle.com,pocketbook.cloud",
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("Expected %s=%s, got %q", name, value, values[name])
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	path := writeConfigFile(t, "config.yaml", "max_file_size: 1000\n")
+	os.Setenv("CONFIG_FILE", path)
+
+	if cfg := config.Load(); cfg.MaxFileSize != 1000 {
+		t.Fatalf("Expected max file size 1000, got %d", cfg.MaxFileSize)
+	}
+
+	if err := os.WriteFile(path, []byte("max_file_size: 2000\n"), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if cfg := config.Load(); cfg.MaxFileSize != 1000 {
+		t.Errorf("Expected the file to be read once until reloaded, got %d", cfg.MaxFileSize)
+	}
+	if reloaded, ignored, err := config.Reload(); err != nil || reloaded != path || len(ignored) != 0 {
+		t.Fatalf("Reload() = %q, %v, %v, want %q, nil, nil", reloaded, ignored, err, path)
+	}
+	if cfg := config.Load(); cfg.MaxFileSize != 2000 {
+		t.Errorf("Expected max file size 2000 after reload, got %d", cfg.MaxFileSize)
+	}
+
+	// A broken file keeps the previous settings
+	if err := os.WriteFile(path, []byte("max_file_size: [\n"), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	if _, _, err := config.Reload(); err == nil {
+		t.Error("Expected Reload to fail for a broken file")
+	}
+	if cfg := config.Load(); cfg.MaxFileSize != 2000 {
+		t.Errorf("Expected the previous settings to stay, got max file size %d", cfg.MaxFileSize)
+	}
+}
+
+func TestReload_RejectsInvalidSettings(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	path := writeConfigFile(t, "config.yaml", "max_file_size: 1000\ntemp_dir: "+t.TempDir()+"\n")
+	os.Setenv("CONFIG_FILE", path)
+	if cfg := config.Load(); cfg.MaxFileSize != 1000 {
+		t.Fatalf("Expected max file size 1000, got %d", cfg.MaxFileSize)
+	}
+
+	// Parses, but Load would silently fall back to the default size
+	if err := os.WriteFile(path, []byte("max_file_size: -5\nconversion_timeout: 1m\n"), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	_, _, err := config.Reload()
+	if err == nil || !strings.Contains(err.Error(), "MAX_FILE_SIZE") {
+		t.Errorf("Expected Reload to report MAX_FILE_SIZE, got %v", err)
+	}
+	cfg := config.Load()
+	if cfg.MaxFileSize != 1000 || cfg.ConversionTimeout == time.Minute {
+		t.Errorf("Expected the previous settings to stay, got max file size %d, timeout %s",
+			cfg.MaxFileSize, cfg.ConversionTimeout)
+	}
+}
+
+func TestReload_IgnoresStartupSettings(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	tempDir := t.TempDir()
+	path := writeConfigFile(t, "config.yaml", "port: 8081\ntemp_dir: "+tempDir+"\nmax_file_size: 1000\n")
+	os.Setenv("CONFIG_FILE", path)
+	config.Load()
+
+	movedDir := t.TempDir()
+	if err := os.WriteFile(path, []byte("port: 9090\ntemp_dir: "+movedDir+"\nmax_file_size: 2000\n"), 0600); err != nil {
+		t.Fatalf("Failed to update config file: %v", err)
+	}
+	_, ignored, err := config.Reload()
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if strings.Join(ignored, ",") != "PORT,TEMP_DIR" {
+		t.Errorf("Expected changes to PORT and TEMP_DIR to be ignored, got %v", ignored)
+	}
+	cfg := config.Load()
+	if cfg.Port != "8081" || cfg.TempDir != tempDir {
+		t.Errorf("Expected port 8081 and temp dir %s to stay, got %s and %s", tempDir, cfg.Port, cfg.TempDir)
+	}
+	if cfg.MaxFileSize != 2000 {
+		t.Errorf("Expected max file size 2000 after reload, got %d", cfg.MaxFileSize)
+	}
+}
@@ -0,0 +1,102 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/config"
+)
+
+func TestValidate_Defaults(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+
+	if err := config.Load().Validate(); err != nil {
+		t.Errorf("Expected the default configuration to be valid, got: %v", err)
+	}
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, []byte("not a directory"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	t.Setenv("TEMP_DIR", filepath.Join(blocker, "temp"))
+	t.Setenv("PORT", "http")
+	t.Setenv("MAX_FILE_SIZE", "50MB")
+	t.Setenv("JOB_RETENTION", "1 hour")
+	t.Setenv("MIN_JOB_RETENTION", "2h")
+	t.Setenv("MAX_JOB_RETENTION", "1h")
+	t.Setenv("FONTS_DIR", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("TEMPLATES_DIR", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("TELEGRAM_BOT_TOKEN", "not-a-token")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "collector:4318")
+	t.Setenv("SENTRY_DSN", "project-42")
+
+	err := config.Load().Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, setting := range []string{
+		"PORT", "TEMP_DIR", "MAX_FILE_SIZE", "JOB_RETENTION", "MIN_JOB_RETENTION",
+		"FONTS_DIR", "TEMPLATES_DIR", "SMTP_FROM", "TELEGRAM_BOT_TOKEN", "OTEL_EXPORTER_OTLP_ENDPOINT", "SENTRY_DSN",
+	} {
+		if !strings.Contains(err.Error(), setting+":") {
+			t.Errorf("Expected a problem with %s, got:\n%v", setting, err)
+		}
+	}
+}
+
+func TestValidate_JobRetentionBounds(t *testing.T) {
+	cfg := config.Load()
+	cfg.TempDir = t.TempDir()
+	cfg.JobRetention = 48 * time.Hour
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "JOB_RETENTION:") {
+		t.Errorf("Expected JOB_RETENTION outside the bounds to be reported, got: %v", err)
+	}
+}
+
+func TestValidate_ConfigFile(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yaml", "max_xml_depth: deep\n"))
+
+	err := config.Load().Validate()
+	if err == nil || !strings.Contains(err.Error(), "MAX_XML_DEPTH:") {
+		t.Errorf("Expected the invalid file value to be reported, got: %v", err)
+	}
+}
+
+func TestValidate_GRPCPort(t *testing.T) {
+	cfg := config.Load()
+	cfg.TempDir = t.TempDir()
+	cfg.GRPCPort = cfg.Port
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "GRPC_PORT:") {
+		t.Errorf("Expected a gRPC port taken by the HTTP server to be reported, got: %v", err)
+	}
+
+	cfg.GRPCPort = "grpc"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "GRPC_PORT:") {
+		t.Errorf("Expected an invalid gRPC port to be reported, got: %v", err)
+	}
+}
+
+func TestValidate_QuotasWithoutAPIKeys(t *testing.T) {
+	cfg := config.Load()
+	cfg.TempDir = t.TempDir()
+	cfg.QuotaConversionsPerDay = 10
+
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "API_KEYS:") {
+		t.Errorf("Expected quotas without API keys to be reported, got: %v", err)
+	}
+
+	cfg.APIKeys = []string{"client-key"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected quotas with API keys to be valid, got: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const accessibilityTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Accessible Book</book-title>
+      <lang>de</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Part One</p><p>The Beginning</p></title>
+      <p>Text <image l:href="#img1" alt="A red square"/></p>
+    </section>
+  </body>
+  <binary id="img1" content-type="image/png">iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==</binary>
+</FictionBook>`
+
+func TestAccessibility_Metadata(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.PageLength = 100
+	opf := generateTestEPUB(t, accessibilityTestFB2, opts)["OEBPS/content.opf"]
+
+	for _, want := range []string{
+		`<meta property="schema:accessMode">textual</meta>`,
+		`<meta property="schema:accessMode">visual</meta>`,
+		`<meta property="schema:accessModeSufficient">textual,visual</meta>`,
+		`<meta property="schema:accessibilityFeature">structuralNavigation</meta>`,
+		`<meta property="schema:accessibilityFeature">tableOfContents</meta>`,
+		`<meta property="schema:accessibilityFeature">pageBreakMarkers</meta>`,
+		`<meta property="schema:accessibilityFeature">alternativeText</meta>`,
+		`<meta property="schema:accessibilityHazard">none</meta>`,
+		`<meta property="schema:accessibilitySummary">`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("Expected %s in content.opf:\n%s", want, opf)
+		}
+	}
+}
+
+func TestAccessibility_TextOnlyBook(t *testing.T) {
+	book := testBook{Lang: "en", Section: chapter("Chapter 1", "Text")}
+	opf := generateTestEPUB(t, book.fb2(), converter.DefaultOptions())["OEBPS/content.opf"]
+
+	if strings.Contains(opf, "visual") || strings.Contains(opf, "alternativeText") {
+		t.Errorf("Expected a textual book without images:\n%s", opf)
+	}
+	if !strings.Contains(opf, `<meta property="schema:accessModeSufficient">textual</meta>`) {
+		t.Errorf("Expected text to suffice:\n%s", opf)
+	}
+}
+
+func TestAccessibility_DocumentLanguage(t *testing.T) {
+	entries := generateTestEPUB(t, accessibilityTestFB2, converter.DefaultOptions())
+
+	for _, name := range []string{"OEBPS/cover.xhtml", "OEBPS/content.xhtml", "OEBPS/nav.xhtml"} {
+		if !strings.Contains(entries[name], `<html xmlns="http://www.w3.org/1999/xhtml" lang="de" xml:lang="de"`) {
+			t.Errorf("Expected the language on the html element of %s", name)
+		}
+	}
+}
+
+const mixedLanguageTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description><title-info><book-title>Война и мир</book-title><lang>ru</lang></title-info></description>
+  <body>
+    <section>
+      <title><p>Глава 1</p></title>
+      <p>Анна Павловна сказала:</p>
+      <p xml:lang="fr">Eh bien, mon prince. Gênes et Lucques ne sont plus que des apanages.</p>
+      <cite xml:lang="de"><p>Ein Zitat</p></cite>
+      <poem xml:lang="en"><stanza><v>A verse</v><v xml:lang="la">Carpe diem</v></stanza></poem>
+    </section>
+    <section xml:lang="fr">
+      <title><p>Lettre</p></title>
+      <p>Chère amie</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestAccessibility_MixedLanguageText(t *testing.T) {
+	entries := generateTestEPUB(t, mixedLanguageTestFB2, converter.DefaultOptions())
+	content := entries["OEBPS/content.xhtml"]
+
+	for _, want := range []string{
+		`<p lang="fr" xml:lang="fr">Eh bien, mon prince.`,
+		`<blockquote class="cite" lang="de" xml:lang="de">`,
+		`<div class="poem" lang="en" xml:lang="en">`,
+		`<p class="verse" lang="la" xml:lang="la">Carpe diem</p>`,
+		`<div class="chapter" lang="fr" xml:lang="fr">`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %s in the content:\n%s", want, content)
+		}
+	}
+	if !strings.Contains(content, "<p>Анна Павловна сказала:</p>") {
+		t.Error("Text in the book language should not repeat it")
+	}
+}
+
+func TestAccessibility_MixedLanguageRoundTrip(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(mixedLanguageTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+	var output bytes.Buffer
+	if err := converter.WriteFB2(fb2, &output); err != nil {
+		t.Fatalf("WriteFB2() error = %v, want nil", err)
+	}
+	written := output.String()
+	if !strings.Contains(written, `<section xml:lang="fr">`) || !strings.Contains(written, `<p xml:lang="fr">Eh bien`) {
+		t.Errorf("Expected the language overrides to be written back:\n%s", written)
+	}
+}
+
+func TestAccessibility_ImagesAndHeadings(t *testing.T) {
+	content := generateTestEPUB(t, accessibilityTestFB2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	if !strings.Contains(content, `alt="A red square"`) {
+		t.Errorf("Expected the FB2 alt text on the image:\n%s", content)
+	}
+	// A multi-line title is one heading, keeping its ID unique
+	if strings.Count(content, `id="section-0"`) != 1 || !strings.Contains(content, "Part One<br/>The Beginning") {
+		t.Errorf("Expected a single heading for the title:\n%s", content)
+	}
+}
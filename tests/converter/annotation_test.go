@@ -0,0 +1,78 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const annotationTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Annotated Book</book-title>
+      <annotation>
+        <p>A <emphasis>gripping</emphasis> story.</p>
+        <p>Second paragraph &amp; more.</p>
+        <poem><stanza><v>A verse line</v></stanza></poem>
+      </annotation>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Chapter 1</p></title>
+      <p>Text</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestAnnotation_Page(t *testing.T) {
+	entries := generateTestEPUB(t, annotationTestFB2, converter.DefaultOptions())
+
+	page, ok := entries["OEBPS/annotation.xhtml"]
+	if !ok {
+		t.Fatal("EPUB should contain annotation.xhtml")
+	}
+	for _, check := range []string{
+		`<body epub:type="frontmatter">`,
+		"<h1>About This Book</h1>",
+		"<em>gripping</em>",
+		"<p>Second paragraph &amp; more.</p>",
+		"A verse line",
+	} {
+		if !strings.Contains(page, check) {
+			t.Errorf("annotation.xhtml should contain %q", check)
+		}
+	}
+
+	opf := entries["OEBPS/content.opf"]
+	for _, check := range []string{
+		`<item id="annotation" href="annotation.xhtml" media-type="application/xhtml+xml"/>`,
+		"<itemref idref=\"cover\"/>\n    <itemref idref=\"annotation\"/>\n    <itemref idref=\"content\"/>",
+		"Second paragraph &amp; more.</dc:description>",
+	} {
+		if !strings.Contains(opf, check) {
+			t.Errorf("content.opf should contain %q", check)
+		}
+	}
+
+	if !strings.Contains(entries["OEBPS/nav.xhtml"], `<a href="annotation.xhtml">About This Book</a>`) {
+		t.Error("nav.xhtml should link the annotation page")
+	}
+	if !strings.Contains(entries["OEBPS/toc.ncx"], `<content src="annotation.xhtml"/>`) {
+		t.Error("toc.ncx should link the annotation page")
+	}
+}
+
+func TestAnnotation_Absent(t *testing.T) {
+	entries := generateTestEPUB(t, libraryTestFB2, converter.DefaultOptions())
+
+	if _, ok := entries["OEBPS/annotation.xhtml"]; ok {
+		t.Error("Books without an annotation should not get an annotation page")
+	}
+	if strings.Contains(entries["OEBPS/content.opf"], "annotation") {
+		t.Error("content.opf should not reference an annotation page")
+	}
+}
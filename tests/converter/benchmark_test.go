@@ -0,0 +1,105 @@
+package converter_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// benchmarkBook describes a generated book the benchmarks convert
+type benchmarkBook struct {
+	name       string
+	chapters   int
+	paragraphs int // Per chapter
+	images     int
+	imageSize  int // Decoded bytes per image
+}
+
+var benchmarkBooks = []benchmarkBook{
+	{name: "small", chapters: 1, paragraphs: 20},
+	{name: "medium", chapters: 30, paragraphs: 50, images: 10, imageSize: 32 << 10},
+	{name: "huge", chapters: 200, paragraphs: 200, images: 50, imageSize: 64 << 10},
+	{name: "image-heavy", chapters: 20, paragraphs: 10, images: 300, imageSize: 64 << 10},
+}
+
+// fb2 generates the FB2 document of the book: chapters of paragraphs with
+// inline markup, the images spread over the chapters
+func (book benchmarkBook) fb2() string {
+	var doc strings.Builder
+	doc.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <author><first-name>Bench</first-name><last-name>Mark</last-name></author>
+      <book-title>Benchmark</book-title>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+`)
+	image := 0
+	for c := 0; c < book.chapters; c++ {
+		fmt.Fprintf(&doc, "    <section><title><p>Chapter %d</p></title>\n", c+1)
+		for p := 0; p < book.paragraphs; p++ {
+			fmt.Fprintf(&doc, "      <p>Paragraph %d of chapter %d, with <emphasis>emphasis</emphasis>, <strong>strong text</strong> and enough plain words to look like prose in a novel.</p>\n", p+1, c+1)
+		}
+		// Spread the images evenly, the remainder in the last chapter
+		for ; image < book.images*(c+1)/book.chapters; image++ {
+			fmt.Fprintf(&doc, "      <image l:href=\"#img%d\"/>\n", image)
+		}
+		doc.WriteString("    </section>\n")
+	}
+	doc.WriteString("  </body>\n")
+
+	for i := 0; i < book.images; i++ {
+		data := make([]byte, book.imageSize)
+		copy(data, "\x89PNG\r\n\x1a\n")
+		for j := 8; j < len(data); j++ {
+			data[j] = byte(i + j*7)
+		}
+		fmt.Fprintf(&doc, "  <binary id=\"img%d\" content-type=\"image/png\">%s</binary>\n", i, base64.StdEncoding.EncodeToString(data))
+	}
+	doc.WriteString("</FictionBook>")
+	return doc.String()
+}
+
+func BenchmarkParseFB2(b *testing.B) {
+	for _, book := range benchmarkBooks {
+		document := book.fb2()
+		b.Run(book.name, func(b *testing.B) {
+			b.SetBytes(int64(len(document)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := converter.ParseFB2FromReader(strings.NewReader(document)); err != nil {
+					b.Fatalf("ParseFB2FromReader() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateEPUB(b *testing.B) {
+	for _, book := range benchmarkBooks {
+		document := book.fb2()
+		b.Run(book.name, func(b *testing.B) {
+			fb2, err := converter.ParseFB2FromReader(strings.NewReader(document))
+			if err != nil {
+				b.Fatalf("ParseFB2FromReader() error = %v", err)
+			}
+			opts := converter.DefaultOptions()
+
+			b.SetBytes(int64(len(document)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := converter.WriteEPUB(fb2, io.Discard, opts); err != nil {
+					b.Fatalf("WriteEPUB() error = %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/lex/fb2epub/book"
+	"github.com/lex/fb2epub/converter"
+)
+
+const notesTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Book With Notes</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Chapter 1</p></title>
+      <p>Main text<a l:href="#n1" type="note">1</a></p>
+    </section>
+  </body>
+  <body name="notes">
+    <title><p>Notes</p></title>
+    <section id="n1">
+      <title><p>1</p></title>
+      <p>The note text</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestParseFB2_MultipleBodies(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(notesTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	if len(fb2.Body) != 2 {
+		t.Fatalf("Expected 2 bodies, got %d", len(fb2.Body))
+	}
+	if len(fb2.MainBody().Section) != 1 {
+		t.Errorf("Expected 1 section in main body, got %d", len(fb2.MainBody().Section))
+	}
+
+	extra := fb2.ExtraBodies()
+	if len(extra) != 1 || extra[0].Name != "notes" {
+		t.Fatalf("Expected one extra body named 'notes', got %+v", extra)
+	}
+	if extra[0].Section[0].ID != "n1" {
+		t.Errorf("Expected note section ID 'n1', got %q", extra[0].Section[0].ID)
+	}
+}
+
+func TestGenerateEPUB_NotesBodyAsBackMatter(t *testing.T) {
+	entries := generateTestEPUB(t, notesTestFB2, converter.DefaultOptions())
+
+	notes, ok := entries["OEBPS/backmatter-1.xhtml"]
+	if !ok {
+		t.Fatal("Notes body should be written to backmatter-1.xhtml")
+	}
+	if !strings.Contains(notes, "The note text") || !strings.Contains(notes, `<a id="n1"></a>`) {
+		t.Error("Back-matter file should contain the note with its anchor")
+	}
+
+	content := entries["OEBPS/content.xhtml"]
+	if strings.Contains(content, "The note text") {
+		t.Error("Notes should not be rendered in the main content")
+	}
+	if !strings.Contains(content, `href="backmatter-1.xhtml#n1"`) {
+		t.Error("Note reference should link into the back-matter file")
+	}
+
+	opf := entries["OEBPS/content.opf"]
+	if !strings.Contains(opf, `<itemref idref="backmatter-1" linear="no"/>`) {
+		t.Error("Notes body should be excluded from the linear reading order")
+	}
+	if !strings.Contains(entries["OEBPS/nav.xhtml"], `href="backmatter-1.xhtml#backmatter-1"`) {
+		t.Error("Navigation should link to the notes body")
+	}
+}
+
+func TestGenerateEPUB_BackMatterTitleFromName(t *testing.T) {
+	// Untitled bodies are named after their name attribute, capitalized by rune
+	fb2 := strings.Replace(notesTestFB2, `<body name="notes">
+    <title><p>Notes</p></title>`, `<body name="примечания">`, 1)
+	entries := generateTestEPUB(t, fb2, converter.DefaultOptions())
+
+	for _, file := range []string{"OEBPS/backmatter-1.xhtml", "OEBPS/nav.xhtml"} {
+		if !strings.Contains(entries[file], "Примечания") {
+			t.Errorf("Expected the body titled Примечания in %s:\n%s", file, entries[file])
+		}
+		if !utf8.ValidString(entries[file]) {
+			t.Errorf("%s is not valid UTF-8", file)
+		}
+	}
+}
+
+func TestGenerateEPUB_NotesAsEndnotes(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Notes = converter.NotesEndnotes
+	entries := generateTestEPUB(t, notesTestFB2, opts)
+
+	opf := entries["OEBPS/content.opf"]
+	if !strings.Contains(opf, `<itemref idref="backmatter-1"/>`) {
+		t.Error("Endnotes should be part of the linear reading order")
+	}
+	if strings.Index(opf, `idref="backmatter-1"`) < strings.Index(opf, `idref="content"`) {
+		t.Error("Endnotes should be read after the text")
+	}
+
+	content := entries["OEBPS/content.xhtml"]
+	if !strings.Contains(content, `<a id="noteref-n1" href="backmatter-1.xhtml#n1">1</a>`) {
+		t.Errorf("Note reference should get an anchor to return to, got %s", content)
+	}
+
+	notes := entries["OEBPS/backmatter-1.xhtml"]
+	if !strings.Contains(notes, `epub:type="endnotes"`) {
+		t.Error("Endnotes should keep their structural semantics")
+	}
+	backlink := `<a href="content.xhtml#noteref-n1" role="doc-backlink">`
+	if !strings.Contains(notes, backlink) {
+		t.Errorf("Note should link back to its reference, got %s", notes)
+	}
+	if strings.Index(notes, backlink) < strings.Index(notes, "The note text") {
+		t.Error("The link back should follow the note text")
+	}
+}
+
+func TestNotesMode_Validate(t *testing.T) {
+	for _, mode := range []converter.NotesMode{"", converter.NotesPopup, converter.NotesEndnotes} {
+		if err := mode.Validate(); err != nil {
+			t.Errorf("Validate(%q) error = %v, want nil", mode, err)
+		}
+	}
+	if err := converter.NotesMode("inline").Validate(); err == nil {
+		t.Error("Validate(inline) should reject an unknown mode")
+	}
+}
+
+const frontMatterTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Book With Front Matter</book-title>
+      <coverpage><image l:href="#cover.png"/></coverpage>
+    </title-info>
+  </description>
+  <body>
+    <image l:href="#frontispiece.png"/>
+    <title><p>The Book</p></title>
+    <epigraph>
+      <p>All happy families are alike.</p>
+      <text-author>Leo Tolstoy</text-author>
+    </epigraph>
+    <section>
+      <title><p>Chapter 1</p></title>
+      <p>Main text</p>
+    </section>
+  </body>
+  <binary id="cover.png" content-type="image/png">iVBORw0KGgo=</binary>
+  <binary id="frontispiece.png" content-type="image/png">iVBORw0KGgoAAAAN</binary>
+</FictionBook>`
+
+func TestGenerateEPUB_BodyFrontMatter(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(frontMatterTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+	body := fb2.MainBody()
+	if len(body.Image) != 1 || len(body.Epigraph) != 1 || len(body.Epigraph[0].TextAuthor) != 1 {
+		t.Fatalf("Expected the body image and epigraph to be parsed, got %+v", body)
+	}
+
+	opts := converter.DefaultOptions()
+	opts.PruneImages = true
+	content := convertTestEPUB(t, strings.NewReader(frontMatterTestFB2), opts)["OEBPS/content.xhtml"]
+
+	image := strings.Index(content, `<div class="illustration"><img src="images/frontispiece`)
+	title := strings.Index(content, "<h1>The Book</h1>")
+	epigraph := strings.Index(content, `<div class="epigraph">`)
+	chapter := strings.Index(content, `<div class="chapter">`)
+	if image < 0 || title < 0 || epigraph < 0 || chapter < 0 {
+		t.Fatalf("Expected the image, title, epigraph and chapter in the content, got:\n%s", content)
+	}
+	if !(image < title && title < epigraph && epigraph < chapter) {
+		t.Error("The body image, title and epigraph should come in order before the first chapter")
+	}
+	if !strings.Contains(content, `<p class="text-author">Leo Tolstoy</p>`) {
+		t.Error("The epigraph should keep its attribution")
+	}
+}
+
+func TestBookFromFB2_FrontMatter(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(frontMatterTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	b := converter.BookFromFB2(fb2)
+	if len(b.Front) != 2 || b.Front[0].ImageID != "frontispiece.png" || b.Front[1].Kind != book.Epigraph {
+		t.Fatalf("Expected the body image and epigraph as front matter, got %+v", b.Front)
+	}
+
+	back := converter.FB2FromBook(b).MainBody()
+	if len(back.Image) != 1 || back.Image[0].Href != "#frontispiece.png" {
+		t.Errorf("Expected the front image back in the body, got %+v", back.Image)
+	}
+	if len(back.Epigraph) != 1 || len(back.Epigraph[0].Paragraph) != 1 || len(back.Epigraph[0].TextAuthor) != 1 {
+		t.Errorf("Expected the epigraph back in the body, got %+v", back.Epigraph)
+	}
+}
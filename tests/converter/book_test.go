@@ -0,0 +1,173 @@
+package converter_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/book"
+	"github.com/lex/fb2epub/converter"
+)
+
+const bookModelTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <genre>sf</genre>
+      <author><first-name>Jane</first-name><last-name>Doe</last-name></author>
+      <book-title>Model Book</book-title>
+      <annotation><p>About the book</p></annotation>
+      <coverpage><image l:href="#cover.png"/></coverpage>
+      <lang>en</lang>
+      <sequence name="Saga" number="2"/>
+    </title-info>
+    <document-info><id>doc-42</id></document-info>
+  </description>
+  <body>
+    <section id="ch1">
+      <title><p>Chapter 1</p></title>
+      <p>Plain <strong>bold</strong><emphasis>italic</emphasis><a l:href="#n1" type="note">1</a></p>
+      <p><image l:href="#cover.png"/></p>
+      <empty-line/>
+      <poem><stanza><v>First line</v><v>Second line</v></stanza></poem>
+      <cite><p>Quoted</p><text-author>Someone</text-author></cite>
+      <section><title><p>Part A</p></title><p>Nested</p></section>
+    </section>
+  </body>
+  <body name="notes">
+    <section id="n1"><title><p>1</p></title><p>The note</p></section>
+  </body>
+  <binary id="cover.png" content-type="image/png">iVBORw0KGgo=</binary>
+  <binary id="broken.png" content-type="image/png">%%%</binary>
+</FictionBook>`
+
+func parseBookModelTestFB2(t *testing.T) *book.Book {
+	t.Helper()
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(bookModelTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+	return converter.BookFromFB2(fb2)
+}
+
+func TestBookFromFB2(t *testing.T) {
+	b := parseBookModelTestFB2(t)
+
+	metadata := b.Metadata
+	if metadata.Title != "Model Book" || metadata.Language != "en" || metadata.Identifier != "doc-42" {
+		t.Errorf("Unexpected metadata: %+v", metadata)
+	}
+	if !reflect.DeepEqual(metadata.Authors, []string{"Jane Doe"}) {
+		t.Errorf("Expected authors [Jane Doe], got %v", metadata.Authors)
+	}
+	if !reflect.DeepEqual(metadata.Series, []book.Series{{Name: "Saga", Number: "2"}}) {
+		t.Errorf("Expected series Saga #2, got %v", metadata.Series)
+	}
+	if len(metadata.Annotation) != 1 || metadata.Annotation[0].Text() != "About the book" {
+		t.Errorf("Expected the annotation paragraph, got %+v", metadata.Annotation)
+	}
+	if b.Cover != "cover.png" {
+		t.Errorf("Expected cover cover.png, got %q", b.Cover)
+	}
+
+	if len(b.Chapters) != 1 {
+		t.Fatalf("Expected 1 chapter, got %d", len(b.Chapters))
+	}
+	chapter := b.Chapters[0]
+	if chapter.ID != "ch1" || chapter.Title != "Chapter 1" {
+		t.Errorf("Expected chapter ch1 titled Chapter 1, got %q %q", chapter.ID, chapter.Title)
+	}
+	kinds := make([]book.BlockKind, 0, len(chapter.Blocks))
+	for _, block := range chapter.Blocks {
+		kinds = append(kinds, block.Kind)
+	}
+	expectedKinds := []book.BlockKind{book.Paragraph, book.Image, book.EmptyLine, book.Poem, book.Quote}
+	if !reflect.DeepEqual(kinds, expectedKinds) {
+		t.Errorf("Expected block kinds %v, got %v", expectedKinds, kinds)
+	}
+	expectedSpans := []book.Span{
+		{Text: "Plain "},
+		{Text: "bold", Style: book.Strong},
+		{Text: "italic", Style: book.Emphasis},
+		{Text: "1", Href: "#n1"},
+	}
+	if !reflect.DeepEqual(chapter.Blocks[0].Spans, expectedSpans) {
+		t.Errorf("Expected spans %+v, got %+v", expectedSpans, chapter.Blocks[0].Spans)
+	}
+	if text := chapter.Blocks[3].Text(); text != "First line\nSecond line" {
+		t.Errorf("Expected the poem verses, got %q", text)
+	}
+	if len(chapter.Children) != 1 || chapter.Children[0].Title != "Part A" {
+		t.Errorf("Expected the nested section as a child chapter, got %+v", chapter.Children)
+	}
+
+	if len(b.Notes) != 1 || len(b.Notes[0].Children) != 1 || b.Notes[0].Children[0].ID != "n1" {
+		t.Errorf("Expected the notes body with note n1, got %+v", b.Notes)
+	}
+	if len(b.Resources) != 1 || b.Resource("cover.png") == nil || b.Resource("broken.png") != nil {
+		t.Errorf("Expected only the valid binary as a resource, got %+v", b.Resources)
+	}
+}
+
+func TestFB2FromBook_RoundTrip(t *testing.T) {
+	b := parseBookModelTestFB2(t)
+	again := converter.BookFromFB2(converter.FB2FromBook(b))
+
+	if !reflect.DeepEqual(again.Metadata, b.Metadata) {
+		t.Errorf("Metadata changed in the round trip:\nbefore %+v\nafter  %+v", b.Metadata, again.Metadata)
+	}
+	if !reflect.DeepEqual(again.Chapters, b.Chapters) {
+		t.Errorf("Chapters changed in the round trip")
+	}
+	if !reflect.DeepEqual(again.Resources, b.Resources) || again.Cover != b.Cover {
+		t.Errorf("Resources changed in the round trip")
+	}
+}
+
+func TestWriteEPUBFromBook(t *testing.T) {
+	b := &book.Book{
+		Metadata: book.Metadata{Title: "Built Book", Authors: []string{"Ann Writer"}, Language: "en"},
+		Chapters: []*book.Chapter{{
+			Title: "Only Chapter",
+			Blocks: []book.Block{{
+				Kind:  book.Paragraph,
+				Spans: []book.Span{{Text: "Hello "}, {Text: "world", Style: book.Emphasis}},
+			}},
+		}},
+	}
+
+	var output bytes.Buffer
+	if err := converter.WriteEPUBFromBook(context.Background(), b, &output, converter.DefaultOptions()); err != nil {
+		t.Fatalf("WriteEPUBFromBook() error = %v, want nil", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatalf("Output is not a zip archive: %v", err)
+	}
+	var content string
+	for _, file := range reader.File {
+		if file.Name != "OEBPS/content.xhtml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		var data bytes.Buffer
+		_, err = data.ReadFrom(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.Name, err)
+		}
+		content = data.String()
+	}
+	for _, expected := range []string{"Only Chapter", "Hello", "<em>world</em>"} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("content.xhtml should contain %q, got: %s", expected, content)
+		}
+	}
+}
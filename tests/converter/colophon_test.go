@@ -0,0 +1,92 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const colophonTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Archived</book-title>
+      <lang>ru</lang>
+    </title-info>
+    <document-info>
+      <author><nickname>scanner</nickname></author>
+      <program-used>FictionBook Editor 2.6</program-used>
+      <date>2010-05-01</date>
+      <id>doc-123</id>
+      <version>1.1</version>
+      <history>
+        <p>1.0 — scanned and proofread</p>
+        <p>1.1 — typos fixed</p>
+      </history>
+    </document-info>
+    <custom-info info-type="library">Shelf 12</custom-info>
+  </description>
+  <body>
+    <section><p>Text</p></section>
+  </body>
+</FictionBook>`
+
+func TestColophon(t *testing.T) {
+	converter.Version = "v9.9.9"
+	defer func() { converter.Version = "" }()
+
+	opts := converter.DefaultOptions()
+	opts.Colophon = true
+	entries := generateTestEPUB(t, colophonTestFB2, opts)
+
+	colophon, ok := entries["OEBPS/colophon.xhtml"]
+	if !ok {
+		t.Fatal("Expected a colophon page")
+	}
+	for _, want := range []string{
+		"<h1>Выходные данные</h1>",
+		"<dd>doc-123 (1.1)</dd>",
+		"<dd>scanner</dd>",
+		"<dd>2010-05-01</dd>",
+		"<dd>FictionBook Editor 2.6</dd>",
+		"<dd>fb2epub v9.9.9</dd>",
+		"<dt>library</dt>\n<dd>Shelf 12</dd>",
+		"<h2>История документа</h2>\n<p>1.0 — scanned and proofread</p>\n<p>1.1 — typos fixed</p>",
+	} {
+		if !strings.Contains(colophon, want) {
+			t.Errorf("Expected %s in the colophon:\n%s", want, colophon)
+		}
+	}
+
+	opf := entries["OEBPS/content.opf"]
+	if !strings.Contains(opf, `<item id="colophon" href="colophon.xhtml" media-type="application/xhtml+xml"/>`) {
+		t.Errorf("Expected the colophon in the manifest:\n%s", opf)
+	}
+	if spine := opf[strings.Index(opf, "<spine"):]; !strings.Contains(spine, `<itemref idref="colophon"/>`+"\n  </spine>") {
+		t.Errorf("Expected the colophon last in the spine:\n%s", spine)
+	}
+}
+
+func TestColophon_Disabled(t *testing.T) {
+	entries := generateTestEPUB(t, colophonTestFB2, converter.DefaultOptions())
+
+	if _, ok := entries["OEBPS/colophon.xhtml"]; ok {
+		t.Error("Expected no colophon by default")
+	}
+}
+
+func TestInspect_HistoryAndCustomInfo(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(colophonTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	info := converter.Inspect(fb2)
+	if len(info.History) != 2 || info.History[1] != "1.1 — typos fixed" {
+		t.Errorf("Expected the document history, got %q", info.History)
+	}
+	if len(info.CustomInfo) != 1 || info.CustomInfo[0] != (converter.CustomInfo{Type: "library", Text: "Shelf 12"}) {
+		t.Errorf("Expected the custom info, got %+v", info.CustomInfo)
+	}
+}
@@ -0,0 +1,71 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const contributorsTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <author><first-name>Leo</first-name><last-name>Tolstoy</last-name></author>
+      <book-title>War and Peace</book-title>
+      <lang>en</lang>
+      <src-lang>ru</src-lang>
+      <translator><first-name>Aylmer</first-name><last-name>Maude</last-name></translator>
+      <translator><first-name>Louise</first-name><last-name>Maude</last-name></translator>
+    </title-info>
+    <document-info>
+      <author><nickname>scanner</nickname></author>
+    </document-info>
+  </description>
+  <body>
+    <section><title><p>Chapter 1</p></title><p>Text</p></section>
+  </body>
+</FictionBook>`
+
+func TestContributors_OPF(t *testing.T) {
+	opf := generateTestEPUB(t, contributorsTestFB2, converter.DefaultOptions())["OEBPS/content.opf"]
+
+	for _, want := range []string{
+		`<dc:creator>Leo Tolstoy</dc:creator>`,
+		`<dc:contributor id="contributor-1">Aylmer Maude</dc:contributor>`,
+		`<meta refines="#contributor-1" property="role" scheme="marc:relators">trl</meta>`,
+		`<dc:contributor id="contributor-2">Louise Maude</dc:contributor>`,
+		`<meta refines="#contributor-2" property="role" scheme="marc:relators">trl</meta>`,
+		`<dc:contributor id="contributor-3">scanner</dc:contributor>`,
+		`<meta refines="#contributor-3" property="role" scheme="marc:relators">bkp</meta>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("Expected %s in content.opf:\n%s", want, opf)
+		}
+	}
+}
+
+func TestContributors_RoundTrip(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(contributorsTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v", err)
+	}
+	var epub bytes.Buffer
+	if err := converter.WriteEPUB(fb2, &epub, converter.DefaultOptions()); err != nil {
+		t.Fatalf("WriteEPUB() error = %v", err)
+	}
+
+	b, err := converter.ParseEPUB(bytes.NewReader(epub.Bytes()), int64(epub.Len()))
+	if err != nil {
+		t.Fatalf("ParseEPUB() error = %v", err)
+	}
+	if got := strings.Join(b.Metadata.Translators, ", "); got != "Aylmer Maude, Louise Maude" {
+		t.Errorf("Expected the translators without the document author, got %q", got)
+	}
+
+	translators := converter.FB2FromBook(b).Description.TitleInfo.Translator
+	if len(translators) != 2 || translators[0].LastName != "Maude" {
+		t.Errorf("Expected the translators in the FB2 title info, got %+v", translators)
+	}
+}
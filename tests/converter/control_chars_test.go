@@ -0,0 +1,55 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const controlCharsTestFB2 = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+	`<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Bro` + "\x00" + `ken</book-title>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>Page` + "\x0c" + ` one&#8; and&#x1F; more &#65;&#x42;` + "\x1b" + `</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestControlCharacters_Removed(t *testing.T) {
+	var warnings []string
+	opts := converter.DefaultOptions()
+	opts.OnWarning = func(d converter.Diagnostic) { warnings = append(warnings, d.Message) }
+
+	var buf bytes.Buffer
+	if err := converter.New(opts).Convert(strings.NewReader(controlCharsTestFB2), &buf); err != nil {
+		t.Fatalf("Convert() error = %v, want nil", err)
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "removed 5 control characters") {
+		t.Errorf("Expected one warning counting 5 removed characters, got %v", warnings)
+	}
+}
+
+func TestControlCharacters_ParseFB2FromReader(t *testing.T) {
+	// One byte at a time, references are cut between reads
+	fb2, err := converter.ParseFB2FromReader(iotest.OneByteReader(strings.NewReader(controlCharsTestFB2)))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	if title := fb2.Description.TitleInfo.BookTitle; title != "Broken" {
+		t.Errorf("Expected title %q, got %q", "Broken", title)
+	}
+	if text := fb2.MainBody().Section[0].Paragraph[0].Text; text != "Page one and more AB" {
+		t.Errorf("Expected text %q, got %q", "Page one and more AB", text)
+	}
+}
@@ -0,0 +1,108 @@
+package converter_test
+
+import (
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// coverBook opens its text with the first of two images
+var coverBook = testBook{
+	Title:   "Covers",
+	Section: `<p><image l:href="#first.png"/></p><p>Text</p>`,
+	Binaries: `<binary id="first.png" content-type="image/png">iVBORw0KGgo=</binary>
+  <binary id="big.png" content-type="image/png">iVBORw0KGgoAAAAAAAAAAAAAAAAAAAAAAAAAAAAA</binary>`,
+}
+
+func TestCoverDetection_FirstReferencedImage(t *testing.T) {
+	entries := generateTestEPUB(t, coverBook.fb2(), converter.DefaultOptions())
+
+	if !strings.Contains(entries["OEBPS/content.opf"], `<meta name="cover" content="first.png"/>`) {
+		t.Error("First image of the opening section should be used as cover")
+	}
+	if !strings.Contains(entries["OEBPS/cover.xhtml"], `<img src="images/first.png.png"`) {
+		t.Error("Cover page should display the detected cover image")
+	}
+}
+
+func TestCoverDetection_CoverpageTakesPrecedence(t *testing.T) {
+	book := coverBook
+	book.TitleInfo = `<coverpage><image l:href="#big.png"/></coverpage>`
+	entries := generateTestEPUB(t, book.fb2(), converter.DefaultOptions())
+
+	if !strings.Contains(entries["OEBPS/content.opf"], `<meta name="cover" content="big.png"/>`) {
+		t.Error("Declared coverpage should be used as cover")
+	}
+}
+
+func TestCoverDetection_Disabled(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.DisableCoverDetection = true
+	entries := generateTestEPUB(t, coverBook.fb2(), opts)
+
+	if strings.Contains(entries["OEBPS/content.opf"], `<meta name="cover"`) {
+		t.Error("No cover should be set when detection is disabled")
+	}
+	if strings.Contains(entries["OEBPS/cover.xhtml"], "<img") {
+		t.Error("Cover page should fall back to text when detection is disabled")
+	}
+}
+
+func TestGeneratedCover(t *testing.T) {
+	fb2 := `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Очень длинное название книги, которое не помещается в одну строку обложки</book-title>
+      <author><first-name>Лев</first-name><last-name>Толстой</last-name></author>
+      <lang>ru</lang>
+    </title-info>
+  </description>
+  <body><section><p>Text</p></section></body>
+</FictionBook>`
+	opts := converter.DefaultOptions()
+	opts.GenerateCover = true
+	entries := generateTestEPUB(t, fb2, opts)
+
+	if !strings.Contains(entries["OEBPS/content.opf"], `<meta name="cover" content="cover-generated"/>`) {
+		t.Error("Generated image should be declared as the cover")
+	}
+	if !strings.Contains(entries["OEBPS/cover.xhtml"], `<img src="images/cover-generated.png"`) {
+		t.Error("Cover page should display the generated image")
+	}
+
+	img, err := png.Decode(strings.NewReader(entries["OEBPS/images/cover-generated.png"]))
+	if err != nil {
+		t.Fatalf("Generated cover should be a PNG: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 600 || bounds.Dy() != 900 {
+		t.Errorf("Expected a 600x900 cover, got %v", bounds)
+	}
+	// White title text is drawn on the colored background
+	white := 0
+	for x := 50; x < 550; x++ {
+		for y := 100; y < 500; y++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r > 0xF000 && g > 0xF000 && b > 0xF000 {
+				white++
+			}
+		}
+	}
+	if white == 0 {
+		t.Error("Expected the title to be drawn on the cover")
+	}
+}
+
+func TestGeneratedCover_KeepsExistingCover(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.GenerateCover = true
+	entries := generateTestEPUB(t, coverBook.fb2(), opts)
+
+	if !strings.Contains(entries["OEBPS/content.opf"], `<meta name="cover" content="first.png"/>`) {
+		t.Error("A detected cover image should be preferred to a generated one")
+	}
+	if _, exists := entries["OEBPS/images/cover-generated.png"]; exists {
+		t.Error("No cover should be generated for a book with a cover image")
+	}
+}
@@ -0,0 +1,56 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const dropCapsTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description><title-info><book-title>Drop Caps</book-title><lang>en</lang></title-info></description>
+  <body>
+    <section>
+      <title><p>Chapter 1</p></title>
+      <p>Once upon a time.<a l:href="#n1" type="note">1</a></p>
+      <p>Then nothing happened.</p>
+    </section>
+    <section>
+      <title><p>Chapter 2</p></title>
+      <p><emphasis>"Well," she said.</emphasis></p>
+    </section>
+  </body>
+  <body name="notes">
+    <section id="n1"><title><p>1</p></title><p>A note.</p></section>
+  </body>
+</FictionBook>`
+
+func TestDropCaps(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.DropCaps = true
+	opts.Typography = true
+	entries := generateTestEPUB(t, dropCapsTestFB2, opts)
+	content := entries["OEBPS/content.xhtml"]
+
+	for _, want := range []string{
+		`<p class="opening"><span class="dropcap">O</span>nce upon a time.`,
+		`<p class="opening"><em><span class="dropcap">“W</span>ell,” she said.</em>`,
+		`<p>Then nothing happened.</p>`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %s in content.xhtml:\n%s", want, content)
+		}
+	}
+	if strings.Contains(entries["OEBPS/backmatter-1.xhtml"], "dropcap") {
+		t.Error("Notes should not get drop caps")
+	}
+}
+
+func TestDropCaps_OffByDefault(t *testing.T) {
+	content := generateTestEPUB(t, dropCapsTestFB2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	if strings.Contains(content, `class="dropcap"`) || strings.Contains(content, `class="opening"`) {
+		t.Errorf("Expected no drop caps unless enabled:\n%s", content)
+	}
+}
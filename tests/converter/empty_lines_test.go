@@ -0,0 +1,73 @@
+package converter_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/book"
+	"github.com/lex/fb2epub/converter"
+)
+
+const emptyLinesTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Spacing</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section id="ch1">
+      <title><p>Chapter</p></title>
+      <p>First</p>
+      <empty-line/>
+      <p>Second</p>
+      <p>Third</p>
+      <empty-line/>
+      <empty-line/>
+      <p>Fourth</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestEmptyLines_KeepDocumentOrder(t *testing.T) {
+	content := generateTestEPUB(t, emptyLinesTestFB2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	blocks := regexp.MustCompile(`<p>(\w+)</p>|<div class="empty-line"></div>`).FindAllStringSubmatch(content, -1)
+	var got []string
+	for _, block := range blocks {
+		if block[1] != "" {
+			got = append(got, block[1])
+		} else {
+			got = append(got, "-")
+		}
+	}
+	if want := "First - Second Third - - Fourth"; strings.Join(got, " ") != want {
+		t.Errorf("Expected blocks %q, got %q", want, strings.Join(got, " "))
+	}
+}
+
+func TestEmptyLines_BookModelOrder(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(emptyLinesTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v", err)
+	}
+
+	chapter := converter.BookFromFB2(fb2).Chapters[0]
+	kinds := []book.BlockKind{book.Paragraph, book.EmptyLine, book.Paragraph, book.Paragraph,
+		book.EmptyLine, book.EmptyLine, book.Paragraph}
+	if len(chapter.Blocks) != len(kinds) {
+		t.Fatalf("Expected %d blocks, got %d", len(kinds), len(chapter.Blocks))
+	}
+	for i, kind := range kinds {
+		if chapter.Blocks[i].Kind != kind {
+			t.Errorf("Block %d: expected kind %v, got %v", i, kind, chapter.Blocks[i].Kind)
+		}
+	}
+
+	// The order survives the round trip through the book model
+	section := converter.FB2FromBook(converter.BookFromFB2(fb2)).Body[0].Section[0]
+	if blocks := section.OrderedBlocks(); len(blocks) != 7 || blocks[1] != fb2.Body[0].Section[0].Blocks[1] {
+		t.Errorf("Expected the empty lines in place after the round trip, got %v", blocks)
+	}
+}
@@ -0,0 +1,95 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const encodingTestFB2 = `<?xml version="1.0" encoding="%s"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <genre>prose_classic</genre>
+      <author><first-name>Лев</first-name><last-name>Толстой</last-name></author>
+      <book-title>Война и мир 😀</book-title>
+      <lang>ru</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>Текст</p>
+    </section>
+  </body>
+</FictionBook>`
+
+// encodeUTF16 returns s encoded as UTF-16 in the given byte order
+func encodeUTF16(s string, bigEndian bool) []byte {
+	var data []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			data = append(data, byte(unit>>8), byte(unit))
+		} else {
+			data = append(data, byte(unit), byte(unit>>8))
+		}
+	}
+	return data
+}
+
+func TestEncoding_ParseFB2FromReader(t *testing.T) {
+	utf8Doc := strings.Replace(encodingTestFB2, "%s", "UTF-8", 1)
+	utf16Doc := strings.Replace(encodingTestFB2, "%s", "UTF-16", 1)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"utf-8", []byte(utf8Doc)},
+		{"utf-8 with BOM", append([]byte{0xEF, 0xBB, 0xBF}, utf8Doc...)},
+		{"leading whitespace", []byte("\n\t  " + utf8Doc)},
+		{"utf-8 BOM and whitespace", append([]byte{0xEF, 0xBB, 0xBF}, "\r\n"+utf8Doc...)},
+		{"utf-16le with BOM", append([]byte{0xFF, 0xFE}, encodeUTF16(utf16Doc, false)...)},
+		{"utf-16be with BOM", append([]byte{0xFE, 0xFF}, encodeUTF16(utf16Doc, true)...)},
+		{"utf-16le", encodeUTF16(utf16Doc, false)},
+		{"utf-16be", encodeUTF16(utf16Doc, true)},
+		{"utf-16le with whitespace", append([]byte{0xFF, 0xFE}, encodeUTF16("\n "+utf16Doc, false)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte at a time, surrogate pairs are cut between reads
+			fb2, err := converter.ParseFB2FromReader(iotest.OneByteReader(bytes.NewReader(tt.data)))
+			if err != nil {
+				t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+			}
+			if title := fb2.Description.TitleInfo.BookTitle; title != "Война и мир 😀" {
+				t.Errorf("Expected title %q, got %q", "Война и мир 😀", title)
+			}
+			if text := fb2.MainBody().Section[0].Paragraph[0].Text; text != "Текст" {
+				t.Errorf("Expected text %q, got %q", "Текст", text)
+			}
+		})
+	}
+}
+
+func TestEncoding_ConvertUTF16(t *testing.T) {
+	data := append([]byte{0xFF, 0xFE}, encodeUTF16(strings.Replace(encodingTestFB2, "%s", "UTF-16", 1), false)...)
+
+	opts := converter.DefaultOptions()
+	opts.Strict = true
+	entries := convertTestEPUB(t, bytes.NewReader(data), opts)
+
+	if opf := entries["OEBPS/content.opf"]; !strings.Contains(opf, "<dc:title>Война и мир 😀</dc:title>") {
+		t.Errorf("content.opf should contain the transcoded title, got:\n%s", opf)
+	}
+}
+
+func TestEncoding_ValidateUTF16(t *testing.T) {
+	data := encodeUTF16(strings.Replace(encodingTestFB2, "%s", "UTF-16", 1), true)
+	if diagnostics := converter.Validate(bytes.NewReader(data)); len(diagnostics) != 0 {
+		t.Errorf("Expected a valid document, got %v", diagnostics)
+	}
+}
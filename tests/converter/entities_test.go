@@ -0,0 +1,71 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const htmlEntitiesTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Tom&nbsp;&amp;&nbsp;Jerry</book-title>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>Wait&hellip; &laquo;quoted&raquo; &mdash; &amp;mdash; &unknown;</p>
+      <p><![CDATA[Literal &mdash; ]] kept]]> after&nbsp;CDATA</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestHTMLEntities_RejectedByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	if err := converter.New(nil).Convert(strings.NewReader(htmlEntitiesTestFB2), &buf); err == nil {
+		t.Fatal("Expected HTML entities to fail parsing without HTMLEntities")
+	}
+}
+
+func TestHTMLEntities_Replaced(t *testing.T) {
+	var warnings []string
+	opts := converter.DefaultOptions()
+	opts.HTMLEntities = true
+	opts.OnWarning = func(d converter.Diagnostic) { warnings = append(warnings, d.Message) }
+
+	// One byte at a time, entities and CDATA delimiters are cut between reads
+	entries := convertTestEPUB(t, iotest.OneByteReader(strings.NewReader(strings.Replace(
+		htmlEntitiesTestFB2, "&unknown;", "", 1))), opts)
+
+	if opf := entries["OEBPS/content.opf"]; !strings.Contains(opf, "<dc:title>Tom &amp; Jerry</dc:title>") {
+		t.Errorf("content.opf should contain the title with no-break spaces, got:\n%s", opf)
+	}
+	content := entries["OEBPS/content.xhtml"]
+	for _, want := range []string{
+		"Wait… «quoted» — &amp;mdash;",
+		"Literal &amp;mdash; ]] kept after CDATA",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content.xhtml should contain %q, got:\n%s", want, content)
+		}
+	}
+	if len(warnings) != 1 || warnings[0] != "replaced 7 HTML entities" {
+		t.Errorf("Expected a warning counting 7 entities, got %v", warnings)
+	}
+}
+
+func TestHTMLEntities_UnknownEntityFails(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.HTMLEntities = true
+
+	var buf bytes.Buffer
+	err := converter.New(opts).Convert(strings.NewReader(htmlEntitiesTestFB2), &buf)
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("Expected an error naming the unknown entity, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package converter_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/book"
+	"github.com/lex/fb2epub/converter"
+)
+
+// createTestEPUBArchive zips entries into an EPUB with its mimetype entry first
+func createTestEPUBArchive(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	w, err := writer.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		t.Fatalf("Failed to create mimetype: %v", err)
+	}
+	_, _ = w.Write([]byte("application/epub+zip"))
+	for name, content := range entries {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close EPUB: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestParseEPUB(t *testing.T) {
+	epub := createTestEPUBArchive(t, map[string]string{
+		"META-INF/container.xml": `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OPS/package.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`,
+		"OPS/package.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Foreign Book</dc:title>
+    <dc:creator>Ann Writer</dc:creator>
+    <dc:language>de</dc:language>
+    <dc:description>A short blurb</dc:description>
+    <meta name="cover" content="cover-img"/>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ch1" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="notes" href="text/notes.xhtml" media-type="application/xhtml+xml"/>
+    <item id="cover-img" href="img/cover.jpg" media-type="image/jpeg"/>
+  </manifest>
+  <spine><itemref idref="nav"/><itemref idref="ch1"/><itemref idref="notes" linear="no"/></spine>
+</package>`,
+		"OPS/nav.xhtml": `<html><body><nav><ol><li>Contents</li></ol></nav></body></html>`,
+		"OPS/text/ch1.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml"><head><title>x</title></head><body>
+<h1 id="c1">First &amp; Foremost</h1>
+<p>Some   <b>bold</b> and <i>italic</i>&nbsp;text<a href="notes.xhtml#n1"><sup>1</sup></a>.</p>
+<p><img src="../img/cover.jpg" alt=""/></p>
+<h2>Inner</h2>
+<blockquote><p>Quoted</p></blockquote>
+<h1>Second</h1>
+<p>Line one<br/>Line two</p>
+</body></html>`,
+		"OPS/text/notes.xhtml": `<html><body><p id="n1">The note</p></body></html>`,
+		"OPS/img/cover.jpg":    "jpeg data",
+	})
+
+	b, err := converter.ParseEPUB(bytes.NewReader(epub), int64(len(epub)))
+	if err != nil {
+		t.Fatalf("ParseEPUB() error = %v, want nil", err)
+	}
+
+	if b.Metadata.Title != "Foreign Book" || b.Metadata.Language != "de" ||
+		len(b.Metadata.Authors) != 1 || b.Metadata.Authors[0] != "Ann Writer" {
+		t.Errorf("Unexpected metadata: %+v", b.Metadata)
+	}
+	if len(b.Metadata.Annotation) != 1 || b.Metadata.Annotation[0].Text() != "A short blurb" {
+		t.Errorf("Expected the description as annotation, got %+v", b.Metadata.Annotation)
+	}
+	if b.Cover != "cover.jpg" || b.Resource("cover.jpg") == nil || string(b.Resource("cover.jpg").Data) != "jpeg data" {
+		t.Errorf("Expected the cover image resource, got cover %q and %+v", b.Cover, b.Resources)
+	}
+
+	if len(b.Chapters) != 2 {
+		t.Fatalf("Expected 2 chapters, got %d: %+v", len(b.Chapters), b.Chapters)
+	}
+	first := b.Chapters[0]
+	if first.ID != "c1" || first.Title != "First & Foremost" {
+		t.Errorf("Expected chapter c1 titled First & Foremost, got %q %q", first.ID, first.Title)
+	}
+	if len(first.Blocks) != 2 || first.Blocks[1].Kind != book.Image || first.Blocks[1].ImageID != "cover.jpg" {
+		t.Fatalf("Expected a paragraph and an image block, got %+v", first.Blocks)
+	}
+	expectedSpans := []book.Span{
+		{Text: "Some "},
+		{Text: "bold", Style: book.Strong},
+		{Text: " and "},
+		{Text: "italic", Style: book.Emphasis},
+		{Text: "\u00a0text"},
+		{Text: "1", Style: book.Superscript, Href: "#n1"},
+		{Text: "."},
+	}
+	if spans := first.Blocks[0].Spans; len(spans) != len(expectedSpans) {
+		t.Errorf("Expected spans %+v, got %+v", expectedSpans, spans)
+	} else {
+		for i := range spans {
+			if spans[i] != expectedSpans[i] {
+				t.Errorf("Span %d: expected %+v, got %+v", i, expectedSpans[i], spans[i])
+			}
+		}
+	}
+	if len(first.Children) != 1 || first.Children[0].Title != "Inner" ||
+		len(first.Children[0].Blocks) != 1 || first.Children[0].Blocks[0].Kind != book.Quote {
+		t.Errorf("Expected the h2 as a child chapter holding a quote, got %+v", first.Children)
+	}
+	if second := b.Chapters[1]; len(second.Blocks) != 2 {
+		t.Errorf("Expected line breaks to split paragraphs, got %+v", second.Blocks)
+	}
+
+	if len(b.Notes) != 1 || len(b.Notes[0].Children) != 1 || b.Notes[0].Children[0].ID != "n1" {
+		t.Errorf("Expected the non-linear document as notes with note n1, got %+v", b.Notes)
+	}
+}
+
+func TestParseEPUB_RoundTrip(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(notesTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+	var epub bytes.Buffer
+	if err := converter.WriteEPUB(fb2, &epub, converter.DefaultOptions()); err != nil {
+		t.Fatalf("WriteEPUB() error = %v, want nil", err)
+	}
+
+	b, err := converter.ParseEPUB(bytes.NewReader(epub.Bytes()), int64(epub.Len()))
+	if err != nil {
+		t.Fatalf("ParseEPUB() error = %v, want nil", err)
+	}
+	var output bytes.Buffer
+	if err := converter.WriteFB2(converter.FB2FromBook(b), &output); err != nil {
+		t.Fatalf("WriteFB2() error = %v, want nil", err)
+	}
+
+	again, err := converter.ParseFB2FromReader(&output)
+	if err != nil {
+		t.Fatalf("Written FB2 does not parse: %v", err)
+	}
+	if title := again.Description.TitleInfo.BookTitle; title != "Book With Notes" {
+		t.Errorf("Expected title Book With Notes, got %q", title)
+	}
+	sections := again.MainBody().Section
+	if len(sections) != 1 || sections[0].Title == nil || sections[0].Title.Paragraph[0].Text != "Chapter 1" {
+		t.Fatalf("Expected the single chapter without title or cover pages, got %+v", sections)
+	}
+	if p := sections[0].Paragraph; len(p) != 1 || len(p[0].Link) != 1 || p[0].Link[0].Href != "#n1" {
+		t.Errorf("Expected the note reference to survive, got %+v", p)
+	}
+	if len(again.Body) != 2 || len(again.Body[1].Section) != 1 || again.Body[1].Section[0].ID != "n1" {
+		t.Errorf("Expected the notes body with note n1, got %+v", again.Body)
+	}
+}
+
+func TestParseEPUB_Invalid(t *testing.T) {
+	tests := map[string][]byte{
+		"not a zip":         []byte("plain text"),
+		"missing container": createTestEPUBArchive(t, map[string]string{"OEBPS/content.opf": "<package/>"}),
+	}
+	for name, data := range tests {
+		_, err := converter.ParseEPUB(bytes.NewReader(data), int64(len(data)))
+		var parseErr *converter.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: expected a *ParseError, got %v", name, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package converter_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/lex/fb2epub/converter"
@@ -23,7 +24,7 @@ func TestParseFB2_ValidFile(t *testing.T) {
 		t.Error("BookTitle is empty")
 	}
 
-	if fb2.Body.Section == nil || len(fb2.Body.Section) == 0 {
+	if fb2.MainBody().Section == nil || len(fb2.MainBody().Section) == 0 {
 		t.Error("Body has no sections")
 	}
 }
@@ -40,8 +41,8 @@ func TestParseFB2_CompleteFile(t *testing.T) {
 	}
 
 	// Check for nested sections
-	if len(fb2.Body.Section) > 0 {
-		firstSection := fb2.Body.Section[0]
+	if len(fb2.MainBody().Section) > 0 {
+		firstSection := fb2.MainBody().Section[0]
 		if len(firstSection.Section) > 0 {
 			// Has nested sections
 			if firstSection.Section[0].Title != nil && len(firstSection.Section[0].Title.Paragraph) == 0 {
@@ -93,8 +94,8 @@ func TestParseFB2_UnicodeFile(t *testing.T) {
 	}
 
 	// Check that Unicode content is preserved
-	if len(fb2.Body.Section) > 0 {
-		section := fb2.Body.Section[0]
+	if len(fb2.MainBody().Section) > 0 {
+		section := fb2.MainBody().Section[0]
 		if section.Title != nil && len(section.Title.Paragraph) > 0 {
 			title := section.Title.Paragraph[0].Text
 			if title == "" {
@@ -142,11 +143,11 @@ func TestParseFB2_WithSections(t *testing.T) {
 	}
 
 	// Check for nested sections
-	if len(fb2.Body.Section) == 0 {
+	if len(fb2.MainBody().Section) == 0 {
 		t.Error("FB2 should have sections")
 	}
 
-	firstSection := fb2.Body.Section[0]
+	firstSection := fb2.MainBody().Section[0]
 	if len(firstSection.Section) == 0 {
 		t.Error("First section should have nested sections")
 	}
@@ -169,8 +170,8 @@ func TestParseFB2_WithImages(t *testing.T) {
 	}
 
 	// Check for image references in paragraphs
-	if len(fb2.Body.Section) > 0 {
-		section := fb2.Body.Section[0]
+	if len(fb2.MainBody().Section) > 0 {
+		section := fb2.MainBody().Section[0]
 		if len(section.Paragraph) > 0 {
 			paragraph := section.Paragraph[0]
 			if len(paragraph.Image) == 0 {
@@ -192,8 +193,8 @@ func TestParseFB2_WithLinks(t *testing.T) {
 	}
 
 	// Check for links in paragraphs
-	if len(fb2.Body.Section) > 0 {
-		section := fb2.Body.Section[0]
+	if len(fb2.MainBody().Section) > 0 {
+		section := fb2.MainBody().Section[0]
 		foundLink := false
 		for _, p := range section.Paragraph {
 			if len(p.Link) > 0 {
@@ -210,6 +211,55 @@ func TestParseFB2_WithLinks(t *testing.T) {
 	}
 }
 
+func TestParseFB2_HrefNamespaceVariations(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces string
+		attr       string
+	}{
+		{"l prefix", `xmlns:l="http://www.w3.org/1999/xlink"`, "l:href"},
+		{"xlink prefix", `xmlns:xlink="http://www.w3.org/1999/xlink"`, "xlink:href"},
+		{"undeclared prefix", "", "l:href"},
+		{"no namespace", "", "href"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" ` + tt.namespaces + `>
+  <description>
+    <title-info>
+      <book-title>Links</book-title>
+      <coverpage><image ` + tt.attr + `="#cover.jpg"/></coverpage>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>See <a ` + tt.attr + `="#n1" type="note">note</a>.<image ` + tt.attr + `="#pic.png"/></p>
+    </section>
+  </body>
+</FictionBook>`
+
+			fb2, err := converter.ParseFB2FromReader(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+			}
+
+			if got := fb2.Description.TitleInfo.Coverpage.Image[0].Href; got != "#cover.jpg" {
+				t.Errorf("Coverpage href = %q, want %q", got, "#cover.jpg")
+			}
+			paragraph := fb2.MainBody().Section[0].Paragraph[0]
+			if got := paragraph.Image[0].Href; got != "#pic.png" {
+				t.Errorf("Image href = %q, want %q", got, "#pic.png")
+			}
+			link := paragraph.Link[0]
+			if link.Href != "#n1" || link.Type != "note" || link.Text != "note" {
+				t.Errorf("Link = %+v, want href #n1, type note and text note", link)
+			}
+		})
+	}
+}
+
 func TestParseFB2_WithFormatting(t *testing.T) {
 	filePath := getTestDataPath(filepath.Join("valid", "with-formatting.fb2"))
 	fb2, err := converter.ParseFB2(filePath)
@@ -222,8 +272,8 @@ func TestParseFB2_WithFormatting(t *testing.T) {
 	}
 
 	// Check for strong and emphasis formatting
-	if len(fb2.Body.Section) > 0 {
-		section := fb2.Body.Section[0]
+	if len(fb2.MainBody().Section) > 0 {
+		section := fb2.MainBody().Section[0]
 		foundStrong := false
 		foundEmphasis := false
 		for _, p := range section.Paragraph {
@@ -258,8 +308,8 @@ func TestParseFB2_WithPoems(t *testing.T) {
 	}
 
 	// Check for poems
-	if len(fb2.Body.Section) > 0 {
-		section := fb2.Body.Section[0]
+	if len(fb2.MainBody().Section) > 0 {
+		section := fb2.MainBody().Section[0]
 		if len(section.Poem) == 0 {
 			t.Error("Section should have poems")
 		} else {
@@ -287,8 +337,8 @@ func TestParseFB2_WithCitations(t *testing.T) {
 	}
 
 	// Check for citations
-	if len(fb2.Body.Section) > 0 {
-		section := fb2.Body.Section[0]
+	if len(fb2.MainBody().Section) > 0 {
+		section := fb2.MainBody().Section[0]
 		if len(section.Cite) == 0 {
 			t.Error("Section should have citations")
 		} else {
@@ -0,0 +1,117 @@
+package converter_test
+
+import (
+	"bytes"
+	"crypto/sha1" //nolint:gosec // Used to verify IDPF font obfuscation
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+var testFontData = bytes.Repeat([]byte{0x00, 0x01, 0x00, 0x00, 0x42}, 300)
+
+func TestFonts_Embedded(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Fonts = []converter.Font{
+		{Name: "PTSerif-Regular.ttf", Data: testFontData},
+		{Name: "PTSerif-BoldItalic.woff2", Data: testFontData},
+		{Name: "notes.txt", Data: []byte("not a font")},
+	}
+	files := generateTestEPUB(t, notesTestFB2, opts)
+
+	if !bytes.Equal([]byte(files["OEBPS/fonts/PTSerif-Regular.ttf"]), testFontData) {
+		t.Error("Font should be stored unchanged in OEBPS/fonts")
+	}
+	if _, exists := files["OEBPS/fonts/notes.txt"]; exists {
+		t.Error("Files with unsupported extensions should not be embedded")
+	}
+
+	opf := files["OEBPS/content.opf"]
+	for _, expected := range []string{
+		`<item id="fonts-css" href="fonts.css" media-type="text/css"/>`,
+		`<item id="font-1" href="fonts/PTSerif-Regular.ttf" media-type="font/ttf"/>`,
+		`<item id="font-2" href="fonts/PTSerif-BoldItalic.woff2" media-type="font/woff2"/>`,
+	} {
+		if !strings.Contains(opf, expected) {
+			t.Errorf("content.opf should contain %q", expected)
+		}
+	}
+
+	css := files["OEBPS/fonts.css"]
+	for _, expected := range []string{
+		`font-family: "PTSerif";`,
+		`font-weight: bold;`,
+		`font-style: italic;`,
+		`src: url("fonts/PTSerif-BoldItalic.woff2");`,
+		`body { font-family: "PTSerif", serif; }`,
+	} {
+		if !strings.Contains(css, expected) {
+			t.Errorf("fonts.css should contain %q, got: %s", expected, css)
+		}
+	}
+
+	for _, doc := range []string{"OEBPS/cover.xhtml", "OEBPS/content.xhtml", "OEBPS/backmatter-1.xhtml"} {
+		if !strings.Contains(files[doc], `<link rel="stylesheet" type="text/css" href="fonts.css"/>`) {
+			t.Errorf("%s should link the font stylesheet", doc)
+		}
+	}
+	if _, exists := files["META-INF/encryption.xml"]; exists {
+		t.Error("encryption.xml should only be written for obfuscated fonts")
+	}
+}
+
+func TestFonts_Obfuscated(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Fonts = []converter.Font{{Name: "Serif.otf", Data: testFontData}}
+	opts.ObfuscateFonts = true
+	files := generateTestEPUB(t, notesTestFB2, opts)
+
+	if !strings.Contains(files["META-INF/encryption.xml"], `<enc:CipherReference URI="OEBPS/fonts/Serif.otf"/>`) {
+		t.Fatal("encryption.xml should reference the obfuscated font")
+	}
+
+	bookID := regexp.MustCompile(`<dc:identifier id="bookid">([^<]+)</dc:identifier>`).
+		FindStringSubmatch(files["OEBPS/content.opf"])
+	if bookID == nil {
+		t.Fatal("content.opf should declare a unique identifier")
+	}
+	if !strings.Contains(files["OEBPS/toc.ncx"], `<meta name="dtb:uid" content="`+bookID[1]+`"/>`) {
+		t.Error("toc.ncx should use the same identifier as content.opf")
+	}
+
+	key := sha1.Sum([]byte(bookID[1])) //nolint:gosec // Mandated by the specification
+	stored := []byte(files["OEBPS/fonts/Serif.otf"])
+	for i := range stored {
+		if i < 1040 {
+			stored[i] ^= key[i%len(key)]
+		}
+	}
+	if !bytes.Equal(stored, testFontData) {
+		t.Error("De-obfuscating the stored font should restore the original data")
+	}
+}
+
+func TestLoadFonts(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"B-Bold.ttf": "b", "A.otf": "a", "readme.md": "x"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fonts, err := converter.LoadFonts(dir)
+	if err != nil {
+		t.Fatalf("LoadFonts() error = %v", err)
+	}
+	if len(fonts) != 2 || fonts[0].Name != "A.otf" || fonts[1].Name != "B-Bold.ttf" {
+		t.Errorf("LoadFonts() should return font files sorted by name, got %+v", fonts)
+	}
+
+	if _, err := converter.LoadFonts(filepath.Join(dir, "missing")); err == nil {
+		t.Error("LoadFonts() should fail for a missing directory")
+	}
+}
@@ -0,0 +1,101 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const genresTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <genre>sf_fantasy</genre>
+      <genre>det_classic</genre>
+      <genre>my_own_genre</genre>
+      <author><first-name>Иван</first-name><last-name>Петров</last-name></author>
+      <book-title>Книга</book-title>
+      <lang>ru</lang>
+    </title-info>
+  </description>
+  <body>
+    <section><p>Текст</p></section>
+  </body>
+</FictionBook>`
+
+func TestGenreName(t *testing.T) {
+	tests := []struct {
+		code     string
+		lang     string
+		expected string
+	}{
+		{"sf_fantasy", "en", "Fantasy"},
+		{"sf_fantasy", "ru-RU", "Фэнтези"},
+		{"det_classic", "de", "Classic detective"},
+		{"SF_FANTASY", "", "Fantasy"},
+		{"my_own_genre", "ru", "my_own_genre"},
+	}
+	for _, tt := range tests {
+		if got := converter.GenreName(tt.code, tt.lang); got != tt.expected {
+			t.Errorf("GenreName(%q, %q) = %q, want %q", tt.code, tt.lang, got, tt.expected)
+		}
+	}
+}
+
+func TestGenres_OPFAndCover(t *testing.T) {
+	entries := generateTestEPUB(t, genresTestFB2, converter.DefaultOptions())
+
+	opf := entries["OEBPS/content.opf"]
+	for _, want := range []string{
+		"<dc:subject>Фэнтези</dc:subject>",
+		"<dc:subject>Классический детектив</dc:subject>",
+		"<dc:subject>my_own_genre</dc:subject>",
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("content.opf should contain %q", want)
+		}
+	}
+
+	want := `<p class="genres">Фэнтези, Классический детектив, my_own_genre</p>`
+	if cover := entries["OEBPS/cover.xhtml"]; !strings.Contains(cover, want) {
+		t.Errorf("cover.xhtml should list the genres, got:\n%s", cover)
+	}
+}
+
+func TestGenres_Inspect(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(genresTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	info := converter.Inspect(fb2)
+	expected := []string{"Фэнтези", "Классический детектив", "my_own_genre"}
+	if strings.Join(info.GenreNames, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected genre names %v, got %v", expected, info.GenreNames)
+	}
+	if strings.Join(info.Genres, "|") != "sf_fantasy|det_classic|my_own_genre" {
+		t.Errorf("Expected the genre codes to be kept, got %v", info.Genres)
+	}
+}
+
+func TestGenres_EPUBRoundTrip(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(genresTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+	var epub bytes.Buffer
+	if err := converter.WriteEPUB(fb2, &epub, converter.DefaultOptions()); err != nil {
+		t.Fatalf("WriteEPUB() error = %v, want nil", err)
+	}
+
+	b, err := converter.ParseEPUB(bytes.NewReader(epub.Bytes()), int64(epub.Len()))
+	if err != nil {
+		t.Fatalf("ParseEPUB() error = %v, want nil", err)
+	}
+	// Subjects named after known genres are read back as their codes
+	if got := strings.Join(b.Metadata.Genres, "|"); got != "sf_fantasy|det_classic|my_own_genre" {
+		t.Errorf("Expected the genre codes back, got %v", b.Metadata.Genres)
+	}
+}
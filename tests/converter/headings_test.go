@@ -0,0 +1,91 @@
+package converter_test
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const headingsTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Headings</book-title>
+    </title-info>
+  </description>
+  <body>
+    <title><p>The Book</p></title>
+    <section>
+      <title><p>Part One</p></title>
+      <section>
+        <section>
+          <title><p>Chapter One</p></title>
+          <p>Text</p>
+          <poem>
+            <title><p>Song</p></title>
+            <stanza><title><p>Verse</p></title><v>La</v></stanza>
+          </poem>
+        </section>
+      </section>
+    </section>
+    <section>
+      <title><p>Part Two</p></title>
+      <p>Text</p>
+    </section>
+  </body>
+</FictionBook>`
+
+var headingPattern = regexp.MustCompile(`<h([1-6])[ >]`)
+
+func TestHeadings_FollowOutline(t *testing.T) {
+	content := generateTestEPUB(t, headingsTestFB2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	for _, want := range []string{
+		`<h1>The Book</h1>`,
+		`<h2 id="section-0">Part One</h2>`,
+		// The untitled section in between adds no level, as in the table of contents
+		`<h3 id="section-0-sub-0-sub-0">Chapter One</h3>`,
+		`<h4 class="poem-title">Song</h4>`,
+		`<h5 class="stanza-title">Verse</h5>`,
+		`<h2 id="section-1">Part Two</h2>`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %s in content.xhtml:\n%s", want, content)
+		}
+	}
+}
+
+func TestHeadings_NoSkippedLevels(t *testing.T) {
+	inputs := map[string]string{
+		"headings": headingsTestFB2,
+		"poem":     poemTestFB2,
+	}
+	for name, input := range inputs {
+		content := generateTestEPUB(t, input, converter.DefaultOptions())["OEBPS/content.xhtml"]
+		previous := 0
+		for _, match := range headingPattern.FindAllStringSubmatch(content, -1) {
+			level, _ := strconv.Atoi(match[1])
+			if level > previous+1 {
+				t.Errorf("%s: h%d follows h%d", name, level, previous)
+			}
+			previous = level
+		}
+	}
+}
+
+func TestHeadings_ChaptersStartNewPages(t *testing.T) {
+	content := generateTestEPUB(t, headingsTestFB2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	if !strings.Contains(content, ".chapter { page-break-before: always; break-before: page; }") {
+		t.Error("Expected chapters to be styled to start on a new page")
+	}
+	if got := strings.Count(content, `<div class="chapter">`); got != 2 {
+		t.Errorf("Expected each top-level section wrapped as a chapter, got %d:\n%s", got, content)
+	}
+	if !strings.Contains(content, "<div class=\"chapter\">\n<h2 id=\"section-1\">Part Two</h2>") {
+		t.Errorf("Expected the chapter break before the heading:\n%s", content)
+	}
+}
@@ -0,0 +1,123 @@
+package converter_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// generateTestEPUB converts inline FB2 content and returns the EPUB entries keyed by name
+func generateTestEPUB(t *testing.T, fb2Content string, opts *converter.Options) map[string]string {
+	t.Helper()
+
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(fb2Content))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "test.epub")
+	if err := converter.GenerateEPUBWithOptions(fb2, outputPath, opts); err != nil {
+		t.Fatalf("GenerateEPUBWithOptions() error = %v, want nil", err)
+	}
+
+	reader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open EPUB: %v", err)
+	}
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil {
+			t.Logf("Error closing ZIP: %v", closeErr)
+		}
+	}()
+
+	entries := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.Name, err)
+		}
+		entries[file.Name] = string(data)
+	}
+	return entries
+}
+
+// convertTestEPUB converts an FB2 document with converter.Converter, which
+// honors the parsing options, and returns the EPUB entries keyed by name
+func convertTestEPUB(t *testing.T, r io.Reader, opts *converter.Options) map[string]string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := converter.New(opts).Convert(r, &buf); err != nil {
+		t.Fatalf("Convert() error = %v, want nil", err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Output is not a valid ZIP archive: %v", err)
+	}
+
+	entries := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file.Name, err)
+		}
+		entries[file.Name] = string(data)
+	}
+	return entries
+}
+
+// writeFile writes test content to path
+func writeFile(path, content string) error {
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// testBook holds the parts of a small FB2 document that tests vary
+type testBook struct {
+	Title     string // book-title, left out when empty
+	Lang      string // lang, left out when empty
+	TitleInfo string // further title-info elements, such as a coverpage
+	Section   string // inner XML of the only main body section
+	Notes     string // inner XML of a notes body, left out when empty
+	Binaries  string // binary elements
+}
+
+// fb2 returns the book as an FB2 document
+func (b testBook) fb2() string {
+	var doc strings.Builder
+	doc.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description><title-info>`)
+	if b.Title != "" {
+		doc.WriteString("<book-title>" + b.Title + "</book-title>")
+	}
+	if b.Lang != "" {
+		doc.WriteString("<lang>" + b.Lang + "</lang>")
+	}
+	doc.WriteString(b.TitleInfo + "</title-info></description>\n  <body><section>" + b.Section + "</section></body>\n")
+	if b.Notes != "" {
+		doc.WriteString(`  <body name="notes">` + b.Notes + "</body>\n")
+	}
+	doc.WriteString(b.Binaries + "\n</FictionBook>")
+	return doc.String()
+}
+
+// chapter returns the inner XML of a section with a heading and one paragraph
+func chapter(heading, text string) string {
+	return "<title><p>" + heading + "</p></title><p>" + text + "</p>"
+}
@@ -0,0 +1,54 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const softHyphen = "\u00AD"
+
+func TestHyphenation_Russian(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Hyphenate = true
+	fb2 := testBook{Title: "Hyphenation", Lang: "ru", Section: chapter("Переворачивание", "Переворачивание страницы &amp; кот")}.fb2()
+	content := generateTestEPUB(t, fb2, opts)["OEBPS/content.xhtml"]
+
+	if !strings.Contains(content, "Пе"+softHyphen+"ре"+softHyphen+"во"+softHyphen+"ра") {
+		t.Errorf("Russian paragraph text should be hyphenated, got: %s", content)
+	}
+	if !strings.Contains(content, ">Переворачивание</h1>") {
+		t.Error("Headings should not be hyphenated")
+	}
+	if !strings.Contains(content, "&amp; кот") {
+		t.Error("Entities and short words should be left intact")
+	}
+}
+
+func TestHyphenation_English(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Hyphenate = true
+	fb2 := testBook{Title: "Hyphenation", Lang: "en-US", Section: chapter("Title", "hyphenation of a little capable NASA")}.fb2()
+	content := generateTestEPUB(t, fb2, opts)["OEBPS/content.xhtml"]
+
+	for _, expected := range []string{
+		"hy" + softHyphen + "phe" + softHyphen + "na" + softHyphen + "tion",
+		"lit" + softHyphen + "tle",
+		"ca" + softHyphen + "pa" + softHyphen + "ble",
+		" NASA",
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("content.xhtml should contain %q", expected)
+		}
+	}
+}
+
+func TestHyphenation_DisabledByDefault(t *testing.T) {
+	fb2 := testBook{Title: "Hyphenation", Lang: "ru", Section: chapter("Заголовок", "Переворачивание страницы")}.fb2()
+	content := generateTestEPUB(t, fb2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	if strings.Contains(content, softHyphen) {
+		t.Error("Soft hyphens should not be inserted unless hyphenation is enabled")
+	}
+}
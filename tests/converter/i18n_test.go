@@ -0,0 +1,57 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// i18nBook has an untitled chapter and a notes body, whose labels are localized
+var i18nBook = testBook{Section: chapter("", "Text"), Notes: `<section id="n1"><p>Note</p></section>`}
+
+func TestLabels_Russian(t *testing.T) {
+	book := i18nBook
+	book.Lang = "ru"
+	files := generateTestEPUB(t, book.fb2(), converter.DefaultOptions())
+
+	expectations := map[string][]string{
+		"OEBPS/nav.xhtml": {
+			"<h1>Оглавление</h1>",
+			`<a href="cover.xhtml">Обложка</a>`,
+			`<a epub:type="endnotes" href="backmatter-1.xhtml">Примечания</a>`,
+		},
+		"OEBPS/toc.ncx":            {"<text>Обложка</text>", "<text>Текст</text>", "<text>Без названия</text>"},
+		"OEBPS/cover.xhtml":        {"<h1>Без названия</h1>", "<h2>Неизвестный автор</h2>"},
+		"OEBPS/content.opf":        {"<dc:title>Без названия</dc:title>"},
+		"OEBPS/backmatter-1.xhtml": {">Примечания</h1>"},
+	}
+	for file, expected := range expectations {
+		for _, text := range expected {
+			if !strings.Contains(files[file], text) {
+				t.Errorf("%s should contain %q", file, text)
+			}
+		}
+	}
+}
+
+func TestLabels_LanguageVariants(t *testing.T) {
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{"uk", "<h1>Зміст</h1>"},
+		{"de-AT", "<h1>Inhaltsverzeichnis</h1>"},
+		{"fr", "<h1>Table des matières</h1>"},
+		{"", "<h1>Table of Contents</h1>"},
+		{"ja", "<h1>Table of Contents</h1>"},
+	}
+	for _, tt := range tests {
+		book := i18nBook
+		book.Lang = tt.lang
+		nav := generateTestEPUB(t, book.fb2(), converter.DefaultOptions())["OEBPS/nav.xhtml"]
+		if !strings.Contains(nav, tt.expected) {
+			t.Errorf("lang %q: nav.xhtml should contain %q", tt.lang, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const illustrationTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Illustrated</book-title>
+      <coverpage><image l:href="#cover"/></coverpage>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Chapter 1</p></title>
+      <p>Before the map.</p>
+      <image l:href="#map" alt="A map of the island"/>
+      <p>After the map.</p>
+    </section>
+  </body>
+  <binary id="cover" content-type="image/png">iVBORw0KGgo=</binary>
+  <binary id="map" content-type="image/png">iVBORw0KGgoAAAA=</binary>
+</FictionBook>`
+
+func TestSectionImage_FullPageIllustration(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.PruneImages = true
+	entries := generateTestEPUB(t, illustrationTestFB2, opts)
+	content := entries["OEBPS/content.xhtml"]
+
+	illustration := `<div class="illustration"><img src="images/map.png" alt="A map of the island"/></div>`
+	before := strings.Index(content, "Before the map.")
+	at := strings.Index(content, illustration)
+	after := strings.Index(content, "After the map.")
+	if at < 0 {
+		t.Fatalf("Expected the section image as an illustration:\n%s", content)
+	}
+	if before > at || at > after {
+		t.Errorf("Expected the illustration between the paragraphs around it:\n%s", content)
+	}
+	if !strings.Contains(content, ".illustration {") || !strings.Contains(content, "page-break-before: always") {
+		t.Error("Expected illustrations to be styled as pages of their own")
+	}
+	if _, ok := entries["OEBPS/images/map.png"]; !ok {
+		t.Error("An image referenced only by a section should not be pruned")
+	}
+}
+
+func TestSectionImage_RoundTrip(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(illustrationTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+	section := fb2.MainBody().Section[0]
+	if len(section.Image) != 1 || section.Image[0].Href != "#map" {
+		t.Fatalf("Expected the section image to be parsed, got %+v", section.Image)
+	}
+
+	var output bytes.Buffer
+	if err := converter.WriteFB2(fb2, &output); err != nil {
+		t.Fatalf("WriteFB2() error = %v, want nil", err)
+	}
+	written := output.String()
+	before := strings.Index(written, "Before the map.")
+	at := strings.Index(written, `href="#map"`)
+	if at < 0 || at < before || at > strings.Index(written, "After the map.") {
+		t.Errorf("Expected the section image to be written back in place:\n%s", written)
+	}
+}
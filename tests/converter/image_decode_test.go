@@ -0,0 +1,82 @@
+package converter_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestCollectImages_ManyImages(t *testing.T) {
+	// 100 distinct PNG images, a copy of the first one and invalid binaries after every tenth image
+	var section, binaries strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&section, `<p><image l:href="#img%d"/></p>`, i)
+		data := append([]byte("\x89PNG\r\n\x1a\n"), []byte(fmt.Sprintf("image %d", i))...)
+		fmt.Fprintf(&binaries, `<binary id="img%d" content-type="image/png">%s</binary>`, i, base64.StdEncoding.EncodeToString(data))
+		if i%10 == 9 {
+			fmt.Fprintf(&binaries, `<binary id="bad%d" content-type="image/png">not base64!</binary>`, i)
+		}
+	}
+	section.WriteString(`<p><image l:href="#copy"/></p>`)
+	first := base64.StdEncoding.EncodeToString(append([]byte("\x89PNG\r\n\x1a\n"), []byte("image 0")...))
+	fmt.Fprintf(&binaries, `<binary id="copy" content-type="image/png">%s</binary>`, first)
+	book := testBook{Title: "Gallery", Section: section.String(), Binaries: binaries.String()}
+
+	var warnings []string
+	opts := converter.DefaultOptions()
+	opts.OnWarning = func(d converter.Diagnostic) { warnings = append(warnings, d.Message) }
+	entries := generateTestEPUB(t, book.fb2(), opts)
+
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("OEBPS/images/img%d.png", i)
+		if want := fmt.Sprintf("image %d", i); !strings.HasSuffix(entries[name], want) {
+			t.Errorf("Expected %s to hold %q", name, want)
+		}
+	}
+	if _, exists := entries["OEBPS/images/copy.png"]; exists {
+		t.Error("Expected the copy of img0 to be stored once")
+	}
+	if content := entries["OEBPS/content.xhtml"]; strings.Count(content, `src="images/img0.png"`) != 2 {
+		t.Error("Expected the copy to refer to img0")
+	}
+
+	// Invalid binaries are reported in document order, whatever the decoding order
+	var skipped []string
+	for _, warning := range warnings {
+		if strings.HasPrefix(warning, "skipped binary") {
+			skipped = append(skipped, warning)
+		}
+	}
+	if len(skipped) != 10 || !strings.Contains(skipped[0], `"bad9"`) || !strings.Contains(skipped[9], `"bad99"`) {
+		t.Errorf("Expected the invalid binaries in order, got %v", skipped)
+	}
+}
+
+func TestCollectImages_LargeImageWrittenIntact(t *testing.T) {
+	// Larger than any buffer on the way, with line breaks as FB2 editors write them
+	data := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 3<<20)...)
+	for i := 8; i < len(data); i++ {
+		data[i] = byte(i * 31)
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var wrapped strings.Builder
+	for len(encoded) > 76 {
+		wrapped.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	wrapped.WriteString(encoded)
+
+	fb2 := `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description><title-info><book-title>Poster</book-title></title-info></description>
+  <body><section><p><image l:href="#poster"/></p></section></body>
+  <binary id="poster" content-type="image/png">` + wrapped.String() + `</binary>
+</FictionBook>`
+
+	if stored := generateTestEPUB(t, fb2, converter.DefaultOptions())["OEBPS/images/poster.png"]; stored != string(data) {
+		t.Errorf("Expected the image to be stored intact, got %d of %d bytes", len(stored), len(data))
+	}
+}
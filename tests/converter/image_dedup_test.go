@@ -0,0 +1,61 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const duplicateImagesFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Duplicates</book-title>
+      <coverpage><image l:href="#copy.png"/></coverpage>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p><image l:href="#original.png"/></p>
+      <p><image l:href="#copy.png"/></p>
+      <p><image l:href="#other.png"/></p>
+    </section>
+  </body>
+  <binary id="original.png" content-type="image/png">iVBORw0KGgo=</binary>
+  <binary id="copy.png" content-type="image/png">iVBORw0KGgo=</binary>
+  <binary id="other.png" content-type="image/png">iVBORw0KGgoAAAA=</binary>
+</FictionBook>`
+
+func TestImageDeduplication(t *testing.T) {
+	files := generateTestEPUB(t, duplicateImagesFB2, converter.DefaultOptions())
+
+	if _, exists := files["OEBPS/images/original.png.png"]; !exists {
+		t.Fatal("First copy of a duplicated image should be stored")
+	}
+	if _, exists := files["OEBPS/images/copy.png.png"]; exists {
+		t.Error("Duplicate image data should not be stored twice")
+	}
+	if _, exists := files["OEBPS/images/other.png.png"]; !exists {
+		t.Error("Distinct images should be stored")
+	}
+
+	content := files["OEBPS/content.xhtml"]
+	if strings.Count(content, `<img src="images/original.png.png"`) != 2 {
+		t.Errorf("Both references should point to the stored copy, got: %s", content)
+	}
+
+	opf := files["OEBPS/content.opf"]
+	if strings.Contains(opf, `id="copy.png"`) {
+		t.Error("Duplicate image should not get its own manifest item")
+	}
+	if !strings.Contains(opf, `<item id="original.png" href="images/original.png.png" media-type="image/png" properties="cover-image"/>`) {
+		t.Errorf("Cover referencing a duplicate should resolve to the stored copy, got: %s", opf)
+	}
+	if !strings.Contains(opf, `<meta name="cover" content="original.png"/>`) {
+		t.Error("Cover meta should reference the stored copy")
+	}
+	if !strings.Contains(files["OEBPS/cover.xhtml"], `<img src="images/original.png.png"`) {
+		t.Error("Cover page should display the stored copy")
+	}
+}
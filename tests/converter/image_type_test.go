@@ -0,0 +1,43 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const imageTypeTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Images</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>A PNG <image l:href="#png"/></p>
+      <p>Not an image <image l:href="#text"/></p>
+    </section>
+  </body>
+  <binary id="png" content-type="image/jpeg">iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==</binary>
+  <binary id="text" content-type="image/jpeg">SGVsbG8sIHdvcmxkIQ==</binary>
+</FictionBook>`
+
+func TestImageType_CorrectsDeclaredType(t *testing.T) {
+	var warnings []string
+	opts := converter.DefaultOptions()
+	opts.OnWarning = func(d converter.Diagnostic) { warnings = append(warnings, d.Message) }
+	entries := generateTestEPUB(t, imageTypeTestFB2, opts)
+
+	if _, ok := entries["OEBPS/images/png.png"]; !ok {
+		t.Error("Expected the PNG declared as JPEG to be stored as images/png.png")
+	}
+	if !strings.Contains(entries["OEBPS/content.opf"], `href="images/png.png" media-type="image/png"`) {
+		t.Errorf("Expected the sniffed media type in the manifest:\n%s", entries["OEBPS/content.opf"])
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `binary "text"`) {
+		t.Errorf("Expected one warning about the text binary, got %v", warnings)
+	}
+}
@@ -0,0 +1,48 @@
+package converter_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestKepub_KoboSpans(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Kepub = true
+	fb2 := testBook{Lang: "en", Section: chapter("Opening", "It began. Did it end?! “Never.” Not yet")}.fb2()
+	content := generateTestEPUB(t, fb2, opts)["OEBPS/content.xhtml"]
+
+	for _, expected := range []string{
+		`<div id="book-columns"><div id="book-inner">`,
+		`<span class="koboSpan" id="kobo.1.1">Opening</span>`,
+		`<span class="koboSpan" id="kobo.2.1">It began.</span> <span class="koboSpan" id="kobo.2.2">Did it end?!</span>`,
+		`<span class="koboSpan" id="kobo.2.3">“Never.”</span> <span class="koboSpan" id="kobo.2.4">Not yet</span>`,
+		`</div></div></body>`,
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("content.xhtml should contain %q, got: %s", expected, content)
+		}
+	}
+
+	// The spans must keep the document well-formed
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("KEPUB content is not well-formed: %v", err)
+		}
+	}
+}
+
+func TestKepub_DisabledByDefault(t *testing.T) {
+	fb2 := testBook{Lang: "en", Section: chapter("Opening", "It began.")}.fb2()
+	content := generateTestEPUB(t, fb2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	if strings.Contains(content, "koboSpan") || strings.Contains(content, "book-columns") {
+		t.Errorf("Kobo markup should only be added for KEPUB output, got: %s", content)
+	}
+}
@@ -0,0 +1,49 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestNav_Landmarks(t *testing.T) {
+	files := generateTestEPUB(t, notesTestFB2, converter.DefaultOptions())
+	nav := files["OEBPS/nav.xhtml"]
+
+	if !strings.Contains(nav, `<nav epub:type="landmarks" id="landmarks" hidden="hidden">`) {
+		t.Fatalf("nav.xhtml should contain a landmarks nav, got: %s", nav)
+	}
+	for _, expected := range []string{
+		`<a epub:type="cover" href="cover.xhtml">Cover</a>`,
+		`<a epub:type="toc" href="nav.xhtml#toc">Table of Contents</a>`,
+		`<a epub:type="bodymatter" href="content.xhtml">Content</a>`,
+		`<a epub:type="endnotes" href="backmatter-1.xhtml">Notes</a>`,
+	} {
+		if !strings.Contains(nav, expected) {
+			t.Errorf("landmarks should contain %q", expected)
+		}
+	}
+}
+
+func TestNav_SemanticBodies(t *testing.T) {
+	files := generateTestEPUB(t, notesTestFB2, converter.DefaultOptions())
+
+	for file, epubType := range map[string]string{
+		"OEBPS/cover.xhtml":        "cover",
+		"OEBPS/content.xhtml":      "bodymatter",
+		"OEBPS/backmatter-1.xhtml": "endnotes",
+	} {
+		if !strings.Contains(files[file], `<body epub:type="`+epubType+`">`) {
+			t.Errorf("%s body should be tagged as %s", file, epubType)
+		}
+	}
+}
+
+func TestNav_LandmarksWithoutNotes(t *testing.T) {
+	nav := generateTestEPUB(t, tocTestFB2, converter.DefaultOptions())["OEBPS/nav.xhtml"]
+
+	if strings.Contains(nav, `epub:type="endnotes"`) {
+		t.Error("Books without notes should not list a notes landmark")
+	}
+}
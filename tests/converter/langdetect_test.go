@@ -0,0 +1,84 @@
+package converter_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// bookWithoutLanguage returns an FB2 document without a lang element whose
+// text repeats paragraph
+func bookWithoutLanguage(paragraph string, repeat int) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <author><nickname>anonymous</nickname></author>
+      <book-title>Book</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>%s</section>
+  </body>
+</FictionBook>`, strings.Repeat("<p>"+paragraph+"</p>", repeat))
+}
+
+func TestLanguageDetection(t *testing.T) {
+	tests := []struct {
+		name      string
+		paragraph string
+		expected  string
+	}{
+		{"russian", "Он вышел из дома и долго смотрел на реку, которая блестела под солнцем.", "ru"},
+		{"ukrainian", "Він вийшов з дому і довго дивився на річку, яка сяяла під сонцем. Їй було добре.", "uk"},
+		{"english", "He left the house and looked at the river for a long time, and it was bright in the sun.", "en"},
+		{"german", "Er verließ das Haus und sah lange auf den Fluss, der in der Sonne glänzte, und es war nicht kalt.", "de"},
+		{"french", "Il est sorti de la maison et il a regardé la rivière qui brillait dans le soleil, et elle était belle.", "fr"},
+		{"greek", "Βγήκε από το σπίτι και κοίταξε το ποτάμι για πολύ ώρα.", "el"},
+		// Too little text to tell falls back to English
+		{"too short", "Ok.", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := generateTestEPUB(t, bookWithoutLanguage(tt.paragraph, 10), converter.DefaultOptions())
+
+			if want := "<dc:language>" + tt.expected + "</dc:language>"; !strings.Contains(entries["OEBPS/content.opf"], want) {
+				t.Errorf("content.opf should contain %q", want)
+			}
+			if want := `xml:lang="` + tt.expected + `"`; !strings.Contains(entries["OEBPS/content.xhtml"], want) {
+				t.Errorf("content.xhtml should declare %q", want)
+			}
+		})
+	}
+}
+
+func TestLanguageDetection_DeclaredLanguageWins(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Metadata.Language = "be"
+	entries := generateTestEPUB(t, bookWithoutLanguage("Он вышел из дома и долго смотрел на реку.", 10), opts)
+
+	if !strings.Contains(entries["OEBPS/content.opf"], "<dc:language>be</dc:language>") {
+		t.Error("The language override should be used instead of the detected language")
+	}
+}
+
+func TestLanguageDetection_Inspect(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(
+		bookWithoutLanguage("Он вышел из дома и долго смотрел на реку, которая блестела под солнцем.", 10)))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	info := converter.Inspect(fb2)
+	if info.Language != "ru" || !info.LanguageDetected {
+		t.Errorf("Expected the detected language ru, got %q (detected: %v)", info.Language, info.LanguageDetected)
+	}
+
+	// Declared languages are reported as they are
+	fb2.Description.TitleInfo.Lang = "en"
+	if info := converter.Inspect(fb2); info.Language != "en" || info.LanguageDetected {
+		t.Errorf("Expected the declared language en, got %q (detected: %v)", info.Language, info.LanguageDetected)
+	}
+}
@@ -0,0 +1,164 @@
+package converter_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const libraryTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Library Book</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Chapter 1</p></title>
+      <p>Some text.</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestConverter_ConvertToWriter(t *testing.T) {
+	var stages []converter.Stage
+	opts := converter.DefaultOptions()
+	opts.OnProgress = func(stage converter.Stage, percent int) {
+		if percent < 0 || percent > 100 {
+			t.Errorf("Progress percent out of range: %d", percent)
+		}
+		stages = append(stages, stage)
+	}
+
+	var buf bytes.Buffer
+	if err := converter.New(opts).Convert(strings.NewReader(libraryTestFB2), &buf); err != nil {
+		t.Fatalf("Convert() error = %v, want nil", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Output is not a valid ZIP archive: %v", err)
+	}
+	if len(reader.File) == 0 || reader.File[0].Name != "mimetype" {
+		t.Error("First entry of the EPUB should be mimetype")
+	}
+
+	if len(stages) == 0 || stages[0] != converter.StageParsing {
+		t.Errorf("First reported stage should be %q, got %v", converter.StageParsing, stages)
+	}
+	if stages[len(stages)-1] != converter.StageDone {
+		t.Errorf("Last reported stage should be %q, got %v", converter.StageDone, stages)
+	}
+}
+
+func TestConverter_ParseError(t *testing.T) {
+	var buf bytes.Buffer
+	err := converter.New(nil).Convert(strings.NewReader("<FictionBook><body>"), &buf)
+	if err == nil {
+		t.Fatal("Convert() should fail on malformed input")
+	}
+
+	var parseErr *converter.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("Expected *converter.ParseError, got %T", err)
+	}
+}
+
+func TestConverter_ConvertFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.fb2")
+	outputPath := filepath.Join(tmpDir, "out", "book.epub")
+
+	if err := writeFile(inputPath, libraryTestFB2); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := converter.New(nil).ConvertFile(inputPath, outputPath); err != nil {
+		t.Fatalf("ConvertFile() error = %v, want nil", err)
+	}
+
+	reader, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open EPUB: %v", err)
+	}
+	_ = reader.Close()
+}
+
+func TestConverter_ConvertFileWithStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.fb2")
+	outputPath := filepath.Join(tmpDir, "book.epub")
+
+	if err := writeFile(inputPath, libraryTestFB2); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	stats, err := converter.New(nil).ConvertFileWithStats(inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("ConvertFileWithStats() error = %v, want nil", err)
+	}
+
+	if stats.InputSize != int64(len(libraryTestFB2)) {
+		t.Errorf("InputSize = %d, want %d", stats.InputSize, len(libraryTestFB2))
+	}
+	if stats.OutputSize <= 0 {
+		t.Error("OutputSize should be the size of the written EPUB")
+	}
+	if stats.ChapterCount != 1 || stats.ImageCount != 0 {
+		t.Errorf("Expected 1 chapter and 0 images, got %d and %d", stats.ChapterCount, stats.ImageCount)
+	}
+}
+
+func TestConverter_ConvertFileWithStats_ParseError(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.fb2")
+
+	if err := writeFile(inputPath, "<FictionBook><body>"); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	stats, err := converter.New(nil).ConvertFileWithStats(inputPath, filepath.Join(tmpDir, "book.epub"))
+	if err == nil {
+		t.Fatal("ConvertFileWithStats() should fail on malformed input")
+	}
+	if stats == nil || stats.InputSize == 0 || stats.OutputSize != 0 {
+		t.Errorf("Stats of the completed stages should be returned on failure, got %+v", stats)
+	}
+}
+
+func TestConverter_ConvertContext(t *testing.T) {
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+
+	err := converter.New(nil).ConvertContext(expired, strings.NewReader(libraryTestFB2), &bytes.Buffer{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ConvertContext() with an expired context error = %v, want DeadlineExceeded", err)
+	}
+	var parseErr *converter.ParseError
+	if errors.As(err, &parseErr) {
+		t.Error("An aborted conversion should not be reported as a parse error")
+	}
+
+	// Cancel once parsing is done, so generation is what gets aborted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := converter.DefaultOptions()
+	opts.OnProgress = func(stage converter.Stage, _ int) {
+		if stage == converter.StageImages {
+			cancel()
+		}
+	}
+
+	err = converter.New(opts).ConvertContext(ctx, strings.NewReader(libraryTestFB2), &bytes.Buffer{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ConvertContext() cancelled during generation error = %v, want Canceled", err)
+	}
+}
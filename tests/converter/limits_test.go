@@ -0,0 +1,83 @@
+package converter_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func convertWithLimits(input string, limits converter.Limits) error {
+	opts := converter.DefaultOptions()
+	opts.Limits = limits
+	return converter.New(opts).Convert(strings.NewReader(input), &bytes.Buffer{})
+}
+
+func TestConverter_Limits(t *testing.T) {
+	deep := strings.Replace(validTestFB2, "<p>Some <emphasis>text</emphasis>.</p>",
+		strings.Repeat("<section>", 50)+"<p>Deep</p>"+strings.Repeat("</section>", 50), 1)
+
+	tests := []struct {
+		name   string
+		input  string
+		limits converter.Limits
+		limit  string // Expected exceeded limit, empty when the conversion should succeed
+	}{
+		{"within limits", validTestFB2, converter.Limits{MaxDepth: 10, MaxBinarySize: 1024, MaxSectionDepth: 1, MaxSections: 1, MaxParagraphs: 2}, ""},
+		{"unlimited", deep, converter.Limits{}, ""},
+		{"too deep", deep, converter.Limits{MaxDepth: 20}, "nesting depth"},
+		{"sections too deep", deep, converter.Limits{MaxSectionDepth: 10}, "section depth"},
+		{"too many sections", deep, converter.Limits{MaxSections: 50}, "section count"},
+		{"too many paragraphs", validTestFB2, converter.Limits{MaxParagraphs: 1}, "paragraph count"},
+		// iVBORw0KGgo= decodes to 8 bytes
+		{"binaries too large", validTestFB2, converter.Limits{MaxBinarySize: 4}, "binary size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := convertWithLimits(tt.input, tt.limits)
+			if tt.limit == "" {
+				if err != nil {
+					t.Fatalf("Convert() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var limitErr *converter.LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("Convert() error = %v, want *LimitError", err)
+			}
+			if limitErr.Limit != tt.limit {
+				t.Errorf("LimitError.Limit = %q, want %q", limitErr.Limit, tt.limit)
+			}
+			var parseErr *converter.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Errorf("Convert() error = %v, want it wrapped in *ParseError", err)
+			}
+		})
+	}
+}
+
+func TestConverter_RejectsEntityDeclarations(t *testing.T) {
+	input := strings.Replace(validTestFB2, "<FictionBook ",
+		"<!DOCTYPE FictionBook [<!ENTITY lol \"lol\"><!ENTITY lol2 \"&lol;&lol;&lol;\">]>\n<FictionBook ", 1)
+
+	err := convertWithLimits(input, converter.Limits{})
+	if err == nil || !strings.Contains(err.Error(), "entity declarations are not allowed") {
+		t.Errorf("Convert() error = %v, want entity declarations rejected", err)
+	}
+}
+
+func TestConverter_LenientDoesNotRepairLimitErrors(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Lenient = true
+	opts.Limits.MaxBinarySize = 4
+
+	err := converter.New(opts).Convert(strings.NewReader(validTestFB2), &bytes.Buffer{})
+	var limitErr *converter.LimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("Convert() in lenient mode error = %v, want *LimitError", err)
+	}
+}
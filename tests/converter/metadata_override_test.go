@@ -0,0 +1,168 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const overrideTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Wrong Title</book-title>
+      <author>
+        <first-name>Wrong</first-name>
+        <last-name>Author</last-name>
+      </author>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>Text</p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestMetadataOverrides_OPF(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Metadata = converter.MetadataOverrides{
+		Title:       "Right Title",
+		Author:      "Lev Nikolayevich Tolstoy, Aylmer Maude",
+		Language:    "ru",
+		Series:      "Collected Works",
+		SeriesIndex: "3",
+	}
+
+	entries := generateTestEPUB(t, overrideTestFB2, opts)
+	opf := entries["OEBPS/content.opf"]
+
+	checks := []string{
+		"<dc:title>Right Title</dc:title>",
+		"<dc:creator>Lev Nikolayevich Tolstoy, Aylmer Maude</dc:creator>",
+		"<dc:language>ru</dc:language>",
+		`<meta property="belongs-to-collection" id="series">Collected Works</meta>`,
+		`<meta refines="#series" property="group-position">3</meta>`,
+		`<meta name="calibre:series" content="Collected Works"/>`,
+	}
+	for _, check := range checks {
+		if !strings.Contains(opf, check) {
+			t.Errorf("content.opf should contain %q", check)
+		}
+	}
+
+	if strings.Contains(opf, "Wrong") {
+		t.Error("content.opf should not contain overridden metadata")
+	}
+
+	if !strings.Contains(entries["OEBPS/cover.xhtml"], "Right Title") {
+		t.Error("Cover page should use the overridden title")
+	}
+}
+
+func TestMetadataOverrides_CoverImage(t *testing.T) {
+	// Minimal PNG signature is enough for the cover to be packaged
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+	opts := converter.DefaultOptions()
+	opts.Metadata.CoverImage = png
+	opts.Metadata.CoverContentType = "image/png"
+
+	entries := generateTestEPUB(t, overrideTestFB2, opts)
+
+	if _, ok := entries["OEBPS/images/cover-override.png"]; !ok {
+		t.Fatal("Cover image should be packaged as images/cover-override.png")
+	}
+	if !strings.Contains(entries["OEBPS/content.opf"], `<meta name="cover" content="cover-override"/>`) {
+		t.Error("content.opf should reference the cover image")
+	}
+	if !strings.Contains(entries["OEBPS/cover.xhtml"], `<img src="images/cover-override.png"`) {
+		t.Error("Cover page should display the cover image")
+	}
+}
+
+func TestMetadataOverrides_EmptyKeepsOriginal(t *testing.T) {
+	entries := generateTestEPUB(t, overrideTestFB2, converter.DefaultOptions())
+	opf := entries["OEBPS/content.opf"]
+
+	if !strings.Contains(opf, "<dc:title>Wrong Title</dc:title>") {
+		t.Error("content.opf should keep the FB2 title when no override is given")
+	}
+	if strings.Contains(opf, "belongs-to-collection") {
+		t.Error("content.opf should not contain series metadata without a series")
+	}
+}
+
+const sequenceTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <genre>sf</genre>
+      <author><first-name>Isaac</first-name><last-name>Asimov</last-name></author>
+      <book-title>Foundation and Empire</book-title>
+      <coverpage><image l:href="#cover.jpg"/></coverpage>
+      <lang>en</lang>
+      <sequence name="Foundation" number="2">
+        <sequence name="Galactic Empire" number="5"/>
+      </sequence>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Part I</p></title>
+      <section><title><p>Chapter 1</p></title><p>Text</p></section>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestSequence_SeriesMetadata(t *testing.T) {
+	entries := generateTestEPUB(t, sequenceTestFB2, converter.DefaultOptions())
+	opf := entries["OEBPS/content.opf"]
+
+	for _, check := range []string{
+		`<meta property="belongs-to-collection" id="series">Foundation</meta>`,
+		`<meta refines="#series" property="group-position">2</meta>`,
+		`<meta name="calibre:series_index" content="2"/>`,
+	} {
+		if !strings.Contains(opf, check) {
+			t.Errorf("content.opf should contain %q", check)
+		}
+	}
+
+	opts := converter.DefaultOptions()
+	opts.Metadata.SeriesIndex = "7"
+	opf = generateTestEPUB(t, sequenceTestFB2, opts)["OEBPS/content.opf"]
+	if !strings.Contains(opf, `<meta refines="#series" property="group-position">7</meta>`) {
+		t.Error("series_index override should renumber the FB2 sequence")
+	}
+
+	opts.Metadata.Series = "Robot"
+	opf = generateTestEPUB(t, sequenceTestFB2, opts)["OEBPS/content.opf"]
+	if !strings.Contains(opf, `id="series">Robot</meta>`) || strings.Contains(opf, "Foundation</meta>") {
+		t.Error("series override should replace the FB2 sequence")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(sequenceTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	info := converter.Inspect(fb2)
+	if info.Title != "Foundation and Empire" || info.Language != "en" || !info.HasCover {
+		t.Errorf("Unexpected book info: %+v", info)
+	}
+	if len(info.Authors) != 1 || info.Authors[0] != "Isaac Asimov" {
+		t.Errorf("Authors = %v, want [Isaac Asimov]", info.Authors)
+	}
+	wantSeries := []converter.Series{{Name: "Foundation", Number: "2"}, {Name: "Galactic Empire", Number: "5"}}
+	if len(info.Series) != len(wantSeries) || info.Series[0] != wantSeries[0] || info.Series[1] != wantSeries[1] {
+		t.Errorf("Series = %v, want %v", info.Series, wantSeries)
+	}
+	if info.ChapterCount != 2 {
+		t.Errorf("ChapterCount = %d, want 2", info.ChapterCount)
+	}
+}
@@ -0,0 +1,47 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const missingImageTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Missing</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>Described <image l:href="#gone" alt="A map"/></p>
+      <p>Undescribed <image l:href="#lost"/></p>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestMissingImage_Placeholder(t *testing.T) {
+	var warnings []string
+	opts := converter.DefaultOptions()
+	opts.OnWarning = func(d converter.Diagnostic) { warnings = append(warnings, d.Message) }
+	content := generateTestEPUB(t, missingImageTestFB2, opts)["OEBPS/content.xhtml"]
+
+	if strings.Contains(content, "<img") {
+		t.Errorf("Expected no img elements for missing binaries:\n%s", content)
+	}
+	for _, want := range []string{
+		`<span class="missing-image">[Image: A map]</span>`,
+		`<span class="missing-image">[Image unavailable]</span>`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %s in content.xhtml:\n%s", want, content)
+		}
+	}
+
+	want := []string{`image refers to missing binary "gone"`, `image refers to missing binary "lost"`}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected warnings %v, got %v", want, warnings)
+	}
+}
@@ -0,0 +1,43 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestContentOPF_ManifestProperties(t *testing.T) {
+	book := coverBook
+	book.TitleInfo = `<coverpage><image l:href="#big.png"/></coverpage>`
+	opf := generateTestEPUB(t, book.fb2(), converter.DefaultOptions())["OEBPS/content.opf"]
+
+	for _, expected := range []string{
+		`<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>`,
+		`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>`,
+		`<item id="big.png" href="images/big.png.png" media-type="image/png" properties="cover-image"/>`,
+		`<item id="first.png" href="images/first.png.png" media-type="image/png"/>`,
+	} {
+		if !strings.Contains(opf, expected) {
+			t.Errorf("content.opf should contain %q, got: %s", expected, opf)
+		}
+	}
+	if strings.Index(opf, `id="big.png"`) > strings.Index(opf, `id="first.png"`) {
+		t.Error("Image items should be listed in a stable, sorted order")
+	}
+}
+
+func TestContentOPF_Guide(t *testing.T) {
+	opf := generateTestEPUB(t, notesTestFB2, converter.DefaultOptions())["OEBPS/content.opf"]
+
+	for _, expected := range []string{
+		`<reference type="cover" title="Cover" href="cover.xhtml"/>`,
+		`<reference type="toc" title="Table of Contents" href="nav.xhtml#toc"/>`,
+		`<reference type="text" title="Content" href="content.xhtml"/>`,
+		`<reference type="notes" title="Notes" href="backmatter-1.xhtml"/>`,
+	} {
+		if !strings.Contains(opf, expected) {
+			t.Errorf("content.opf guide should contain %q", expected)
+		}
+	}
+}
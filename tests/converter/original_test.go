@@ -0,0 +1,85 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const translationTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <author><first-name>Leo</first-name><last-name>Tolstoy</last-name></author>
+      <book-title>War and Peace</book-title>
+      <lang>en</lang>
+      <translator><first-name>Aylmer</first-name><last-name>Maude</last-name></translator>
+    </title-info>
+    <src-title-info>
+      <author><first-name>Лев</first-name><last-name>Толстой</last-name></author>
+      <book-title>Война и мир</book-title>
+      <lang>ru</lang>
+    </src-title-info>
+  </description>
+  <body>
+    <section><title><p>Chapter 1</p></title><p>Text</p></section>
+  </body>
+</FictionBook>`
+
+func TestOriginal_OPF(t *testing.T) {
+	opf := generateTestEPUB(t, translationTestFB2, converter.DefaultOptions())["OEBPS/content.opf"]
+
+	for _, want := range []string{
+		`<meta property="schema:translationOfWork" id="original" xml:lang="ru">Война и мир</meta>`,
+		`<meta refines="#original" property="schema:author">Лев Толстой</meta>`,
+		`<meta refines="#original" property="schema:inLanguage">ru</meta>`,
+	} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("Expected %s in content.opf:\n%s", want, opf)
+		}
+	}
+}
+
+func TestOriginal_SrcLangOnly(t *testing.T) {
+	// src-lang alone names the language of an untitled original
+	opf := generateTestEPUB(t, contributorsTestFB2, converter.DefaultOptions())["OEBPS/content.opf"]
+	if !strings.Contains(opf, `<meta property="schema:translationOfWork" id="original" xml:lang="ru">War and Peace</meta>`) {
+		t.Errorf("Expected the book title as the original title:\n%s", opf)
+	}
+
+	opf = generateTestEPUB(t, validTestFB2, converter.DefaultOptions())["OEBPS/content.opf"]
+	if strings.Contains(opf, "schema:translationOfWork") {
+		t.Errorf("Books that are not translations should not name an original:\n%s", opf)
+	}
+}
+
+func TestOriginal_Cover(t *testing.T) {
+	cover := generateTestEPUB(t, translationTestFB2, converter.DefaultOptions())["OEBPS/cover.xhtml"]
+	want := `<p class="original">Original: <span lang="ru" xml:lang="ru">Война и мир — Лев Толстой</span></p>`
+	if !strings.Contains(cover, want) {
+		t.Errorf("Expected %s on the cover:\n%s", want, cover)
+	}
+}
+
+func TestOriginal_InspectAndRoundTrip(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(translationTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v", err)
+	}
+
+	info := converter.Inspect(fb2)
+	if info.OriginalTitle != "Война и мир" || strings.Join(info.OriginalAuthors, ", ") != "Лев Толстой" || info.OriginalLanguage != "ru" {
+		t.Errorf("Expected the original in the book info, got %q by %v in %q",
+			info.OriginalTitle, info.OriginalAuthors, info.OriginalLanguage)
+	}
+
+	back := converter.FB2FromBook(converter.BookFromFB2(fb2))
+	src := back.Description.SrcTitleInfo
+	if src == nil || src.BookTitle != "Война и мир" || len(src.Author) != 1 || src.Author[0].LastName != "Толстой" {
+		t.Fatalf("Expected the src-title-info to survive the book model, got %+v", src)
+	}
+	if back.Description.TitleInfo.SrcLang != "ru" {
+		t.Errorf("Expected src-lang ru, got %q", back.Description.TitleInfo.SrcLang)
+	}
+}
@@ -0,0 +1,66 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestPagination_PageList(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.PageLength = 20
+	files := generateTestEPUB(t, notesTestFB2, opts)
+	content := files["OEBPS/content.xhtml"]
+
+	if !strings.Contains(content, `<body epub:type="bodymatter"><span epub:type="pagebreak" role="doc-pagebreak" id="page-1" title="1"></span>`) {
+		t.Errorf("First page should start at the beginning of the content, got: %s", content)
+	}
+	if !strings.Contains(files["OEBPS/backmatter-1.xhtml"], `id="page-2"`) {
+		t.Error("Page numbers should continue into back-matter files")
+	}
+
+	nav := files["OEBPS/nav.xhtml"]
+	for _, expected := range []string{
+		`<nav epub:type="page-list" id="page-list" hidden="hidden">`,
+		`<li><a href="content.xhtml#page-1">1</a></li>`,
+		`<li><a href="backmatter-1.xhtml#page-2">2</a></li>`,
+	} {
+		if !strings.Contains(nav, expected) {
+			t.Errorf("nav.xhtml should contain %q", expected)
+		}
+	}
+
+	ncx := files["OEBPS/toc.ncx"]
+	for _, expected := range []string{
+		`<meta name="dtb:totalPageCount" content="2"/>`,
+		`<pageTarget id="page-1" type="normal" value="1"`,
+		`<content src="backmatter-1.xhtml#page-2"/>`,
+	} {
+		if !strings.Contains(ncx, expected) {
+			t.Errorf("toc.ncx should contain %q", expected)
+		}
+	}
+}
+
+func TestPagination_BreaksBetweenWords(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.PageLength = 8
+	fb2 := testBook{Lang: "en", Section: chapter("T", "alpha beta gamma delta")}.fb2()
+	content := generateTestEPUB(t, fb2, opts)["OEBPS/content.xhtml"]
+
+	if !strings.Contains(content, `alpha beta<span epub:type="pagebreak"`) {
+		t.Errorf("Page break should be placed at the first word boundary after the page length, got: %s", content)
+	}
+}
+
+func TestPagination_DisabledByDefault(t *testing.T) {
+	files := generateTestEPUB(t, notesTestFB2, converter.DefaultOptions())
+
+	if strings.Contains(files["OEBPS/content.xhtml"], "pagebreak") || strings.Contains(files["OEBPS/nav.xhtml"], "page-list") {
+		t.Error("Page breaks should only be added when a page length is set")
+	}
+	if strings.Contains(files["OEBPS/toc.ncx"], "<pageList>") {
+		t.Error("toc.ncx should not contain a page list by default")
+	}
+}
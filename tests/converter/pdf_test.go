@@ -0,0 +1,103 @@
+package converter_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/book"
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestWritePDF(t *testing.T) {
+	b := parseBookModelTestFB2(t)
+
+	var warnings []string
+	opts := converter.DefaultOptions()
+	opts.OnWarning = func(d converter.Diagnostic) { warnings = append(warnings, d.Message) }
+
+	var output bytes.Buffer
+	if err := converter.WritePDF(b, &output, opts); err != nil {
+		t.Fatalf("WritePDF() error = %v, want nil", err)
+	}
+	if !bytes.HasPrefix(output.Bytes(), []byte("%PDF-")) {
+		t.Fatalf("Expected a PDF document, got %q", output.String()[:min(output.Len(), 20)])
+	}
+	if !bytes.Contains(output.Bytes(), []byte("/Outlines")) {
+		t.Error("Expected the chapters as PDF bookmarks")
+	}
+	// The test cover is a bare PNG signature, which cannot be embedded
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "cover.png") {
+		t.Errorf("Expected one warning about cover.png, got %v", warnings)
+	}
+}
+
+func TestWritePDF_PageSizes(t *testing.T) {
+	b := &book.Book{
+		Metadata: book.Metadata{Title: "Sized Book"},
+		Chapters: []*book.Chapter{{Title: "One", Blocks: []book.Block{{Kind: book.Paragraph, Spans: []book.Span{{Text: "Text"}}}}}},
+	}
+
+	for _, size := range []string{"A4", "a5", "A6", "Letter", "legal"} {
+		opts := converter.DefaultOptions()
+		opts.PDF = converter.PDFOptions{PageSize: size, Margin: 10, Font: "Courier", FontSize: 9}
+		var output bytes.Buffer
+		if err := converter.WritePDF(b, &output, opts); err != nil {
+			t.Errorf("WritePDF() with page size %s error = %v, want nil", size, err)
+		}
+	}
+}
+
+func TestWritePDF_InvalidOptions(t *testing.T) {
+	tests := []converter.PDFOptions{
+		{PageSize: "B7"},
+		{Font: "Comic Sans"},
+	}
+
+	for _, pdfOpts := range tests {
+		opts := converter.DefaultOptions()
+		opts.PDF = pdfOpts
+		var output bytes.Buffer
+		if err := converter.WritePDF(&book.Book{}, &output, opts); err == nil {
+			t.Errorf("WritePDF() with %+v should fail", pdfOpts)
+		}
+	}
+}
+
+func TestConvertFileToPDF(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "book.fb2")
+	outputPath := filepath.Join(dir, "book.pdf")
+	if err := os.WriteFile(inputPath, []byte(bookModelTestFB2), 0600); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	stats, err := converter.New(converter.DefaultOptions()).ConvertFileToPDFWithStatsContext(context.Background(), inputPath, outputPath)
+	if err != nil {
+		t.Fatalf("ConvertFileToPDFWithStatsContext() error = %v, want nil", err)
+	}
+	if stats.Title != "Model Book" || stats.OutputSize == 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestWritePDF_CoreFontWarnsAboutMissingCharacters(t *testing.T) {
+	b := &book.Book{
+		Metadata: book.Metadata{Title: "Книга"},
+		Chapters: []*book.Chapter{{Title: "Глава", Blocks: []book.Block{{Kind: book.Paragraph, Spans: []book.Span{{Text: "Текст"}}}}}},
+	}
+
+	var warnings []string
+	opts := converter.DefaultOptions()
+	opts.OnWarning = func(d converter.Diagnostic) { warnings = append(warnings, d.Message) }
+	var output bytes.Buffer
+	if err := converter.WritePDF(b, &output, opts); err != nil {
+		t.Fatalf("WritePDF() error = %v, want nil", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "TrueType") {
+		t.Errorf("Expected one warning suggesting a TrueType font, got %v", warnings)
+	}
+}
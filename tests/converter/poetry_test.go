@@ -0,0 +1,160 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const poemTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Poems</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <poem>
+        <title><p>The Poem</p><p>Second Line</p></title>
+        <epigraph>
+          <p>Epigraph text</p>
+          <text-author>Epigraph Author</text-author>
+        </epigraph>
+        <stanza>
+          <title><p>Part I</p></title>
+          <subtitle>Stanza subtitle</subtitle>
+          <v>First verse</v>
+          <v>Second verse</v>
+        </stanza>
+        <text-author>Poet Name</text-author>
+        <date>1830</date>
+      </poem>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestParseFB2_PoemAttribution(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(poemTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	poem := fb2.MainBody().Section[0].Poem[0]
+	if len(poem.Epigraph) != 1 || len(poem.Epigraph[0].TextAuthor) != 1 {
+		t.Errorf("Expected one epigraph with text-author, got %+v", poem.Epigraph)
+	}
+	if len(poem.TextAuthor) != 1 || poem.TextAuthor[0].Text != "Poet Name" {
+		t.Errorf("Expected text-author 'Poet Name', got %+v", poem.TextAuthor)
+	}
+	if poem.Date != "1830" {
+		t.Errorf("Expected date '1830', got %q", poem.Date)
+	}
+	if poem.Stanza[0].Title == nil || poem.Stanza[0].Subtitle == nil {
+		t.Error("Stanza title and subtitle should be parsed")
+	}
+}
+
+func TestGenerateEPUB_PoemFormatting(t *testing.T) {
+	content := generateTestEPUB(t, poemTestFB2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	// The section has no title, so the poem heads the outline
+	checks := []string{
+		`<h1 class="poem-title">The Poem<br/>Second Line</h1>`,
+		`<div class="epigraph">`,
+		`<p class="text-author">Epigraph Author</p>`,
+		`<h2 class="stanza-title">Part I</h2>`,
+		`<p class="stanza-subtitle">Stanza subtitle</p>`,
+		`<p class="verse">First verse</p>`,
+		`<p class="text-author">Poet Name</p>`,
+		`<p class="date">1830</p>`,
+	}
+	for _, check := range checks {
+		if !strings.Contains(content, check) {
+			t.Errorf("content.xhtml should contain %q", check)
+		}
+	}
+}
+
+func TestGenerateEPUB_CiteFullContent(t *testing.T) {
+	fb2Content := `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Cites</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <cite id="c1">
+        <subtitle>Cite subtitle</subtitle>
+        <p>Quoted paragraph</p>
+        <empty-line/>
+        <poem>
+          <stanza><v>Quoted verse</v></stanza>
+        </poem>
+        <text-author>Quoted Author</text-author>
+      </cite>
+    </section>
+  </body>
+</FictionBook>`
+
+	content := generateTestEPUB(t, fb2Content, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	checks := []string{
+		`<blockquote class="cite" id="c1">`,
+		`<p class="subtitle">Cite subtitle</p>`,
+		`<p>Quoted paragraph</p>`,
+		`<p class="verse">Quoted verse</p>`,
+		`<p class="text-author">Quoted Author</p>`,
+	}
+	for _, check := range checks {
+		if !strings.Contains(content, check) {
+			t.Errorf("content.xhtml should contain %q", check)
+		}
+	}
+}
+
+func TestGenerateEPUB_CiteKeepsDocumentOrder(t *testing.T) {
+	fb2Content := `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Cites</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <cite>
+        <p>First paragraph</p>
+        <empty-line/>
+        <subtitle>Middle subtitle</subtitle>
+        <p>Second paragraph</p>
+        <text-author>Quoted Author</text-author>
+      </cite>
+    </section>
+  </body>
+</FictionBook>`
+
+	content := generateTestEPUB(t, fb2Content, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	order := []string{
+		`<p>First paragraph</p>`,
+		`<div class="empty-line"></div>`,
+		`<p class="subtitle">Middle subtitle</p>`,
+		`<p>Second paragraph</p>`,
+		`<p class="text-author">Quoted Author</p>`,
+	}
+	last := -1
+	for _, part := range order {
+		index := strings.Index(content, part)
+		if index < 0 {
+			t.Fatalf("content.xhtml should contain %q", part)
+		}
+		if index < last {
+			t.Errorf("Expected %q to follow the previous children of the cite", part)
+		}
+		last = index
+	}
+}
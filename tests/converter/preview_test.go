@@ -0,0 +1,44 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestPreviewHTML(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(validTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v", err)
+	}
+
+	preview := converter.PreviewHTML(fb2)
+	if !strings.Contains(preview, "Chapter 1</h1>") {
+		t.Errorf("Preview should contain the chapter title, got %q", preview)
+	}
+	if strings.Contains(preview, "<img") {
+		t.Errorf("Preview should not reference images, got %q", preview)
+	}
+
+	fb2.Body = nil
+	if preview := converter.PreviewHTML(fb2); preview != "" {
+		t.Errorf("PreviewHTML() of a book without sections = %q, want empty", preview)
+	}
+}
+
+func TestConverter_OnPreview(t *testing.T) {
+	var previews []string
+	opts := converter.DefaultOptions()
+	opts.OnPreview = func(html string) {
+		previews = append(previews, html)
+	}
+
+	if err := converter.New(opts).Convert(strings.NewReader(validTestFB2), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if len(previews) != 1 || !strings.Contains(previews[0], "Chapter 1") {
+		t.Errorf("OnPreview should be called once with the first chapter, got %q", previews)
+	}
+}
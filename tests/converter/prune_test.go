@@ -0,0 +1,70 @@
+package converter_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// pruneTestFB2 references one of its three images; "unused" decodes to 13 bytes
+const pruneTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Pruning</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>Picture <image l:href="#used"/></p>
+    </section>
+  </body>
+  <binary id="used" content-type="image/png">iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==</binary>
+  <binary id="unused" content-type="image/png">SGVsbG8sIHdv
+cmxkIQ==</binary>
+  <binary id="cover-art" content-type="image/png">Q292ZXI=</binary>
+</FictionBook>`
+
+func TestPruneImages(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.PruneImages = true
+	entries := generateTestEPUB(t, pruneTestFB2, opts)
+
+	if _, ok := entries["OEBPS/images/used.png"]; !ok {
+		t.Error("Expected the referenced image to be kept")
+	}
+	if _, ok := entries["OEBPS/images/unused.png"]; ok {
+		t.Error("Expected the unreferenced image to be dropped")
+	}
+	// Without a coverpage, images named like a cover stay for cover detection
+	if _, ok := entries["OEBPS/images/cover-art.png"]; !ok {
+		t.Error("Expected the cover candidate to be kept")
+	}
+}
+
+func TestPruneImages_Disabled(t *testing.T) {
+	entries := generateTestEPUB(t, pruneTestFB2, converter.DefaultOptions())
+
+	if _, ok := entries["OEBPS/images/unused.png"]; !ok {
+		t.Error("Expected every binary to be packaged by default")
+	}
+}
+
+func TestPruneImages_Stats(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.fb2")
+	if err := writeFile(inputPath, pruneTestFB2); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	opts := converter.DefaultOptions()
+	opts.PruneImages = true
+	stats, err := converter.New(opts).ConvertFileWithStats(inputPath, filepath.Join(tmpDir, "book.epub"))
+	if err != nil {
+		t.Fatalf("ConvertFileWithStats() error = %v", err)
+	}
+	if stats.PrunedImageBytes != 13 || stats.ImageCount != 3 {
+		t.Errorf("Expected 13 pruned bytes of 3 images, got %d of %d", stats.PrunedImageBytes, stats.ImageCount)
+	}
+}
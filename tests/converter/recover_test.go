@@ -0,0 +1,120 @@
+package converter_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const malformedTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <book-title>Damaged Book</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Chapter 1</p></title>
+      <p>First chapter text.</p>
+    </section>
+    <section>
+      <title><p>Chapter 2</p></title>
+      <p>Broken <emphasis <p>markup</p>
+    </section>
+    <section>
+      <title><p>Chapter 3</p></title>
+      <p>Third chapter text.</p>
+    </section>
+  </body>
+  <binary id="bad.png" content-type="image/png">!!!not base64!!!</binary>
+</FictionBook>`
+
+func convertLenient(t *testing.T, input string) (string, []converter.Diagnostic) {
+	t.Helper()
+
+	var warnings []converter.Diagnostic
+	opts := converter.DefaultOptions()
+	opts.Lenient = true
+	opts.OnWarning = func(warning converter.Diagnostic) {
+		warnings = append(warnings, warning)
+	}
+
+	var buf bytes.Buffer
+	if err := converter.New(opts).Convert(strings.NewReader(input), &buf); err != nil {
+		t.Fatalf("Convert() in lenient mode error = %v, want nil", err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Output is not a valid ZIP archive: %v", err)
+	}
+	file, err := reader.Open("OEBPS/content.xhtml")
+	if err != nil {
+		t.Fatalf("Failed to open content.xhtml: %v", err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("Failed to read content.xhtml: %v", err)
+	}
+	return string(content), warnings
+}
+
+func TestConverter_LenientSkipsMalformedSection(t *testing.T) {
+	if err := converter.New(nil).Convert(strings.NewReader(malformedTestFB2), &bytes.Buffer{}); err == nil {
+		t.Fatal("Convert() without lenient mode should fail on malformed markup")
+	}
+
+	content, warnings := convertLenient(t, malformedTestFB2)
+
+	for _, want := range []string{"First chapter text.", "Third chapter text."} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Content should keep %q", want)
+		}
+	}
+	if strings.Contains(content, "Chapter 2") {
+		t.Error("The malformed section should be dropped")
+	}
+
+	var skippedSection, skippedBinary bool
+	for _, warning := range warnings {
+		switch {
+		case strings.HasPrefix(warning.Message, "skipped malformed section"):
+			skippedSection = true
+			if warning.Line != 15 {
+				t.Errorf("Section warning line = %d, want 15", warning.Line)
+			}
+		case strings.Contains(warning.Message, `skipped binary "bad.png"`):
+			skippedBinary = true
+		}
+	}
+	if !skippedSection || !skippedBinary {
+		t.Errorf("Expected warnings for the section and the binary, got %v", warnings)
+	}
+}
+
+func TestConverter_LenientClosesTruncatedDocument(t *testing.T) {
+	truncated := malformedTestFB2[:strings.Index(malformedTestFB2, "<section>\n      <title><p>Chapter 2")]
+	truncated += "<section><p>Cut off in the mid"
+
+	content, warnings := convertLenient(t, truncated)
+
+	if !strings.Contains(content, "Cut off in the mid") {
+		t.Error("Content of the truncated section should be kept")
+	}
+	if len(warnings) == 0 || !strings.Contains(warnings[len(warnings)-1].Message, "document ends inside") {
+		t.Errorf("Expected a warning about the truncated document, got %v", warnings)
+	}
+}
+
+func TestConverter_LenientValidDocumentHasNoWarnings(t *testing.T) {
+	_, warnings := convertLenient(t, validTestFB2)
+	if len(warnings) != 0 {
+		t.Errorf("Valid document should produce no warnings, got %v", warnings)
+	}
+}
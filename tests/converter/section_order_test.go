@@ -0,0 +1,74 @@
+package converter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const sectionOrderTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Order</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Chapter</p></title>
+      <p>Before the poem</p>
+      <poem><stanza><v>Verse line</v></stanza></poem>
+      <p>Between</p>
+      <cite><p>Quoted text</p></cite>
+      <p>After the quote</p>
+      <section><title><p>Inner</p></title><p>Inner text</p></section>
+      <p>Closing words</p>
+    </section>
+  </body>
+</FictionBook>`
+
+// assertInOrder fails unless every marker occurs in text, each after the one before
+func assertInOrder(t *testing.T, text string, markers ...string) {
+	t.Helper()
+
+	position := 0
+	for _, marker := range markers {
+		index := strings.Index(text[position:], marker)
+		if index < 0 {
+			t.Fatalf("Expected %q after position %d in:\n%s", marker, position, text)
+		}
+		position += index + len(marker)
+	}
+}
+
+func TestSectionOrder_EPUB(t *testing.T) {
+	content := generateTestEPUB(t, sectionOrderTestFB2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	assertInOrder(t, content, "Before the poem", "Verse line", "Between", "Quoted text",
+		"After the quote", "Inner text", "Closing words")
+}
+
+func TestSectionOrder_FB2RoundTrip(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(sectionOrderTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := converter.WriteFB2(converter.FB2FromBook(converter.BookFromFB2(fb2)), &buf); err != nil {
+		t.Fatalf("WriteFB2() error = %v", err)
+	}
+	// Subsections follow the content of their parent in the book model
+	assertInOrder(t, buf.String(), "Before the poem", "Verse line", "Between", "Quoted text",
+		"After the quote", "Closing words", "Inner text")
+
+	written, err := converter.ParseFB2FromReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to parse the written FB2: %v", err)
+	}
+	if section := written.Body[0].Section[0]; len(section.Poem) != 1 || len(section.Cite) != 1 || len(section.Paragraph) != 4 {
+		t.Errorf("Expected the children to survive the round trip, got %+v", section)
+	}
+}
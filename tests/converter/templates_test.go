@@ -0,0 +1,81 @@
+package converter_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// writeTemplates creates a template directory holding the given files
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write template: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestTemplates_Override(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Metadata.Title = "Fish & <Chips>"
+	opts.TemplateDir = writeTemplates(t, map[string]string{
+		"cover.xhtml": `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head><title>{{.Title}}</title></head>
+<body><p class="custom-cover">{{template "byline" .}}</p></body>
+</html>`,
+		"byline.tmpl": `{{define "byline"}}{{.Title}} by {{.Author}}{{end}}`,
+	})
+
+	entries := generateTestEPUB(t, overrideTestFB2, opts)
+	cover := entries["OEBPS/cover.xhtml"]
+
+	if !strings.HasPrefix(cover, `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("cover.xhtml should start with the XML declaration, got:\n%s", cover)
+	}
+	if !strings.Contains(cover, `<p class="custom-cover">Fish &amp; &lt;Chips&gt; by Wrong Author</p>`) {
+		t.Errorf("cover.xhtml should be rendered from the override with escaped text, got:\n%s", cover)
+	}
+	if !strings.Contains(cover, `<html xmlns="http://www.w3.org/1999/xhtml" lang="en" xml:lang="en">`) {
+		t.Errorf("cover.xhtml should get the book language, got:\n%s", cover)
+	}
+
+	// Templates that are not overridden keep the built-in markup
+	if !strings.Contains(entries["OEBPS/content.xhtml"], `<body epub:type="bodymatter">`) {
+		t.Error("content.xhtml should use the built-in template")
+	}
+}
+
+func TestTemplates_Errors(t *testing.T) {
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(overrideTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{"parse", `<nav>{{range .TOC}</nav>`, "failed to parse template nav.xhtml"},
+		{"render", `<nav>{{.Missing}}</nav>`, "failed to render template nav.xhtml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := converter.DefaultOptions()
+			opts.TemplateDir = writeTemplates(t, map[string]string{"nav.xhtml": tt.template})
+
+			err := converter.GenerateEPUBWithOptions(fb2, filepath.Join(t.TempDir(), "test.epub"), opts)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package converter_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+// hexColorPattern matches colors fixed in CSS, which dark modes cannot adapt
+var hexColorPattern = regexp.MustCompile(`color:\s*#[0-9a-fA-F]{3,6}`)
+
+func TestTheme_DefaultHasNoFixedColors(t *testing.T) {
+	entries := generateTestEPUB(t, notesTestFB2, converter.DefaultOptions())
+
+	for name, content := range entries {
+		if strings.HasSuffix(name, ".xhtml") && hexColorPattern.MatchString(content) {
+			t.Errorf("%s should not fix colors: %s", name, hexColorPattern.FindString(content))
+		}
+	}
+	if strings.Contains(entries["OEBPS/content.xhtml"], "background-color: transparent") {
+		t.Error("The default theme should not add the night styles")
+	}
+}
+
+func TestTheme_Night(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Theme = converter.ThemeNight
+	entries := generateTestEPUB(t, notesTestFB2, opts)
+
+	for _, name := range []string{
+		"OEBPS/cover.xhtml", "OEBPS/content.xhtml", "OEBPS/backmatter-1.xhtml", "OEBPS/nav.xhtml",
+	} {
+		content := entries[name]
+		styles := strings.LastIndex(content, "color: inherit; background-color: transparent;")
+		if styles < 0 || styles > strings.Index(content, "</head>") {
+			t.Errorf("%s should end its head with the night styles:\n%s", name, content)
+		}
+	}
+}
+
+func TestTheme_Validate(t *testing.T) {
+	for _, theme := range []converter.Theme{"", converter.ThemeDefault, converter.ThemeNight} {
+		if err := theme.Validate(); err != nil {
+			t.Errorf("Validate(%q) error = %v, want nil", theme, err)
+		}
+	}
+	if err := converter.Theme("sepia").Validate(); err == nil {
+		t.Error("Validate(sepia) should reject an unknown theme")
+	}
+}
@@ -0,0 +1,146 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const tocTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>TOC</book-title>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>Part One</p></title>
+      <section>
+        <title><p>Book Wrapper</p></title>
+        <section>
+          <title><p>Chapter 1</p></title>
+          <p>Text</p>
+        </section>
+        <section>
+          <title><p>Chapter 2</p></title>
+          <section>
+            <title><p>Scene A</p></title>
+            <p>Text</p>
+          </section>
+        </section>
+      </section>
+    </section>
+  </body>
+</FictionBook>`
+
+func TestTOC_FullTreeByDefault(t *testing.T) {
+	files := generateTestEPUB(t, tocTestFB2, converter.DefaultOptions())
+
+	for _, title := range []string{"Part One", "Book Wrapper", "Chapter 1", "Scene A"} {
+		if !strings.Contains(files["OEBPS/nav.xhtml"], ">"+title+"<") {
+			t.Errorf("nav.xhtml should list %q", title)
+		}
+		if !strings.Contains(files["OEBPS/toc.ncx"], ">"+title+"<") {
+			t.Errorf("toc.ncx should list %q", title)
+		}
+	}
+	if !strings.Contains(files["OEBPS/toc.ncx"], `<meta name="dtb:depth" content="5"/>`) {
+		t.Error("toc.ncx depth should reflect the full section tree")
+	}
+}
+
+func TestTOC_DepthLimit(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.TOCDepth = 2
+	files := generateTestEPUB(t, tocTestFB2, opts)
+
+	for _, doc := range []string{"OEBPS/nav.xhtml", "OEBPS/toc.ncx"} {
+		if !strings.Contains(files[doc], ">Book Wrapper<") {
+			t.Errorf("%s should keep entries within the depth limit", doc)
+		}
+		if strings.Contains(files[doc], ">Chapter 1<") || strings.Contains(files[doc], ">Scene A<") {
+			t.Errorf("%s should drop entries deeper than the limit", doc)
+		}
+	}
+	if !strings.Contains(files["OEBPS/toc.ncx"], `<meta name="dtb:depth" content="3"/>`) {
+		t.Error("toc.ncx depth should reflect the limited tree")
+	}
+}
+
+func TestTOC_FlattenSingleChild(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.FlattenSingleChild = true
+	files := generateTestEPUB(t, tocTestFB2, opts)
+	nav := files["OEBPS/nav.xhtml"]
+
+	for _, title := range []string{"Book Wrapper", "Scene A"} {
+		if strings.Contains(nav, ">"+title+"<") {
+			t.Errorf("Only child %q should be collapsed into its parent", title)
+		}
+	}
+	for _, title := range []string{"Part One", "Chapter 1", "Chapter 2"} {
+		if !strings.Contains(nav, ">"+title+"<") {
+			t.Errorf("nav.xhtml should list %q", title)
+		}
+	}
+	if !strings.Contains(nav, `href="content.xhtml#section-0"`) {
+		t.Error("Collapsed entry should keep the link of the outer section")
+	}
+}
+
+const untitledTOCTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description><title-info><book-title>Untitled</book-title><lang>en</lang></title-info></description>
+  <body>
+    <section><p>It was a dark and stormy night. The rain fell in torrents.</p></section>
+    <section><empty-line/><p>Call me Ishmael, some years ago never mind how long precisely having little money</p></section>
+    <section><title><p>Epilogue</p></title><p>The end.</p></section>
+  </body>
+</FictionBook>`
+
+func TestTOC_UntitledSectionsOmittedByDefault(t *testing.T) {
+	files := generateTestEPUB(t, untitledTOCTestFB2, converter.DefaultOptions())
+
+	if strings.Contains(files["OEBPS/nav.xhtml"], "stormy") || strings.Contains(files["OEBPS/nav.xhtml"], "Chapter 1") {
+		t.Error("Untitled sections should be left out of the table of contents by default")
+	}
+}
+
+func TestTOC_UntitledSectionsFirstLine(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.UntitledSections = converter.UntitledFirstLine
+	files := generateTestEPUB(t, untitledTOCTestFB2, opts)
+
+	for _, doc := range []string{"OEBPS/nav.xhtml", "OEBPS/toc.ncx"} {
+		for _, title := range []string{
+			">It was a dark and stormy night.<",
+			">Call me Ishmael, some years ago never mind how long…<",
+			">Epilogue<",
+		} {
+			if !strings.Contains(files[doc], title) {
+				t.Errorf("%s should list %s:\n%s", doc, title, files[doc])
+			}
+		}
+	}
+	if !strings.Contains(files["OEBPS/nav.xhtml"], `href="content.xhtml#section-1"`) {
+		t.Error("Entries of untitled sections should link to them")
+	}
+	if !strings.Contains(files["OEBPS/content.xhtml"], `<a id="section-1"></a>`) {
+		t.Error("Untitled sections should have an anchor to link to")
+	}
+}
+
+func TestTOC_UntitledSectionsNumbered(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.UntitledSections = converter.UntitledNumber
+	files := generateTestEPUB(t, strings.Replace(untitledTOCTestFB2, "<lang>en</lang>", "<lang>ru</lang>", 1), opts)
+
+	for _, title := range []string{">Глава 1<", ">Глава 2<", ">Epilogue<"} {
+		if !strings.Contains(files["OEBPS/nav.xhtml"], title) {
+			t.Errorf("nav.xhtml should list %s", title)
+		}
+	}
+}
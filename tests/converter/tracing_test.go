@@ -0,0 +1,48 @@
+package converter_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestConverter_TracesStages(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "job")
+	err := converter.New(nil).ConvertContext(ctx, strings.NewReader(libraryTestFB2), &bytes.Buffer{})
+	parent.End()
+	if err != nil {
+		t.Fatalf("ConvertContext() error = %v, want nil", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{"parse", "images", "packaging", "resources", "generate"} {
+		span, found := spans[name]
+		if !found {
+			t.Errorf("Expected a %q span, got %v", name, recorder.Ended())
+			continue
+		}
+		if span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+			t.Errorf("The %q span should belong to the trace of the caller", name)
+		}
+	}
+	if generate, found := spans["generate"]; found {
+		if images := spans["images"]; images != nil && images.Parent().SpanID() != generate.SpanContext().SpanID() {
+			t.Error("The images span should be a child of the generate span")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package converter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const noBreakSpace = "\u00A0"
+
+func typographyContent(t *testing.T, lang, text string, hyphenate bool) string {
+	opts := converter.DefaultOptions()
+	opts.Typography = true
+	opts.Hyphenate = hyphenate
+	fb2 := testBook{Lang: lang, Section: chapter(`"Title" -- here`, text)}.fb2()
+	return generateTestEPUB(t, fb2, opts)["OEBPS/content.xhtml"]
+}
+
+func TestTypography_Russian(t *testing.T) {
+	content := typographyContent(t, "ru",
+		`- Он сказал: "Читай "Войну и мир" в поезде" -- и ушёл - быстро.`, false)
+
+	for _, expected := range []string{
+		"—" + noBreakSpace + "Он",
+		"«Читай „Войну и" + noBreakSpace + "мир“ в" + noBreakSpace + "поезде»",
+		"— и" + noBreakSpace + "ушёл",
+		"ушёл" + noBreakSpace + "— быстро",
+	} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("content.xhtml should contain %q, got: %s", expected, content)
+		}
+	}
+	if !strings.Contains(content, `>&#34;Title&#34; -- here</h1>`) {
+		t.Error("Headings should be left untouched")
+	}
+}
+
+func TestTypography_English(t *testing.T) {
+	content := typographyContent(t, "en", `She said "don't" and 'maybe' -- twice.`, false)
+
+	for _, expected := range []string{"“don’t”", "‘maybe’", "— twice"} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("content.xhtml should contain %q, got: %s", expected, content)
+		}
+	}
+	if strings.Contains(content, "and"+noBreakSpace) {
+		t.Error("Short English words should not be bound to the next word")
+	}
+}
+
+func TestTypography_German(t *testing.T) {
+	content := typographyContent(t, "de", `"Achtung", rief er.`, false)
+
+	if !strings.Contains(content, "„Achtung“,") {
+		t.Errorf("German quotes should be used, got: %s", content)
+	}
+}
+
+func TestTypography_WithHyphenation(t *testing.T) {
+	content := typographyContent(t, "ru", `на "переворачивание"`, true)
+
+	if !strings.Contains(content, "на"+noBreakSpace+"«пе"+softHyphen) {
+		t.Errorf("Typography and hyphenation should combine, got: %s", content)
+	}
+}
+
+func TestTypography_DisabledByDefault(t *testing.T) {
+	fb2 := testBook{Lang: "ru", Section: chapter("Заголовок", `"Цитата" -- в тексте`)}.fb2()
+	content := generateTestEPUB(t, fb2, converter.DefaultOptions())["OEBPS/content.xhtml"]
+
+	if strings.Contains(content, "«") || strings.Contains(content, noBreakSpace) {
+		t.Error("Typography should not be applied unless enabled")
+	}
+}
@@ -0,0 +1,108 @@
+package converter_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const validTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description>
+    <title-info>
+      <genre>prose</genre>
+      <author><first-name>Test</first-name><last-name>Author</last-name></author>
+      <book-title>Valid Book</book-title>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+    <section id="ch1">
+      <title><p>Chapter 1</p></title>
+      <p>Some <emphasis>text</emphasis>.</p>
+      <image l:href="#pic.png"/>
+    </section>
+  </body>
+  <binary id="pic.png" content-type="image/png">iVBORw0KGgo=</binary>
+</FictionBook>`
+
+func TestValidate_ValidDocument(t *testing.T) {
+	if diagnostics := converter.Validate(strings.NewReader(validTestFB2)); len(diagnostics) != 0 {
+		t.Errorf("Validate() = %v, want no diagnostics", diagnostics)
+	}
+}
+
+func TestValidate_Diagnostics(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace [2]string
+		want    string
+		line    int
+	}{
+		{"misplaced element", [2]string{"<section id=\"ch1\">", "<p>Stray</p>\n    <section id=\"ch1\">"},
+			"unexpected element <p> in <body>", 12},
+		{"missing required metadata", [2]string{"<lang>en</lang>", ""},
+			"<title-info> is missing required element <lang>", 9},
+		{"unknown binary", [2]string{"#pic.png\"/>", "#missing.png\"/>"},
+			"image references unknown binary \"#missing.png\"", 15},
+		{"invalid base64", [2]string{"iVBORw0KGgo=", "not base64!"},
+			"binary data is not valid base64", 18},
+		{"malformed XML", [2]string{"</emphasis>", "</strong>"}, "malformed XML", 14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := strings.Replace(validTestFB2, tt.replace[0], tt.replace[1], 1)
+			diagnostics := converter.Validate(strings.NewReader(input))
+			if len(diagnostics) == 0 {
+				t.Fatal("Validate() returned no diagnostics")
+			}
+			if !strings.Contains(diagnostics[0].Message, tt.want) {
+				t.Errorf("Message = %q, want it to contain %q", diagnostics[0].Message, tt.want)
+			}
+			if diagnostics[0].Line != tt.line {
+				t.Errorf("Line = %d, want %d", diagnostics[0].Line, tt.line)
+			}
+			if diagnostics[0].Column <= 0 {
+				t.Errorf("Column = %d, want a positive column", diagnostics[0].Column)
+			}
+		})
+	}
+}
+
+func TestConverter_StrictMode(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Strict = true
+
+	var buf bytes.Buffer
+	if err := converter.New(opts).Convert(strings.NewReader(validTestFB2), &buf); err != nil {
+		t.Fatalf("Convert() of a valid document error = %v, want nil", err)
+	}
+
+	// libraryTestFB2 lacks the genre, author and lang the schema requires
+	err := converter.New(opts).Convert(strings.NewReader(libraryTestFB2), &buf)
+	var validationErr *converter.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Convert() error = %v, want *converter.ValidationError", err)
+	}
+	if len(validationErr.Diagnostics) != 3 {
+		t.Errorf("Expected 3 diagnostics, got %v", validationErr.Diagnostics)
+	}
+	var parseErr *converter.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Error("Validation errors should be reported as *converter.ParseError")
+	}
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "input.fb2")
+	if err := writeFile(inputPath, validTestFB2); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := converter.New(opts).ConvertFileWithStats(inputPath, filepath.Join(tmpDir, "book.epub")); err != nil {
+		t.Errorf("ConvertFileWithStats() in strict mode error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,113 @@
+package converter_test
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+const edgeCaseTextFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <author><first-name>Tom &amp; Jerry</first-name><last-name>"Quoted"</last-name></author>
+      <book-title>A ]]&gt; B</book-title>
+      <annotation><p>End a CDATA section with ]]&gt; &lt;![CDATA[ here</p></annotation>
+      <lang>en</lang>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <title><p>&lt;/title&gt; ]]&gt; &amp;amp;</p></title>
+      <p>Text with &lt;tags&gt; and ]]&gt;</p>
+    </section>
+  </body>
+</FictionBook>`
+
+// checkWellFormed fails the test if an XML document of the EPUB does not parse
+func checkWellFormed(t *testing.T, name, document string) {
+	t.Helper()
+
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Errorf("%s is not well-formed: %v\n%s", name, err, document)
+			return
+		}
+	}
+}
+
+func TestXMLOutput_WellFormed(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Colophon = true
+	opts.Metadata.Series = "Series\x01 ]]>"
+	opts.Metadata.SeriesIndex = "1\x1f"
+
+	entries := generateTestEPUB(t, edgeCaseTextFB2, opts)
+	checked := 0
+	for name, document := range entries {
+		if strings.HasSuffix(name, ".xhtml") || strings.HasSuffix(name, ".opf") ||
+			strings.HasSuffix(name, ".ncx") || strings.HasSuffix(name, ".xml") {
+			checkWellFormed(t, name, document)
+			checked++
+		}
+	}
+	if checked < 5 {
+		t.Errorf("Expected the XML documents of the EPUB to be checked, found %d", checked)
+	}
+
+	if opf := entries["OEBPS/content.opf"]; !strings.Contains(opf, "<dc:title>A ]]&gt; B</dc:title>") {
+		t.Errorf("content.opf should contain the escaped title, got:\n%s", opf)
+	}
+}
+
+func TestXMLOutput_ControlCharactersRemoved(t *testing.T) {
+	opts := converter.DefaultOptions()
+	opts.Metadata.Title = "Bad\x02 \x08Title\uFFFE"
+	opts.Metadata.Author = "Ann\x0b Author"
+
+	entries := generateTestEPUB(t, edgeCaseTextFB2, opts)
+	for _, name := range []string{"OEBPS/content.opf", "OEBPS/toc.ncx", "OEBPS/nav.xhtml", "OEBPS/cover.xhtml"} {
+		checkWellFormed(t, name, entries[name])
+	}
+
+	opf := entries["OEBPS/content.opf"]
+	for _, want := range []string{"<dc:title>Bad Title</dc:title>", "<dc:creator>Ann Author</dc:creator>"} {
+		if !strings.Contains(opf, want) {
+			t.Errorf("content.opf should contain %q, got:\n%s", want, opf)
+		}
+	}
+}
+
+func TestXMLOutput_RepeatedLinkText(t *testing.T) {
+	fb2Content := `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+  <description><title-info><book-title>Notes</book-title><lang>en</lang></title-info></description>
+  <body>
+    <section>
+      <p>First<a l:href="#n1" type="note">1</a> and <strong>bold <a l:href="#n1">1</a></strong> then<a l:href="#n1" type="note">1</a> end.</p>
+    </section>
+  </body>
+  <body name="notes">
+    <section id="n1"><title><p>1</p></title><p>A note.</p></section>
+  </body>
+</FictionBook>`
+
+	entries := generateTestEPUB(t, fb2Content, converter.DefaultOptions())
+	content := entries["OEBPS/content.xhtml"]
+	checkWellFormed(t, "content.xhtml", content)
+
+	if strings.Count(content, `">1</a>`) != 3 {
+		t.Errorf("Expected three note links, got:\n%s", content)
+	}
+	for _, want := range []string{"First<a ", "</a> and <strong>bold <a ", "</a></strong> then<a ", "</a> end.</p>"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %q in content.xhtml, links should stay where they stood:\n%s", want, content)
+		}
+	}
+}
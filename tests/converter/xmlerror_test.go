@@ -0,0 +1,80 @@
+package converter_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+)
+
+func TestLocateXMLError(t *testing.T) {
+	mismatched := strings.Replace(validTestFB2, "<p>Some <emphasis>text</emphasis>.</p>",
+		"<p>Some <emphasis>text</strong>.</p>", 1)
+	truncated := validTestFB2[:strings.Index(validTestFB2, "<emphasis>")+len("<emphasis>te")]
+	deep := strings.Replace(validTestFB2, "<p>Some <emphasis>text</emphasis>.</p>",
+		"<section><section><p>Deep</p></section></section>", 1)
+
+	tests := []struct {
+		name    string
+		input   string
+		limits  *converter.Limits
+		line    int
+		element string
+		message string
+		snippet string
+	}{
+		{"well-formed", validTestFB2, nil, 0, "", "", ""},
+		{"mismatched tag", mismatched, nil, 14, "FictionBook/body/section/p/emphasis",
+			"element <emphasis> closed by </strong>", "Some <emphasis>text</strong>"},
+		{"truncated", truncated, nil, 14, "FictionBook/body/section/p/emphasis",
+			"document ends before </emphasis> is closed", "<p>Some <emphasis>te"},
+		{"empty", "", nil, 1, "", "document has no root element", ""},
+		// The element over the limit is the last one of the path
+		{"over a limit", deep, &converter.Limits{MaxSectionDepth: 2}, 14, "FictionBook/body/section/section/section",
+			"section depth limit", "<section><section>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := converter.LocateXMLError(strings.NewReader(tt.input), tt.limits)
+			if tt.message == "" {
+				if err != nil {
+					t.Fatalf("LocateXMLError() = %v, want nil", err)
+				}
+				return
+			}
+			var xmlErr *converter.XMLError
+			if !errors.As(err, &xmlErr) {
+				t.Fatalf("LocateXMLError() = %v, want an *XMLError", err)
+			}
+			if xmlErr.Line != tt.line || xmlErr.Element != tt.element {
+				t.Errorf("Error at line %d in %q, want line %d in %q", xmlErr.Line, xmlErr.Element, tt.line, tt.element)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("LocateXMLError() = %q, want it to mention %q", err, tt.message)
+			}
+			if !strings.Contains(xmlErr.Snippet, tt.snippet) {
+				t.Errorf("Snippet %q should contain %q", xmlErr.Snippet, tt.snippet)
+			}
+		})
+	}
+}
+
+func TestConverter_ParseErrorLocation(t *testing.T) {
+	truncated := validTestFB2[:strings.Index(validTestFB2, "</body>")]
+	err := converter.New(nil).Convert(strings.NewReader(truncated), &bytes.Buffer{})
+
+	var parseErr *converter.ParseError
+	var xmlErr *converter.XMLError
+	if !errors.As(err, &parseErr) || !errors.As(err, &xmlErr) {
+		t.Fatalf("Convert() error = %v, want a *ParseError locating the problem", err)
+	}
+	if xmlErr.Element != "FictionBook/body" || xmlErr.Offset != int64(len(truncated)) {
+		t.Errorf("Error in %q at byte %d, want FictionBook/body at byte %d", xmlErr.Element, xmlErr.Offset, len(truncated))
+	}
+	if !strings.Contains(err.Error(), "document ends before </body> is closed") {
+		t.Errorf("Convert() error = %q, want the unclosed element named", err)
+	}
+}
@@ -0,0 +1,153 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestAdmin_DisabledWithoutKey(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	req := httptest.NewRequest("GET", "/api/v1/admin/storage", nil)
+	req.Header.Set("X-Admin-Key", "anything")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d when admin key is not configured, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestAdmin_RejectsWrongKey(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	req := httptest.NewRequest("GET", "/api/v1/admin/storage", nil)
+	req.Header.Set("X-Admin-Key", "wrong")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for wrong key, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAdmin_StorageStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	jobID := "aaaaaaaa-1111-2222-3333-444444444444"
+	jobDir := filepath.Join(tmpDir, jobID)
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		t.Fatalf("Failed to create job dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(jobDir, "output.epub"), make([]byte, 1000), 0644); err != nil {
+		t.Fatalf("Failed to create output file: %v", err)
+	}
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusCompleted,
+		CreatedAt: time.Now().Add(-10 * time.Minute),
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	router := setupTestRouter()
+	req := httptest.NewRequest("GET", "/api/v1/admin/storage", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		DiskUsage    int64          `json:"disk_usage_bytes"`
+		Directories  int            `json:"job_directories"`
+		JobsByStatus map[string]int `json:"jobs_by_status"`
+		OldestAge    int64          `json:"oldest_job_age_seconds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if response.DiskUsage < 1000 {
+		t.Errorf("Expected disk usage of at least 1000 bytes, got %d", response.DiskUsage)
+	}
+	if response.Directories != 1 {
+		t.Errorf("Expected 1 job directory, got %d", response.Directories)
+	}
+	if response.JobsByStatus[handlers.JobStatusCompleted] < 1 {
+		t.Errorf("Expected at least one completed job, got %v", response.JobsByStatus)
+	}
+	if response.OldestAge < 600 {
+		t.Errorf("Expected oldest job age of at least 600s, got %d", response.OldestAge)
+	}
+}
+
+func TestAdmin_ForceCleanup(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	jobID := "bbbbbbbb-1111-2222-3333-444444444444"
+	jobDir := filepath.Join(tmpDir, jobID)
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		t.Fatalf("Failed to create job dir: %v", err)
+	}
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusCompleted,
+		CreatedAt: time.Now().Add(-10 * time.Minute),
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	router := setupTestRouter()
+
+	// Default retention of one hour keeps the job
+	req := httptest.NewRequest("POST", "/api/v1/admin/cleanup", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if _, err := os.Stat(jobDir); err != nil {
+		t.Fatal("Job younger than the default retention should not be removed")
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/admin/cleanup?max_age=5m", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if _, err := os.Stat(jobDir); !os.IsNotExist(err) {
+		t.Error("Job directory should be removed with max_age=5m")
+	}
+	if handlers.GetConversionJob(jobID) != nil {
+		t.Error("Job should be removed from memory")
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/admin/cleanup?max_age=soon", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid max_age, got %d", http.StatusBadRequest, w.Code)
+	}
+}
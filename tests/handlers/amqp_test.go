@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// convertQueueRequest converts the request of a queue message without a broker
+func convertQueueRequest(t *testing.T, request handlers.QueueRequest) *handlers.QueueEvent {
+	t.Helper()
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+	event := handlers.NewQueueIngester(config.Load()).Convert(body)
+	if event.JobID != "" {
+		t.Cleanup(func() { handlers.DeleteConversionJob(event.JobID) })
+	}
+	return event
+}
+
+func TestQueueIngester_InlinedBook(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	event := convertQueueRequest(t, handlers.QueueRequest{
+		Filename: "book.fb2",
+		Content:  []byte(optionsTestFB2),
+		Metadata: &handlers.QueueBookMetadata{Title: "From the Queue"},
+	})
+
+	if event.Status != handlers.JobStatusCompleted {
+		t.Fatalf("Expected completed event, got %+v", event)
+	}
+	if event.Title != "From the Queue" || event.Filename != "book.fb2" {
+		t.Errorf("Expected the title and file name in the event, got %+v", event)
+	}
+	if event.DownloadURL != "/api/v1/download/"+event.JobID {
+		t.Errorf("Expected the download URL of the job, got %q", event.DownloadURL)
+	}
+
+	// The job is shared with the REST API
+	router := setupTestRouter()
+	if status := waitForJob(t, router, event.JobID); status["status"] != handlers.JobStatusCompleted {
+		t.Errorf("Expected the job over REST as well, got %v", status)
+	}
+}
+
+func TestQueueIngester_FileReference(t *testing.T) {
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "shared.fb2"), []byte(optionsTestFB2), 0600); err != nil {
+		t.Fatalf("Failed to write book: %v", err)
+	}
+	outside := filepath.Join(t.TempDir(), "outside.fb2")
+	if err := os.WriteFile(outside, []byte(optionsTestFB2), 0600); err != nil {
+		t.Fatalf("Failed to write book: %v", err)
+	}
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("AMQP_INPUT_DIR", inputDir)
+	defer os.Clearenv()
+
+	event := convertQueueRequest(t, handlers.QueueRequest{Path: "shared.fb2"})
+	if event.Status != handlers.JobStatusCompleted || event.Filename != "shared.fb2" {
+		t.Errorf("Expected the referenced book to be converted, got %+v", event)
+	}
+
+	for _, path := range []string{outside, "../outside.fb2", filepath.Join(inputDir, "..", "outside.fb2")} {
+		event := convertQueueRequest(t, handlers.QueueRequest{Path: path})
+		if event.Status != handlers.JobStatusFailed || !strings.Contains(event.Error, "outside AMQP_INPUT_DIR") {
+			t.Errorf("Expected %s to be refused, got %+v", path, event)
+		}
+	}
+
+	event = convertQueueRequest(t, handlers.QueueRequest{Path: "missing.fb2"})
+	if event.Status != handlers.JobStatusFailed || event.JobID != "" {
+		t.Errorf("Expected a missing file to fail without a job, got %+v", event)
+	}
+}
+
+func TestQueueIngester_InvalidRequests(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	event := handlers.NewQueueIngester(config.Load()).Convert([]byte("not json"))
+	if event.Status != handlers.JobStatusFailed || !strings.Contains(event.Error, "Invalid request") {
+		t.Errorf("Expected malformed JSON to fail, got %+v", event)
+	}
+
+	event = convertQueueRequest(t, handlers.QueueRequest{Path: "book.fb2"})
+	if event.Status != handlers.JobStatusFailed || !strings.Contains(event.Error, "AMQP_INPUT_DIR") {
+		t.Errorf("Expected file references to be disabled without AMQP_INPUT_DIR, got %+v", event)
+	}
+
+	event = convertQueueRequest(t, handlers.QueueRequest{Filename: "book.txt", Content: []byte("text")})
+	if event.Status != handlers.JobStatusFailed || !strings.Contains(event.Error, "Invalid file type") {
+		t.Errorf("Expected an unsupported file to fail, got %+v", event)
+	}
+}
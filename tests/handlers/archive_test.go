@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+const archiveTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description><title-info><book-title>Zipped Book</book-title></title-info></description>
+  <body><section><title><p>Chapter 1</p></title><p>Zipped text.</p></section></body>
+</FictionBook>`
+
+// createZipArchive builds a zip archive with the given entries
+func createZipArchive(t *testing.T, entries map[string]string) []byte {
+	t.Helper()
+
+	archive := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(archive)
+	for name, content := range entries {
+		entry, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write zip entry: %v", err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatalf("Failed to close zip writer: %v", err)
+	}
+	return archive.Bytes()
+}
+
+func TestConvertFB2ToEPUB_ZipUpload(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	archive := createZipArchive(t, map[string]string{"book.fb2": archiveTestFB2})
+	body, contentType := createConvertRequestBody(t, nil, formFile{"file", "book.fb2.zip", archive})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+
+	status := waitForJob(t, router, jobID)
+
+	if status["status"] != "completed" {
+		t.Errorf("Expected zipped book to convert, got %v", status)
+	}
+}
+
+func TestConvertFB2ToEPUB_ZipUploadRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+		env     map[string]string
+		status  int
+	}{
+		{"no fb2 entry", map[string]string{"readme.txt": "hello"}, nil, http.StatusBadRequest},
+		{"decompressed size", map[string]string{"book.fb2": archiveTestFB2},
+			map[string]string{"MAX_DECOMPRESSED_SIZE": "100"}, http.StatusRequestEntityTooLarge},
+		{"compression ratio", map[string]string{"book.fb2": strings.Repeat("a", 1<<20)},
+			nil, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TEMP_DIR", t.TempDir())
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+			}
+			defer os.Clearenv()
+
+			router := setupTestRouter()
+			body, contentType := createConvertRequestBody(t, nil, formFile{"file", "book.fb2.zip", createZipArchive(t, tt.entries)})
+			req := httptest.NewRequest("POST", "/api/v1/convert", body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getJobLog returns the events of the audit log of a job, in order
+func getJobLog(t *testing.T, router *gin.Engine, jobID string) []string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/jobs/"+jobID+"/log", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		JobID   string `json:"job_id"`
+		Entries []struct {
+			Event   string `json:"event"`
+			Message string `json:"message"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.JobID != jobID {
+		t.Errorf("Expected the log of job %s, got %s", jobID, response.JobID)
+	}
+	events := make([]string, 0, len(response.Entries))
+	for _, entry := range response.Entries {
+		events = append(events, entry.Event)
+	}
+	return events
+}
+
+// containsInOrder reports whether want appears in events in the same order,
+// possibly with other events in between
+func containsInOrder(events, want []string) bool {
+	next := 0
+	for _, event := range events {
+		if next < len(want) && event == want[next] {
+			next++
+		}
+	}
+	return next == len(want)
+}
+
+func TestGetJobLog(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"force": "true"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID := created["job_id"].(string)
+	waitForJob(t, router, jobID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/"+jobID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the download to succeed, got %d", w.Code)
+	}
+
+	events := getJobLog(t, router, jobID)
+	if !containsInOrder(events, []string{"received", "started", "stage", "validated", "completed", "downloaded"}) {
+		t.Errorf("Expected the events of a completed and downloaded job, got %v", events)
+	}
+
+	// The log outlives the job
+	req = httptest.NewRequest("POST", "/api/v1/admin/cleanup?max_age=0s", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	events = getJobLog(t, router, jobID)
+	if events[len(events)-1] != "cleaned" {
+		t.Errorf("Expected the cleanup to be logged last, got %v", events)
+	}
+}
+
+func TestGetJobLog_UnknownJob(t *testing.T) {
+	router := setupTestRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/jobs/ffffffff-0000-4000-8000-000000000000/log", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitRequestBody_DeclaredLength(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("MAX_FILE_SIZE", "1000")
+
+	router := setupTestRouter()
+	req := httptest.NewRequest("POST", "/api/v1/uploads", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = 1000 + 2*1024*1024
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Expected a JSON error, got %q", w.Body.String())
+	}
+	if !strings.Contains(response["error"].(string), "Request too large") {
+		t.Errorf("Unexpected error: %v", response["error"])
+	}
+}
+
+func TestLimitRequestBody_ChunkedBody(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("MAX_FILE_SIZE", "1000")
+
+	router := setupTestRouter()
+	// Without Content-Length the raw body is cut off once it passes the limit
+	body := io.MultiReader(strings.NewReader(optionsTestFB2), bytes.NewReader(make([]byte, 2*1024*1024)))
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", "application/x-fictionbook+xml")
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	}
+}
+
+func TestLimitRequestBody_AllowsMultipartOverhead(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil, formFile{"file", "book.fb2", []byte(optionsTestFB2)})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,75 @@
+package handlers_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestConvertFB2ToEPUB_Checksum(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	md5Sum := md5.Sum([]byte(optionsTestFB2))
+	sha256Sum := sha256.Sum256([]byte(optionsTestFB2))
+	truncated := sha256.Sum256([]byte(optionsTestFB2[:len(optionsTestFB2)/2]))
+
+	tests := []struct {
+		name     string
+		fields   map[string]string
+		headers  map[string]string
+		expected int
+		message  string
+	}{
+		{"no checksum", nil, nil, http.StatusAccepted, ""},
+		{"md5 header", nil, map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(md5Sum[:])}, http.StatusAccepted, ""},
+		{"sha256 header", nil, map[string]string{"X-Checksum-SHA256": hex.EncodeToString(sha256Sum[:])}, http.StatusAccepted, ""},
+		{"md5 field", map[string]string{"content_md5": hex.EncodeToString(md5Sum[:])}, nil, http.StatusAccepted, ""},
+		{"sha256 field", map[string]string{"checksum_sha256": base64.StdEncoding.EncodeToString(sha256Sum[:])}, nil, http.StatusAccepted, ""},
+		{"sha256 mismatch", map[string]string{"checksum_sha256": hex.EncodeToString(truncated[:])}, nil,
+			http.StatusBadRequest, "SHA-256 checksum mismatch: the upload has " + hex.EncodeToString(sha256Sum[:])},
+		{"md5 mismatch", nil, map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(make([]byte, 16))}, http.StatusBadRequest, "MD5 checksum mismatch"},
+		{"invalid checksum", nil, map[string]string{"X-Checksum-SHA256": "abc"}, http.StatusBadRequest, "Invalid SHA-256 checksum"},
+	}
+	router := setupTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]string{"force": "true"}
+			for name, value := range tt.fields {
+				fields[name] = value
+			}
+			body, contentType := createConvertRequestBody(t, fields)
+			req := httptest.NewRequest("POST", "/api/v1/convert", body)
+			req.Header.Set("Content-Type", contentType)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if jobID, ok := response["job_id"].(string); ok {
+				waitForJob(t, router, jobID)
+				defer handlers.DeleteConversionJob(jobID)
+			}
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if message, _ := response["error"].(string); !strings.Contains(message, tt.message) {
+				t.Errorf("Expected an error containing %q, got %q", tt.message, message)
+			}
+		})
+	}
+}
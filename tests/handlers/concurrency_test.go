@@ -18,6 +18,7 @@ func TestConcurrency_MultipleConversions(t *testing.T) {
 	tmpDir := t.TempDir()
 	os.Setenv("TEMP_DIR", tmpDir)
 	os.Setenv("MAX_FILE_SIZE", "10485760") // 10MB
+	os.Setenv("DEDUPLICATE_UPLOADS", "false") // Every upload must start its own job
 	defer os.Clearenv()
 
 	router := setupTestRouter()
@@ -192,7 +193,7 @@ func TestConcurrency_Downloads(t *testing.T) {
 	defer os.Clearenv()
 
 	// Create a completed job
-	jobID := "concurrent-download-job-id"
+	jobID := "c0c0c0c0-0000-4000-8000-000000000001"
 	jobDir := filepath.Join(tmpDir, jobID)
 	os.MkdirAll(jobDir, 0755)
 	
@@ -21,9 +21,33 @@ import (
 func setupTestRouter() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.POST("/api/v1/convert", handlers.ConvertFB2ToEPUB)
-	router.GET("/api/v1/status/:id", handlers.GetConversionStatus)
-	router.GET("/api/v1/download/:id", handlers.DownloadEPUB)
+	router.Use(handlers.LimitRequestBody())
+	router.Use(handlers.ValidateIDParam())
+	router.GET("/health", handlers.HealthCheck)
+
+	client := router.Group("/api/v1", handlers.RequireAPIKey())
+	client.POST("/convert", handlers.ConvertFB2ToEPUB)
+	client.POST("/validate", handlers.ValidateFB2)
+	client.POST("/inspect", handlers.InspectFB2)
+	client.GET("/status/:id", handlers.GetConversionStatus)
+	client.GET("/events", handlers.StreamJobStatus)
+	client.GET("/events/:id", handlers.StreamJobEvents)
+	client.GET("/download/:id", handlers.DownloadEPUB)
+	client.GET("/jobs/:id/log", handlers.GetJobLog)
+	client.POST("/uploads", handlers.CreateUpload)
+	client.GET("/uploads/:id", handlers.GetUpload)
+	client.PATCH("/uploads/:id", handlers.PatchUpload)
+	client.DELETE("/uploads/:id", handlers.DeleteUpload)
+	client.POST("/uploads/:id/complete", handlers.CompleteUpload)
+	client.GET("/usage", handlers.GetUsage)
+
+	router.GET("/api/v1/history", handlers.RequireAdminKey(), handlers.GetHistory)
+	router.GET("/api/v1/opds", handlers.RequireAdminKey(), handlers.GetOPDSCatalog)
+	router.POST("/api/v1/jobs/:id/retry", handlers.RequireAdminKey(), handlers.RetryConversion)
+
+	admin := router.Group("/api/v1/admin", handlers.RequireAdminKey())
+	admin.GET("/storage", handlers.GetStorageStats)
+	admin.POST("/cleanup", handlers.ForceCleanup)
 	return router
 }
 
@@ -70,6 +94,25 @@ func createTestFB2File(t *testing.T) (*bytes.Buffer, string) {
 	return body, contentType
 }
 
+// waitForJob polls the status endpoint until the job is no longer pending or processing
+func waitForJob(t *testing.T, router *gin.Engine, jobID string) map[string]interface{} {
+	t.Helper()
+
+	var status map[string]interface{}
+	for i := 0; i < 50; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/status/"+jobID, nil))
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Failed to parse status: %v", err)
+		}
+		if status["status"] != "pending" && status["status"] != "processing" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return status
+}
+
 func TestConvertFB2ToEPUB_ValidFile(t *testing.T) {
 	// Set up test environment
 	tmpDir := t.TempDir()
@@ -105,6 +148,10 @@ func TestConvertFB2ToEPUB_ValidFile(t *testing.T) {
 		t.Errorf("Expected status 'processing', got %v", response["status"])
 	}
 
+	if expiresAt, _ := response["expires_at"].(string); expiresAt == "" {
+		t.Error("Response should contain expires_at")
+	}
+
 	// Wait for async processing and cleanup
 	time.Sleep(500 * time.Millisecond)
 	
@@ -210,7 +257,7 @@ func TestGetConversionStatus_ValidJob(t *testing.T) {
 	defer os.Clearenv()
 
 	// Create a test job
-	jobID := "test-job-id"
+	jobID := "10000000-0000-4000-8000-000000000001"
 	job := &handlers.ConversionJob{
 		ID:        jobID,
 		Status:    handlers.JobStatusProcessing,
@@ -244,9 +291,39 @@ func TestGetConversionStatus_ValidJob(t *testing.T) {
 	}
 }
 
+func TestGetConversionStatus_ExpiresAt(t *testing.T) {
+	createdAt := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	jobID := "10000000-0000-4000-8000-000000000002"
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusCompleted,
+		CreatedAt: createdAt,
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	router := setupTestRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/status/"+jobID, nil))
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	// Requesting the status is an access, so the job expires an hour from now
+	lastAccess, err := time.Parse(time.RFC3339Nano, response["last_accessed_at"].(string))
+	if err != nil || !lastAccess.After(createdAt) {
+		t.Fatalf("Expected last_accessed_at after the creation, got %v", response["last_accessed_at"])
+	}
+	expiresAt, err := time.Parse(time.RFC3339Nano, response["expires_at"].(string))
+	if err != nil || !expiresAt.Equal(lastAccess.Add(time.Hour)) {
+		t.Errorf("Expected expires_at an hour after %v, got %v", lastAccess, response["expires_at"])
+	}
+}
+
 func TestGetConversionStatus_NonExistentJob(t *testing.T) {
 	router := setupTestRouter()
-	req := httptest.NewRequest("GET", "/api/v1/status/non-existent", nil)
+	req := httptest.NewRequest("GET", "/api/v1/status/ffffffff-0000-4000-8000-000000000000", nil)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -261,7 +338,7 @@ func TestGetConversionStatus_CompletedJob(t *testing.T) {
 	defer os.Clearenv()
 
 	// Create a completed job
-	jobID := "completed-job-id"
+	jobID := "10000000-0000-4000-8000-000000000003"
 	tmpDir := t.TempDir()
 	epubPath := filepath.Join(tmpDir, "output.epub")
 	
@@ -305,7 +382,7 @@ func TestGetConversionStatus_FailedJob(t *testing.T) {
 	os.Setenv("TEMP_DIR", t.TempDir())
 	defer os.Clearenv()
 
-	jobID := "failed-job-id"
+	jobID := "10000000-0000-4000-8000-000000000004"
 	job := &handlers.ConversionJob{
 		ID:        jobID,
 		Status:    handlers.JobStatusFailed,
@@ -335,14 +412,54 @@ func TestGetConversionStatus_FailedJob(t *testing.T) {
 	}
 }
 
-func TestDownloadEPUB_CompletedJob(t *testing.T) {
-	os.Setenv("TEMP_DIR", t.TempDir())
+func TestConvertFB2ToEPUB_Timeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	os.Setenv("CONVERSION_TIMEOUT", "1ns")
 	defer os.Clearenv()
 
-	jobID := "download-job-id"
+	router := setupTestRouter()
+	body, contentType := createTestFB2File(t)
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+
+	status := waitForJob(t, router, jobID)
+
+	if status["status"] != "failed" {
+		t.Fatalf("Expected the job to time out, got %v", status)
+	}
+	if errMsg, _ := status["error"].(string); !strings.Contains(errMsg, "timed out") {
+		t.Errorf("Expected a timeout error, got %q", errMsg)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, jobID)); !os.IsNotExist(err) {
+		t.Error("The temp directory of a timed out job should be removed")
+	}
+}
+
+func TestDownloadEPUB_CompletedJob(t *testing.T) {
 	tmpDir := t.TempDir()
-	epubPath := filepath.Join(tmpDir, "output.epub")
-	
+	os.Setenv("TEMP_DIR", tmpDir)
+	defer os.Clearenv()
+
+	jobID := "10000000-0000-4000-8000-000000000005"
+	// Results are only served from the directory of their job
+	epubPath := filepath.Join(tmpDir, jobID, "output.epub")
+	if err := os.MkdirAll(filepath.Dir(epubPath), 0755); err != nil {
+		t.Fatalf("Failed to create job directory: %v", err)
+	}
+
 	// Create a dummy EPUB file with content
 	file, err := os.Create(epubPath)
 	if err != nil {
@@ -383,7 +500,7 @@ func TestDownloadEPUB_CompletedJob(t *testing.T) {
 
 func TestDownloadEPUB_NonExistentJob(t *testing.T) {
 	router := setupTestRouter()
-	req := httptest.NewRequest("GET", "/api/v1/download/non-existent", nil)
+	req := httptest.NewRequest("GET", "/api/v1/download/ffffffff-0000-4000-8000-000000000000", nil)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -397,7 +514,7 @@ func TestDownloadEPUB_NotCompleted(t *testing.T) {
 	os.Setenv("TEMP_DIR", t.TempDir())
 	defer os.Clearenv()
 
-	jobID := "processing-job-id"
+	jobID := "10000000-0000-4000-8000-000000000006"
 	job := &handlers.ConversionJob{
 		ID:        jobID,
 		Status:    handlers.JobStatusProcessing,
@@ -496,8 +613,8 @@ func TestConvertFB2ToEPUB_JobCreation(t *testing.T) {
 	if job == nil {
 		t.Error("Job should be created in memory")
 	} else {
-		if job.Status != "processing" {
-			t.Errorf("Expected job status 'processing', got %s", job.Status)
+		if status := job.Snapshot().Status; status != "processing" {
+			t.Errorf("Expected job status 'processing', got %s", status)
 		}
 	}
 
@@ -573,7 +690,7 @@ func TestGetConversionStatus_Processing(t *testing.T) {
 	os.Setenv("TEMP_DIR", t.TempDir())
 	defer os.Clearenv()
 
-	jobID := "processing-job-id"
+	jobID := "10000000-0000-4000-8000-000000000006"
 	job := &handlers.ConversionJob{
 		ID:        jobID,
 		Status:    handlers.JobStatusProcessing,
@@ -609,13 +726,17 @@ func TestGetConversionStatus_Processing(t *testing.T) {
 }
 
 func TestDownloadEPUB_Headers(t *testing.T) {
-	os.Setenv("TEMP_DIR", t.TempDir())
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
 	defer os.Clearenv()
 
-	jobID := "download-headers-job-id"
-	tmpDir := t.TempDir()
-	epubPath := filepath.Join(tmpDir, "output.epub")
-	
+	jobID := "10000000-0000-4000-8000-000000000007"
+	// Results are only served from the directory of their job
+	epubPath := filepath.Join(tmpDir, jobID, "output.epub")
+	if err := os.MkdirAll(filepath.Dir(epubPath), 0755); err != nil {
+		t.Fatalf("Failed to create job directory: %v", err)
+	}
+
 	// Create a dummy EPUB file
 	file, err := os.Create(epubPath)
 	if err != nil {
@@ -662,14 +783,70 @@ func TestDownloadEPUB_Headers(t *testing.T) {
 	}
 }
 
+func TestDownloadEPUB_RangeAndETag(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TEMP_DIR", tmpDir)
+
+	jobID := "10000000-0000-4000-8000-000000000008"
+	epubPath := filepath.Join(tmpDir, jobID, "output.epub")
+	if err := os.MkdirAll(filepath.Dir(epubPath), 0755); err != nil {
+		t.Fatalf("Failed to create job directory: %v", err)
+	}
+	if err := os.WriteFile(epubPath, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("Failed to create test EPUB: %v", err)
+	}
+
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusCompleted,
+		CreatedAt: time.Now(),
+		FilePath:  epubPath,
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	router := setupTestRouter()
+	url := fmt.Sprintf("/api/v1/download/%s", jobID)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" || w.Header().Get("Content-Length") != "10" {
+		t.Fatalf("Expected ETag, Last-Modified and Content-Length headers, got %v", w.Header())
+	}
+
+	req := httptest.NewRequest("GET", url, nil)
+	req.Header.Set("Range", "bytes=4-")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusPartialContent, w.Code)
+	}
+	if w.Body.String() != "456789" || w.Header().Get("Content-Range") != "bytes 4-9/10" {
+		t.Errorf("Unexpected partial response %q with Content-Range %q",
+			w.Body.String(), w.Header().Get("Content-Range"))
+	}
+
+	req = httptest.NewRequest("GET", url, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d for a matching ETag, got %d", http.StatusNotModified, w.Code)
+	}
+}
+
 func TestDownloadEPUB_ValidFile(t *testing.T) {
-	os.Setenv("TEMP_DIR", t.TempDir())
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
 	defer os.Clearenv()
 
-	jobID := "download-valid-job-id"
-	tmpDir := t.TempDir()
-	epubPath := filepath.Join(tmpDir, "output.epub")
-	
+	jobID := "10000000-0000-4000-8000-000000000009"
+	// Results are only served from the directory of their job
+	epubPath := filepath.Join(tmpDir, jobID, "output.epub")
+	if err := os.MkdirAll(filepath.Dir(epubPath), 0755); err != nil {
+		t.Fatalf("Failed to create job directory: %v", err)
+	}
+
 	// Create a minimal valid EPUB (ZIP archive)
 	zipFile, err := os.Create(epubPath)
 	if err != nil {
@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/handlers"
+)
+
+func setupCORSRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handlers.CORS())
+	router.GET("/api/v1/status/:id", handlers.GetConversionStatus)
+	return router
+}
+
+func TestCORS_Disabled(t *testing.T) {
+	os.Clearenv()
+
+	req := httptest.NewRequest("GET", "/api/v1/status/missing", nil)
+	req.Header.Set("Origin", "https://books.example.com")
+	w := httptest.NewRecorder()
+	setupCORSRouter().ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("CORS headers should not be set without configured origins, got %q", got)
+	}
+}
+
+func TestCORS_AllowedOrigin(t *testing.T) {
+	os.Setenv("CORS_ALLOWED_ORIGINS", "https://books.example.com")
+	defer os.Clearenv()
+
+	router := setupCORSRouter()
+
+	req := httptest.NewRequest("GET", "/api/v1/status/missing", nil)
+	req.Header.Set("Origin", "https://books.example.com")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://books.example.com" {
+		t.Errorf("Expected the origin to be allowed, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Error("Download headers should be exposed to browsers")
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/status/missing", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Other origins should not be allowed, got %q", got)
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	os.Setenv("CORS_ALLOWED_ORIGINS", "*")
+	defer os.Clearenv()
+
+	router := setupCORSRouter()
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/convert", nil)
+	req.Header.Set("Origin", "https://books.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected wildcard origin, got %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PATCH, DELETE, OPTIONS" {
+		t.Errorf("Unexpected allowed methods %q", w.Header().Get("Access-Control-Allow-Methods"))
+	}
+}
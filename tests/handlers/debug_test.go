@@ -0,0 +1,68 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/handlers"
+)
+
+func setupDebugRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	debug := router.Group("/debug", handlers.RequireAdminKey())
+	debug.GET("/stats", handlers.GetRuntimeStats)
+	debug.GET("/pprof/*profile", handlers.Pprof)
+	return router
+}
+
+func TestDebug_RuntimeStats(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "secret")
+
+	req := httptest.NewRequest("GET", "/debug/stats", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w := httptest.NewRecorder()
+	setupDebugRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if goroutines, _ := response["goroutines"].(float64); goroutines < 1 {
+		t.Errorf("Expected a goroutine count, got %v", response["goroutines"])
+	}
+	heap, ok := response["heap"].(map[string]interface{})
+	if !ok || heap["alloc_bytes"].(float64) <= 0 {
+		t.Errorf("Expected heap statistics, got %v", response["heap"])
+	}
+	if _, ok := response["gc"].(map[string]interface{}); !ok {
+		t.Errorf("Expected GC statistics, got %v", response["gc"])
+	}
+}
+
+func TestDebug_Pprof(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "secret")
+	router := setupDebugRouter()
+
+	req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("Expected the pprof index, got status %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/debug/pprof/heap", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without the admin key, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+// startTestSMTPServer accepts a single SMTP session on a local port and sends
+// the recipient and message data it receives to the returned channel
+func startTestSMTPServer(t *testing.T) (port string, messages <-chan [2]string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	received := make(chan [2]string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+		reply("220 localhost ESMTP")
+		var recipient string
+		var data strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				recipient = strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>")
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 Send data")
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				received <- [2]string{recipient, data.String()}
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	_, port, _ = net.SplitHostPort(listener.Addr().String())
+	return port, received
+}
+
+func TestConvertFB2ToEPUB_EmailDelivery(t *testing.T) {
+	port, messages := startTestSMTPServer(t)
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("SMTP_HOST", "127.0.0.1")
+	os.Setenv("SMTP_PORT", port)
+	os.Setenv("SMTP_FROM", "books@example.com")
+	os.Setenv("DELIVERY_ALLOWED_DOMAINS", "kindle.com")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"email": "reader@kindle.com"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	waitForJob(t, router, jobID)
+	defer handlers.DeleteConversionJob(jobID)
+
+	select {
+	case message := <-messages:
+		if message[0] != "reader@kindle.com" {
+			t.Errorf("Expected the book to be sent to reader@kindle.com, got %q", message[0])
+		}
+		for _, expected := range []string{"From: books@example.com", "Subject: Test Book", "application/epub+zip", `filename=test.epub`} {
+			if !strings.Contains(message[1], expected) {
+				t.Errorf("Expected the message to contain %q, got:\n%s", expected, message[1])
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No email was sent")
+	}
+
+	var delivery map[string]interface{}
+	for i := 0; i < 50; i++ {
+		status := waitForJob(t, router, jobID)
+		delivery, _ = status["delivery"].(map[string]interface{})
+		if delivery != nil && delivery["status"] != handlers.DeliveryPending {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if delivery == nil || delivery["status"] != handlers.DeliverySent || delivery["to"] != "reader@kindle.com" {
+		t.Errorf("Expected the delivery to be reported as sent, got %v", delivery)
+	}
+}
+
+func TestConvertFB2ToEPUB_InvalidDelivery(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	convert := func(email string) *httptest.ResponseRecorder {
+		body, contentType := createConvertRequestBody(t, map[string]string{"email": email})
+		req := httptest.NewRequest("POST", "/api/v1/convert", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := convert("reader@kindle.com"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d while delivery is disabled, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	os.Setenv("SMTP_HOST", "127.0.0.1")
+	os.Setenv("DELIVERY_ALLOWED_DOMAINS", "kindle.com,pocketbook.cloud")
+	for _, email := range []string{"not an address", "reader@example.com", "reader@notkindle.com"} {
+		if w := convert(email); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", email, http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestConvertFB2ToEPUB_InsufficientStorage(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	os.Setenv("MIN_FREE_DISK_SPACE", "9000000000000000000") // More than any disk has
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createTestFB2File(t)
+
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusInsufficientStorage, w.Code, w.Body.String())
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("No job directory should be created when storage is insufficient, found %d", len(entries))
+	}
+}
@@ -0,0 +1,75 @@
+package handlers_test
+
+import (
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestDownloadEPUB_ContentDisposition(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TEMP_DIR", tmpDir)
+	router := setupTestRouter()
+
+	tests := []struct {
+		name     string
+		title    string
+		filename string
+		header   string // {id} stands for the job ID
+		parsed   string // File name a client decoding filename* sees
+	}{
+		{"ascii title", "Treasure Island", "upload.fb2",
+			`attachment; filename="Treasure Island.epub"`, "Treasure Island.epub"},
+		{"cyrillic title", "Война и мир", "book.fb2",
+			`attachment; filename="book_{id}.epub"; filename*=UTF-8''%D0%92%D0%BE%D0%B9%D0%BD%D0%B0%20%D0%B8%20%D0%BC%D0%B8%D1%80.epub`,
+			"Война и мир.epub"},
+		{"cjk file name", "", "紅樓夢.fb2.zip",
+			`attachment; filename="book_{id}.epub"; filename*=UTF-8''%E7%B4%85%E6%A8%93%E5%A4%A2.epub`,
+			"紅樓夢.epub"},
+		{"control and reserved characters", "Bad\x07 \"Name\"\r\nX-Injected: 1", "book.fb2",
+			`attachment; filename="Bad Name X-Injected 1.epub"`, "Bad Name X-Injected 1.epub"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobID := "30000000-0000-4000-8000-00000000000" + string(rune('1'+i))
+			epubPath := filepath.Join(tmpDir, jobID, "output.epub")
+			if err := os.MkdirAll(filepath.Dir(epubPath), 0755); err != nil {
+				t.Fatalf("Failed to create job directory: %v", err)
+			}
+			if err := os.WriteFile(epubPath, []byte("EPUB content"), 0644); err != nil {
+				t.Fatalf("Failed to create test EPUB: %v", err)
+			}
+			handlers.SetConversionJob(&handlers.ConversionJob{
+				ID:        jobID,
+				Status:    handlers.JobStatusCompleted,
+				CreatedAt: time.Now(),
+				FilePath:  epubPath,
+				Filename:  tt.filename,
+				Title:     tt.title,
+			})
+			defer handlers.DeleteConversionJob(jobID)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/"+jobID, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+
+			header := w.Header().Get("Content-Disposition")
+			want := strings.Replace(tt.header, "{id}", jobID, 1)
+			if header != want {
+				t.Errorf("Expected Content-Disposition\n%s\ngot\n%s", want, header)
+			}
+			if _, params, err := mime.ParseMediaType(header); err != nil || params["filename"] != tt.parsed {
+				t.Errorf("Expected clients to see %q, got %q (%v)", tt.parsed, params["filename"], err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestDownloadZIP_CompletedJob(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	defer os.Clearenv()
+
+	jobID := "10000000-0000-4000-8000-000000000d01"
+	epubPath := filepath.Join(tmpDir, jobID, "output.epub")
+	if err := os.MkdirAll(filepath.Dir(epubPath), 0755); err != nil {
+		t.Fatalf("Failed to create job directory: %v", err)
+	}
+	if err := os.WriteFile(epubPath, []byte("EPUB content"), 0644); err != nil {
+		t.Fatalf("Failed to create test EPUB: %v", err)
+	}
+
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusCompleted,
+		CreatedAt: time.Now(),
+		FilePath:  epubPath,
+		Title:     "Война и мир",
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	router := setupTestRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/"+jobID+".zip", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("Expected Content-Type application/zip, got %s", contentType)
+	}
+	expected := `attachment; filename="book_` + jobID + `.zip"; filename*=UTF-8''%D0%92%D0%BE%D0%B9%D0%BD%D0%B0%20%D0%B8%20%D0%BC%D0%B8%D1%80.zip`
+	if disposition := w.Header().Get("Content-Disposition"); disposition != expected {
+		t.Errorf("Expected Content-Disposition %q, got %q", expected, disposition)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Response is not a ZIP archive: %v", err)
+	}
+	if len(archive.File) != 1 || archive.File[0].Name != "Война и мир.epub" {
+		t.Fatalf("Expected the archive to hold Война и мир.epub, got %v", archive.File)
+	}
+	rc, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatalf("Failed to open the archived EPUB: %v", err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "EPUB content" {
+		t.Errorf("Expected the archived EPUB to be the result, got %q", data)
+	}
+}
+
+func TestDownloadZIP_Errors(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	jobID := "10000000-0000-4000-8000-000000000d02"
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusProcessing,
+		CreatedAt: time.Now(),
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{"not completed", "/api/v1/download/" + jobID + ".zip", http.StatusBadRequest},
+		{"unknown job", "/api/v1/download/ffffffff-0000-4000-8000-000000000000.zip", http.StatusNotFound},
+		{"invalid ID", "/api/v1/download/not-a-job.zip", http.StatusBadRequest},
+		{"other suffix", "/api/v1/download/" + jobID + ".tar", http.StatusBadRequest},
+	}
+	router := setupTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
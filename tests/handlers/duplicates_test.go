@@ -0,0 +1,68 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDuplicateUpload_ReturnsExistingJob(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	router := setupTestRouter()
+
+	w := postConvert(t, router, nil, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+	jobID := responseJSON(t, w)["job_id"].(string)
+	waitForJob(t, router, jobID)
+
+	w = postConvert(t, router, nil, nil)
+	second := responseJSON(t, w)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for a repeated upload, got %d: %v", http.StatusOK, w.Code, second)
+	}
+	if second["job_id"] != jobID || second["duplicate"] != true {
+		t.Errorf("Expected the existing job %s, got %v", jobID, second)
+	}
+	if second["download_url"] != "/api/v1/download/"+jobID {
+		t.Errorf("Expected the download link of the existing job, got %v", second["download_url"])
+	}
+}
+
+func TestDuplicateUpload_DifferentOptionsOrForce(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	router := setupTestRouter()
+
+	first := responseJSON(t, postConvert(t, router, nil, nil))
+	waitForJob(t, router, first["job_id"].(string))
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{"different options", map[string]string{"title": "Another Title"}},
+		{"kepub", map[string]string{"kepub": "true"}},
+		{"forced", map[string]string{"force": "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postConvert(t, router, tt.fields, nil)
+			if response := responseJSON(t, w); w.Code != http.StatusAccepted || response["job_id"] == first["job_id"] {
+				t.Errorf("Expected a new job, got status %d: %v", w.Code, response)
+			}
+		})
+	}
+}
+
+func TestDuplicateUpload_Disabled(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("DEDUPLICATE_UPLOADS", "false")
+	router := setupTestRouter()
+
+	first := responseJSON(t, postConvert(t, router, nil, nil))
+	waitForJob(t, router, first["job_id"].(string))
+
+	if w := postConvert(t, router, nil, nil); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d with deduplication disabled, got %d", http.StatusAccepted, w.Code)
+	}
+}
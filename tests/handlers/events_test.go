@@ -0,0 +1,237 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+// sseEvent is one event read from a Server-Sent Events stream
+type sseEvent struct {
+	Name string
+	Data map[string]interface{}
+}
+
+// readSSEvents reads events from stream until it ends or stop returns true
+func readSSEvents(t *testing.T, stream io.Reader, stop func(sseEvent) bool) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.Name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &current.Data); err != nil {
+				t.Fatalf("Invalid event data %q: %v", line, err)
+			}
+		case line == "" && current.Name != "":
+			events = append(events, current)
+			if stop != nil && stop(current) {
+				return events
+			}
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+func TestStreamJobEvents_Conversion(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	body, contentType := createTestFB2File(t)
+	resp, err := http.Post(server.URL+"/api/v1/convert", contentType, body)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	var created map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	resp.Body.Close()
+	jobID, _ := created["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err = client.Get(server.URL + "/api/v1/events/" + jobID)
+	if err != nil {
+		t.Fatalf("Event stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Expected an event stream, got Content-Type %q", ct)
+	}
+
+	// The stream ends by itself after the final status event
+	events := readSSEvents(t, resp.Body, nil)
+	if len(events) == 0 {
+		t.Fatal("Expected at least one event")
+	}
+	last := events[len(events)-1]
+	if last.Name != "status" || last.Data["status"] != "completed" {
+		t.Errorf("Expected a final completed status event, got %+v", last)
+	}
+}
+
+func TestStreamJobEvents_Snapshot(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	jobID := "e0000000-0000-4000-8000-000000000001"
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusProcessing,
+		CreatedAt: time.Now(),
+		Stage:     "content",
+		Progress:  60,
+		Preview:   "<h1>Chapter 1</h1>",
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(server.URL + "/api/v1/events/" + jobID)
+	if err != nil {
+		t.Fatalf("Event stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// A running job stays open, so stop reading after the preview
+	events := readSSEvents(t, resp.Body, func(event sseEvent) bool { return event.Name == "preview" })
+	if len(events) != 2 {
+		t.Fatalf("Expected progress and preview events, got %+v", events)
+	}
+	if events[0].Name != "progress" || events[0].Data["stage"] != "content" || events[0].Data["percent"] != float64(60) {
+		t.Errorf("Unexpected progress event %+v", events[0])
+	}
+	if events[1].Data["html"] != "<h1>Chapter 1</h1>" {
+		t.Errorf("Unexpected preview event %+v", events[1])
+	}
+}
+
+func TestStreamJobEvents_FinishedAndMissingJobs(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	jobID := "e0000000-0000-4000-8000-000000000002"
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusFailed,
+		CreatedAt: time.Now(),
+		Error:     "Failed to parse FB2: test error",
+	})
+	defer handlers.DeleteConversionJob(jobID)
+
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/events/" + jobID)
+	if err != nil {
+		t.Fatalf("Event stream request failed: %v", err)
+	}
+	events := readSSEvents(t, resp.Body, nil)
+	resp.Body.Close()
+	if len(events) != 1 || events[0].Name != "status" || events[0].Data["error"] == nil {
+		t.Errorf("Expected a single failed status event, got %+v", events)
+	}
+
+	resp, err = http.Get(server.URL + "/api/v1/events/ffffffff-0000-4000-8000-000000000000")
+	if err != nil {
+		t.Fatalf("Event stream request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown job, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestStreamJobStatus(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	finishedID := "status-stream-finished-job"
+	handlers.SetConversionJob(&handlers.ConversionJob{
+		ID:        finishedID,
+		Status:    handlers.JobStatusCompleted,
+		CreatedAt: time.Now(),
+	})
+	defer handlers.DeleteConversionJob(finishedID)
+
+	server := httptest.NewServer(setupTestRouter())
+	defer server.Close()
+
+	body, contentType := createTestFB2File(t)
+	resp, err := http.Post(server.URL+"/api/v1/convert", contentType, body)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	var created map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	resp.Body.Close()
+	jobID, _ := created["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err = client.Get(server.URL + "/api/v1/events?jobs=" + finishedID + "," + jobID)
+	if err != nil {
+		t.Fatalf("Status stream request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The stream ends by itself once both jobs have finished
+	final := make(map[string]interface{})
+	for _, event := range readSSEvents(t, resp.Body, nil) {
+		if event.Name != "status" {
+			t.Errorf("Expected only status events, got %q", event.Name)
+		}
+		id, _ := event.Data["id"].(string)
+		final[id] = event.Data["status"]
+	}
+	if final[finishedID] != "completed" || final[jobID] != "completed" {
+		t.Errorf("Expected both jobs to end completed, got %v", final)
+	}
+}
+
+func TestStreamJobStatus_InvalidRequests(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusBadRequest},
+		{"?jobs=,", http.StatusBadRequest},
+		{"?jobs=missing-job", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/events"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("GET /api/v1/events%s: expected status %d, got %d", tt.query, tt.status, w.Code)
+		}
+	}
+}
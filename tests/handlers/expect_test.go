@@ -0,0 +1,124 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+// sendHeadersExpectingContinue sends the headers of a convert request with
+// Expect: 100-continue but not its body, and returns the first response
+func sendHeadersExpectingContinue(t *testing.T, server *httptest.Server, headers string) *http.Response {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("Failed to set deadline: %v", err)
+	}
+
+	request := "POST /api/v1/convert HTTP/1.1\r\nHost: " + server.Listener.Addr().String() + "\r\n" +
+		"Expect: 100-continue\r\n" + headers + "\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("Failed to send headers: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestExpectContinue_RefusesLargeUploadBeforeBody(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("MAX_FILE_SIZE", "1000")
+	server := httptest.NewServer(setupTestRouter())
+	t.Cleanup(server.Close) // After the connections left open by the client
+
+	resp := sendHeadersExpectingContinue(t, server,
+		"Content-Type: application/x-fictionbook+xml\r\nContent-Length: 5000\r\n")
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d before the body was sent, got %d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+}
+
+func TestExpectContinue_RefusesExhaustedQuotaBeforeBody(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	key := fmt.Sprintf("key-expect-%d", time.Now().UnixNano())
+	t.Setenv("API_KEYS", key)
+	t.Setenv("QUOTA_CONCURRENT_JOBS", "1")
+	release := setupStalledDelivery(t)
+	router := setupTestRouter()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close) // After the connections left open by the client
+
+	// A job whose delivery stalls takes the only slot of the key
+	w := postConvert(t, router, stalledJobFields, map[string]string{"X-API-Key": key})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var started map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	defer handlers.DeleteConversionJob(started["job_id"].(string))
+	defer waitForFreeSlots(t, router, key)
+	defer release()
+
+	resp := sendHeadersExpectingContinue(t, server,
+		"X-API-Key: "+key+"\r\nContent-Type: multipart/form-data; boundary=x\r\nContent-Length: 500\r\n")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d before the body was sent, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+
+	// Without a key the request is not limited and gets to send its body
+	resp = sendHeadersExpectingContinue(t, server,
+		"Content-Type: multipart/form-data; boundary=x\r\nContent-Length: 500\r\n")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for a missing key, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestExpectContinue_AcceptedUpload(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	router := setupTestRouter()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	body, contentType := createConvertRequestBody(t, map[string]string{"force": "true"})
+	req, err := http.NewRequest("POST", server.URL+"/api/v1/convert", body)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Expect", "100-continue")
+	// Without the 100 Continue the client would give up waiting and send the body after a minute
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: time.Minute}, Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+
+	var created map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID := fmt.Sprint(created["job_id"])
+	waitForJob(t, router, jobID)
+	handlers.DeleteConversionJob(jobID)
+}
@@ -0,0 +1,186 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/conversionpb"
+	"github.com/lex/fb2epub/handlers"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startTestGRPCServer serves the conversion service on a local port and
+// returns a client connected to it
+func startTestGRPCServer(t *testing.T) conversionpb.ConversionServiceClient {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := handlers.NewGRPCServer(config.Load())
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conversionpb.NewConversionServiceClient(conn)
+}
+
+// waitForGRPCJob polls the status of a job until it is no longer pending or processing
+func waitForGRPCJob(t *testing.T, client conversionpb.ConversionServiceClient, jobID string) *conversionpb.JobStatus {
+	t.Helper()
+
+	var job *conversionpb.JobStatus
+	for i := 0; i < 50; i++ {
+		var err error
+		job, err = client.GetStatus(context.Background(), &conversionpb.GetStatusRequest{JobId: jobID})
+		if err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+		if job.Status != handlers.JobStatusPending && job.Status != handlers.JobStatusProcessing {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return job
+}
+
+func TestGRPCConvertAndDownload(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	client := startTestGRPCServer(t)
+	started, err := client.Convert(context.Background(), &conversionpb.ConvertRequest{
+		Filename: "book.fb2",
+		Content:  []byte(optionsTestFB2),
+		Metadata: &conversionpb.BookMetadata{Title: "Over gRPC"},
+	})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	job := waitForGRPCJob(t, client, started.JobId)
+	defer handlers.DeleteConversionJob(started.JobId)
+	if job.Status != handlers.JobStatusCompleted {
+		t.Fatalf("Expected completed job, got %s: %s", job.Status, job.Error)
+	}
+
+	// The job is shared with the REST API
+	router := setupTestRouter()
+	if status := waitForJob(t, router, started.JobId); status["status"] != handlers.JobStatusCompleted {
+		t.Errorf("Expected the job over REST as well, got %v", status)
+	}
+
+	stream, err := client.Download(context.Background(), &conversionpb.DownloadRequest{JobId: started.JobId})
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	var data bytes.Buffer
+	var filename, contentType string
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to receive chunk: %v", err)
+		}
+		if filename == "" {
+			filename, contentType = chunk.Filename, chunk.ContentType
+		}
+		data.Write(chunk.Data)
+	}
+
+	if filename != "Over gRPC.epub" {
+		t.Errorf("Expected the title as file name, got %q", filename)
+	}
+	if contentType != "application/epub+zip" {
+		t.Errorf("Expected EPUB content type, got %q", contentType)
+	}
+	if !bytes.HasPrefix(data.Bytes(), []byte("PK")) {
+		t.Error("Expected the downloaded EPUB to be a ZIP archive")
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	client := startTestGRPCServer(t)
+	ctx := context.Background()
+
+	_, err := client.GetStatus(ctx, &conversionpb.GetStatusRequest{JobId: "10000000-0000-4000-8000-000000000639"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown job, got %v", err)
+	}
+
+	_, err = client.Convert(ctx, &conversionpb.ConvertRequest{Filename: "book.txt", Content: []byte("text")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an unsupported file, got %v", err)
+	}
+
+	_, err = client.Convert(ctx, &conversionpb.ConvertRequest{
+		Filename:     "book.fb2",
+		Content:      []byte(optionsTestFB2),
+		OutputFormat: "fb2",
+	})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Expected InvalidArgument for an unsupported conversion, got %v", err)
+	}
+
+	stream, err := client.Download(ctx, &conversionpb.DownloadRequest{JobId: "10000000-0000-4000-8000-000000000639"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound downloading an unknown job, got %v", err)
+	}
+
+	// Books over the size limit are refused like a 413 over REST
+	os.Setenv("MAX_FILE_SIZE", "100")
+	_, err = client.Convert(ctx, &conversionpb.ConvertRequest{Filename: "book.fb2", Content: []byte(optionsTestFB2)})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted for a book over the size limit, got %v", err)
+	}
+}
+
+func TestGRPCRequiresAPIKey(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("API_KEYS", "client-key")
+	defer os.Clearenv()
+
+	client := startTestGRPCServer(t)
+	request := &conversionpb.GetStatusRequest{JobId: "10000000-0000-4000-8000-000000000639"}
+
+	if _, err := client.GetStatus(context.Background(), request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a key, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "wrong-key")
+	if _, err := client.GetStatus(ctx, request); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied with an unknown key, got %v", err)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer client-key")
+	if _, err := client.GetStatus(ctx, request); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected the key to be accepted, got %v", err)
+	}
+}
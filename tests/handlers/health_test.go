@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// getHealth requests /health with the given admin key, if any
+func getHealth(t *testing.T, key string) map[string]interface{} {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	if key != "" {
+		req.Header.Set("X-Admin-Key", key)
+	}
+	w := httptest.NewRecorder()
+	setupTestRouter().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response
+}
+
+func TestHealth_PipelineDetails(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	router := setupTestRouter()
+
+	// A failed conversion shows up as the last failure
+	body, contentType := createConvertRequestBody(t, nil, formFile{"file", "broken.fb2", []byte("<FictionBook><body>")})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	waitForJob(t, router, response["job_id"].(string))
+
+	health := getHealth(t, "")
+	if health["status"] != "ok" || health["service"] != "fb2epub" {
+		t.Errorf("Unexpected status %v", health)
+	}
+	jobs, ok := health["jobs_by_status"].(map[string]interface{})
+	if !ok || jobs["failed"].(float64) < 1 {
+		t.Errorf("Expected failed jobs to be counted, got %v", health["jobs_by_status"])
+	}
+	if _, ok := health["queue_depth"]; !ok {
+		t.Error("Expected the queue depth")
+	}
+	failure, ok := health["last_failure"].(map[string]interface{})
+	if !ok || !strings.HasPrefix(failure["error"].(string), "Failed to parse FB2") {
+		t.Errorf("Expected the last failure, got %v", health["last_failure"])
+	}
+}
+
+func TestHealth_DetailsRequireAdminKey(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("ADMIN_API_KEY", "secret")
+
+	if health := getHealth(t, ""); health["jobs_by_status"] != nil || health["status"] != "ok" {
+		t.Errorf("Expected only the status without the admin key, got %v", health)
+	}
+	if health := getHealth(t, "secret"); health["jobs_by_status"] == nil {
+		t.Errorf("Expected pipeline details with the admin key, got %v", health)
+	}
+}
@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func getHistory(t *testing.T, router http.Handler, query string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/api/v1/history"+query, nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetHistory_RecordsConversions(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	os.Setenv("HISTORY_DB", filepath.Join(t.TempDir(), "history.db"))
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createTestFB2File(t)
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	waitForJob(t, router, jobID)
+
+	w = getHistory(t, router, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Entries []handlers.HistoryEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Entries) != 1 {
+		t.Fatalf("Expected 1 history entry, got %d", len(response.Entries))
+	}
+
+	entry := response.Entries[0]
+	if entry.JobID != jobID || entry.Status != handlers.JobStatusCompleted {
+		t.Errorf("Unexpected history entry %+v", entry)
+	}
+	if entry.Filename != "test.fb2" || entry.Title != "Test Book" {
+		t.Errorf("Expected filename test.fb2 and title 'Test Book', got %q and %q", entry.Filename, entry.Title)
+	}
+	if entry.InputSize == 0 || entry.OutputSize == 0 {
+		t.Errorf("Expected input and output sizes, got %d and %d", entry.InputSize, entry.OutputSize)
+	}
+	if entry.DownloadURL != "/api/v1/download/"+jobID {
+		t.Errorf("Expected a download URL while the EPUB exists, got %q", entry.DownloadURL)
+	}
+
+	// History outlives the job and its files
+	handlers.DeleteConversionJob(jobID)
+	w = getHistory(t, router, "?limit=10")
+	response.Entries = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(response.Entries) != 1 || response.Entries[0].DownloadURL != "" {
+		t.Errorf("Expected the entry to remain without a download URL, got %+v", response.Entries)
+	}
+}
+
+func TestGetHistory_InvalidRequests(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	if w := getHistory(t, router, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d when history is disabled, got %d", http.StatusNotFound, w.Code)
+	}
+
+	os.Setenv("HISTORY_DB", filepath.Join(t.TempDir(), "history.db"))
+	for _, query := range []string{"?limit=0", "?limit=1000", "?limit=many"} {
+		if w := getHistory(t, router, query); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/v1/history%s: expected status %d, got %d", query, http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+// writeHookScript writes an executable shell script for use as a hook
+func writeHookScript(t *testing.T, dir, name, script string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	//nolint:gosec // The hook must be executable
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+	return path
+}
+
+// convertWithHooks uploads the test book and returns its finished status
+func convertWithHooks(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"force": "true", "title": "Hooked"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID := response["job_id"].(string)
+	status := waitForJob(t, router, jobID)
+	t.Cleanup(func() { handlers.DeleteConversionJob(jobID) })
+	return status
+}
+
+func TestPostConversionHooks(t *testing.T) {
+	hookDir := t.TempDir()
+	record := filepath.Join(hookDir, "record.txt")
+	hook := writeHookScript(t, hookDir, "record.sh",
+		`echo "$1 $FB2EPUB_TITLE $FB2EPUB_OUTPUT_FORMAT $FB2EPUB_JOB_ID" > "`+record+`"`+"\n")
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("POST_CONVERSION_HOOKS", hook+" --flag")
+	defer os.Clearenv()
+
+	status := convertWithHooks(t)
+	if status["status"] != handlers.JobStatusCompleted {
+		t.Fatalf("Expected completed job, got %v", status)
+	}
+	if _, ok := status["warnings"]; ok {
+		t.Errorf("Expected no warnings from a successful hook, got %v", status["warnings"])
+	}
+
+	recorded, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("Expected the hook to run: %v", err)
+	}
+	fields := strings.Fields(string(recorded))
+	if len(fields) != 4 || fields[0] != "--flag" {
+		t.Fatalf("Expected the configured argument first, got %q", recorded)
+	}
+	if fields[1] != "Hooked" || fields[2] != "epub" || fields[3] != status["id"] {
+		t.Errorf("Expected the job metadata in the environment, got %q", recorded)
+	}
+}
+
+func TestPostConversionHooks_FailureIsWarning(t *testing.T) {
+	hookDir := t.TempDir()
+	failing := writeHookScript(t, hookDir, "epubcheck.sh", "echo 'ERROR(RSC-005): invalid markup' >&2\nexit 1\n")
+	slow := writeHookScript(t, hookDir, "slow.sh", "exec sleep 5\n")
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("POST_CONVERSION_HOOKS", failing+", "+slow)
+	os.Setenv("HOOK_TIMEOUT", "100ms")
+	defer os.Clearenv()
+
+	status := convertWithHooks(t)
+	if status["status"] != handlers.JobStatusCompleted {
+		t.Fatalf("Expected the job to complete despite failing hooks, got %v", status)
+	}
+
+	warnings, _ := status["warnings"].([]interface{})
+	if len(warnings) != 2 {
+		t.Fatalf("Expected a warning per failed hook, got %v", status["warnings"])
+	}
+	first := warnings[0].(map[string]interface{})["message"].(string)
+	if !strings.Contains(first, "epubcheck.sh failed") || !strings.Contains(first, "RSC-005") {
+		t.Errorf("Expected the hook output in the warning, got %q", first)
+	}
+	second := warnings[1].(map[string]interface{})["message"].(string)
+	if !strings.Contains(second, "timed out after 100ms") {
+		t.Errorf("Expected the slow hook to time out, got %q", second)
+	}
+}
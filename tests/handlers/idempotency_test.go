@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestConvertFB2ToEPUB_IdempotencyKey(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	first := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"Idempotency-Key": "retry-me"})
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", first.Code, first.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(first.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID := created["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+	waitForJob(t, router, jobID)
+
+	retried := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"Idempotency-Key": "retry-me"})
+	if retried.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for a retried request, got %d: %s", retried.Code, retried.Body.String())
+	}
+	if retried.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected the replayed response to be marked")
+	}
+	var replayed map[string]interface{}
+	if err := json.Unmarshal(retried.Body.Bytes(), &replayed); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if replayed["job_id"] != jobID {
+		t.Errorf("Expected the original job %s, got %v", jobID, replayed["job_id"])
+	}
+
+	other := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"Idempotency-Key": "another-request"})
+	if other.Code != http.StatusAccepted {
+		t.Fatalf("Expected a new job for another key, got %d: %s", other.Code, other.Body.String())
+	}
+	var otherJob map[string]interface{}
+	if err := json.Unmarshal(other.Body.Bytes(), &otherJob); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	defer handlers.DeleteConversionJob(otherJob["job_id"].(string))
+	waitForJob(t, router, otherJob["job_id"].(string))
+	if otherJob["job_id"] == jobID {
+		t.Error("Expected a different key to start a different job")
+	}
+}
+
+func TestConvertFB2ToEPUB_IdempotencyKeyFreedOnFailure(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	failed := postConvert(t, router, map[string]string{"toc_depth": "deep"}, map[string]string{"Idempotency-Key": "fix-and-retry"})
+	if failed.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", failed.Code)
+	}
+
+	fixed := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"Idempotency-Key": "fix-and-retry"})
+	if fixed.Code != http.StatusAccepted {
+		t.Fatalf("Expected the key to be usable after a failed request, got %d: %s", fixed.Code, fixed.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(fixed.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	waitForJob(t, router, created["job_id"].(string))
+	handlers.DeleteConversionJob(created["job_id"].(string))
+
+	tooLong := postConvert(t, router, nil, map[string]string{"Idempotency-Key": strings.Repeat("k", 256)})
+	if tooLong.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an overlong key, got %d", tooLong.Code)
+	}
+}
+
+func TestConvertFB2ToEPUB_IdempotencyKeyFreedWithJob(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	first := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"Idempotency-Key": "reused-later"})
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", first.Code, first.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(first.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	waitForJob(t, router, created["job_id"].(string))
+	handlers.DeleteConversionJob(created["job_id"].(string))
+
+	again := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"Idempotency-Key": "reused-later"})
+	if again.Code != http.StatusAccepted {
+		t.Fatalf("Expected a new job once the first one was removed, got %d: %s", again.Code, again.Body.String())
+	}
+	var recreated map[string]interface{}
+	if err := json.Unmarshal(again.Body.Bytes(), &recreated); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	defer handlers.DeleteConversionJob(recreated["job_id"].(string))
+	waitForJob(t, router, recreated["job_id"].(string))
+	if recreated["job_id"] == created["job_id"] {
+		t.Error("Expected the removed job not to be replayed")
+	}
+}
+
+func TestConvertFB2ToEPUB_IdempotencyKeysOfDuplicateFreedWithJob(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	before := handlers.IdempotencyKeysInUse()
+	first := postConvert(t, router, nil, map[string]string{"Idempotency-Key": "first-key"})
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", first.Code, first.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(first.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID := created["job_id"].(string)
+	waitForJob(t, router, jobID)
+
+	// The same book under another key is answered with the existing job
+	second := postConvert(t, router, nil, map[string]string{"Idempotency-Key": "second-key"})
+	var duplicate map[string]interface{}
+	if err := json.Unmarshal(second.Body.Bytes(), &duplicate); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if duplicate["job_id"] != jobID {
+		t.Fatalf("Expected the duplicate upload to return job %s, got %v", jobID, duplicate)
+	}
+	if used := handlers.IdempotencyKeysInUse(); used != before+2 {
+		t.Errorf("Expected both keys to be remembered, got %d keys after %d", used, before)
+	}
+
+	handlers.DeleteConversionJob(jobID)
+	if used := handlers.IdempotencyKeysInUse(); used != before {
+		t.Errorf("Expected both keys to be freed with the job, got %d keys after %d", used, before)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestIDParam_RejectsNonUUIDs(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	router := setupTestRouter()
+
+	for _, path := range []string{
+		"/api/v1/status/not-a-job",
+		"/api/v1/status/..%2F..%2Fetc",
+		"/api/v1/download/550E8400-E29B-41D4-A716-446655440000",
+		"/api/v1/events/{550e8400-e29b-41d4-a716-446655440000}",
+		"/api/v1/uploads/550e8400e29b41d4a716446655440000",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusBadRequest, path, w.Code)
+		}
+	}
+
+	// Well-formed IDs reach the handlers
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/status/550e8400-e29b-41d4-a716-446655440000", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown job, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDownloadEPUB_RefusesFilesOutsideJobDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TEMP_DIR", tmpDir)
+
+	outside := filepath.Join(t.TempDir(), "secret.epub")
+	if err := os.WriteFile(outside, []byte("secret"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		id       string
+		filePath string
+	}{
+		{"outside temp dir", "20000000-0000-4000-8000-000000000001", outside},
+		{"traversal", "20000000-0000-4000-8000-000000000002",
+			filepath.Join(tmpDir, "20000000-0000-4000-8000-000000000002", "..", "..", filepath.Base(filepath.Dir(outside)), "secret.epub")},
+		{"other job", "20000000-0000-4000-8000-000000000003",
+			filepath.Join(tmpDir, "20000000-0000-4000-8000-000000000004", "output.epub")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers.SetConversionJob(&handlers.ConversionJob{
+				ID:        tt.id,
+				Status:    handlers.JobStatusCompleted,
+				CreatedAt: time.Now(),
+				FilePath:  tt.filePath,
+			})
+			defer handlers.DeleteConversionJob(tt.id)
+
+			router := setupTestRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/"+tt.id, nil))
+			if w.Code != http.StatusNotFound || w.Body.String() == "secret" {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+			}
+		})
+	}
+}
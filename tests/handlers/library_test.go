@@ -0,0 +1,82 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// convertToLibrary converts optionsTestFB2 with the given metadata overrides
+// and returns the library path reported by the finished job
+func convertToLibrary(t *testing.T, router *gin.Engine, fields map[string]string) string {
+	t.Helper()
+
+	body, contentType := createConvertRequestBody(t, fields)
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	status := waitForJob(t, router, response["job_id"].(string))
+	if status["status"] != "completed" {
+		t.Fatalf("Expected completed job, got %v", status)
+	}
+	path, _ := status["library_path"].(string)
+	return path
+}
+
+func TestLibrary_AuthorSeriesTitle(t *testing.T) {
+	libraryDir := t.TempDir()
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("LIBRARY_DIR", libraryDir)
+	router := setupTestRouter()
+
+	fields := map[string]string{"author": "Jane Doe", "series": "Saga: Part/One", "title": "First Book"}
+	first := convertToLibrary(t, router, fields)
+	if first != "Jane Doe/Saga Part One/First Book.epub" {
+		t.Errorf("Unexpected library path %q", first)
+	}
+	if _, err := os.Stat(filepath.Join(libraryDir, first)); err != nil {
+		t.Errorf("Expected the book in the library: %v", err)
+	}
+
+	// A second book with the same name must not replace the first
+	fields["force"] = "true"
+	if second := convertToLibrary(t, router, fields); second != "Jane Doe/Saga Part One/First Book (2).epub" {
+		t.Errorf("Unexpected library path for the duplicate %q", second)
+	}
+
+	// Books without author or series fall back to the unknown author folder
+	if path := convertToLibrary(t, router, nil); path != "Unknown Author/Test Book.epub" {
+		t.Errorf("Unexpected library path %q", path)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(libraryDir, "Jane Doe", "Saga Part One"))
+	if err != nil {
+		t.Fatalf("Failed to read library: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 books and no temporary files, got %d entries", len(entries))
+	}
+}
+
+func TestLibrary_Disabled(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	router := setupTestRouter()
+
+	if path := convertToLibrary(t, router, nil); path != "" {
+		t.Errorf("Expected no library path without LIBRARY_DIR, got %q", path)
+	}
+}
@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+type opdsTestFeed struct {
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+func TestGetOPDSCatalog_ListsAvailableBooks(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	os.Setenv("HISTORY_DB", filepath.Join(t.TempDir(), "history.db"))
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createTestFB2File(t)
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	waitForJob(t, router, jobID)
+
+	// OPDS readers authenticate with Basic auth
+	req = httptest.NewRequest("GET", "/api/v1/opds", nil)
+	req.SetBasicAuth("reader", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, "profile=opds-catalog") {
+		t.Errorf("Expected an OPDS content type, got %q", ct)
+	}
+
+	var feed opdsTestFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Feed is not valid XML: %v", err)
+	}
+	if len(feed.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(feed.Entries))
+	}
+	entry := feed.Entries[0]
+	if entry.Title != "Test Book" || entry.ID != "urn:uuid:"+jobID {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if len(entry.Links) != 1 || entry.Links[0].Rel != "http://opds-spec.org/acquisition" ||
+		entry.Links[0].Href != "/api/v1/download/"+jobID || entry.Links[0].Type != "application/epub+zip" {
+		t.Errorf("Expected an EPUB acquisition link, got %+v", entry.Links)
+	}
+
+	// Books whose files are gone cannot be downloaded and leave the catalog
+	handlers.DeleteConversionJob(jobID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	feed = opdsTestFeed{}
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Feed is not valid XML: %v", err)
+	}
+	if len(feed.Entries) != 0 {
+		t.Errorf("Expected no entries after the job is deleted, got %d", len(feed.Entries))
+	}
+}
+
+func TestGetOPDSCatalog_RequiresKeyAndHistory(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	req := httptest.NewRequest("GET", "/api/v1/opds", nil)
+	req.SetBasicAuth("reader", "wrong")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d with a wrong password, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if challenge := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, "Basic") {
+		t.Errorf("Expected a Basic auth challenge, got %q", challenge)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/opds", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d when history is disabled, got %d", http.StatusNotFound, w.Code)
+	}
+}
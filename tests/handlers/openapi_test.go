@@ -0,0 +1,62 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+type openAPIDocument struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func TestGetOpenAPISpec(t *testing.T) {
+	router := setupTestRouter()
+	router.GET("/api/v1/openapi.json", handlers.GetOpenAPISpec)
+
+	req := httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("OpenAPI spec is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+}
+
+// TestOpenAPISpec_CoversRoutes keeps the spec in sync with the registered API routes
+func TestOpenAPISpec_CoversRoutes(t *testing.T) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(handlers.OpenAPISpec(), &doc); err != nil {
+		t.Fatalf("OpenAPI spec is not valid JSON: %v", err)
+	}
+
+	router := setupTestRouter()
+	router.GET("/api/v1/openapi.json", handlers.GetOpenAPISpec)
+
+	param := regexp.MustCompile(`:(\w+)`)
+	for _, route := range router.Routes() {
+		path := param.ReplaceAllString(route.Path, "{$1}")
+		operations, ok := doc.Paths[path]
+		if !ok {
+			t.Errorf("OpenAPI spec is missing path %s", path)
+			continue
+		}
+		if _, ok := operations[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("OpenAPI spec is missing %s %s", route.Method, path)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/handlers"
+)
+
+const optionsTestFB2 = `<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description>
+    <title-info>
+      <book-title>Test Book</book-title>
+    </title-info>
+  </description>
+  <body>
+    <section>
+      <p>This is a test paragraph.</p>
+    </section>
+  </body>
+</FictionBook>`
+
+// formFile is a file part of a multipart test request
+type formFile struct {
+	field    string
+	filename string
+	content  []byte
+}
+
+// noBook stands in for the book of a request that sends form fields only
+var noBook = formFile{field: "file"}
+
+// createConvertRequestBody builds a multipart request with the given form
+// fields and files. Without files it uploads optionsTestFB2 as test.fb2; a
+// file without content, such as noBook, is left out.
+func createConvertRequestBody(t *testing.T, fields map[string]string, files ...formFile) (*bytes.Buffer, string) {
+	t.Helper()
+
+	if len(files) == 0 {
+		files = []formFile{{"file", "test.fb2", []byte(optionsTestFB2)}}
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, file := range files {
+		if file.content == nil {
+			continue
+		}
+		part, err := writer.CreateFormFile(file.field, file.filename)
+		if err != nil {
+			t.Fatalf("Failed to create form file %s: %v", file.field, err)
+		}
+		if _, err := part.Write(file.content); err != nil {
+			t.Fatalf("Failed to write form file %s: %v", file.field, err)
+		}
+	}
+
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("Failed to write field %s: %v", name, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+	return body, writer.FormDataContentType()
+}
+
+// postConvert sends the test book with the given form fields and request
+// headers to the convert endpoint
+func postConvert(t *testing.T, router *gin.Engine, fields, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, contentType := createConvertRequestBody(t, fields)
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// responseJSON decodes the JSON body of a response
+func responseJSON(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response
+}
+
+func TestConvertFB2ToEPUB_MetadataOverrideFields(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{
+		"title":    "Override Title",
+		"author":   "Jane Doe",
+		"language": "de",
+		"series":   "Series",
+	})
+
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d, got %d. Body: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+}
+
+func TestConvertFB2ToEPUB_InvalidCoverImage(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil,
+		formFile{"file", "test.fb2", []byte(optionsTestFB2)},
+		formFile{"cover", "cover.bin", []byte("this is not an image")})
+
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestConvertFB2ToEPUB_InvalidTOCDepth(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	for _, value := range []string{"-1", "deep"} {
+		body, contentType := createConvertRequestBody(t, map[string]string{"toc_depth": value})
+
+		req := httptest.NewRequest("POST", "/api/v1/convert", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("toc_depth=%s: expected status %d, got %d", value, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestConvertFB2ToEPUB_InvalidNotesMode(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"notes": "inline"})
+
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestConvertFB2ToEPUB_FontOptions(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	fontsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(fontsDir, "Serif.ttf"), []byte("font data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fontsDir string
+		fields   map[string]string
+		files    []formFile
+		expected int
+	}{
+		{"server fonts", fontsDir, map[string]string{"embed_fonts": "true"}, nil, http.StatusAccepted},
+		{"server fonts not configured", "", map[string]string{"embed_fonts": "true"}, nil, http.StatusBadRequest},
+		{"unsupported font type", "", nil, []formFile{
+			{"file", "test.fb2", []byte(optionsTestFB2)},
+			{"fonts", "fonts.bin", []byte("font data")},
+		}, http.StatusBadRequest},
+	}
+
+	router := setupTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("FONTS_DIR", tt.fontsDir)
+			body, contentType := createConvertRequestBody(t, tt.fields, tt.files...)
+
+			req := httptest.NewRequest("POST", "/api/v1/convert", body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d. Body: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestConvertFB2ToEPUB_KepubOutput(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"kepub": "true"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	if status := waitForJob(t, router, jobID); status["status"] != "completed" {
+		t.Fatalf("Expected the conversion to complete, got %v", status)
+	}
+	defer handlers.DeleteConversionJob(jobID)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/"+jobID, nil))
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasSuffix(disposition, `.kepub.epub"`) {
+		t.Errorf("Expected a .kepub.epub file name, got %q", disposition)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestConvertFB2ToEPUB_PDFOutput(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{
+		"pdf_page_size": "A5",
+		"pdf_margin":    "15",
+		"pdf_font":      "Helvetica",
+	})
+	req := httptest.NewRequest("POST", "/api/v1/convert?to=pdf", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	if status := waitForJob(t, router, jobID); status["status"] != "completed" {
+		t.Fatalf("Expected the conversion to complete, got %v", status)
+	}
+	defer handlers.DeleteConversionJob(jobID)
+
+	req = httptest.NewRequest("GET", "/api/v1/download/"+jobID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("Expected a PDF content type, got %q", ct)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasSuffix(disposition, `.pdf"`) {
+		t.Errorf("Expected a .pdf file name, got %q", disposition)
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")) {
+		t.Errorf("Expected a PDF document, got %q", w.Body.String()[:min(w.Body.Len(), 20)])
+	}
+}
+
+func TestConvertFB2ToEPUB_InvalidPDFOptions(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	for _, fields := range []map[string]string{
+		{"pdf_page_size": "B7"},
+		{"pdf_font": "Comic Sans"},
+		{"pdf_margin": "-1"},
+	} {
+		body, contentType := createConvertRequestBody(t, fields)
+		req := httptest.NewRequest("POST", "/api/v1/convert?to=pdf", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: expected status %d, got %d: %s", fields, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// startStalledSMTPServer accepts SMTP connections without answering them
+// until the returned function is called, which closes them
+func startStalledSMTPServer(t *testing.T) (port string, release func()) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	connections := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			connections <- conn
+		}
+	}()
+
+	_, port, _ = net.SplitHostPort(listener.Addr().String())
+	return port, func() {
+		_ = listener.Close()
+		for {
+			select {
+			case conn := <-connections:
+				_ = conn.Close()
+			default:
+				return
+			}
+		}
+	}
+}
+
+// startQueueTestJob uploads the test book with fields and returns the response
+func startQueueTestJob(t *testing.T, router *gin.Engine, fields map[string]string) map[string]interface{} {
+	t.Helper()
+
+	body, contentType := createConvertRequestBody(t, fields)
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := response["job_id"].(string)
+	t.Cleanup(func() { handlers.DeleteConversionJob(jobID) })
+	return response
+}
+
+func TestConversionWorkers_QueuePosition(t *testing.T) {
+	port, release := startStalledSMTPServer(t)
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("CONVERSION_WORKERS", "1")
+	os.Setenv("SMTP_HOST", "127.0.0.1")
+	os.Setenv("SMTP_PORT", port)
+	os.Setenv("SMTP_FROM", "books@example.com")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+
+	// The delivery of the first job stalls, keeping the only worker busy
+	first := startQueueTestJob(t, router, map[string]string{"email": "reader@kindle.com"})
+	if first["status"] != "processing" {
+		t.Fatalf("Expected the first job to start at once, got %v", first)
+	}
+	second := startQueueTestJob(t, router, map[string]string{"force": "true"})
+	third := startQueueTestJob(t, router, map[string]string{"force": "true"})
+	for i, response := range []map[string]interface{}{second, third} {
+		if response["status"] != "pending" || response["message"] != "Conversion queued" {
+			t.Errorf("Expected job %d to be queued, got %v", i+2, response)
+		}
+		if position, _ := response["queue_position"].(float64); int(position) != i+1 {
+			t.Errorf("Expected job %d at queue position %d, got %v", i+2, i+1, response["queue_position"])
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/status/"+third["job_id"].(string), nil))
+	var status map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to parse status: %v", err)
+	}
+	if status["status"] != "pending" || status["queue_position"] != float64(2) {
+		t.Errorf("Expected the third job pending at position 2, got %v", status)
+	}
+	if wait, ok := status["estimated_wait_seconds"]; ok {
+		if seconds, _ := wait.(float64); seconds < 1 {
+			t.Errorf("Expected a positive estimated wait, got %v", wait)
+		}
+	}
+
+	// Once the first job is done, the queued ones run in turn
+	release()
+	deadline := time.Now().Add(10 * time.Second)
+	for _, response := range []map[string]interface{}{first, second, third} {
+		jobID := response["job_id"].(string)
+		for time.Now().Before(deadline) {
+			if handlers.GetConversionJob(jobID).Snapshot().Status == handlers.JobStatusCompleted {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if status := handlers.GetConversionJob(jobID).Snapshot().Status; status != handlers.JobStatusCompleted {
+			t.Errorf("Expected job %s to complete, got %s", jobID, status)
+		}
+	}
+}
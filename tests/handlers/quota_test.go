@@ -0,0 +1,245 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// getUsage returns the usage reported for the given API key
+func getUsage(t *testing.T, router *gin.Engine, key string) map[string]interface{} {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/api/v1/usage", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var usage map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return usage
+}
+
+func TestAPIKeys_RequiredWhenConfigured(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("API_KEYS", "key-required-a, key-required-b")
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	router := setupTestRouter()
+
+	tests := []struct {
+		name     string
+		key      string
+		expected int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"unknown", "key-unknown", http.StatusForbidden},
+		{"second key", "key-required-b", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"X-API-Key": tt.key})
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusAccepted {
+				var response map[string]interface{}
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to parse response: %v", err)
+				}
+				waitForJob(t, router, response["job_id"].(string))
+			}
+		})
+	}
+
+	// The admin key is accepted as well
+	req := httptest.NewRequest("GET", "/api/v1/status/ffffffff-0000-4000-8000-000000000000", nil)
+	req.Header.Set("X-Admin-Key", "admin-secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected the admin key to pass, got status %d", w.Code)
+	}
+}
+
+func TestQuota_ConversionsPerDay(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	// Usage is kept for the whole process, so every run needs a fresh key
+	key := fmt.Sprintf("key-daily-%d", time.Now().UnixNano())
+	t.Setenv("API_KEYS", key)
+	t.Setenv("QUOTA_CONVERSIONS_PER_DAY", "1")
+	router := setupTestRouter()
+
+	first := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"X-API-Key": key})
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, first.Code, first.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(first.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	waitForJob(t, router, response["job_id"].(string))
+
+	// A repeated upload returns the existing job and is not counted
+	if w := postConvert(t, router, nil, map[string]string{"X-API-Key": key}); w.Code != http.StatusOK {
+		t.Fatalf("Expected the existing job, got status %d: %s", w.Code, w.Body.String())
+	}
+
+	w := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"X-API-Key": key})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusTooManyRequests, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+
+	usage := getUsage(t, router, key)
+	if usage["conversions"] != float64(1) || usage["conversions_limit"] != float64(1) {
+		t.Errorf("Expected 1 of 1 conversions used, got %v", usage)
+	}
+	if usage["day"] != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("Expected today's usage, got %v", usage["day"])
+	}
+}
+
+func TestQuota_BytesPerDay(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	key := fmt.Sprintf("key-bytes-%d", time.Now().UnixNano())
+	t.Setenv("API_KEYS", key)
+	t.Setenv("QUOTA_BYTES_PER_DAY", "16")
+	router := setupTestRouter()
+
+	if w := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"X-API-Key": key}); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for an upload beyond the daily quota, got %d", http.StatusForbidden, w.Code)
+	}
+	if usage := getUsage(t, router, key); usage["bytes"] != float64(0) {
+		t.Errorf("Expected no bytes counted for a refused upload, got %v", usage["bytes"])
+	}
+}
+
+// setupStalledDelivery points deliveries at an SMTP server that does not
+// answer, so jobs asking for one keep running until release is called
+func setupStalledDelivery(t *testing.T) (release func()) {
+	t.Helper()
+
+	port, release := startStalledSMTPServer(t)
+	t.Setenv("SMTP_HOST", "127.0.0.1")
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_FROM", "books@example.com")
+	t.Cleanup(release)
+	return release
+}
+
+// stalledJobFields asks for a delivery that stalls, keeping the job running
+var stalledJobFields = map[string]string{"force": "true", "email": "reader@kindle.com"}
+
+func TestQuota_ConcurrentJobs(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	key := fmt.Sprintf("key-concurrent-%d", time.Now().UnixNano())
+	t.Setenv("API_KEYS", key+",key-other")
+	t.Setenv("QUOTA_CONCURRENT_JOBS", "1")
+	release := setupStalledDelivery(t)
+	router := setupTestRouter()
+
+	running := postConvert(t, router, stalledJobFields, map[string]string{"X-API-Key": key})
+	if running.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, running.Code, running.Body.String())
+	}
+	var started map[string]interface{}
+	if err := json.Unmarshal(running.Body.Bytes(), &started); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	defer handlers.DeleteConversionJob(started["job_id"].(string))
+
+	if w := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"X-API-Key": key}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d while a job runs, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	// Other keys are not limited by it
+	w := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"X-API-Key": "key-other"})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d for another key, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	waitForJob(t, router, response["job_id"].(string))
+
+	// The slot is free again once the running job, including its delivery, finishes
+	release()
+	waitForFreeSlots(t, router, key)
+	if w := postConvert(t, router, map[string]string{"force": "true"}, map[string]string{"X-API-Key": key}); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status %d after the job finished, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+}
+
+func TestQuota_ConcurrentJobsParallelRequests(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	key := fmt.Sprintf("key-parallel-%d", time.Now().UnixNano())
+	t.Setenv("API_KEYS", key)
+	t.Setenv("QUOTA_CONCURRENT_JOBS", "1")
+	release := setupStalledDelivery(t)
+	router := setupTestRouter()
+
+	const requests = 8
+	codes := make(chan *httptest.ResponseRecorder, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- postConvert(t, router, stalledJobFields, map[string]string{"X-API-Key": key})
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	var accepted []string
+	for w := range codes {
+		switch w.Code {
+		case http.StatusAccepted:
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			accepted = append(accepted, response["job_id"].(string))
+		case http.StatusTooManyRequests:
+		default:
+			t.Errorf("Expected status %d or %d, got %d: %s", http.StatusAccepted, http.StatusTooManyRequests, w.Code, w.Body.String())
+		}
+	}
+	if len(accepted) != 1 {
+		t.Errorf("Expected 1 of %d parallel requests accepted, got %d", requests, len(accepted))
+	}
+
+	release()
+	waitForFreeSlots(t, router, key)
+	for _, jobID := range accepted {
+		handlers.DeleteConversionJob(jobID)
+	}
+}
+
+// waitForFreeSlots polls the usage of key until none of its conversions is running
+func waitForFreeSlots(t *testing.T, router *gin.Engine, key string) {
+	t.Helper()
+
+	var usage map[string]interface{}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		if usage = getUsage(t, router, key); usage["concurrent_jobs"] == float64(0) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected no running jobs left, got %v", usage["concurrent_jobs"])
+}
@@ -0,0 +1,109 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestConvertFB2ToEPUB_RawBody(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		body        []byte
+		filename    string
+		title       string
+	}{
+		{
+			name:        "fictionbook content type",
+			url:         "/api/v1/convert?title=Raw+Title",
+			contentType: "application/x-fictionbook+xml; charset=utf-8",
+			body:        []byte(optionsTestFB2),
+			filename:    "book.fb2",
+			title:       "Raw Title",
+		},
+		{
+			name:        "sniffed binary body",
+			url:         "/api/v1/convert?filename=novel.fb2",
+			contentType: "application/octet-stream",
+			body:        []byte(optionsTestFB2),
+			filename:    "novel.fb2",
+			title:       "Test Book",
+		},
+		{
+			name:        "zipped book",
+			url:         "/api/v1/convert",
+			contentType: "application/zip",
+			body:        createZipArchive(t, map[string]string{"book.fb2": archiveTestFB2}),
+			filename:    "book.fb2.zip",
+			title:       "Zipped Book",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+			}
+
+			var created map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			jobID, _ := created["job_id"].(string)
+			if status := waitForJob(t, router, jobID); status["status"] != "completed" {
+				t.Fatalf("Expected the conversion to complete, got %v", status)
+			}
+			defer handlers.DeleteConversionJob(jobID)
+
+			job := handlers.GetConversionJob(jobID)
+			if title := job.Snapshot().Title; job.Filename != tt.filename || title != tt.title {
+				t.Errorf("Expected filename %q and title %q, got %q and %q", tt.filename, tt.title, job.Filename, title)
+			}
+		})
+	}
+}
+
+func TestConvertFB2ToEPUB_RawBodyRejected(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("MAX_FILE_SIZE", "100")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"unsupported content type", "text/plain", "hello", http.StatusUnsupportedMediaType},
+		{"empty body", "application/x-fictionbook+xml", "", http.StatusBadRequest},
+		{"unrecognized binary", "application/octet-stream", "not a book", http.StatusBadRequest},
+		{"too large", "application/x-fictionbook+xml", strings.Repeat("x", 101), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/convert", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+		}
+	}
+}
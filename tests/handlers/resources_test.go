@@ -0,0 +1,69 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+// largeTestFB2 returns a book of many chapters, which takes a while to convert
+func largeTestFB2(chapters int) []byte {
+	var book bytes.Buffer
+	book.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0">
+  <description><title-info><book-title>Large Book</book-title></title-info></description>
+  <body>`)
+	for i := 0; i < chapters; i++ {
+		fmt.Fprintf(&book, "<section><title><p>Chapter %d</p></title>", i+1)
+		for j := 0; j < 20; j++ {
+			book.WriteString("<p>Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor.</p>")
+		}
+		book.WriteString("</section>")
+	}
+	book.WriteString("</body></FictionBook>")
+	return book.Bytes()
+}
+
+func TestConvertFB2ToEPUB_MemoryBudget(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	os.Setenv("MAX_CONVERSION_MEMORY", "1")
+	defer os.Clearenv()
+
+	body, contentType := createConvertRequestBody(t, nil, formFile{"file", "large.fb2", largeTestFB2(2000)})
+
+	router := setupTestRouter()
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+
+	status := waitForJob(t, router, jobID)
+	if status["status"] != handlers.JobStatusFailed {
+		t.Fatalf("Expected the job to exceed its memory budget, got %v", status["status"])
+	}
+	if errMsg, _ := status["error"].(string); !strings.HasPrefix(errMsg, "Resource limit exceeded: memory") {
+		t.Errorf("Expected a resource limit error, got %q", errMsg)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, jobID)); !os.IsNotExist(err) {
+		t.Error("The temp directory of an aborted job should be removed")
+	}
+}
@@ -0,0 +1,160 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// createChunkedUpload starts a chunked upload and returns its ID
+func createChunkedUpload(t *testing.T, router *gin.Engine, filename string, size int) string {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"filename": %q, "size": %d}`, filename, size)
+	req := httptest.NewRequest("POST", "/api/v1/uploads", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	uploadID, _ := created["upload_id"].(string)
+	return uploadID
+}
+
+// patchChunk sends a chunk of a chunked upload at offset
+func patchChunk(router *gin.Engine, uploadID string, offset int, chunk []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", "/api/v1/uploads/"+uploadID, bytes.NewReader(chunk))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestChunkedUpload_ConvertsBook(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	book := []byte(optionsTestFB2)
+	uploadID := createChunkedUpload(t, router, "book.fb2", len(book))
+	half := len(book) / 2
+
+	if w := patchChunk(router, uploadID, 0, book[:half]); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for the first chunk, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// A client resuming with a stale offset learns where to continue
+	w := patchChunk(router, uploadID, 0, book[:half])
+	if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != strconv.Itoa(half) {
+		t.Fatalf("Expected status %d and offset %d, got %d and %q",
+			http.StatusConflict, half, w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	// Completing early is refused
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/uploads/"+uploadID+"/complete", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for an incomplete upload, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/uploads/"+uploadID, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != strconv.Itoa(half) {
+		t.Errorf("Expected the upload offset %d, got %d and %q", half, w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	if w := patchChunk(router, uploadID, half, book[half:]); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for the last chunk, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	body, contentType := createConvertRequestBody(t, map[string]string{"title": "Chunked"}, noBook)
+	req := httptest.NewRequest("POST", "/api/v1/uploads/"+uploadID+"/complete", body)
+	req.Header.Set("Content-Type", contentType)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	if status := waitForJob(t, router, jobID); status["status"] != "completed" {
+		t.Fatalf("Expected the conversion to complete, got %v", status)
+	}
+	defer handlers.DeleteConversionJob(jobID)
+	if job := handlers.GetConversionJob(jobID); job.Filename != "book.fb2" || job.Snapshot().Title != "Chunked" {
+		t.Errorf("Expected filename book.fb2 and title Chunked, got %q and %q", job.Filename, job.Snapshot().Title)
+	}
+
+	// The upload is consumed by the conversion
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/uploads/"+uploadID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d after completion, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestChunkedUpload_InvalidRequests(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("MAX_FILE_SIZE", "1000")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"filename": "book.fb2", "size": 0}`, http.StatusBadRequest},
+		{`{"size": 100}`, http.StatusBadRequest},
+		{`{"filename": "book.fb2", "size": 1001}`, http.StatusRequestEntityTooLarge},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/uploads", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("Create upload %s: expected status %d, got %d", tt.body, tt.status, w.Code)
+		}
+	}
+
+	uploadID := createChunkedUpload(t, router, "book.txt", 4)
+	if w := patchChunk(router, uploadID, 0, []byte("too long")); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d for a chunk past the declared size, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/uploads/"+uploadID+"/complete", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unsupported file, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/uploads/"+uploadID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d when abandoning an upload, got %d", http.StatusNoContent, w.Code)
+	}
+	if w := patchChunk(router, uploadID, 0, []byte("data")); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an abandoned upload, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestConvertFB2ToEPUB_Retention(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"retention": "12h"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var created struct {
+		JobID     string    `json:"job_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	waitForJob(t, router, created.JobID)
+	defer handlers.DeleteConversionJob(created.JobID)
+
+	job := handlers.GetConversionJob(created.JobID)
+	if !created.ExpiresAt.Equal(job.CreatedAt.Add(12 * time.Hour)) {
+		t.Errorf("Expected the job to expire 12h after creation, got %s", created.ExpiresAt)
+	}
+}
+
+func TestConvertFB2ToEPUB_InvalidRetention(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("MAX_JOB_RETENTION", "6h")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	for _, retention := range []string{"forever", "1m", "12h"} {
+		body, contentType := createConvertRequestBody(t, map[string]string{"retention": retention})
+		req := httptest.NewRequest("POST", "/api/v1/convert", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("retention=%s: expected status %d, got %d", retention, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestAdmin_CleanupHonorsJobRetention(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	created := time.Now().Add(-2 * time.Hour)
+	jobs := map[string]time.Duration{
+		"cccccccc-1111-2222-3333-444444444444": 3 * time.Hour,    // Not yet expired
+		"dddddddd-1111-2222-3333-444444444444": 30 * time.Minute, // Expired
+	}
+	for jobID, retention := range jobs {
+		if err := os.MkdirAll(filepath.Join(tmpDir, jobID), 0755); err != nil {
+			t.Fatalf("Failed to create job dir: %v", err)
+		}
+		handlers.SetConversionJob(&handlers.ConversionJob{
+			ID:        jobID,
+			Status:    handlers.JobStatusCompleted,
+			CreatedAt: created,
+			Retention: retention,
+		})
+		defer handlers.DeleteConversionJob(jobID)
+	}
+
+	router := setupTestRouter()
+	req := httptest.NewRequest("POST", "/api/v1/admin/cleanup", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if handlers.GetConversionJob("cccccccc-1111-2222-3333-444444444444") == nil {
+		t.Error("Job within its retention should be kept")
+	}
+	if handlers.GetConversionJob("dddddddd-1111-2222-3333-444444444444") != nil {
+		t.Error("Job past its retention should be removed")
+	}
+}
+
+func TestAdmin_CleanupKeepsRecentlyAccessedJobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Setenv("TEMP_DIR", tmpDir)
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	created := time.Now().Add(-2 * time.Hour)
+	polled := "eeeeeeee-1111-2222-3333-444444444444"
+	idle := "ffffffff-1111-2222-3333-444444444444"
+	for _, jobID := range []string{polled, idle} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, jobID), 0755); err != nil {
+			t.Fatalf("Failed to create job dir: %v", err)
+		}
+		handlers.SetConversionJob(&handlers.ConversionJob{
+			ID:        jobID,
+			Status:    handlers.JobStatusCompleted,
+			CreatedAt: created,
+			Retention: time.Hour,
+		})
+		defer handlers.DeleteConversionJob(jobID)
+	}
+
+	router := setupTestRouter()
+	// Polling the status keeps the job past an hour after its creation
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/status/"+polled, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/admin/cleanup", nil)
+	req.Header.Set("X-Admin-Key", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if handlers.GetConversionJob(polled) == nil {
+		t.Error("Job accessed within its retention should be kept")
+	}
+	if handlers.GetConversionJob(idle) != nil {
+		t.Error("Job not accessed within its retention should be removed")
+	}
+}
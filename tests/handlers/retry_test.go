@@ -0,0 +1,151 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// retryJob posts a retry request for jobID with the given option fields
+func retryJob(t *testing.T, router *gin.Engine, jobID string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, contentType := createConvertRequestBody(t, fields, noBook)
+	req := httptest.NewRequest("POST", "/api/v1/jobs/"+jobID+"/retry", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Admin-Key", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRetryConversion_AmendedOptions(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"strict": "true", "title": "Retried"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	if status := waitForJob(t, router, jobID); status["status"] != "failed" {
+		t.Fatalf("Expected strict conversion to fail, got %v", status)
+	}
+	defer handlers.DeleteConversionJob(jobID)
+
+	// Repeating the same options fails the same way
+	if w := retryJob(t, router, jobID, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if status := waitForJob(t, router, jobID); status["status"] != "failed" {
+		t.Fatalf("Expected the unchanged retry to fail again, got %v", status)
+	}
+
+	w = retryJob(t, router, jobID, map[string]string{"strict": "false"})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	status := waitForJob(t, router, jobID)
+	if status["status"] != "completed" {
+		t.Fatalf("Expected the amended retry to complete, got %v", status)
+	}
+	if _, ok := status["diagnostics"]; ok {
+		t.Errorf("Diagnostics of the failed attempt should be cleared, got %v", status)
+	}
+
+	// Only the first request set the title, so the retry must have kept it
+	if title := handlers.GetConversionJob(jobID).Snapshot().Title; title != "Retried" {
+		t.Errorf("Expected the retry to keep the original title override, got %q", title)
+	}
+
+	if w := retryJob(t, router, jobID, nil); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a completed job, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestRetryConversion_Unavailable(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	if w := retryJob(t, router, "ffffffff-0000-4000-8000-000000000000", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown job, got %d", http.StatusNotFound, w.Code)
+	}
+
+	body, contentType := createConvertRequestBody(t, map[string]string{"strict": "true"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	waitForJob(t, router, jobID)
+	defer handlers.DeleteConversionJob(jobID)
+
+	if w := retryJob(t, router, jobID, map[string]string{"toc_depth": "-1"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid options, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if err := os.RemoveAll(filepath.Join(os.Getenv("TEMP_DIR"), jobID)); err != nil {
+		t.Fatalf("Failed to remove job directory: %v", err)
+	}
+	if w := retryJob(t, router, jobID, nil); w.Code != http.StatusGone {
+		t.Errorf("Expected status %d once the input is gone, got %d", http.StatusGone, w.Code)
+	}
+}
+
+func TestRetryConversion_UpdatesDuplicateKey(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	os.Setenv("ADMIN_API_KEY", "secret")
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	w := postConvert(t, router, map[string]string{"strict": "true"}, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	jobID := responseJSON(t, w)["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+	waitForJob(t, router, jobID)
+
+	if w := retryJob(t, router, jobID, map[string]string{"strict": "false"}); w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	if status := waitForJob(t, router, jobID); status["status"] != "completed" {
+		t.Fatalf("Expected the amended retry to complete, got %v", status)
+	}
+
+	// The result was built with the amended options, so it only matches uploads with those
+	w = postConvert(t, router, map[string]string{"strict": "true"}, nil)
+	original := responseJSON(t, w)
+	if w.Code != http.StatusAccepted || original["job_id"] == jobID {
+		t.Errorf("Expected a new job for the original options, got status %d: %v", w.Code, original)
+	}
+	if newID, ok := original["job_id"].(string); ok && newID != jobID {
+		defer handlers.DeleteConversionJob(newID)
+		waitForJob(t, router, newID)
+	}
+	w = postConvert(t, router, map[string]string{"strict": "false"}, nil)
+	if amended := responseJSON(t, w); w.Code != http.StatusOK || amended["job_id"] != jobID {
+		t.Errorf("Expected the retried job for the amended options, got status %d: %v", w.Code, amended)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/lex/fb2epub/converter"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// createTestEPUB converts the options test book into an EPUB
+func createTestEPUB(t *testing.T) []byte {
+	t.Helper()
+	fb2, err := converter.ParseFB2FromReader(strings.NewReader(optionsTestFB2))
+	if err != nil {
+		t.Fatalf("ParseFB2FromReader() error = %v", err)
+	}
+	var epub bytes.Buffer
+	if err := converter.WriteEPUB(fb2, &epub, converter.DefaultOptions()); err != nil {
+		t.Fatalf("WriteEPUB() error = %v", err)
+	}
+	return epub.Bytes()
+}
+
+func TestConvertFB2ToEPUB_EPUBToFB2(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil, formFile{"file", "book.epub", createTestEPUB(t)})
+	req := httptest.NewRequest("POST", "/api/v1/convert?from=epub&to=fb2", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+	if status := waitForJob(t, router, jobID); status["status"] != "completed" {
+		t.Fatalf("Expected the conversion to complete, got %v", status)
+	}
+	defer handlers.DeleteConversionJob(jobID)
+
+	req = httptest.NewRequest("GET", "/api/v1/download/"+jobID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-fictionbook+xml" {
+		t.Errorf("Expected an FB2 content type, got %q", ct)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.HasSuffix(disposition, `.fb2"`) {
+		t.Errorf("Expected an .fb2 file name, got %q", disposition)
+	}
+
+	fb2, err := converter.ParseFB2FromReader(w.Body)
+	if err != nil {
+		t.Fatalf("Downloaded FB2 does not parse: %v", err)
+	}
+	if title := fb2.Description.TitleInfo.BookTitle; title != "Test Book" {
+		t.Errorf("Expected title Test Book, got %q", title)
+	}
+	if len(fb2.MainBody().Section) == 0 {
+		t.Error("Expected the chapters of the EPUB as FB2 sections")
+	}
+}
+
+func TestConvertFB2ToEPUB_UnsupportedDirection(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	tests := []struct {
+		name     string
+		query    string
+		filename string
+		content  string
+	}{
+		{"FB2 declared as EPUB", "?from=epub&to=fb2", "test.fb2", optionsTestFB2},
+		{"FB2 to FB2", "?to=fb2", "test.fb2", optionsTestFB2},
+		{"EPUB to EPUB", "?to=epub", "book.epub", string(createTestEPUB(t))},
+		{"EPUB to PDF", "?to=pdf", "book.epub", string(createTestEPUB(t))},
+		{"unknown target", "?to=mobi", "test.fb2", optionsTestFB2},
+	}
+
+	for _, tt := range tests {
+		body, contentType := createConvertRequestBody(t, nil, formFile{"file", tt.filename, []byte(tt.content)})
+		req := httptest.NewRequest("POST", "/api/v1/convert"+tt.query, body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestConvertFB2ToEPUB_RawEPUBBody(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	for _, contentType := range []string{"application/epub+zip", "application/octet-stream"} {
+		req := httptest.NewRequest("POST", "/api/v1/convert", bytes.NewReader(createTestEPUB(t)))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("%s: expected status %d, got %d: %s", contentType, http.StatusAccepted, w.Code, w.Body.String())
+		}
+
+		var created map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		jobID, _ := created["job_id"].(string)
+		if status := waitForJob(t, router, jobID); status["status"] != "completed" {
+			t.Fatalf("%s: expected the conversion to complete, got %v", contentType, status)
+		}
+		job := handlers.GetConversionJob(jobID)
+		if job.OutputFormat != "fb2" || job.Filename != "book.epub" {
+			t.Errorf("%s: expected book.epub converted to FB2, got %q to %q", contentType, job.Filename, job.OutputFormat)
+		}
+		handlers.DeleteConversionJob(jobID)
+	}
+}
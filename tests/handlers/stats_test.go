@@ -0,0 +1,59 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetConversionStatus_Stats(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil)
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+
+	var status map[string]interface{}
+	for i := 0; i < 50; i++ {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/status/"+jobID, nil))
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Failed to parse status: %v", err)
+		}
+		if status["status"] != "processing" {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if w.Code != http.StatusOK || status["status"] != "completed" {
+		t.Fatalf("Expected completed job, got %d: %s", w.Code, w.Body.String())
+	}
+
+	stats, ok := status["stats"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Completed job status should include stats, got: %s", w.Body.String())
+	}
+	if size, _ := stats["input_size_bytes"].(float64); int(size) != len(optionsTestFB2) {
+		t.Errorf("input_size_bytes = %v, want %d", stats["input_size_bytes"], len(optionsTestFB2))
+	}
+	for _, key := range []string{"output_size_bytes", "size_ratio", "image_count", "chapter_count",
+		"parse_duration_ms", "generate_duration_ms"} {
+		if _, exists := stats[key]; !exists {
+			t.Errorf("stats should include %s", key)
+		}
+	}
+}
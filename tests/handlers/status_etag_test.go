@@ -0,0 +1,65 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestGetConversionStatus_ETag(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	jobID := "10000000-0000-4000-8000-000000000e01"
+	job := &handlers.ConversionJob{
+		ID:        jobID,
+		Status:    handlers.JobStatusProcessing,
+		CreatedAt: time.Now(),
+		Stage:     "parsing",
+		Progress:  10,
+	}
+	handlers.SetConversionJob(job)
+	defer handlers.DeleteConversionJob(jobID)
+
+	router := setupTestRouter()
+	status := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/status/"+jobID, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := status("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("Expected 200 with a weak ETag, got %d and %q", first.Code, etag)
+	}
+
+	// Accesses change last_accessed_at but not the ETag
+	time.Sleep(5 * time.Millisecond)
+	for _, header := range []string{etag, `"other", ` + etag, strings.TrimPrefix(etag, "W/"), "*"} {
+		if w := status(header); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("Expected 304 without a body for If-None-Match %s, got %d: %s", header, w.Code, w.Body.String())
+		}
+	}
+	if job.LastAccessedAt().Equal(job.CreatedAt) {
+		t.Error("Requests answered with 304 should still count as accesses")
+	}
+
+	job.Progress = 40
+	w := status(etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 once the job progressed, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("Expected a new ETag once the job progressed")
+	}
+}
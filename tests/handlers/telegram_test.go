@@ -0,0 +1,140 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lex/fb2epub/config"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// telegramTestReply is a message the bot sent to the fake Bot API
+type telegramTestReply struct {
+	Method   string
+	Text     string
+	Filename string
+	Document string
+}
+
+// startTestBotAPI serves the Bot API methods used by the bot. The first poll
+// returns the given update JSON and later polls none; replies are sent to the
+// returned channel.
+func startTestBotAPI(t *testing.T, token, update, document string) (*httptest.Server, <-chan telegramTestReply) {
+	t.Helper()
+
+	replies := make(chan telegramTestReply, 4)
+	var once sync.Once
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bot"+token+"/getUpdates", func(w http.ResponseWriter, r *http.Request) {
+		result := "[]"
+		once.Do(func() { result = "[" + update + "]" })
+		if result == "[]" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+	})
+	mux.HandleFunc("/bot"+token+"/getFile", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ok":true,"result":{"file_id":%q,"file_path":"documents/file_1.fb2"}}`, r.FormValue("file_id"))
+	})
+	mux.HandleFunc("/file/bot"+token+"/documents/file_1.fb2", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, document)
+	})
+	mux.HandleFunc("/bot"+token+"/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		replies <- telegramTestReply{Method: "sendMessage", Text: r.FormValue("text")}
+		_, _ = io.WriteString(w, `{"ok":true,"result":{}}`)
+	})
+	mux.HandleFunc("/bot"+token+"/sendDocument", func(w http.ResponseWriter, r *http.Request) {
+		reply := telegramTestReply{Method: "sendDocument", Text: r.FormValue("caption")}
+		if file, header, err := r.FormFile("document"); err == nil {
+			data, _ := io.ReadAll(file)
+			reply.Filename, reply.Document = header.Filename, string(data)
+		}
+		replies <- reply
+		_, _ = io.WriteString(w, `{"ok":true,"result":{}}`)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, replies
+}
+
+func runTestBot(t *testing.T, update, document string) telegramTestReply {
+	t.Helper()
+
+	server, replies := startTestBotAPI(t, "123:abc", update, document)
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+	cfg := config.Load()
+	cfg.TelegramBotToken = "123:abc"
+	cfg.TelegramAPIURL = server.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handlers.NewTelegramBot(cfg).Run(ctx)
+
+	select {
+	case reply := <-replies:
+		return reply
+	case <-time.After(5 * time.Second):
+		t.Fatal("The bot did not reply")
+	}
+	return telegramTestReply{}
+}
+
+func TestTelegramBot_ConvertsDocuments(t *testing.T) {
+	update := `{"update_id":1,"message":{"message_id":7,"chat":{"id":42},
+		"document":{"file_id":"f1","file_name":"story.fb2","file_size":300}}}`
+	reply := runTestBot(t, update, optionsTestFB2)
+
+	if reply.Method != "sendDocument" {
+		t.Fatalf("Expected the EPUB as a document, got %+v", reply)
+	}
+	if reply.Filename != "story.epub" || reply.Text != "Test Book" {
+		t.Errorf("Expected story.epub captioned Test Book, got %q captioned %q", reply.Filename, reply.Text)
+	}
+	if !strings.HasPrefix(reply.Document, "PK") {
+		t.Error("Expected the document to be an EPUB archive")
+	}
+}
+
+func TestTelegramBot_RepliesWithErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		update   string
+		document string
+		expected string
+	}{
+		{
+			"text message",
+			`{"update_id":1,"message":{"message_id":1,"chat":{"id":42},"text":"hello"}}`,
+			"", "Send me an FB2 book",
+		},
+		{
+			"unsupported file",
+			`{"update_id":1,"message":{"message_id":1,"chat":{"id":42},
+				"document":{"file_id":"f1","file_name":"notes.txt","file_size":5}}}`,
+			"notes", "Invalid file type",
+		},
+		{
+			"broken book",
+			`{"update_id":1,"message":{"message_id":1,"chat":{"id":42},
+				"document":{"file_id":"f1","file_name":"broken.fb2","file_size":9}}}`,
+			"<broken", "Failed to parse FB2",
+		},
+	}
+
+	for _, tt := range tests {
+		reply := runTestBot(t, tt.update, tt.document)
+		if reply.Method != "sendMessage" || !strings.Contains(reply.Text, tt.expected) {
+			t.Errorf("%s: expected a message containing %q, got %+v", tt.name, tt.expected, reply)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/lex/fb2epub/handlers"
+)
+
+// recordingTransport keeps the events Sentry would send
+type recordingTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *recordingTransport) Configure(sentry.ClientOptions) {}
+func (t *recordingTransport) Flush(time.Duration) bool       { return true }
+func (t *recordingTransport) Close()                         {}
+func (t *recordingTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func (t *recordingTransport) recorded() []*sentry.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*sentry.Event(nil), t.events...)
+}
+
+// useRecordingSentry reports to a transport that records the events, until the test ends
+func useRecordingSentry(t *testing.T) *recordingTransport {
+	t.Helper()
+
+	transport := &recordingTransport{}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:       "https://key@sentry.example.com/1",
+		Transport: transport,
+	})
+	if err != nil {
+		t.Fatalf("Failed to set up Sentry: %v", err)
+	}
+	t.Cleanup(func() { sentry.CurrentHub().BindClient(nil) })
+	return transport
+}
+
+func TestSentry_ReportsFailedConversion(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("DEDUPLICATE_UPLOADS", "false")
+	t.Setenv("CONVERSION_TIMEOUT", "1ns")
+	transport := useRecordingSentry(t)
+
+	router := setupTestRouter()
+	w := postConvert(t, router, nil, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+	jobID := responseJSON(t, w)["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+	if status := waitForJob(t, router, jobID); status["status"] != handlers.JobStatusFailed {
+		t.Fatalf("Expected the job to time out, got %v", status["status"])
+	}
+
+	events := transport.recorded()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 reported failure, got %d", len(events))
+	}
+	event := events[0]
+	if event.Tags["job_id"] != jobID || event.Tags["output_format"] != "epub" {
+		t.Errorf("Expected the report to be tagged with the job, got %v", event.Tags)
+	}
+	if book := event.Contexts["book"]; book == nil || book["filename"] != "test.fb2" {
+		t.Errorf("Expected the book in the report, got %v", event.Contexts["book"])
+	}
+	if len(event.Exception) == 0 || !strings.Contains(event.Exception[len(event.Exception)-1].Value, "deadline") {
+		t.Errorf("Expected the conversion error in the report, got %+v", event.Exception)
+	}
+}
+
+func TestSentry_IgnoresInvalidBooks(t *testing.T) {
+	t.Setenv("TEMP_DIR", t.TempDir())
+	t.Setenv("DEDUPLICATE_UPLOADS", "false")
+	transport := useRecordingSentry(t)
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil, formFile{"file", "broken.fb2", []byte("<FictionBook><body>")})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID := created["job_id"].(string)
+	defer handlers.DeleteConversionJob(jobID)
+	if status := waitForJob(t, router, jobID); status["status"] != handlers.JobStatusFailed {
+		t.Fatalf("Expected the book to fail to parse, got %v", status["status"])
+	}
+
+	if events := transport.recorded(); len(events) != 0 {
+		t.Errorf("Books that fail to parse should not be reported, got %d report(s)", len(events))
+	}
+}
@@ -0,0 +1,82 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/lex/fb2epub/handlers"
+)
+
+func TestConvertFB2ToEPUB_UploadNaming(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		filename string
+		content  string
+		status   int
+	}{
+		{"file field", "file", "book.fb2", archiveTestFB2, http.StatusAccepted},
+		{"files[] field", "files[]", "book.fb2", archiveTestFB2, http.StatusAccepted},
+		{"no extension", "file", "book", archiveTestFB2, http.StatusAccepted},
+		{"no extension after comment", "files[]", "blob",
+			"<?xml version=\"1.0\"?>\n<!-- exported -->\n" + archiveTestFB2[len("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"):],
+			http.StatusAccepted},
+		{"no extension, other XML", "file", "page", "<html><body>Not a book</body></html>", http.StatusBadRequest},
+		{"no extension, plain text", "file", "notes", "Just some text", http.StatusBadRequest},
+		{"unknown field", "document", "book.fb2", archiveTestFB2, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TEMP_DIR", t.TempDir())
+			defer os.Clearenv()
+
+			router := setupTestRouter()
+			body, contentType := createConvertRequestBody(t, nil, formFile{tt.field, tt.filename, []byte(tt.content)})
+			req := httptest.NewRequest("POST", "/api/v1/convert", body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			if jobID, ok := response["job_id"].(string); ok {
+				waitForJob(t, router, jobID)
+				handlers.DeleteConversionJob(jobID)
+			}
+		})
+	}
+}
+
+func TestInspectFB2_FilesFieldWithoutExtension(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil, formFile{"files[]", "upload", []byte(archiveTestFB2)})
+	req := httptest.NewRequest("POST", "/api/v1/inspect", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var info struct {
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if info.Title != "Zipped Book" {
+		t.Errorf("Expected title %q, got %q", "Zipped Book", info.Title)
+	}
+}
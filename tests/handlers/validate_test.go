@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestValidateFB2(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil)
+	req := httptest.NewRequest("POST", "/api/v1/validate", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var result struct {
+		Valid       bool `json:"valid"`
+		Diagnostics []struct {
+			Line    int    `json:"line"`
+			Column  int    `json:"column"`
+			Message string `json:"message"`
+		} `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	// The options test book has no genre or lang
+	if result.Valid || len(result.Diagnostics) == 0 {
+		t.Fatalf("Expected diagnostics for an incomplete title-info, got %s", w.Body.String())
+	}
+	if result.Diagnostics[0].Line == 0 {
+		t.Error("Diagnostics should carry a line number")
+	}
+}
+
+func TestConvertFB2ToEPUB_StrictMode(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"strict": "true"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var created map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	jobID, _ := created["job_id"].(string)
+
+	status := waitForJob(t, router, jobID)
+
+	if status["status"] != "failed" {
+		t.Fatalf("Expected strict conversion to fail, got %v", status)
+	}
+	diagnostics, ok := status["diagnostics"].([]interface{})
+	if !ok || len(diagnostics) == 0 {
+		t.Errorf("Expected diagnostics in the status response, got %v", status)
+	}
+}
+
+func TestConvertFB2ToEPUB_LenientOption(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, map[string]string{"lenient": "true"})
+	req := httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+
+	body, contentType = createConvertRequestBody(t, map[string]string{"lenient": "maybe"})
+	req = httptest.NewRequest("POST", "/api/v1/convert", body)
+	req.Header.Set("Content-Type", contentType)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid lenient value, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestInspectFB2(t *testing.T) {
+	os.Setenv("TEMP_DIR", t.TempDir())
+	defer os.Clearenv()
+
+	router := setupTestRouter()
+	body, contentType := createConvertRequestBody(t, nil)
+	req := httptest.NewRequest("POST", "/api/v1/inspect", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var info map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if info["title"] != "Test Book" {
+		t.Errorf("Expected title %q, got %v", "Test Book", info["title"])
+	}
+	if _, ok := info["has_cover"]; !ok {
+		t.Error("Response should include has_cover")
+	}
+}
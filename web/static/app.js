@@ -1,6 +1,17 @@
 const API_BASE = '/api/v1';
 let currentJobId = null;
 let statusCheckInterval = null;
+let jobEventSource = null;
+
+// Human-readable names of the conversion stages reported by the server
+const STAGE_MESSAGES = {
+    parsing: 'Parsing the FB2 file...',
+    images: 'Processing images...',
+    packaging: 'Writing book metadata...',
+    content: 'Rendering chapters...',
+    resources: 'Adding images and fonts...',
+    done: 'Finishing...'
+};
 
 // DOM elements
 const uploadSection = document.getElementById('uploadSection');
@@ -15,6 +26,8 @@ const errorMessage = document.getElementById('errorMessage');
 const downloadBtn = document.getElementById('downloadBtn');
 const convertAnotherBtn = document.getElementById('convertAnotherBtn');
 const retryBtn = document.getElementById('retryBtn');
+const previewSection = document.getElementById('previewSection');
+const previewFrame = document.getElementById('previewFrame');
 
 // File input change
 fileInput.addEventListener('change', (e) => {
@@ -89,13 +102,72 @@ async function handleFile(file) {
         const data = await response.json();
         currentJobId = data.job_id;
 
-        // Start polling for status
-        startStatusPolling();
+        // Follow progress over Server-Sent Events, falling back to polling
+        if (window.EventSource) {
+            watchJobEvents();
+        } else {
+            startStatusPolling();
+        }
     } catch (error) {
         showError(error.message);
     }
 }
 
+// Stream conversion progress and the first chapter preview
+function watchJobEvents() {
+    jobEventSource = new EventSource(`${API_BASE}/events/${currentJobId}`);
+
+    jobEventSource.addEventListener('progress', (e) => {
+        const data = JSON.parse(e.data);
+        statusMessage.textContent = STAGE_MESSAGES[data.stage] || 'Converting...';
+        updateProgress(Math.max(data.percent, 10));
+    });
+
+    jobEventSource.addEventListener('preview', (e) => {
+        showPreview(JSON.parse(e.data).html);
+    });
+
+    jobEventSource.addEventListener('status', (e) => {
+        closeJobEvents();
+        const data = JSON.parse(e.data);
+        if (data.status === 'completed') {
+            updateProgress(100);
+            setTimeout(() => showResult(), 500);
+        } else {
+            showError(data.error || 'Conversion failed');
+        }
+    });
+
+    // The stream is closed by the server after the final status; any other
+    // interruption falls back to polling
+    jobEventSource.onerror = () => {
+        if (jobEventSource) {
+            closeJobEvents();
+            startStatusPolling();
+        }
+    };
+}
+
+// Stop listening to job events
+function closeJobEvents() {
+    if (jobEventSource) {
+        jobEventSource.close();
+        jobEventSource = null;
+    }
+}
+
+// Show the first chapter in a sandboxed frame, so book markup cannot run scripts
+function showPreview(html) {
+    if (!html) {
+        return;
+    }
+    previewFrame.srcdoc = `<!DOCTYPE html><html><head><meta charset="UTF-8"><style>
+        body { font-family: Georgia, serif; line-height: 1.6; padding: 0 1em; color: #333; }
+        h1, h2, h3 { font-size: 1.2em; }
+    </style></head><body>${html}</body></html>`;
+    previewSection.style.display = 'block';
+}
+
 // Poll for conversion status
 function startStatusPolling() {
     let attempts = 0;
@@ -167,9 +239,11 @@ function showError(message) {
     if (statusCheckInterval) {
         clearInterval(statusCheckInterval);
     }
+    closeJobEvents();
     uploadSection.style.display = 'none';
     processingSection.style.display = 'none';
     resultSection.style.display = 'none';
+    previewSection.style.display = 'none';
     errorSection.style.display = 'block';
     errorMessage.textContent = message;
 }
@@ -197,7 +271,10 @@ function resetUI() {
     if (statusCheckInterval) {
         clearInterval(statusCheckInterval);
     }
+    closeJobEvents();
     fileInput.value = '';
+    previewSection.style.display = 'none';
+    previewFrame.srcdoc = '';
     uploadSection.style.display = 'block';
     processingSection.style.display = 'none';
     resultSection.style.display = 'none';